@@ -0,0 +1,47 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/MrLonely14/cf-ddns/audit"
+	"github.com/MrLonely14/cf-ddns/config"
+	"github.com/MrLonely14/cf-ddns/term"
+)
+
+func auditCommand(args []string) {
+	if len(args) < 1 || args[0] != "verify" {
+		fmt.Println("Usage: cf-ddns audit verify [-config path | -file path]")
+		os.Exit(1)
+	}
+
+	verifyCmd := flag.NewFlagSet("audit verify", flag.ExitOnError)
+	configPath := verifyCmd.String("config", "config.yaml", "Path to configuration file")
+	file := verifyCmd.String("file", "", "Path to the audit log (default: audit_log from the config)")
+	verifyCmd.Parse(args[1:])
+
+	verifyAuditLog(*configPath, *file)
+}
+
+// verifyAuditLog checks that no entry of the audit log was modified or removed
+func verifyAuditLog(configPath, path string) {
+	if path == "" {
+		cfg, err := config.Load(configPath)
+		if err != nil {
+			log.Fatalf("Failed to load configuration: %v", err)
+		}
+		if cfg.AuditLog == "" {
+			log.Fatalf("No audit_log configured in %s", configPath)
+		}
+		path = cfg.AuditLog
+	}
+
+	count, err := audit.Verify(path)
+	if err != nil {
+		fmt.Println(term.Fail(fmt.Sprintf("%s: %v (%d entries verified before it)", path, err, count)))
+		os.Exit(1)
+	}
+	fmt.Println(term.OK(fmt.Sprintf("%s: %d entries, chain intact", path, count)))
+}
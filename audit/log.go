@@ -0,0 +1,210 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/user"
+	"sync"
+	"time"
+
+	"github.com/MrLonely14/cf-ddns/cloudflare"
+)
+
+// genesisHash is the previous hash of the first entry in a log
+var genesisHash = hex.EncodeToString(make([]byte, sha256.Size))
+
+// Entry is one API write in the audit log. Each entry includes the hash of
+// the previous one, so editing or removing a line breaks the chain.
+type Entry struct {
+	Time     time.Time `json:"time"`
+	Actor    string    `json:"actor"`  // user@host running cf-ddns
+	Source   string    `json:"source"` // what issued the write, e.g. "update" or "restore"
+	Op       string    `json:"op"`     // create, update or delete
+	ZoneID   string    `json:"zone_id"`
+	Name     string    `json:"name"`
+	Type     string    `json:"type"`
+	Before   string    `json:"before,omitempty"`
+	Content  string    `json:"content"`
+	TTL      int       `json:"ttl"`
+	Proxied  bool      `json:"proxied"`
+	RecordID string    `json:"record_id"` // ID returned by the API
+	PrevHash string    `json:"prev_hash"`
+	Hash     string    `json:"hash"`
+}
+
+// computeHash hashes the entry with its Hash field cleared
+func (e Entry) computeHash() (string, error) {
+	e.Hash = ""
+	data, err := json.Marshal(e)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Log is an append-only, hash-chained audit log file
+type Log struct {
+	path     string
+	actor    string
+	mu       sync.Mutex
+	lastHash string
+}
+
+// Open opens an audit log, continuing the chain of any existing entries
+func Open(path string) (*Log, error) {
+	l := &Log{path: path, actor: actor(), lastHash: genesisHash}
+
+	entries, err := readEntries(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read audit log: %w", err)
+	}
+	if len(entries) > 0 {
+		l.lastHash = entries[len(entries)-1].Hash
+	}
+
+	return l, nil
+}
+
+// actor identifies who is issuing the writes
+func actor() string {
+	name := "unknown"
+	if u, err := user.Current(); err == nil {
+		name = u.Username
+	}
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return name + "@" + host
+}
+
+// Record appends an entry for a record change made by source
+func (l *Log) Record(source string, change *cloudflare.RecordChange) error {
+	if change.After == nil {
+		before := change.Before
+		return l.Append(Entry{
+			Source:   source,
+			Op:       "delete",
+			ZoneID:   before.ZoneID,
+			Name:     before.Name,
+			Type:     before.Type,
+			Before:   before.Content,
+			RecordID: before.ID,
+		})
+	}
+
+	after := change.After
+	entry := Entry{
+		Source:   source,
+		Op:       "update",
+		ZoneID:   after.ZoneID,
+		Name:     after.Name,
+		Type:     after.Type,
+		Content:  after.Content,
+		TTL:      after.TTL,
+		Proxied:  after.Proxied,
+		RecordID: after.ID,
+	}
+	if change.Before == nil {
+		entry.Op = "create"
+	} else {
+		entry.Before = change.Before.Content
+	}
+
+	return l.Append(entry)
+}
+
+// Append chains an entry to the log and writes it to the file
+func (l *Log) Append(entry Entry) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entry.Time = time.Now().UTC()
+	entry.Actor = l.actor
+	entry.PrevHash = l.lastHash
+	hash, err := entry.computeHash()
+	if err != nil {
+		return fmt.Errorf("failed to hash audit entry: %w", err)
+	}
+	entry.Hash = hash
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit entry: %w", err)
+	}
+
+	f, err := os.OpenFile(l.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write audit log: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		return fmt.Errorf("failed to sync audit log: %w", err)
+	}
+
+	l.lastHash = hash
+	return nil
+}
+
+// Verify checks the hash chain of an audit log and returns the number of
+// entries. The error names the first line that doesn't verify.
+func Verify(path string) (int, error) {
+	entries, err := readEntries(path)
+	if err != nil {
+		return 0, err
+	}
+
+	prev := genesisHash
+	for i, entry := range entries {
+		if entry.PrevHash != prev {
+			return i, fmt.Errorf("line %d: chain broken (an entry before it was modified or removed)", i+1)
+		}
+		hash, err := entry.computeHash()
+		if err != nil {
+			return i, fmt.Errorf("line %d: %w", i+1, err)
+		}
+		if hash != entry.Hash {
+			return i, fmt.Errorf("line %d: content does not match its hash", i+1)
+		}
+		prev = entry.Hash
+	}
+
+	return len(entries), nil
+}
+
+// readEntries parses every line of the log file
+func readEntries(path string) ([]Entry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []Entry
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("line %d: failed to parse audit entry: %w", line, err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read audit log: %w", err)
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,67 @@
+package backup
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/MrLonely14/cf-ddns/cloudflare"
+)
+
+// Snapshot is a point-in-time copy of the managed DNS records
+type Snapshot struct {
+	CreatedAt time.Time `json:"created_at"`
+	Records   []Record  `json:"records"`
+}
+
+// Record holds the recorded attributes of a single DNS record
+type Record struct {
+	ZoneID  string `json:"zone_id"`
+	Name    string `json:"name"`
+	Type    string `json:"type"`
+	Content string `json:"content"`
+	TTL     int    `json:"ttl"`
+	Proxied bool   `json:"proxied"`
+}
+
+// Load reads a snapshot from a file
+func Load(path string) (*Snapshot, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot file: %w", err)
+	}
+
+	var snap Snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return nil, fmt.Errorf("failed to parse snapshot file: %w", err)
+	}
+
+	return &snap, nil
+}
+
+// Save writes a snapshot to a file
+func Save(path string, snap *Snapshot) error {
+	data, err := json.MarshalIndent(snap, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode snapshot: %w", err)
+	}
+
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write snapshot file: %w", err)
+	}
+
+	return nil
+}
+
+// Find returns the recorded entry for a record, or nil if it isn't in the snapshot
+func (s *Snapshot) Find(zoneID, name, recordType string) *Record {
+	name = cloudflare.NormalizeName(name)
+	for i := range s.Records {
+		r := &s.Records[i]
+		if r.ZoneID == zoneID && cloudflare.NormalizeName(r.Name) == name && r.Type == recordType {
+			return r
+		}
+	}
+	return nil
+}
@@ -2,14 +2,26 @@ package cloudflare
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
+	"strings"
+	"sync"
 
 	"github.com/cloudflare/cloudflare-go"
+	"golang.org/x/net/idna"
 )
 
-// Client wraps the Cloudflare API client
+// Client wraps the Cloudflare API client. Zones mapped to their own token or
+// to an account are served by a separate API instance, one per distinct token.
 type Client struct {
-	api *cloudflare.API
+	api         *cloudflare.API            // default token; nil if every zone is mapped
+	accountAPIs map[string]*cloudflare.API // account name -> API
+	apiMu       sync.RWMutex
+	zoneAPIs    map[string]*cloudflare.API // zone ID -> API for zones with their own token
+	zoneMu      sync.Mutex
+	zoneIDs     map[string]map[string]string // account ("" for the default token) -> normalized zone name -> ID, listed on first use
+	retry       *retryTransport              // shared by all API instances
 }
 
 // DNSRecordInfo holds information about a DNS record
@@ -21,31 +33,268 @@ type DNSRecordInfo struct {
 	Content string
 	TTL     int
 	Proxied bool
+	Comment string
 }
 
-// NewClient creates a new Cloudflare client
-func NewClient(apiToken string) (*Client, error) {
-	if apiToken == "" {
+// ZoneInfo holds metadata about a zone
+type ZoneInfo struct {
+	ID     string
+	Name   string
+	Plan   string
+	Status string
+}
+
+// RecordChange describes a DNS record before and after a write
+type RecordChange struct {
+	Before *DNSRecordInfo // nil when the record was created
+	After  *DNSRecordInfo // nil when the record was deleted
+}
+
+// String formats the change as a structured "diff" event
+func (rc *RecordChange) String() string {
+	before, after := rc.Before, rc.After
+	op := "update"
+	switch {
+	case before == nil:
+		before = &DNSRecordInfo{Name: after.Name, Type: after.Type}
+		op = "create"
+	case after == nil:
+		after = &DNSRecordInfo{Name: before.Name, Type: before.Type}
+		op = "delete"
+	}
+
+	return fmt.Sprintf("diff op=%s name=%s type=%s content=%q->%q ttl=%d->%d proxied=%t->%t comment=%q->%q",
+		op, DisplayName(after.Name), after.Type,
+		before.Content, after.Content,
+		before.TTL, after.TTL,
+		before.Proxied, after.Proxied,
+		before.Comment, after.Comment,
+	)
+}
+
+// newRecordInfo converts an API record into a DNSRecordInfo
+func newRecordInfo(zoneID string, record cloudflare.DNSRecord) *DNSRecordInfo {
+	info := &DNSRecordInfo{
+		ID:      record.ID,
+		ZoneID:  zoneID,
+		Name:    record.Name,
+		Type:    record.Type,
+		Content: record.Content,
+		TTL:     record.TTL,
+		Comment: record.Comment,
+	}
+	if record.Proxied != nil {
+		info.Proxied = *record.Proxied
+	}
+	return info
+}
+
+// NewClient creates a new Cloudflare client. zoneTokens maps zone IDs to the
+// token used for them; other zones use apiToken. accountTokens maps account
+// names to their tokens, which serve the zones looked up with AccountZoneID.
+func NewClient(apiToken string, zoneTokens, accountTokens map[string]string) (*Client, error) {
+	if apiToken == "" && len(zoneTokens) == 0 && len(accountTokens) == 0 {
 		return nil, fmt.Errorf("API token is required")
 	}
 
-	api, err := cloudflare.NewWithAPIToken(apiToken)
+	c := &Client{
+		accountAPIs: make(map[string]*cloudflare.API),
+		zoneAPIs:    make(map[string]*cloudflare.API),
+		zoneIDs:     make(map[string]map[string]string),
+		retry:       newRetryTransport(),
+	}
+	byToken := make(map[string]*cloudflare.API)
+
+	// Requests are retried by the client's transport, which honors
+	// Retry-After, instead of by the library
+	newAPI := func(token string) (*cloudflare.API, error) {
+		if api, ok := byToken[token]; ok {
+			return api, nil
+		}
+		api, err := cloudflare.NewWithAPIToken(token,
+			cloudflare.HTTPClient(&http.Client{Transport: c.retry}),
+			cloudflare.UsingRetryPolicy(0, 0, 0),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create Cloudflare client: %w", err)
+		}
+		byToken[token] = api
+		return api, nil
+	}
+
+	if apiToken != "" {
+		api, err := newAPI(apiToken)
+		if err != nil {
+			return nil, err
+		}
+		c.api = api
+	}
+
+	for zoneID, token := range zoneTokens {
+		api, err := newAPI(token)
+		if err != nil {
+			return nil, err
+		}
+		c.zoneAPIs[zoneID] = api
+	}
+
+	for name, token := range accountTokens {
+		api, err := newAPI(token)
+		if err != nil {
+			return nil, err
+		}
+		c.accountAPIs[name] = api
+	}
+
+	return c, nil
+}
+
+// SetRetryPolicy changes how failed API requests are retried
+func (c *Client) SetRetryPolicy(policy RetryPolicy) {
+	c.retry.setPolicy(policy)
+}
+
+// apiFor returns the API instance holding the token for a zone
+func (c *Client) apiFor(zoneID string) (*cloudflare.API, error) {
+	c.apiMu.RLock()
+	api, ok := c.zoneAPIs[zoneID]
+	c.apiMu.RUnlock()
+	if ok {
+		return api, nil
+	}
+	if c.api == nil {
+		return nil, fmt.Errorf("no API token configured for zone %s", zoneID)
+	}
+	return c.api, nil
+}
+
+// nameProfile converts IDN hostnames while still allowing the wildcard and
+// underscore labels that DNS records commonly use
+var nameProfile = idna.New(idna.MapForLookup(), idna.StrictDomainName(false))
+
+// NormalizeName converts a record name to the canonical form used for lookups
+// and comparisons: lowercase, no trailing dot, and punycode for IDN hostnames
+func NormalizeName(name string) string {
+	name = strings.TrimSuffix(strings.TrimSpace(name), ".")
+	if ascii, err := nameProfile.ToASCII(name); err == nil {
+		name = ascii
+	}
+	return strings.ToLower(name)
+}
+
+// DisplayName converts a record name to its Unicode form for logs and status
+// output, so IDN hostnames are shown the way users wrote them
+func DisplayName(name string) string {
+	if unicode, err := nameProfile.ToUnicode(NormalizeName(name)); err == nil {
+		return unicode
+	}
+	return name
+}
+
+// GetZone fetches metadata for a zone by ID
+func (c *Client) GetZone(ctx context.Context, zoneID string) (*ZoneInfo, error) {
+	api, err := c.apiFor(zoneID)
+	if err != nil {
+		return nil, err
+	}
+
+	zone, err := api.ZoneDetails(ctx, zoneID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create Cloudflare client: %w", err)
+		return nil, fmt.Errorf("failed to get zone %s: %w", zoneID, err)
 	}
 
-	return &Client{
-		api: api,
+	return &ZoneInfo{
+		ID:     zone.ID,
+		Name:   zone.Name,
+		Plan:   zone.Plan.Name,
+		Status: zone.Status,
 	}, nil
 }
 
-// GetDNSRecord finds a DNS record by zone ID, name, and type
-func (c *Client) GetDNSRecord(ctx context.Context, zoneID, name, recordType string) (*DNSRecordInfo, error) {
+// ErrZoneNotFound is returned by ZoneID for zones the token can't see
+var ErrZoneNotFound = errors.New("zone not found")
+
+// ZoneID resolves a zone name to its ID with the default token
+func (c *Client) ZoneID(ctx context.Context, name string) (string, error) {
+	if c.api == nil {
+		return "", errNoDefaultToken
+	}
+	return c.zoneID(ctx, "", c.api, name)
+}
+
+// AccountZoneID resolves a zone name to its ID with the token of an account,
+// which then serves the zone
+func (c *Client) AccountZoneID(ctx context.Context, account, name string) (string, error) {
+	api, ok := c.accountAPIs[account]
+	if !ok {
+		return "", fmt.Errorf("no API token configured for account %s", account)
+	}
+	id, err := c.zoneID(ctx, account, api, name)
+	if err != nil {
+		return "", fmt.Errorf("account %s: %w", account, err)
+	}
+	c.apiMu.Lock()
+	c.zoneAPIs[id] = api
+	c.apiMu.Unlock()
+	return id, nil
+}
+
+// zoneID looks up a zone name among the zones visible to an account's
+// token, which are listed once and cached for the lifetime of the client
+func (c *Client) zoneID(ctx context.Context, account string, api *cloudflare.API, name string) (string, error) {
+	c.zoneMu.Lock()
+	defer c.zoneMu.Unlock()
+
+	ids, ok := c.zoneIDs[account]
+	if !ok {
+		zones, err := listZones(ctx, api)
+		if err != nil {
+			return "", err
+		}
+		ids = make(map[string]string, len(zones))
+		for _, zone := range zones {
+			ids[NormalizeName(zone.Name)] = zone.ID
+		}
+		c.zoneIDs[account] = ids
+	}
+
+	id, ok := ids[NormalizeName(name)]
+	if !ok {
+		return "", fmt.Errorf("%w: %s is not visible to the API token (%d zones are; it needs Zone:Read on this zone)", ErrZoneNotFound, DisplayName(name), len(ids))
+	}
+	return id, nil
+}
+
+// RecordKind tells apart records that share a name and type but hold
+// different data, such as an SPF policy next to a domain verification token.
+// It is the lowercased version tag of TXT content ("v=spf1", "v=dmarc1"), and
+// empty for TXT content without one and for other types.
+func RecordKind(recordType, content string) string {
+	if recordType != "TXT" {
+		return ""
+	}
+	content = strings.TrimLeft(content, `"`)
+	if !strings.HasPrefix(strings.ToLower(content), "v=") {
+		return ""
+	}
+	tag, _, _ := strings.Cut(content, " ")
+	return strings.ToLower(strings.TrimRight(tag, `"`))
+}
+
+// GetDNSRecord finds a DNS record by zone ID, name, type and kind (see
+// RecordKind)
+func (c *Client) GetDNSRecord(ctx context.Context, zoneID, name, recordType, kind string) (*DNSRecordInfo, error) {
 	// Create resource container for the zone
 	rc := cloudflare.ZoneIdentifier(zoneID)
+	name = NormalizeName(name)
+
+	api, err := c.apiFor(zoneID)
+	if err != nil {
+		return nil, err
+	}
 
 	// List DNS records with filters
-	records, _, err := c.api.ListDNSRecords(ctx, rc, cloudflare.ListDNSRecordsParams{
+	records, _, err := api.ListDNSRecords(ctx, rc, cloudflare.ListDNSRecordsParams{
 		Name: name,
 		Type: recordType,
 	})
@@ -53,79 +302,129 @@ func (c *Client) GetDNSRecord(ctx context.Context, zoneID, name, recordType stri
 		return nil, fmt.Errorf("failed to list DNS records: %w", err)
 	}
 
-	// Check if we found any records
-	if len(records) == 0 {
-		return nil, fmt.Errorf("DNS record not found: %s (%s)", name, recordType)
+	// Return the first record whose normalized name and kind match
+	for _, record := range records {
+		if NormalizeName(record.Name) == name && RecordKind(record.Type, record.Content) == kind {
+			return newRecordInfo(zoneID, record), nil
+		}
 	}
 
-	// Return the first matching record
-	record := records[0]
-	return &DNSRecordInfo{
-		ID:      record.ID,
-		ZoneID:  zoneID,
-		Name:    record.Name,
-		Type:    record.Type,
-		Content: record.Content,
-		TTL:     record.TTL,
-		Proxied: *record.Proxied,
-	}, nil
+	return nil, fmt.Errorf("%w: %s (%s)", ErrRecordNotFound, name, recordType)
+}
+
+// ListDNSRecords returns every DNS record in a zone, following pagination
+func (c *Client) ListDNSRecords(ctx context.Context, zoneID string) ([]*DNSRecordInfo, error) {
+	rc := cloudflare.ZoneIdentifier(zoneID)
+
+	api, err := c.apiFor(zoneID)
+	if err != nil {
+		return nil, err
+	}
+
+	records, _, err := api.ListDNSRecords(ctx, rc, cloudflare.ListDNSRecordsParams{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list DNS records: %w", err)
+	}
+
+	infos := make([]*DNSRecordInfo, len(records))
+	for i, record := range records {
+		infos[i] = newRecordInfo(zoneID, record)
+	}
+	return infos, nil
 }
 
-// UpdateDNSRecord updates an existing DNS record
-func (c *Client) UpdateDNSRecord(ctx context.Context, recordID, zoneID, name, recordType, content string, ttl int, proxied bool) error {
+// UpdateDNSRecord updates an existing DNS record. An empty comment keeps the
+// record's current comment.
+func (c *Client) UpdateDNSRecord(ctx context.Context, recordID, zoneID, name, recordType, content string, ttl int, proxied bool, comment string) (*DNSRecordInfo, error) {
 	// Create resource container for the zone
 	rc := cloudflare.ZoneIdentifier(zoneID)
 
-	_, err := c.api.UpdateDNSRecord(ctx, rc, cloudflare.UpdateDNSRecordParams{
+	api, err := c.apiFor(zoneID)
+	if err != nil {
+		return nil, err
+	}
+
+	params := cloudflare.UpdateDNSRecordParams{
 		ID:      recordID,
 		Content: content,
 		TTL:     ttl,
 		Proxied: &proxied,
-	})
+	}
+	if comment != "" {
+		params.Comment = &comment
+	}
+	record, err := api.UpdateDNSRecord(ctx, rc, params)
 	if err != nil {
-		return fmt.Errorf("failed to update DNS record: %w", err)
+		return nil, fmt.Errorf("failed to update DNS record: %w", err)
 	}
 
-	return nil
+	return newRecordInfo(zoneID, record), nil
 }
 
 // CreateDNSRecord creates a new DNS record if it doesn't exist
-func (c *Client) CreateDNSRecord(ctx context.Context, zoneID, name, recordType, content string, ttl int, proxied bool) (*DNSRecordInfo, error) {
+func (c *Client) CreateDNSRecord(ctx context.Context, zoneID, name, recordType, content string, ttl int, proxied bool, comment string) (*DNSRecordInfo, error) {
 	// Create resource container for the zone
 	rc := cloudflare.ZoneIdentifier(zoneID)
 
-	record, err := c.api.CreateDNSRecord(ctx, rc, cloudflare.CreateDNSRecordParams{
-		Name:    name,
+	api, err := c.apiFor(zoneID)
+	if err != nil {
+		return nil, err
+	}
+
+	record, err := api.CreateDNSRecord(ctx, rc, cloudflare.CreateDNSRecordParams{
+		Name:    NormalizeName(name),
 		Type:    recordType,
 		Content: content,
 		TTL:     ttl,
 		Proxied: &proxied,
+		Comment: comment,
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to create DNS record: %w", err)
 	}
 
-	return &DNSRecordInfo{
-		ID:      record.ID,
-		ZoneID:  zoneID,
-		Name:    record.Name,
-		Type:    record.Type,
-		Content: record.Content,
-		TTL:     record.TTL,
-		Proxied: *record.Proxied,
-	}, nil
+	return newRecordInfo(zoneID, record), nil
+}
+
+// DeleteDNSRecord removes a DNS record. The returned change holds the record
+// as it was before.
+func (c *Client) DeleteDNSRecord(ctx context.Context, record *DNSRecordInfo) (*RecordChange, error) {
+	api, err := c.apiFor(record.ZoneID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := api.DeleteDNSRecord(ctx, cloudflare.ZoneIdentifier(record.ZoneID), record.ID); err != nil {
+		return nil, fmt.Errorf("failed to delete DNS record: %w", err)
+	}
+	return &RecordChange{Before: record}, nil
 }
 
-// UpsertDNSRecord updates a DNS record if it exists, or creates it if it doesn't
-func (c *Client) UpsertDNSRecord(ctx context.Context, zoneID, name, recordType, content string, ttl int, proxied bool) error {
-	// Try to get existing record
-	existing, err := c.GetDNSRecord(ctx, zoneID, name, recordType)
+// UpsertDNSRecord updates a DNS record if it exists, or creates it if it doesn't.
+// Of several records with the name and type, the one of the same kind as the
+// content is updated. The returned change holds the record attributes before
+// and after the write.
+func (c *Client) UpsertDNSRecord(ctx context.Context, zoneID, name, recordType, content string, ttl int, proxied bool, comment string) (*RecordChange, error) {
+	// Try to get existing record. Only a lookup that succeeded without a match
+	// means the record is missing; auth and other errors must not lead to a create.
+	existing, err := c.GetDNSRecord(ctx, zoneID, name, recordType, RecordKind(recordType, content))
 	if err != nil {
+		if !errors.Is(err, ErrRecordNotFound) {
+			return nil, err
+		}
+
 		// Record doesn't exist, create it
-		_, err := c.CreateDNSRecord(ctx, zoneID, name, recordType, content, ttl, proxied)
-		return err
+		created, err := c.CreateDNSRecord(ctx, zoneID, name, recordType, content, ttl, proxied, comment)
+		if err != nil {
+			return nil, err
+		}
+		return &RecordChange{After: created}, nil
 	}
 
 	// Record exists, update it
-	return c.UpdateDNSRecord(ctx, existing.ID, zoneID, name, recordType, content, ttl, proxied)
+	updated, err := c.UpdateDNSRecord(ctx, existing.ID, zoneID, name, recordType, content, ttl, proxied, comment)
+	if err != nil {
+		return nil, err
+	}
+	return &RecordChange{Before: existing, After: updated}, nil
 }
@@ -0,0 +1,42 @@
+package cloudflare
+
+import (
+	"errors"
+
+	"github.com/cloudflare/cloudflare-go"
+)
+
+// ErrRecordNotFound is returned when a lookup succeeded but no record matched
+var ErrRecordNotFound = errors.New("DNS record not found")
+
+// Error categories of failed API calls
+const (
+	CategoryAuth      = "auth"       // 401/403: token invalid or lacking permission
+	CategoryRateLimit = "rate_limit" // 429
+	CategoryNotFound  = "not_found"
+	CategoryAPI       = "api" // any other error response
+)
+
+// ErrorCategory classifies an API error, returning "" for errors that did not
+// come from a Cloudflare API response, such as network failures
+func ErrorCategory(err error) string {
+	if errors.Is(err, ErrRateLimited) {
+		return CategoryRateLimit
+	}
+
+	var apiErr *cloudflare.Error
+	if !errors.As(err, &apiErr) {
+		return ""
+	}
+
+	switch apiErr.Type {
+	case cloudflare.ErrorTypeAuthentication, cloudflare.ErrorTypeAuthorization:
+		return CategoryAuth
+	case cloudflare.ErrorTypeRateLimit:
+		return CategoryRateLimit
+	case cloudflare.ErrorTypeNotFound:
+		return CategoryNotFound
+	default:
+		return CategoryAPI
+	}
+}
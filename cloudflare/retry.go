@@ -0,0 +1,167 @@
+package cloudflare
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RetryPolicy controls how failed API requests are retried
+type RetryPolicy struct {
+	MaxAttempts  int           // attempts per request including the first; 1 disables retries
+	InitialDelay time.Duration // delay before the first retry, doubled for each further one
+	MaxDelay     time.Duration // longest delay, and the longest Retry-After that is waited for
+}
+
+// DefaultRetryPolicy is used unless the configuration sets another
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:  4,
+	InitialDelay: time.Second,
+	MaxDelay:     30 * time.Second,
+}
+
+// ErrRateLimited is returned when requests were still rate limited after the
+// last attempt, or Cloudflare asked to wait longer than the policy allows
+var ErrRateLimited = errors.New("rate limited by the Cloudflare API")
+
+// retryTransport retries requests that failed with a network error, a 429,
+// or a 5xx response. POST requests create records, so they are only retried
+// when the response shows they weren't processed.
+type retryTransport struct {
+	base   http.RoundTripper
+	mu     sync.RWMutex
+	policy RetryPolicy
+}
+
+// newRetryTransport creates a transport with the default policy
+func newRetryTransport() *retryTransport {
+	return &retryTransport{base: http.DefaultTransport, policy: DefaultRetryPolicy}
+}
+
+// setPolicy replaces the retry policy for later requests
+func (t *retryTransport) setPolicy(policy RetryPolicy) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.policy = policy
+}
+
+// RoundTrip sends a request, retrying it with exponential backoff and jitter
+// or after the delay a Retry-After header asks for
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.mu.RLock()
+	policy := t.policy
+	t.mu.RUnlock()
+
+	for attempt := 1; ; attempt++ {
+		resp, err := t.base.RoundTrip(req)
+		if !retryable(req, resp, err) {
+			return resp, err
+		}
+
+		rateLimited := resp != nil && resp.StatusCode == http.StatusTooManyRequests
+		delay := backoff(policy, attempt)
+		if after, ok := retryAfter(resp); ok {
+			if after > policy.MaxDelay {
+				discard(resp)
+				return nil, fmt.Errorf("%w: asked to wait %s", ErrRateLimited, after)
+			}
+			delay = after
+		}
+		if attempt >= policy.MaxAttempts || (req.Body != nil && req.GetBody == nil) {
+			if rateLimited {
+				discard(resp)
+				return nil, fmt.Errorf("%w after %d attempt(s)", ErrRateLimited, attempt)
+			}
+			return resp, err
+		}
+
+		reason := "network error"
+		if resp != nil {
+			reason = fmt.Sprintf("HTTP %d", resp.StatusCode)
+			discard(resp)
+		}
+		log.Printf("Warning: Cloudflare API %s %s failed (%s), retrying in %s (attempt %d of %d)",
+			req.Method, req.URL.Path, reason, delay.Round(time.Millisecond), attempt+1, policy.MaxAttempts)
+
+		select {
+		case <-time.After(delay):
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+
+		if req.Body != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+	}
+}
+
+// retryable reports whether a failed request may be sent again
+func retryable(req *http.Request, resp *http.Response, err error) bool {
+	if req.Context().Err() != nil {
+		return false
+	}
+	if err != nil {
+		// A POST may have been processed before the connection failed
+		return req.Method != http.MethodPost
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests, resp.StatusCode == http.StatusServiceUnavailable:
+		return true
+	case resp.StatusCode >= 500:
+		return req.Method != http.MethodPost
+	}
+	return false
+}
+
+// backoff returns the delay before the retry following an attempt: the
+// initial delay doubled per attempt, capped, with up to half of it random
+func backoff(policy RetryPolicy, attempt int) time.Duration {
+	delay := policy.InitialDelay
+	for i := 1; i < attempt && delay < policy.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > policy.MaxDelay {
+		delay = policy.MaxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+// retryAfter parses the Retry-After header of a response, given in seconds
+// or as an HTTP date
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+	if resp == nil {
+		return 0, false
+	}
+	value := resp.Header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return max(time.Until(at), 0), true
+	}
+	return 0, false
+}
+
+// discard drains and closes a response body so its connection can be reused
+func discard(resp *http.Response) {
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+	resp.Body.Close()
+}
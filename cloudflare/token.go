@@ -0,0 +1,215 @@
+package cloudflare
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/cloudflare/cloudflare-go"
+)
+
+// TokenInfo describes the API token the client authenticates with
+type TokenInfo struct {
+	ID        string
+	Status    string
+	ExpiresOn time.Time
+}
+
+// TokenPolicy is a single policy attached to an API token
+type TokenPolicy struct {
+	Effect      string
+	Permissions []string
+	Resources   []string
+}
+
+// errNoDefaultToken is returned by token-level calls on a client without a default token
+var errNoDefaultToken = fmt.Errorf("no default API token configured")
+
+// VerifyToken checks that the default token is valid and returns its ID and status
+func (c *Client) VerifyToken(ctx context.Context) (*TokenInfo, error) {
+	if c.api == nil {
+		return nil, errNoDefaultToken
+	}
+
+	body, err := c.api.VerifyAPIToken(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify API token: %w", err)
+	}
+
+	return &TokenInfo{
+		ID:        body.ID,
+		Status:    body.Status,
+		ExpiresOn: body.ExpiresOn,
+	}, nil
+}
+
+// TokenPolicies returns the policies of a token. This only succeeds if the
+// token itself is allowed to read API tokens.
+func (c *Client) TokenPolicies(ctx context.Context, tokenID string) ([]TokenPolicy, error) {
+	if c.api == nil {
+		return nil, errNoDefaultToken
+	}
+
+	token, err := c.api.GetAPIToken(ctx, tokenID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read token details: %w", err)
+	}
+
+	policies := make([]TokenPolicy, 0, len(token.Policies))
+	for _, p := range token.Policies {
+		policy := TokenPolicy{Effect: p.Effect}
+		for _, group := range p.PermissionGroups {
+			policy.Permissions = append(policy.Permissions, group.Name)
+		}
+		for resource := range p.Resources {
+			policy.Resources = append(policy.Resources, resource)
+		}
+		sort.Strings(policy.Resources)
+		policies = append(policies, policy)
+	}
+
+	return policies, nil
+}
+
+// ListZones returns every zone the default token can see
+func (c *Client) ListZones(ctx context.Context) ([]ZoneInfo, error) {
+	if c.api == nil {
+		return nil, errNoDefaultToken
+	}
+	return listZones(ctx, c.api)
+}
+
+// listZones returns every zone a token can see
+func listZones(ctx context.Context, api *cloudflare.API) ([]ZoneInfo, error) {
+	zones, err := api.ListZones(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list zones: %w", err)
+	}
+
+	infos := make([]ZoneInfo, len(zones))
+	for i, zone := range zones {
+		infos[i] = ZoneInfo{
+			ID:     zone.ID,
+			Name:   zone.Name,
+			Plan:   zone.Plan.Name,
+			Status: zone.Status,
+		}
+	}
+	return infos, nil
+}
+
+// CheckDNSRead verifies that DNS records of a zone can be read, with the
+// smallest possible request
+func (c *Client) CheckDNSRead(ctx context.Context, zoneID string) error {
+	api, err := c.apiFor(zoneID)
+	if err != nil {
+		return err
+	}
+
+	_, _, err = api.ListDNSRecords(ctx, cloudflare.ZoneIdentifier(zoneID), cloudflare.ListDNSRecordsParams{
+		ResultInfo: cloudflare.ResultInfo{PerPage: 1},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to read DNS records: %w", err)
+	}
+	return nil
+}
+
+// probeRecordID is the ID of a record that doesn't exist, for checking write
+// access without writing
+const probeRecordID = "00000000000000000000000000000000"
+
+// CheckDNSEdit verifies that DNS records of a zone can be changed. It
+// updates a record that doesn't exist: Cloudflare answers "not found" if the
+// token may edit records, and refuses the request otherwise.
+func (c *Client) CheckDNSEdit(ctx context.Context, zoneID string) error {
+	api, err := c.apiFor(zoneID)
+	if err != nil {
+		return err
+	}
+
+	_, err = api.UpdateDNSRecord(ctx, cloudflare.ZoneIdentifier(zoneID), cloudflare.UpdateDNSRecordParams{ID: probeRecordID})
+	if err == nil || ErrorCategory(err) == CategoryNotFound {
+		return nil
+	}
+	return fmt.Errorf("failed to edit DNS records: %w", err)
+}
+
+// ErrAccessDenied is returned by CheckAccess when a token is invalid or
+// lacks a permission the daemon needs
+var ErrAccessDenied = errors.New("API token access denied")
+
+// CheckAccess verifies every token used for the zones and checks that it can
+// read each zone and its DNS records, and with edit also change them. All
+// problems are returned; those caused by missing permissions wrap
+// ErrAccessDenied, while other failures, such as network errors, don't.
+func (c *Client) CheckAccess(ctx context.Context, zoneIDs []string, edit bool) error {
+	var problems []error
+	tokens := make(map[*cloudflare.API]error)
+	for _, zoneID := range zoneIDs {
+		api, err := c.apiFor(zoneID)
+		if err != nil {
+			problems = append(problems, err)
+			continue
+		}
+
+		// Each token is verified once; its zones aren't checked if it fails
+		tokenErr, verified := tokens[api]
+		if !verified {
+			tokenErr = verifyToken(ctx, api)
+			tokens[api] = tokenErr
+			if tokenErr != nil {
+				problems = append(problems, fmt.Errorf("token used for zone %s: %w", zoneID, tokenErr))
+			}
+		}
+		if tokenErr != nil {
+			continue
+		}
+
+		zone, err := c.GetZone(ctx, zoneID)
+		if err != nil {
+			if category := ErrorCategory(err); category == CategoryAuth || category == CategoryNotFound {
+				err = fmt.Errorf("%w: token can't read zone %s (it needs Zone:Read or DNS:Edit on the zone, or the zone doesn't exist)", ErrAccessDenied, zoneID)
+			}
+			problems = append(problems, err)
+			continue
+		}
+		name := fmt.Sprintf("%s (%s)", zone.Name, zone.ID)
+
+		if err := c.CheckDNSRead(ctx, zoneID); err != nil {
+			problems = append(problems, accessError(err, "DNS:Read", name))
+			continue
+		}
+		if edit {
+			if err := c.CheckDNSEdit(ctx, zoneID); err != nil {
+				problems = append(problems, accessError(err, "DNS:Edit", name))
+			}
+		}
+	}
+	return errors.Join(problems...)
+}
+
+// verifyToken checks that a token is valid and active
+func verifyToken(ctx context.Context, api *cloudflare.API) error {
+	body, err := api.VerifyAPIToken(ctx)
+	if err != nil {
+		if ErrorCategory(err) == CategoryAuth {
+			return fmt.Errorf("%w: token is invalid: %w", ErrAccessDenied, err)
+		}
+		return fmt.Errorf("failed to verify API token: %w", err)
+	}
+	if body.Status != "active" {
+		return fmt.Errorf("%w: token is %s", ErrAccessDenied, body.Status)
+	}
+	return nil
+}
+
+// accessError describes a failed permission check on a zone
+func accessError(err error, permission, zone string) error {
+	if ErrorCategory(err) == CategoryAuth {
+		return fmt.Errorf("%w: token lacks %s on zone %s", ErrAccessDenied, permission, zone)
+	}
+	return fmt.Errorf("failed to check %s on zone %s: %w", permission, zone, err)
+}
@@ -0,0 +1,68 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/MrLonely14/cf-ddns/cloudflare"
+)
+
+// defaultCanaryTimeout bounds the verification of a canary record
+const defaultCanaryTimeout = 30 * time.Second
+
+// CanaryConfig names a record that is updated and verified before the others
+// receive a new address
+type CanaryConfig struct {
+	Record    string `yaml:"record"`     // name of a configured record
+	VerifyDNS bool   `yaml:"verify_dns"` // wait until the zone's nameservers return the new address
+	HTTPProbe string `yaml:"http_probe"` // URL requested from the new address; must answer with a status below 400
+	Timeout   string `yaml:"timeout"`    // how long verification may take (default 30s)
+}
+
+// GetTimeout returns how long verification may take
+func (c CanaryConfig) GetTimeout() time.Duration {
+	if d, err := time.ParseDuration(c.Timeout); err == nil && d > 0 {
+		return d
+	}
+	return defaultCanaryTimeout
+}
+
+// CanaryRecord returns the configured canary record, if any
+func (c *Config) CanaryRecord() (DNSRecord, bool) {
+	if c.Canary == nil {
+		return DNSRecord{}, false
+	}
+	for _, record := range c.Records {
+		if cloudflare.NormalizeName(record.Name) == cloudflare.NormalizeName(c.Canary.Record) {
+			return record, true
+		}
+	}
+	return DNSRecord{}, false
+}
+
+// validateCanary checks that the canary names a detected address record
+func (c *Config) validateCanary() error {
+	if c.Canary.Record == "" {
+		return fmt.Errorf("canary.record is required")
+	}
+	record, ok := c.CanaryRecord()
+	if !ok {
+		return fmt.Errorf("canary.record %s is not a configured record", c.Canary.Record)
+	}
+	if record.Push || record.Template() != "" {
+		return fmt.Errorf("canary.record %s must be an A or AAAA record with a detected address", c.Canary.Record)
+	}
+	if c.Canary.HTTPProbe != "" {
+		u, err := url.Parse(c.Canary.HTTPProbe)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid canary.http_probe %s (must be an http or https URL)", c.Canary.HTTPProbe)
+		}
+	}
+	if c.Canary.Timeout != "" {
+		if d, err := time.ParseDuration(c.Canary.Timeout); err != nil || d <= 0 {
+			return fmt.Errorf("invalid canary.timeout %s", c.Canary.Timeout)
+		}
+	}
+	return nil
+}
@@ -2,56 +2,647 @@ package config
 
 import (
 	"fmt"
+	"net/netip"
+	"net/url"
 	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
 	"time"
 
+	"github.com/MrLonely14/cf-ddns/cloudflare"
+	"github.com/MrLonely14/cf-ddns/encryption"
+	"github.com/MrLonely14/cf-ddns/i18n"
+	"golang.org/x/net/idna"
 	"gopkg.in/yaml.v3"
 )
 
+// Cloudflare allows 1200 API requests per 5 minutes per user
+const (
+	apiRateLimitRequests = 1200
+	apiRateLimitWindow   = 5 * time.Minute
+	// requestsPerUpdate is the worst case per record type: one lookup and one write
+	requestsPerUpdate = 2
+)
+
+// Startup update policies
+const (
+	StartupUpdateAlways    = "always"     // write every record at startup
+	StartupUpdateIfChanged = "if-changed" // write only records that differ from Cloudflare
+	StartupUpdateNever     = "never"      // wait for the first interval or trigger
+)
+
+// Record settings that can be left as they are in Cloudflare
+const (
+	PreserveTTL     = "ttl"     // keep the TTL set in the dashboard
+	PreserveProxied = "proxied" // keep the proxy setting of the dashboard
+)
+
+// AutoTTL is Cloudflare's automatic TTL, which proxied records always use
+const AutoTTL = 1
+
+// Modes of operation
+const (
+	ModeUpdate  = "update"  // keep records in sync with the detected addresses
+	ModeObserve = "observe" // detect and report drift, but never write to Cloudflare
+)
+
+// ProviderCloudflare is the default DNS provider
+const ProviderCloudflare = "cloudflare"
+
 // Config represents the application configuration
 type Config struct {
-	Cloudflare    CloudflareConfig `yaml:"cloudflare"`
-	CheckInterval string           `yaml:"check_interval"`
-	Records       []DNSRecord      `yaml:"records"`
+	Provider        string            `yaml:"provider"`         // DNS provider the records are kept in (default cloudflare)
+	ProviderOptions map[string]string `yaml:"provider_options"` // settings of providers other than cloudflare
+
+	Cloudflare    CloudflareConfig  `yaml:"cloudflare"`
+	CheckInterval string            `yaml:"check_interval"`
+	CycleBudget   string            `yaml:"cycle_budget"`   // warn when an update cycle takes longer
+	StartupUpdate string            `yaml:"startup_update"` // always, if-changed (default) or never
+	Mode          string            `yaml:"mode"`           // update (default) or observe
+	DryRun        bool              `yaml:"dry_run"`        // log intended writes instead of making them
+	FreezeFile    string            `yaml:"freeze_file"`    // writes are paused while this file exists
+	StrictStartup bool              `yaml:"strict_startup"` // exit if any zone is inaccessible at startup
+	AuditLog      string            `yaml:"audit_log"`      // hash-chained log of every API write; empty disables it
+	StateDir      string            `yaml:"state_dir"`      // where state, history and statistics are kept (default per platform)
+	Records       []DNSRecord       `yaml:"records"`
+	Triggers      TriggersConfig    `yaml:"triggers"`
+	IPDetection   IPDetectionConfig `yaml:"ip_detection"`
+	Server        ServerConfig      `yaml:"server"`
+	DynDNS        DynDNSConfig      `yaml:"dyndns"`
+	ConfigSource  string            `yaml:"config_source"` // git or url pulls options from config_git or config_url; empty uses this file only
+	ConfigGit     ConfigGitConfig   `yaml:"config_git"`
+	ConfigURL     string            `yaml:"config_url"`          // HTTPS location of a configuration file; implies config_source url
+	ConfigURLPoll string            `yaml:"config_url_interval"` // how often to poll config_url (default 5m)
+
+	ReconcileInterval  string              `yaml:"reconcile_interval"`  // how often to re-read the records from the provider; empty disables it
+	ConfirmChecks      int                 `yaml:"confirm_checks"`      // consecutive checks a new address must be detected on before records change
+	MaintenanceWindows []MaintenanceWindow `yaml:"maintenance_windows"` // times when changes are withheld
+	Canary             *CanaryConfig       `yaml:"canary"`              // record verified before the others change
+	Rollout            *RolloutConfig      `yaml:"rollout"`             // updates record groups one after another
+	Notifications      NotificationsConfig `yaml:"notifications"`       // webhooks and chats told about changes and failures
+	SystemProxy        bool                `yaml:"system_proxy"`        // Windows and macOS: use the proxy and PAC file of the network settings
+	Language           string              `yaml:"language"`            // language of command output, e.g. de; default from LANG
+
+	unknownKeys []string // top-level keys that are neither options nor x- extensions
+	encrypted   bool     // the local file is encrypted at rest
+}
+
+// ConfigGitConfig locates a configuration file in a git repository that is
+// layered over the local file and reloaded when it changes
+type ConfigGitConfig struct {
+	Repository    string `yaml:"repository"`     // clone URL
+	Branch        string `yaml:"branch"`         // default: the repository's default branch
+	Path          string `yaml:"path"`           // file within the repository
+	Interval      string `yaml:"interval"`       // how often to pull (default 5m)
+	VerifyCommits bool   `yaml:"verify_commits"` // require a valid signature on the pulled commit
+	Checksum      bool   `yaml:"checksum"`       // require <path>.sha256 to match the file
+}
+
+// defaultSyncInterval is how often a config source is pulled if not configured
+const defaultSyncInterval = 5 * time.Minute
+
+// GetSyncInterval returns how often the config source is pulled
+func (c *Config) GetSyncInterval() time.Duration {
+	interval := c.ConfigGit.Interval
+	if c.ConfigSource == "url" {
+		interval = c.ConfigURLPoll
+	}
+	if duration, err := time.ParseDuration(interval); err == nil && duration > 0 {
+		return duration
+	}
+	return defaultSyncInterval
+}
+
+// IPDetectionConfig selects where the public IP addresses come from
+type IPDetectionConfig struct {
+	Source               string           `yaml:"source"`                  // http (default), trace, dns, snmp, fritzbox or interface
+	Sources              []IPSourceConfig `yaml:"sources"`                 // several sources combined by Quorum; overrides Source
+	Quorum               string           `yaml:"quorum"`                  // first-success (default), majority or all-agree
+	PreferDNSWhenMetered bool             `yaml:"prefer_dns_when_metered"` // Linux: use DNS detection on NetworkManager metered connections
+	FollowDefaultRoute   bool             `yaml:"follow_default_route"`    // http/dns: send requests from the uplink holding the default route
+	SkipOnCGNAT          bool             `yaml:"skip_on_cgnat"`           // leave A records alone while the IPv4 address is behind carrier-grade NAT
+	Interface            string           `yaml:"interface"`               // interface source: network interface holding the public address
+	SNMP                 SNMPConfig       `yaml:"snmp"`
+	FritzBox             FritzBoxConfig   `yaml:"fritzbox"`
+
+	// Services asked by the http source, tried before the built-in ones
+	IPv4URLs           []DetectionURL `yaml:"ipv4_urls"`
+	IPv6URLs           []DetectionURL `yaml:"ipv6_urls"`
+	ReplaceBuiltinURLs bool           `yaml:"replace_builtin_urls"` // use only the listed services
+	DoHURL             string         `yaml:"doh_url"`              // resolve the services' host names with this DNS over HTTPS server
+	DoHBootstrap       []string       `yaml:"doh_bootstrap"`        // addresses of the DoH server, required if doh_url names it by host name
+	HTTP3              bool           `yaml:"http3"`                // send requests over HTTP/3 (QUIC), falling back to TCP
+}
+
+// DetectionURL is an HTTP service answering with the caller's address in plain text
+type DetectionURL struct {
+	URL     string `yaml:"url"`
+	Timeout string `yaml:"timeout"` // default 10s
+}
+
+// GetTimeout returns how long a request to the service may take, or 0 for the default
+func (d DetectionURL) GetTimeout() time.Duration {
+	duration, _ := time.ParseDuration(d.Timeout)
+	return duration
+}
+
+// IPSourceConfig is one entry of a multi-source detection setup
+type IPSourceConfig struct {
+	Type   string `yaml:"type"`   // http, trace, dns, snmp, fritzbox or interface
+	Weight int    `yaml:"weight"` // vote weight for majority/all-agree (default 1)
+}
+
+// FritzBoxConfig holds the settings for querying a Fritz!Box or other
+// TR-064/IGD router for its external address
+type FritzBoxConfig struct {
+	URL string `yaml:"url"` // WANIPConnection control URL (default: Fritz!Box IGD endpoint)
+}
+
+// SNMPConfig holds the settings for reading the WAN address from a router via SNMP
+type SNMPConfig struct {
+	Host         string `yaml:"host"`          // router address, optionally with :port
+	Version      string `yaml:"version"`       // 2c (default) or 3
+	Community    string `yaml:"community"`     // v2c community (default "public")
+	Username     string `yaml:"username"`      // v3 security name
+	AuthProtocol string `yaml:"auth_protocol"` // v3: MD5 or SHA
+	AuthPassword string `yaml:"auth_password"` // v3
+	PrivProtocol string `yaml:"priv_protocol"` // v3: DES or AES
+	PrivPassword string `yaml:"priv_password"` // v3
+	Interface    string `yaml:"interface"`     // WAN interface name (ifDescr), e.g. ppp0
+}
+
+// ServerConfig configures the local HTTP endpoint for health probes and control requests
+type ServerConfig struct {
+	Listen     string      `yaml:"listen"`      // e.g. "127.0.0.1:8080"; empty disables the server
+	PushTokens []PushToken `yaml:"push_tokens"` // bearer tokens accepted by /api/v1/push
+	Pprof      bool        `yaml:"pprof"`       // serve Go profiling data under /debug/pprof/
+}
+
+// PushToken allows a client of /api/v1/push to set the listed push records
+type PushToken struct {
+	Token   string   `yaml:"token"`
+	Records []string `yaml:"records"`
+}
+
+// SimulateTokens returns the push tokens scoped to every configured record,
+// which alone may inject a simulated address, as it is written to all of them
+func (c *Config) SimulateTokens() []PushToken {
+	var tokens []PushToken
+	for _, token := range c.Server.PushTokens {
+		covered := true
+		for _, record := range c.Records {
+			if !slices.ContainsFunc(token.Records, func(name string) bool {
+				return cloudflare.NormalizeName(name) == cloudflare.NormalizeName(record.Name)
+			}) {
+				covered = false
+				break
+			}
+		}
+		if covered {
+			tokens = append(tokens, token)
+		}
+	}
+	return tokens
+}
+
+// DynDNSConfig configures the DynDNS2-compatible listener that lets routers
+// push their address for records marked with push
+type DynDNSConfig struct {
+	Listen   string `yaml:"listen"` // e.g. ":8245"; empty disables the listener
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+}
+
+// TriggersConfig enables event sources that request an immediate check
+// instead of waiting for the next interval
+type TriggersConfig struct {
+	DBus          bool          `yaml:"dbus"`           // Linux: NetworkManager / systemd-networkd signals
+	AddressChange bool          `yaml:"address_change"` // Windows: NotifyAddrChange, Linux: netlink
+	Sleep         bool          `yaml:"sleep"`          // Linux: pause during suspend via systemd-logind
+	LogTail       LogTailConfig `yaml:"log_tail"`
+}
+
+// LogTailConfig fires a check when a line matching Pattern appears in a log
+// file or in syslog messages forwarded to SyslogListen
+type LogTailConfig struct {
+	Path         string `yaml:"path"`
+	SyslogListen string `yaml:"syslog_listen"` // UDP address, e.g. ":5514"
+	Pattern      string `yaml:"pattern"`       // regular expression
+}
+
+// Enabled reports whether a log input is configured
+func (l LogTailConfig) Enabled() bool {
+	return l.Path != "" || l.SyslogListen != ""
 }
 
 // CloudflareConfig holds Cloudflare API credentials
 type CloudflareConfig struct {
-	APIToken string `yaml:"api_token"`
+	APIToken     string              `yaml:"api_token"`      // default token for zones without their own
+	APITokenEnv  string              `yaml:"api_token_env"`  // environment variable holding the default token
+	APITokenFile string              `yaml:"api_token_file"` // file holding the default token, e.g. a container secret
+	ZoneTokens   map[string]string   `yaml:"zone_tokens"`    // zone ID -> token scoped to that zone
+	Accounts     []CloudflareAccount `yaml:"accounts"`       // named credentials that records select with account
+	Retry        RetryConfig         `yaml:"retry"`
+}
+
+// CloudflareAccount is a named API token, e.g. of a second Cloudflare
+// account, used for the records that name it
+type CloudflareAccount struct {
+	Name         string `yaml:"name"`
+	APIToken     string `yaml:"api_token"`
+	APITokenEnv  string `yaml:"api_token_env"`
+	APITokenFile string `yaml:"api_token_file"`
+}
+
+// RetryConfig controls how failed API requests are retried. Unset options
+// keep their defaults.
+type RetryConfig struct {
+	MaxAttempts  int    `yaml:"max_attempts"`  // attempts per request including the first (default 4)
+	InitialDelay string `yaml:"initial_delay"` // delay before the first retry (default 1s)
+	MaxDelay     string `yaml:"max_delay"`     // longest delay and Retry-After waited for (default 30s)
+}
+
+// maxRetryAttempts bounds retry.max_attempts so a failing cycle can't stall for long
+const maxRetryAttempts = 10
+
+// validate checks the retry settings
+func (r RetryConfig) validate() error {
+	if r.MaxAttempts < 0 || r.MaxAttempts > maxRetryAttempts {
+		return fmt.Errorf("cloudflare.retry.max_attempts must be between 1 and %d", maxRetryAttempts)
+	}
+	var delays [2]time.Duration
+	for i, option := range []struct{ name, value string }{{"initial_delay", r.InitialDelay}, {"max_delay", r.MaxDelay}} {
+		if option.value == "" {
+			continue
+		}
+		d, err := time.ParseDuration(option.value)
+		if err != nil || d <= 0 {
+			return fmt.Errorf("invalid cloudflare.retry.%s %s", option.name, option.value)
+		}
+		delays[i] = d
+	}
+	if delays[0] > 0 && delays[1] > 0 && delays[0] > delays[1] {
+		return fmt.Errorf("cloudflare.retry.initial_delay must not exceed cloudflare.retry.max_delay")
+	}
+	return nil
+}
+
+// TokenFor returns the token used for a zone
+func (c CloudflareConfig) TokenFor(zoneID string) string {
+	if token, ok := c.ZoneTokens[zoneID]; ok {
+		return token
+	}
+	return c.APIToken
+}
+
+// accountName names an account in messages, including the default token
+func accountName(name string) string {
+	if name == "" {
+		return "the default token"
+	}
+	return name
+}
+
+// Account returns the account with a name
+func (c CloudflareConfig) Account(name string) (CloudflareAccount, bool) {
+	for _, account := range c.Accounts {
+		if account.Name == name {
+			return account, true
+		}
+	}
+	return CloudflareAccount{}, false
+}
+
+// TokenForRecord returns the token used for a record: its account's, else
+// the one for its zone
+func (c CloudflareConfig) TokenForRecord(record DNSRecord) string {
+	if record.Account != "" {
+		account, _ := c.Account(record.Account)
+		return account.APIToken
+	}
+	return c.TokenFor(record.ZoneID)
 }
 
 // DNSRecord represents a DNS record to update
 type DNSRecord struct {
 	ZoneID  string   `yaml:"zone_id"`
-	Name    string   `yaml:"name"`
-	Types   []string `yaml:"types"` // A, AAAA
-	TTL     int      `yaml:"ttl"`
+	Zone    string   `yaml:"zone"`  // zone name, resolved to zone_id at startup
+	Name    string   `yaml:"name"`  // Unicode (IDN) names are converted to punycode for API calls
+	Types   []string `yaml:"types"` // A, AAAA, or TXT with a content template
+	TTL     int      `yaml:"ttl"`   // optional for proxied records, which use automatic TTL
 	Proxied bool     `yaml:"proxied"`
+	Push    bool     `yaml:"push"`    // address is pushed by a DynDNS2 client instead of detected
+	Comment string   `yaml:"comment"` // record comment in Cloudflare; empty leaves it unmanaged
+	Group   string   `yaml:"group"`   // selects the maintenance windows that apply
+	Account string   `yaml:"account"` // cloudflare.accounts entry whose token is used
+
+	// ContentTemplate derives the content from the detected addresses, e.g.
+	// "v=spf1 ip4:{ipv4} -all" for an SPF TXT record
+	ContentTemplate string     `yaml:"content_template"`
+	SPF             *SPFConfig `yaml:"spf"` // generates an SPF policy instead of a template
+
+	// ReverseHint is the domain below which a TXT record named after the
+	// current address, reversed as in in-addr.arpa, holds the record's name
+	ReverseHint string `yaml:"reverse_hint"`
+	Follow      string `yaml:"follow"` // name of a record whose address this one takes
+
+	ErrorBudget *ErrorBudgetConfig `yaml:"error_budget"` // overrides notifications.error_budget
+
+	// Preserve lists settings of an existing record that are left as they
+	// are in Cloudflare, so that only the content is written: ttl, proxied
+	Preserve []string `yaml:"preserve"`
+}
+
+// Preserves reports whether a setting of the existing record is left as it is
+func (r DNSRecord) Preserves(setting string) bool {
+	return slices.Contains(r.Preserve, setting)
+}
+
+// GetTTL returns the TTL to write, which is automatic if a proxied record
+// leaves it out
+func (r DNSRecord) GetTTL() int {
+	if r.TTL == 0 {
+		return AutoTTL
+	}
+	return r.TTL
 }
 
-// Load reads and parses the configuration file
+// SPFConfig describes an SPF policy that authorizes the detected addresses to
+// send mail for the record's name
+type SPFConfig struct {
+	IPv4       bool   `yaml:"ipv4"`       // authorize the detected IPv4 address
+	IPv6       bool   `yaml:"ipv6"`       // authorize the detected IPv6 address
+	Mechanisms string `yaml:"mechanisms"` // further mechanisms, e.g. "mx include:_spf.example.net"
+	All        string `yaml:"all"`        // qualifier of the closing all: -, ~ (default) or ?
+}
+
+// spfLookupLimit is the number of DNS lookups an SPF check may take (RFC 7208)
+const spfLookupLimit = 10
+
+// Template returns the SPF policy as a content template
+func (s SPFConfig) Template() string {
+	parts := []string{"v=spf1"}
+	if s.IPv4 {
+		parts = append(parts, "ip4:"+PlaceholderIPv4)
+	}
+	if s.IPv6 {
+		parts = append(parts, "ip6:"+PlaceholderIPv6)
+	}
+	if s.Mechanisms != "" {
+		parts = append(parts, strings.Fields(s.Mechanisms)...)
+	}
+	all := s.All
+	if all == "" {
+		all = "~"
+	}
+	return strings.Join(append(parts, all+"all"), " ")
+}
+
+// validate checks the SPF policy settings
+func (s SPFConfig) validate() error {
+	if !s.IPv4 && !s.IPv6 {
+		return fmt.Errorf("spf must authorize ipv4, ipv6 or both")
+	}
+	switch s.All {
+	case "", "-", "~", "?":
+	default:
+		return fmt.Errorf("invalid spf.all %s (must be -, ~ or ?)", s.All)
+	}
+	for _, term := range strings.Fields(s.Mechanisms) {
+		name := strings.ToLower(strings.TrimLeft(term, "+-~?"))
+		if name == "all" || strings.HasPrefix(name, "v=") {
+			return fmt.Errorf("spf.mechanisms must not contain %s; the version and all are added automatically", term)
+		}
+	}
+	return nil
+}
+
+// lookups counts the mechanisms of the policy that cost a DNS lookup
+func (s SPFConfig) lookups() int {
+	count := 0
+	for _, term := range strings.Fields(s.Mechanisms) {
+		name, _, _ := strings.Cut(strings.ToLower(strings.TrimLeft(term, "+-~?")), ":")
+		name, _, _ = strings.Cut(name, "/")
+		name, _, _ = strings.Cut(name, "=")
+		switch name {
+		case "a", "mx", "ptr", "include", "exists", "redirect":
+			count++
+		}
+	}
+	return count
+}
+
+// Template returns the record's content template: the configured one, the
+// generated SPF policy, or "" for address records
+func (r DNSRecord) Template() string {
+	if r.SPF != nil {
+		return r.SPF.Template()
+	}
+	return r.ContentTemplate
+}
+
+// Placeholders replaced with the detected addresses in a content template
+const (
+	PlaceholderIPv4 = "{ipv4}"
+	PlaceholderIPv6 = "{ipv6}"
+)
+
+// nameProfile validates IDN record names, allowing wildcard and underscore labels
+var nameProfile = idna.New(idna.MapForLookup(), idna.StrictDomainName(false))
+
+// SyncedFileName is the copy of the last configuration pulled from a config
+// source, kept next to the config file
+const SyncedFileName = "config.synced.yaml"
+
+// SyncedPath returns the location of the synced copy for a configuration file
+func SyncedPath(configPath string) string {
+	return filepath.Join(filepath.Dir(configPath), SyncedFileName)
+}
+
+// Load reads and parses the configuration file. If it sets config_source, the
+// last configuration pulled from the source is layered over it.
 func Load(path string) (*Config, error) {
+	cfg, err := loadLocal(path)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.ConfigSource == "" {
+		return validated(cfg)
+	}
+
+	data, err := os.ReadFile(SyncedPath(path))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("no configuration has been pulled from config_source yet; start the daemon to pull it")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read synced config file: %w", err)
+	}
+	return layer(cfg, data)
+}
+
+// LoadSynced layers pulled configuration data over the local configuration
+// file and validates the result, without relying on a synced copy
+func LoadSynced(path string, data []byte) (*Config, error) {
+	cfg, err := loadLocal(path)
+	if err != nil {
+		return nil, err
+	}
+	return layer(cfg, data)
+}
+
+// LoadSource reads only the config source settings of the local
+// configuration file, which are needed before the rest can be pulled
+func LoadSource(path string) (*Config, error) {
+	cfg, err := loadLocal(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := cfg.validateConfigSource(); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+	return cfg, nil
+}
+
+// loadLocal reads and decodes the configuration file without validating it
+func loadLocal(path string) (*Config, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read config file: %w", err)
 	}
+	encrypted := encryption.IsEncrypted(data)
+	if data, err = plaintext(data); err != nil {
+		return nil, fmt.Errorf("failed to decrypt config file: %w", err)
+	}
 
-	var cfg Config
-	if err := yaml.Unmarshal(data, &cfg); err != nil {
+	cfg := Config{encrypted: encrypted}
+	if err := decode(&cfg, data); err != nil {
 		return nil, fmt.Errorf("failed to parse config file: %w", err)
 	}
+	if cfg.ConfigSource == "" && cfg.ConfigURL != "" {
+		cfg.ConfigSource = "url"
+	}
+	return &cfg, nil
+}
+
+// layer decodes pulled data over cfg, so options it sets override the local
+// file while the config source itself always comes from the local file
+func layer(cfg *Config, data []byte) (*Config, error) {
+	local := *cfg
+	data, err := plaintext(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt pulled config: %w", err)
+	}
 
+	// A token source in the pulled data replaces the local one instead of
+	// conflicting with it
+	cfg.Cloudflare.APIToken, cfg.Cloudflare.APITokenEnv, cfg.Cloudflare.APITokenFile = "", "", ""
+	if err := decode(cfg, data); err != nil {
+		return nil, fmt.Errorf("failed to parse pulled config: %w", err)
+	}
+	if cfg.Cloudflare.tokenSources() == 0 {
+		cfg.Cloudflare.APIToken, cfg.Cloudflare.APITokenEnv, cfg.Cloudflare.APITokenFile = local.Cloudflare.APIToken, local.Cloudflare.APITokenEnv, local.Cloudflare.APITokenFile
+	}
+	cfg.ConfigSource, cfg.ConfigGit = local.ConfigSource, local.ConfigGit
+	cfg.ConfigURL, cfg.ConfigURLPoll = local.ConfigURL, local.ConfigURLPoll
+	cfg.StateDir = local.StateDir
+	return validated(cfg)
+}
+
+// plaintext decrypts data if it's encrypted at rest
+func plaintext(data []byte) ([]byte, error) {
+	if !encryption.IsEncrypted(data) {
+		return data, nil
+	}
+	key, err := encryption.LoadKey()
+	if err != nil {
+		return nil, err
+	}
+	return encryption.Decrypt(data, key)
+}
+
+// decode parses YAML data into cfg, adding to the unknown keys
+func decode(cfg *Config, data []byte) error {
+	// Decode through a node tree so anchors, aliases, and merge keys are
+	// resolved before the top-level keys are inspected
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return err
+	}
+
+	if len(root.Content) > 0 {
+		if err := root.Content[0].Decode(cfg); err != nil {
+			return err
+		}
+		cfg.unknownKeys = append(cfg.unknownKeys, unknownKeys(root.Content[0])...)
+	}
+	return nil
+}
+
+// validated reads the API token from its source and returns cfg if it
+// passes validation
+func validated(cfg *Config) (*Config, error) {
+	if err := cfg.Cloudflare.resolveToken(); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
 	if err := cfg.Validate(); err != nil {
 		return nil, fmt.Errorf("invalid configuration: %w", err)
 	}
+	return cfg, nil
+}
 
-	return &cfg, nil
+// extensionPrefix marks top-level keys that only hold YAML anchors for reuse
+// elsewhere in the file, e.g. "x-record-defaults: &defaults"
+const extensionPrefix = "x-"
+
+// unknownKeys returns the top-level mapping keys that don't correspond to a
+// configuration option, ignoring x- extension keys
+func unknownKeys(node *yaml.Node) []string {
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+
+	known := make(map[string]bool)
+	t := reflect.TypeOf(Config{})
+	for i := 0; i < t.NumField(); i++ {
+		known[strings.Split(t.Field(i).Tag.Get("yaml"), ",")[0]] = true
+	}
+
+	var unknown []string
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key := node.Content[i].Value
+		if !known[key] && key != "<<" && !strings.HasPrefix(key, extensionPrefix) {
+			unknown = append(unknown, key)
+		}
+	}
+	return unknown
 }
 
 // Validate checks if the configuration is valid
 func (c *Config) Validate() error {
-	if c.Cloudflare.APIToken == "" {
-		return fmt.Errorf("cloudflare.api_token is required")
+	for zoneID, token := range c.Cloudflare.ZoneTokens {
+		if token == "" {
+			return fmt.Errorf("cloudflare.zone_tokens: token for zone %s is empty", zoneID)
+		}
+	}
+	accounts := make(map[string]bool)
+	for i, account := range c.Cloudflare.Accounts {
+		if account.Name == "" {
+			return fmt.Errorf("cloudflare.accounts %d: name is required", i)
+		}
+		if accounts[account.Name] {
+			return fmt.Errorf("cloudflare.accounts: duplicate name %s", account.Name)
+		}
+		accounts[account.Name] = true
+	}
+	if err := c.Cloudflare.Retry.validate(); err != nil {
+		return err
+	}
+
+	if err := c.validateConfigSource(); err != nil {
+		return err
 	}
 
 	if c.CheckInterval == "" {
@@ -63,30 +654,384 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("invalid check_interval format: %w", err)
 	}
 
+	if c.CycleBudget != "" {
+		if _, err := time.ParseDuration(c.CycleBudget); err != nil {
+			return fmt.Errorf("invalid cycle_budget format: %w", err)
+		}
+	}
+
+	if c.ReconcileInterval != "" {
+		interval, err := time.ParseDuration(c.ReconcileInterval)
+		if err != nil {
+			return fmt.Errorf("invalid reconcile_interval format: %w", err)
+		}
+		if interval < minReconcileInterval {
+			return fmt.Errorf("reconcile_interval must be at least %s", minReconcileInterval)
+		}
+	}
+	if c.ConfirmChecks < 0 || c.ConfirmChecks > maxConfirmChecks {
+		return fmt.Errorf("confirm_checks must be between 0 and %d", maxConfirmChecks)
+	}
+	if c.StateDir != "" && !filepath.IsAbs(c.StateDir) {
+		return fmt.Errorf("state_dir must be an absolute path")
+	}
+
+	switch c.Mode {
+	case "", ModeUpdate, ModeObserve:
+	default:
+		return fmt.Errorf("invalid mode %s (must be update or observe)", c.Mode)
+	}
+
+	if c.Language != "" && !i18n.Supported(c.Language) {
+		return fmt.Errorf("invalid language %s (must be one of %s)", c.Language, strings.Join(i18n.Languages, ", "))
+	}
+
+	switch c.StartupUpdate {
+	case "", StartupUpdateAlways, StartupUpdateIfChanged, StartupUpdateNever:
+	default:
+		return fmt.Errorf("invalid startup_update %s (must be always, if-changed or never)", c.StartupUpdate)
+	}
+
 	if len(c.Records) == 0 {
 		return fmt.Errorf("at least one DNS record must be configured")
 	}
 
+	zoneAccounts := make(map[string]string) // zone ID -> account of its records
 	for i, record := range c.Records {
-		if record.ZoneID == "" {
-			return fmt.Errorf("record %d: zone_id is required", i)
+		switch {
+		case record.ZoneID == "" && record.Zone == "":
+			return fmt.Errorf("record %d: zone_id or zone is required", i)
+		case record.ZoneID != "" && record.Zone != "":
+			return fmt.Errorf("record %d: set either zone_id or zone, not both", i)
+		case record.Account != "" && c.GetProvider() != ProviderCloudflare:
+			return fmt.Errorf("record %d: account only applies to the cloudflare provider", i)
+		case c.GetProvider() != ProviderCloudflare:
+			// Other providers check their own settings
+		case record.Account != "" && !accounts[record.Account]:
+			return fmt.Errorf("record %d: unknown account %s (not in cloudflare.accounts)", i, record.Account)
+		case record.Account != "":
+			// The account's token serves the zone and looks up its ID
+		case record.Zone != "" && c.Cloudflare.APIToken == "":
+			return fmt.Errorf("record %d: zone requires cloudflare.api_token, which is used to look up zone IDs", i)
+		case record.ZoneID != "" && c.Cloudflare.TokenFor(record.ZoneID) == "":
+			return fmt.Errorf("record %d: cloudflare.api_token is required (zone %s has no entry in cloudflare.zone_tokens)", i, record.ZoneID)
+		}
+		if record.ZoneID != "" && c.GetProvider() == ProviderCloudflare {
+			// Each zone is served by a single token
+			if _, ok := c.Cloudflare.ZoneTokens[record.ZoneID]; ok && record.Account != "" {
+				return fmt.Errorf("record %d: zone %s has a token in cloudflare.zone_tokens and can't use account %s", i, record.ZoneID, record.Account)
+			}
+			if account, ok := zoneAccounts[record.ZoneID]; ok && account != record.Account {
+				return fmt.Errorf("record %d: zone %s is used with different accounts (%s and %s)", i, record.ZoneID, accountName(account), accountName(record.Account))
+			}
+			zoneAccounts[record.ZoneID] = record.Account
 		}
 		if record.Name == "" {
 			return fmt.Errorf("record %d: name is required", i)
 		}
+		if _, err := nameProfile.ToASCII(record.Name); err != nil {
+			return fmt.Errorf("record %d: invalid name %s: %w", i, record.Name, err)
+		}
 		if len(record.Types) == 0 {
 			return fmt.Errorf("record %d: at least one type (A or AAAA) is required", i)
 		}
-		for _, t := range record.Types {
-			if t != "A" && t != "AAAA" {
-				return fmt.Errorf("record %d: invalid type %s (must be A or AAAA)", i, t)
+		if record.Template() != "" {
+			if err := record.validateTemplate(); err != nil {
+				return fmt.Errorf("record %d: %w", i, err)
+			}
+		} else {
+			for _, t := range record.Types {
+				if t != "A" && t != "AAAA" {
+					return fmt.Errorf("record %d: invalid type %s (must be A or AAAA, or TXT with content_template or spf)", i, t)
+				}
+			}
+		}
+		if record.Follow != "" {
+			if err := c.validateFollow(record); err != nil {
+				return fmt.Errorf("record %d: %w", i, err)
+			}
+		}
+		if record.ReverseHint != "" {
+			if record.Template() != "" {
+				return fmt.Errorf("record %d: reverse_hint only applies to A and AAAA records", i)
+			}
+			if _, err := nameProfile.ToASCII(record.ReverseHint); err != nil {
+				return fmt.Errorf("record %d: invalid reverse_hint %s: %w", i, record.ReverseHint, err)
+			}
+		}
+		for _, setting := range record.Preserve {
+			if setting != PreserveTTL && setting != PreserveProxied {
+				return fmt.Errorf("record %d: invalid preserve %s (must be ttl or proxied)", i, setting)
+			}
+		}
+		switch {
+		case record.Proxied && (record.TTL == 0 || record.TTL == AutoTTL):
+			// Proxied records use automatic TTL
+		case record.TTL < 60 || record.TTL > 86400:
+			return fmt.Errorf("record %d: ttl must be between 60 and 86400 (or omitted for proxied records)", i)
+		}
+		if record.Push && c.DynDNS.Listen == "" && len(c.Server.PushTokens) == 0 {
+			return fmt.Errorf("record %d: push requires dyndns.listen or server.push_tokens", i)
+		}
+		if record.ErrorBudget != nil {
+			if err := record.ErrorBudget.validate(); err != nil {
+				return fmt.Errorf("record %d: error_budget: %w", i, err)
+			}
+		}
+	}
+
+	if len(c.IPDetection.Sources) == 0 {
+		if err := c.IPDetection.validateSource(c.IPDetection.Source); err != nil {
+			return err
+		}
+	}
+	for i, source := range c.IPDetection.Sources {
+		if err := c.IPDetection.validateSource(source.Type); err != nil {
+			return fmt.Errorf("ip_detection.sources %d: %w", i, err)
+		}
+		if source.Weight < 0 {
+			return fmt.Errorf("ip_detection.sources %d: weight must not be negative", i)
+		}
+	}
+	if err := c.IPDetection.validateURLs(); err != nil {
+		return err
+	}
+	switch c.IPDetection.Quorum {
+	case "", "first-success", "majority", "all-agree":
+	default:
+		return fmt.Errorf("invalid ip_detection.quorum %s (must be first-success, majority or all-agree)", c.IPDetection.Quorum)
+	}
+
+	if c.Server.Pprof && c.Server.Listen == "" {
+		return fmt.Errorf("server.pprof requires server.listen")
+	}
+	if len(c.Server.PushTokens) > 0 && c.Server.Listen == "" {
+		return fmt.Errorf("server.push_tokens requires server.listen")
+	}
+	for i, token := range c.Server.PushTokens {
+		if token.Token == "" {
+			return fmt.Errorf("server.push_tokens %d: token is required", i)
+		}
+		if len(token.Records) == 0 {
+			return fmt.Errorf("server.push_tokens %d: at least one record is required", i)
+		}
+	}
+
+	if c.DynDNS.Listen != "" && (c.DynDNS.Username == "" || c.DynDNS.Password == "") {
+		return fmt.Errorf("dyndns.username and dyndns.password are required")
+	}
+
+	for i := range c.MaintenanceWindows {
+		if err := c.MaintenanceWindows[i].validate(); err != nil {
+			return fmt.Errorf("maintenance_windows %d: %w", i, err)
+		}
+	}
+	if c.Canary != nil {
+		if err := c.validateCanary(); err != nil {
+			return err
+		}
+	}
+	if c.Rollout != nil {
+		if err := c.Rollout.validate(); err != nil {
+			return fmt.Errorf("rollout: %w", err)
+		}
+	}
+
+	for i, webhook := range c.Notifications.Webhooks {
+		if err := webhook.validate(); err != nil {
+			return fmt.Errorf("notifications.webhooks %d: %w", i, err)
+		}
+	}
+	for _, chat := range c.Notifications.chats() {
+		if err := chat.config.validate(); err != nil {
+			return fmt.Errorf("notifications.%s: %w", chat.name, err)
+		}
+	}
+	if c.Notifications.Telegram != nil {
+		if err := c.Notifications.Telegram.validate(); err != nil {
+			return fmt.Errorf("notifications.telegram: %w", err)
+		}
+	}
+	if c.Notifications.ErrorBudget != nil {
+		if err := c.Notifications.ErrorBudget.validate(); err != nil {
+			return fmt.Errorf("notifications.error_budget: %w", err)
+		}
+	}
+	if err := validateEscalation(c.Notifications.Escalation); err != nil {
+		return fmt.Errorf("notifications.escalation: %w", err)
+	}
+	if err := c.Notifications.validateDigest(); err != nil {
+		return fmt.Errorf("notifications: %w", err)
+	}
+
+	if c.Triggers.LogTail.Enabled() {
+		if c.Triggers.LogTail.Pattern == "" {
+			return fmt.Errorf("triggers.log_tail.pattern is required")
+		}
+		if _, err := regexp.Compile(c.Triggers.LogTail.Pattern); err != nil {
+			return fmt.Errorf("invalid triggers.log_tail.pattern: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Primary returns the record of the given type that a following record takes
+// its address from. Records that follow another can't be followed themselves.
+func (c *Config) Primary(follower DNSRecord, recordType string) (DNSRecord, bool) {
+	for _, record := range c.Records {
+		if record.Follow == "" && cloudflare.NormalizeName(record.Name) == cloudflare.NormalizeName(follower.Follow) && slices.Contains(record.Types, recordType) {
+			return record, true
+		}
+	}
+	return DNSRecord{}, false
+}
+
+// validateFollow checks that a following record is an address record whose
+// types all exist on its primary
+func (c *Config) validateFollow(record DNSRecord) error {
+	if record.Push {
+		return fmt.Errorf("follow cannot be combined with push")
+	}
+	if record.Template() != "" {
+		return fmt.Errorf("follow only applies to A and AAAA records")
+	}
+	for _, t := range record.Types {
+		if _, ok := c.Primary(record, t); !ok {
+			return fmt.Errorf("follow %s names no %s record (records that follow another can't be followed)", record.Follow, t)
+		}
+	}
+	return nil
+}
+
+// validateTemplate checks a record with a content template or SPF policy: it
+// publishes a single TXT record that references at least one detected address
+func (r DNSRecord) validateTemplate() error {
+	option := "content_template"
+	if r.SPF != nil {
+		option = "spf"
+		if r.ContentTemplate != "" {
+			return fmt.Errorf("set either content_template or spf, not both")
+		}
+		if err := r.SPF.validate(); err != nil {
+			return err
+		}
+	}
+	if len(r.Types) != 1 || r.Types[0] != "TXT" {
+		return fmt.Errorf("%s requires types: [TXT]", option)
+	}
+	if !strings.Contains(r.ContentTemplate, PlaceholderIPv4) && !strings.Contains(r.ContentTemplate, PlaceholderIPv6) && r.SPF == nil {
+		return fmt.Errorf("content_template must contain %s or %s", PlaceholderIPv4, PlaceholderIPv6)
+	}
+	if r.Proxied {
+		return fmt.Errorf("TXT records cannot be proxied")
+	}
+	if r.Push {
+		return fmt.Errorf("%s cannot be combined with push", option)
+	}
+	return nil
+}
+
+// validateConfigSource checks the settings of the config source
+func (c *Config) validateConfigSource() error {
+	switch c.ConfigSource {
+	case "":
+	case "git":
+		if c.ConfigURL != "" {
+			return fmt.Errorf("config_url can't be combined with config_source git")
+		}
+		if c.ConfigGit.Repository == "" {
+			return fmt.Errorf("config_git.repository is required")
+		}
+		if c.ConfigGit.Path == "" {
+			return fmt.Errorf("config_git.path is required")
+		}
+		if c.ConfigGit.Interval != "" {
+			if _, err := time.ParseDuration(c.ConfigGit.Interval); err != nil {
+				return fmt.Errorf("invalid config_git.interval format: %w", err)
 			}
 		}
-		if record.TTL < 60 || record.TTL > 86400 {
-			return fmt.Errorf("record %d: ttl must be between 60 and 86400", i)
+	case "url":
+		u, err := url.Parse(c.ConfigURL)
+		if err != nil || u.Host == "" {
+			return fmt.Errorf("config_url must be an absolute URL")
+		}
+		if u.Scheme != "https" {
+			return fmt.Errorf("config_url must use https")
+		}
+		if c.ConfigURLPoll != "" {
+			if _, err := time.ParseDuration(c.ConfigURLPoll); err != nil {
+				return fmt.Errorf("invalid config_url_interval format: %w", err)
+			}
 		}
+	default:
+		return fmt.Errorf("invalid config_source %s (must be git or url)", c.ConfigSource)
 	}
+	return nil
+}
 
+// validateURLs checks the configured detection services
+func (d *IPDetectionConfig) validateURLs() error {
+	for _, list := range []struct {
+		name string
+		urls []DetectionURL
+	}{{"ipv4_urls", d.IPv4URLs}, {"ipv6_urls", d.IPv6URLs}} {
+		for i, service := range list.urls {
+			u, err := url.Parse(service.URL)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return fmt.Errorf("ip_detection.%s %d: invalid url %s (must be an http or https URL)", list.name, i, service.URL)
+			}
+			if service.Timeout != "" {
+				if d, err := time.ParseDuration(service.Timeout); err != nil || d <= 0 {
+					return fmt.Errorf("ip_detection.%s %d: invalid timeout %s", list.name, i, service.Timeout)
+				}
+			}
+		}
+	}
+	if d.ReplaceBuiltinURLs && len(d.IPv4URLs) == 0 && len(d.IPv6URLs) == 0 {
+		return fmt.Errorf("ip_detection.replace_builtin_urls requires ipv4_urls or ipv6_urls")
+	}
+	if d.DoHURL != "" {
+		if u, err := url.Parse(d.DoHURL); err != nil || u.Scheme != "https" || u.Host == "" {
+			return fmt.Errorf("invalid ip_detection.doh_url %s (must be an https URL)", d.DoHURL)
+		} else if _, err := netip.ParseAddr(u.Hostname()); err != nil && len(d.DoHBootstrap) == 0 {
+			// Looking the server up with the system resolver would defeat DoH
+			return fmt.Errorf("ip_detection.doh_bootstrap is required when doh_url names its server by host name")
+		}
+	}
+	for _, addr := range d.DoHBootstrap {
+		if _, err := netip.ParseAddr(addr); err != nil {
+			return fmt.Errorf("invalid ip_detection.doh_bootstrap address %s", addr)
+		}
+	}
+	if len(d.DoHBootstrap) > 0 && d.DoHURL == "" {
+		return fmt.Errorf("ip_detection.doh_bootstrap requires doh_url")
+	}
+	return nil
+}
+
+// validateSource checks the settings required by a detection source type
+func (d *IPDetectionConfig) validateSource(kind string) error {
+	switch kind {
+	case "", "http":
+	case "snmp":
+		if d.SNMP.Host == "" {
+			return fmt.Errorf("ip_detection.snmp.host is required")
+		}
+		if d.SNMP.Version != "" && d.SNMP.Version != "2c" && d.SNMP.Version != "3" {
+			return fmt.Errorf("invalid ip_detection.snmp.version %s (must be 2c or 3)", d.SNMP.Version)
+		}
+		if d.SNMP.Version == "3" && d.SNMP.Username == "" {
+			return fmt.Errorf("ip_detection.snmp.username is required for SNMPv3")
+		}
+	case "interface":
+		if d.Interface == "" {
+			return fmt.Errorf("ip_detection.interface is required for the interface source")
+		}
+	case "trace", "fritzbox", "dns":
+	default:
+		return fmt.Errorf("invalid IP source %s (must be http, trace, dns, snmp, fritzbox or interface)", kind)
+	}
 	return nil
 }
 
@@ -95,3 +1040,74 @@ func (c *Config) GetCheckInterval() time.Duration {
 	duration, _ := time.ParseDuration(c.CheckInterval)
 	return duration
 }
+
+// GetCycleBudget returns the update cycle latency budget, or 0 if none is set
+func (c *Config) GetCycleBudget() time.Duration {
+	duration, _ := time.ParseDuration(c.CycleBudget)
+	return duration
+}
+
+// minReconcileInterval keeps reconciliation, which lists every zone, from
+// using up the API rate limit
+const minReconcileInterval = time.Minute
+
+// GetReconcileInterval returns how often records are reconciled with the
+// provider, or 0 if reconciliation is disabled
+func (c *Config) GetReconcileInterval() time.Duration {
+	duration, _ := time.ParseDuration(c.ReconcileInterval)
+	return duration
+}
+
+// maxConfirmChecks bounds confirm_checks; beyond it, real changes would be
+// published too late
+const maxConfirmChecks = 10
+
+// GetProvider returns the name of the DNS provider with the default applied
+func (c *Config) GetProvider() string {
+	if c.Provider == "" {
+		return ProviderCloudflare
+	}
+	return c.Provider
+}
+
+// Observing reports whether the daemon only reports drift instead of writing
+func (c *Config) Observing() bool {
+	return c.Mode == ModeObserve
+}
+
+// Frozen reports whether the freeze file exists, pausing all writes
+func (c *Config) Frozen() bool {
+	if c.FreezeFile == "" {
+		return false
+	}
+	_, err := os.Stat(c.FreezeFile)
+	return err == nil
+}
+
+// GetStartupUpdate returns the startup update policy with the default applied
+func (c *Config) GetStartupUpdate() string {
+	if c.StartupUpdate == "" {
+		return StartupUpdateIfChanged
+	}
+	return c.StartupUpdate
+}
+
+// MinCheckInterval returns the shortest check interval at which a full update
+// cycle of every configured record stays within Cloudflare's API rate limit
+func (c *Config) MinCheckInterval() time.Duration {
+	requests := 0
+	for _, record := range c.Records {
+		requests += len(record.Types) * requestsPerUpdate
+	}
+	return apiRateLimitWindow * time.Duration(requests) / apiRateLimitRequests
+}
+
+// EffectiveCheckInterval returns the configured check interval, stretched to
+// MinCheckInterval when the configuration would otherwise exceed the rate limit
+func (c *Config) EffectiveCheckInterval() time.Duration {
+	interval := c.GetCheckInterval()
+	if floor := c.MinCheckInterval(); interval < floor {
+		return floor
+	}
+	return interval
+}
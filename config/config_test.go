@@ -0,0 +1,226 @@
+package config
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestDecodeMergedNodes(t *testing.T) {
+	tests := []struct {
+		name     string
+		yaml     string
+		interval string
+		records  []DNSRecord
+		unknown  []string
+	}{
+		{
+			name: "x- anchor merged into records",
+			yaml: `
+check_interval: 5m
+x-defaults: &defaults
+  zone_id: zone1
+  types: [A]
+  ttl: 300
+  proxied: true
+records:
+  - <<: *defaults
+    name: a.example.com
+  - <<: *defaults
+    name: b.example.com
+    ttl: 120
+    proxied: false
+`,
+			interval: "5m",
+			records: []DNSRecord{
+				{ZoneID: "zone1", Name: "a.example.com", Types: []string{"A"}, TTL: 300, Proxied: true},
+				{ZoneID: "zone1", Name: "b.example.com", Types: []string{"A"}, TTL: 120, Proxied: false},
+			},
+		},
+		{
+			name: "aliases inside lists",
+			yaml: `
+check_interval: 5m
+x-types: &dual [A, AAAA]
+x-host: &host c.example.com
+records:
+  - zone_id: zone1
+    name: *host
+    types: *dual
+  - zone_id: zone2
+    name: d.example.com
+    types: [*host]
+`,
+			interval: "5m",
+			records: []DNSRecord{
+				{ZoneID: "zone1", Name: "c.example.com", Types: []string{"A", "AAAA"}},
+				{ZoneID: "zone2", Name: "d.example.com", Types: []string{"c.example.com"}},
+			},
+		},
+		{
+			name: "unknown top-level key",
+			yaml: `
+check_interval: 5m
+chek_interval: 1m
+records:
+  - zone_id: zone1
+    name: e.example.com
+    types: [A]
+`,
+			interval: "5m",
+			records: []DNSRecord{
+				{ZoneID: "zone1", Name: "e.example.com", Types: []string{"A"}},
+			},
+			unknown: []string{"chek_interval"},
+		},
+		{
+			name: "merge key at the top level",
+			yaml: `
+x-base: &base
+  check_interval: 10m
+<<: *base
+records:
+  - zone_id: zone1
+    name: f.example.com
+    types: [A]
+`,
+			interval: "10m",
+			records: []DNSRecord{
+				{ZoneID: "zone1", Name: "f.example.com", Types: []string{"A"}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var cfg Config
+			if err := decode(&cfg, []byte(tt.yaml)); err != nil {
+				t.Fatalf("decode() error = %v", err)
+			}
+
+			if cfg.CheckInterval != tt.interval {
+				t.Errorf("check_interval = %q, want %q", cfg.CheckInterval, tt.interval)
+			}
+			if len(cfg.Records) != len(tt.records) {
+				t.Fatalf("decoded %d records, want %d", len(cfg.Records), len(tt.records))
+			}
+			for i, want := range tt.records {
+				got := cfg.Records[i]
+				if got.ZoneID != want.ZoneID || got.Name != want.Name || !slices.Equal(got.Types, want.Types) || got.TTL != want.TTL || got.Proxied != want.Proxied {
+					t.Errorf("record %d = %+v, want %+v", i, got, want)
+				}
+			}
+			if !slices.Equal(cfg.unknownKeys, tt.unknown) {
+				t.Errorf("unknown keys = %q, want %q", cfg.unknownKeys, tt.unknown)
+			}
+		})
+	}
+}
+
+func TestLintUnknownKeys(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+		warn bool
+	}{
+		{"unknown key", "check_interval: 5m\nrecrods: []\n", true},
+		{"x- key", "check_interval: 5m\nx-defaults: &defaults\n  ttl: 300\n", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var cfg Config
+			if err := decode(&cfg, []byte(tt.yaml)); err != nil {
+				t.Fatalf("decode() error = %v", err)
+			}
+			warned := false
+			for _, warning := range cfg.Lint("config.yaml") {
+				if strings.Contains(warning, "unknown option") {
+					warned = true
+				}
+			}
+			if warned != tt.warn {
+				t.Errorf("unknown option warning = %t, want %t (warnings: %q)", warned, tt.warn, cfg.Lint("config.yaml"))
+			}
+		})
+	}
+}
+
+func TestSimulateTokens(t *testing.T) {
+	cfg := &Config{
+		Records: []DNSRecord{{Name: "a.example.com"}, {Name: "b.example.com"}},
+	}
+	cfg.Server.PushTokens = []PushToken{
+		{Token: "one", Records: []string{"a.example.com"}},
+		{Token: "all", Records: []string{"B.example.com.", "a.example.com"}},
+	}
+
+	var got []string
+	for _, token := range cfg.SimulateTokens() {
+		got = append(got, token.Token)
+	}
+	if want := []string{"all"}; !slices.Equal(got, want) {
+		t.Errorf("SimulateTokens() = %q, want %q", got, want)
+	}
+}
+
+func TestValidateDoHBootstrap(t *testing.T) {
+	tests := []struct {
+		name      string
+		url       string
+		bootstrap []string
+		valid     bool
+	}{
+		{"IP literal", "https://1.1.1.1/dns-query", nil, true},
+		{"IPv6 literal", "https://[2606:4700:4700::1111]/dns-query", nil, true},
+		{"host name without bootstrap", "https://dns.google/dns-query", nil, false},
+		{"host name with bootstrap", "https://dns.google/dns-query", []string{"8.8.8.8"}, true},
+		{"invalid bootstrap", "https://dns.google/dns-query", []string{"dns.google"}, false},
+		{"bootstrap without url", "", []string{"8.8.8.8"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := IPDetectionConfig{DoHURL: tt.url, DoHBootstrap: tt.bootstrap}
+			if err := d.validateURLs(); (err == nil) != tt.valid {
+				t.Errorf("validate() error = %v, want valid %t", err, tt.valid)
+			}
+		})
+	}
+}
+
+func TestProxiedTTL(t *testing.T) {
+	tests := []struct {
+		name    string
+		ttl     int
+		proxied bool
+		valid   bool
+		warn    bool
+	}{
+		{"proxied without ttl", 0, true, true, false},
+		{"proxied with automatic ttl", 1, true, true, false},
+		{"proxied with explicit ttl", 300, true, true, true},
+		{"unproxied without ttl", 0, false, false, false},
+		{"unproxied with automatic ttl", 1, false, false, false},
+		{"unproxied with ttl", 300, false, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{CheckInterval: "5m"}
+			cfg.Cloudflare.APIToken = "token"
+			cfg.Records = []DNSRecord{{ZoneID: "zone", Name: "a.example.com", Types: []string{"A"}, TTL: tt.ttl, Proxied: tt.proxied}}
+
+			if err := cfg.Validate(); (err == nil) != tt.valid {
+				t.Fatalf("Validate() error = %v, want valid %t", err, tt.valid)
+			}
+			warned := false
+			for _, warning := range cfg.Lint("config.yaml") {
+				if strings.Contains(warning, "automatic TTL") {
+					warned = true
+				}
+			}
+			if warned != tt.warn {
+				t.Errorf("TTL warning = %t, want %t", warned, tt.warn)
+			}
+		})
+	}
+}
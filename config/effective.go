@@ -0,0 +1,300 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/MrLonely14/cf-ddns/i18n"
+)
+
+// redacted replaces secrets in the effective configuration
+const redacted = "[redacted]"
+
+// Effective describes the fully resolved configuration, one "key: value" line
+// per setting, with defaults applied and marked and secrets redacted
+func (c *Config) Effective() []string {
+	var lines []string
+	add := func(key, format string, args ...any) {
+		lines = append(lines, key+": "+fmt.Sprintf(format, args...))
+	}
+
+	add("provider", "%s", withDefault(c.Provider, c.GetProvider()))
+	if len(c.ProviderOptions) > 0 {
+		// Provider options commonly hold credentials
+		add("provider_options", "%d option(s) %s", len(c.ProviderOptions), redacted)
+	}
+	if c.GetProvider() == ProviderCloudflare {
+		add("cloudflare.api_token", "%s", c.Cloudflare.tokenSource())
+		if len(c.Cloudflare.ZoneTokens) > 0 {
+			add("cloudflare.zone_tokens", "%d zone(s) with their own token %s", len(c.Cloudflare.ZoneTokens), redacted)
+		}
+		for _, account := range c.Cloudflare.Accounts {
+			add("cloudflare.accounts."+account.Name, "%s", describeToken(account.APIToken, account.APITokenEnv, account.APITokenFile))
+		}
+		retry := c.Cloudflare.Retry
+		add("cloudflare.retry", "max_attempts %s, initial_delay %s, max_delay %s",
+			withDefault(positive(retry.MaxAttempts), "4"), withDefault(retry.InitialDelay, "1s"), withDefault(retry.MaxDelay, "30s"))
+	}
+
+	interval := c.CheckInterval
+	if effective := c.EffectiveCheckInterval(); effective != c.GetCheckInterval() {
+		interval += fmt.Sprintf(" (stretched to %s by the API rate limit)", effective)
+	}
+	add("check_interval", "%s", interval)
+	if c.CycleBudget != "" {
+		add("cycle_budget", "%s", c.CycleBudget)
+	}
+	add("reconcile_interval", "%s", withDefault(c.ReconcileInterval, "disabled"))
+	add("confirm_checks", "%s", withDefault(positive(c.ConfirmChecks), "1"))
+	add("startup_update", "%s", withDefault(c.StartupUpdate, c.GetStartupUpdate()))
+	add("mode", "%s", withDefault(c.Mode, ModeUpdate))
+	add("dry_run", "%t", c.DryRun)
+	if c.FreezeFile != "" {
+		state := "absent"
+		if c.Frozen() {
+			state = "present, writes paused"
+		}
+		add("freeze_file", "%s (%s)", c.FreezeFile, state)
+	}
+	add("strict_startup", "%t", c.StrictStartup)
+	add("audit_log", "%s", orNone(c.AuditLog))
+	add("language", "%s", withDefault(c.Language, i18n.Language()))
+	if c.SystemProxy {
+		add("system_proxy", "%t", c.SystemProxy)
+	}
+
+	add("ip_detection", "%s", c.IPDetection.describe())
+
+	var triggers []string
+	for _, trigger := range []struct {
+		name    string
+		enabled bool
+	}{
+		{"dbus", c.Triggers.DBus},
+		{"address_change", c.Triggers.AddressChange},
+		{"sleep", c.Triggers.Sleep},
+		{"log_tail", c.Triggers.LogTail.Enabled()},
+	} {
+		if trigger.enabled {
+			triggers = append(triggers, trigger.name)
+		}
+	}
+	add("triggers", "%s", orNone(strings.Join(triggers, ", ")))
+
+	if c.Server.Listen != "" {
+		add("server.listen", "%s (%d push token(s) %s, pprof %t)", c.Server.Listen, len(c.Server.PushTokens), redacted, c.Server.Pprof)
+	} else {
+		add("server.listen", "none")
+	}
+	if c.DynDNS.Listen != "" {
+		add("dyndns.listen", "%s (user %s, password %s)", c.DynDNS.Listen, c.DynDNS.Username, redacted)
+	}
+
+	switch c.ConfigSource {
+	case "git":
+		add("config_source", "git %s %s, every %s", redactURL(c.ConfigGit.Repository), c.ConfigGit.Path, c.GetSyncInterval())
+	case "url":
+		add("config_source", "url %s, every %s", redactURL(c.ConfigURL), c.GetSyncInterval())
+	default:
+		add("config_source", "local file only")
+	}
+
+	if len(c.MaintenanceWindows) > 0 {
+		var names []string
+		for i, window := range c.MaintenanceWindows {
+			names = append(names, withDefault(window.Name, fmt.Sprintf("#%d", i+1)))
+		}
+		add("maintenance_windows", "%s", strings.Join(names, ", "))
+	}
+	if c.Canary != nil {
+		add("canary", "%s (verify_dns %t, timeout %s)", c.Canary.Record, c.Canary.VerifyDNS, c.Canary.GetTimeout())
+	}
+	if c.Rollout != nil {
+		add("rollout", "%s (delay %s, verify_dns %t, timeout %s)",
+			strings.Join(c.Rollout.Groups, " -> "), c.Rollout.GetDelay(), c.Rollout.VerifyDNS, c.Rollout.GetTimeout())
+	}
+	for _, webhook := range c.Notifications.Webhooks {
+		// Webhook URLs often carry a secret in their path
+		add("notifications.webhook", "%s %s (%s)", webhook.GetMethod(), urlHost(webhook.URL), strings.Join(subscribed(webhook.Events), ", "))
+	}
+	for _, chat := range c.Notifications.chats() {
+		add("notifications."+chat.name, "%s (%s)", urlHost(chat.config.WebhookURL), strings.Join(subscribed(chat.config.Events), ", "))
+	}
+	if t := c.Notifications.Telegram; t != nil {
+		add("notifications.telegram", "chat %s, bot token %s (%s)", t.ChatID, redacted, strings.Join(subscribed(t.Events), ", "))
+	}
+	if c.Alerting() {
+		budget := c.ErrorBudgetFor("")
+		var steps []string
+		for _, step := range c.EscalationSteps() {
+			steps = append(steps, fmt.Sprintf("%s after %s", step.Action, step.GetAfter()))
+		}
+		add("notifications.error_budget", "%d failure(s) in %s, then %s", budget.Failures, budget.GetWindow(), strings.Join(steps, ", "))
+	}
+	if digest := c.Notifications.Digest; digest == DigestCycle {
+		add("notifications.digest", "changes batched per cycle")
+	} else if digest != "" {
+		add("notifications.digest", "changes batched over %s", c.Notifications.DigestWindow())
+	}
+
+	for _, record := range c.Records {
+		lines = append(lines, "record: "+record.describe())
+	}
+	return lines
+}
+
+// tokenSource describes where the default token came from without revealing it
+func (c CloudflareConfig) tokenSource() string {
+	return describeToken(c.APIToken, c.APITokenEnv, c.APITokenFile)
+}
+
+// describeToken describes where a token came from without revealing it
+func describeToken(token, env, file string) string {
+	switch {
+	case env != "":
+		return fmt.Sprintf("%s from environment variable %s", redacted, env)
+	case file != "":
+		return fmt.Sprintf("%s from file %s", redacted, file)
+	case token != "":
+		return redacted + " set inline"
+	}
+	return "none"
+}
+
+// describe summarizes the detection sources
+func (d IPDetectionConfig) describe() string {
+	var s string
+	if len(d.Sources) > 0 {
+		var sources []string
+		for _, source := range d.Sources {
+			weight := source.Weight
+			if weight <= 0 {
+				weight = 1
+			}
+			sources = append(sources, fmt.Sprintf("%s (weight %d)", source.Type, weight))
+		}
+		s = fmt.Sprintf("%s, quorum %s", strings.Join(sources, ", "), withDefault(d.Quorum, "first-success"))
+	} else {
+		s = withDefault(d.Source, "http")
+	}
+	if n := len(d.IPv4URLs) + len(d.IPv6URLs); n > 0 {
+		if d.ReplaceBuiltinURLs {
+			s += fmt.Sprintf(", %d custom service(s) replacing the built-in ones", n)
+		} else {
+			s += fmt.Sprintf(", %d custom service(s) before the built-in ones", n)
+		}
+	}
+	if d.DoHURL != "" {
+		s += ", DoH via " + urlHost(d.DoHURL)
+		if len(d.DoHBootstrap) > 0 {
+			s += " at " + strings.Join(d.DoHBootstrap, ", ")
+		}
+	}
+	if d.HTTP3 {
+		s += ", HTTP/3"
+	}
+	if d.Interface != "" {
+		s += ", interface " + d.Interface
+	}
+	if d.PreferDNSWhenMetered {
+		s += ", dns when metered"
+	}
+	if d.FollowDefaultRoute {
+		s += ", follows default route"
+	}
+	if d.SkipOnCGNAT {
+		s += ", skips A records behind CGNAT"
+	}
+	return s
+}
+
+// describe summarizes a record's managed settings
+func (r DNSRecord) describe() string {
+	parts := []string{fmt.Sprintf("%s %s", r.Name, strings.Join(r.Types, ","))}
+	if r.Zone != "" {
+		parts = append(parts, "zone "+r.Zone)
+	}
+	if r.ZoneID != "" {
+		parts = append(parts, "zone_id "+r.ZoneID)
+	}
+	if r.Account != "" {
+		parts = append(parts, "account "+r.Account)
+	}
+	if r.Proxied {
+		parts = append(parts, "proxied")
+	} else {
+		parts = append(parts, fmt.Sprintf("ttl %d", r.TTL))
+	}
+	if len(r.Preserve) > 0 {
+		parts = append(parts, "preserves "+strings.Join(r.Preserve, ","))
+	}
+	if r.Group != "" {
+		parts = append(parts, "group "+r.Group)
+	}
+	if r.ErrorBudget != nil {
+		parts = append(parts, fmt.Sprintf("error budget %d in %s", r.ErrorBudget.Failures, r.ErrorBudget.GetWindow()))
+	}
+	switch {
+	case r.Push:
+		parts = append(parts, "pushed")
+	case r.Follow != "":
+		parts = append(parts, "follows "+r.Follow)
+	case r.SPF != nil:
+		parts = append(parts, "spf")
+	case r.ContentTemplate != "":
+		parts = append(parts, "template")
+	}
+	return strings.Join(parts, ", ")
+}
+
+// withDefault returns value, or def marked as a default if value is empty
+func withDefault(value, def string) string {
+	if value == "" {
+		return def + " (default)"
+	}
+	return value
+}
+
+// subscribed returns the events a notification channel receives
+func subscribed(events []string) []string {
+	if len(events) == 0 {
+		return []string{EventChange, EventFailure}
+	}
+	return events
+}
+
+// positive formats n, or returns "" if it is not set
+func positive(n int) string {
+	if n <= 0 {
+		return ""
+	}
+	return fmt.Sprint(n)
+}
+
+// orNone returns value, or "none" if it is empty
+func orNone(value string) string {
+	if value == "" {
+		return "none"
+	}
+	return value
+}
+
+// redactURL removes credentials embedded in a URL
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.User == nil {
+		return raw
+	}
+	u.User = url.User("redacted")
+	return u.String()
+}
+
+// urlHost returns only the scheme and host of a URL
+func urlHost(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return redacted
+	}
+	return u.Scheme + "://" + u.Host + "/" + redacted
+}
@@ -0,0 +1,129 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/MrLonely14/cf-ddns/cloudflare"
+)
+
+// minRecommendedInterval is the check interval below which detection services
+// are likely to rate limit the daemon
+const minRecommendedInterval = time.Minute
+
+// Lint looks for risky but valid settings and returns human-readable warnings.
+// path is the file the configuration was loaded from, used for permission checks.
+func (c *Config) Lint(path string) []string {
+	var warnings []string
+
+	for _, key := range c.unknownKeys {
+		warnings = append(warnings, fmt.Sprintf("unknown option %q is ignored (prefix keys that only hold YAML anchors with %q)", key, extensionPrefix))
+	}
+
+	if interval := c.GetCheckInterval(); interval < minRecommendedInterval {
+		warnings = append(warnings, fmt.Sprintf("check_interval %s is very low; IP detection services may rate limit you (recommended: at least %s)", interval, minRecommendedInterval))
+	}
+
+	if runtime.GOOS != "windows" && !c.encrypted && (c.Cloudflare.inlineToken() || len(c.Cloudflare.ZoneTokens) > 0) {
+		if info, err := os.Stat(path); err == nil && info.Mode().Perm()&0004 != 0 {
+			warnings = append(warnings, fmt.Sprintf("%s contains the API token and is world-readable; restrict it with: chmod 600 %s", path, path))
+		}
+	}
+	if file := c.Cloudflare.APITokenFile; runtime.GOOS != "windows" && file != "" {
+		if info, err := os.Stat(file); err == nil && info.Mode().Perm()&0004 != 0 {
+			warnings = append(warnings, fmt.Sprintf("cloudflare.api_token_file %s is world-readable; restrict it with: chmod 600 %s", file, file))
+		}
+	}
+
+	groups := make(map[string]bool)
+	for _, record := range c.Records {
+		groups[record.Group] = true
+	}
+	for i, window := range c.MaintenanceWindows {
+		for _, group := range window.Groups {
+			if !groups[group] {
+				warnings = append(warnings, fmt.Sprintf("maintenance_windows %d applies to group %s, which no record is in", i, group))
+			}
+		}
+	}
+	if c.Rollout != nil {
+		for _, group := range c.Rollout.Groups {
+			if !groups[group] {
+				warnings = append(warnings, fmt.Sprintf("rollout.groups lists group %s, which no record is in", group))
+			}
+		}
+		if wait := c.Rollout.GetDelay() * time.Duration(len(c.Rollout.Groups)); wait >= c.EffectiveCheckInterval() {
+			warnings = append(warnings, fmt.Sprintf("rollout delays can add up to %s, which is not shorter than the check interval", wait))
+		}
+	}
+
+	for _, service := range append(c.IPDetection.IPv4URLs, c.IPDetection.IPv6URLs...) {
+		if strings.HasPrefix(service.URL, "http://") {
+			warnings = append(warnings, fmt.Sprintf("detection service %s uses plain http, so its answers can be altered on the way", service.URL))
+		}
+	}
+
+	used := make(map[string]bool)
+	for _, record := range c.Records {
+		used[record.ZoneID] = true
+		used["account:"+record.Account] = true
+	}
+	for zoneID := range c.Cloudflare.ZoneTokens {
+		if !used[zoneID] {
+			warnings = append(warnings, fmt.Sprintf("cloudflare.zone_tokens has a token for zone %s, which no record uses", zoneID))
+		}
+	}
+	for _, account := range c.Cloudflare.Accounts {
+		if !used["account:"+account.Name] {
+			warnings = append(warnings, fmt.Sprintf("cloudflare.accounts has an account %s, which no record uses", account.Name))
+		}
+	}
+
+	seen := make(map[string]int)
+	for i, record := range c.Records {
+		// Unless the proxy setting is left to the dashboard, the TTL never applies
+		if record.Proxied && record.TTL != 0 && record.TTL != AutoTTL && !record.Preserves(PreserveProxied) {
+			warnings = append(warnings, fmt.Sprintf("record %d (%s): ttl %d is ignored because Cloudflare uses automatic TTL for proxied records; leave it out", i, record.Name, record.TTL))
+		}
+
+		if record.Proxied && strings.HasPrefix(record.Name, "*.") {
+			warnings = append(warnings, fmt.Sprintf("record %d (%s): proxied wildcard routes every otherwise-undefined subdomain through Cloudflare", i, record.Name))
+		}
+
+		if record.SPF != nil && record.SPF.lookups() > spfLookupLimit {
+			warnings = append(warnings, fmt.Sprintf("record %d (%s): spf.mechanisms need %d DNS lookups; receivers reject policies with more than %d", i, record.Name, record.SPF.lookups(), spfLookupLimit))
+		}
+
+		zone := record.ZoneID
+		if zone == "" {
+			zone = cloudflare.NormalizeName(record.Zone)
+		}
+		for _, recordType := range record.Types {
+			key := fmt.Sprintf("%s:%s:%s", zone, cloudflare.NormalizeName(record.Name), recordType)
+			if first, ok := seen[key]; ok {
+				warnings = append(warnings, fmt.Sprintf("record %d (%s %s) duplicates record %d; both will update the same DNS record", i, record.Name, recordType, first))
+				continue
+			}
+			seen[key] = i
+		}
+	}
+
+	pushed := make(map[string]bool)
+	for _, record := range c.Records {
+		if record.Push {
+			pushed[cloudflare.NormalizeName(record.Name)] = true
+		}
+	}
+	for i, token := range c.Server.PushTokens {
+		for _, name := range token.Records {
+			if !pushed[cloudflare.NormalizeName(name)] {
+				warnings = append(warnings, fmt.Sprintf("server.push_tokens %d allows %s, which is not a record with push: true", i, name))
+			}
+		}
+	}
+
+	return warnings
+}
@@ -0,0 +1,141 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// MaintenanceWindow is a recurring time range during which DNS changes to
+// some records are withheld
+type MaintenanceWindow struct {
+	Name     string   `yaml:"name"`
+	Timezone string   `yaml:"timezone"` // IANA name, e.g. Europe/Berlin (default: local time)
+	Days     []string `yaml:"days"`     // mon..sun the window starts on (default: every day)
+	Start    string   `yaml:"start"`    // HH:MM
+	End      string   `yaml:"end"`      // HH:MM; before start for windows that span midnight
+	Groups   []string `yaml:"groups"`   // record groups it applies to (default: all records)
+}
+
+// weekdays maps the accepted day names
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// validate checks the window's settings
+func (w *MaintenanceWindow) validate() error {
+	if w.Timezone != "" {
+		if _, err := time.LoadLocation(w.Timezone); err != nil {
+			return fmt.Errorf("invalid timezone %s: %w", w.Timezone, err)
+		}
+	}
+	for _, day := range w.Days {
+		if _, ok := weekdays[strings.ToLower(day)]; !ok {
+			return fmt.Errorf("invalid day %s (must be mon, tue, wed, thu, fri, sat or sun)", day)
+		}
+	}
+	start, err := parseClock(w.Start)
+	if err != nil {
+		return fmt.Errorf("invalid start: %w", err)
+	}
+	end, err := parseClock(w.End)
+	if err != nil {
+		return fmt.Errorf("invalid end: %w", err)
+	}
+	if start == end {
+		return fmt.Errorf("start and end are the same")
+	}
+	return nil
+}
+
+// parseClock parses HH:MM into the time since midnight
+func parseClock(value string) (time.Duration, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not HH:MM", value)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// AppliesTo reports whether the window covers records of the given group
+func (w *MaintenanceWindow) AppliesTo(group string) bool {
+	if len(w.Groups) == 0 {
+		return true
+	}
+	for _, g := range w.Groups {
+		if g == group {
+			return true
+		}
+	}
+	return false
+}
+
+// ActiveUntil returns when the window ends if now is within it, or the zero
+// time if it isn't. The window must be valid.
+func (w *MaintenanceWindow) ActiveUntil(now time.Time) time.Time {
+	loc := time.Local
+	if w.Timezone != "" {
+		loc, _ = time.LoadLocation(w.Timezone)
+	}
+	start, _ := parseClock(w.Start)
+	end, _ := parseClock(w.End)
+	if end < start {
+		end += 24 * time.Hour // ends the next day
+	}
+
+	// A window that spans midnight may have started the day before
+	now = now.In(loc)
+	for _, daysAgo := range []int{0, 1} {
+		y, m, d := now.AddDate(0, 0, -daysAgo).Date()
+		midnight := time.Date(y, m, d, 0, 0, 0, 0, loc)
+		if !w.onDay(midnight.Weekday()) {
+			continue
+		}
+		from, until := clockTime(midnight, start), clockTime(midnight, end)
+		if !now.Before(from) && now.Before(until) {
+			return until
+		}
+	}
+	return time.Time{}
+}
+
+// clockTime returns the wall clock time offset from midnight, so windows keep
+// their local times across daylight saving changes
+func clockTime(midnight time.Time, offset time.Duration) time.Time {
+	days := int(offset / (24 * time.Hour))
+	offset -= time.Duration(days) * 24 * time.Hour
+	y, m, d := midnight.Date()
+	return time.Date(y, m, d+days, int(offset/time.Hour), int(offset%time.Hour/time.Minute), 0, 0, midnight.Location())
+}
+
+// onDay reports whether the window starts on the given weekday
+func (w *MaintenanceWindow) onDay(day time.Weekday) bool {
+	if len(w.Days) == 0 {
+		return true
+	}
+	for _, name := range w.Days {
+		if weekdays[strings.ToLower(name)] == day {
+			return true
+		}
+	}
+	return false
+}
+
+// MaintenanceUntil returns the latest end of the maintenance windows that
+// currently withhold changes to record, and the window's name, or the zero
+// time if none is active
+func (c *Config) MaintenanceUntil(record DNSRecord, now time.Time) (time.Time, string) {
+	var until time.Time
+	var name string
+	for i := range c.MaintenanceWindows {
+		w := &c.MaintenanceWindows[i]
+		if !w.AppliesTo(record.Group) {
+			continue
+		}
+		if end := w.ActiveUntil(now); end.After(until) {
+			until, name = end, w.Name
+		}
+	}
+	return until, name
+}
@@ -0,0 +1,322 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/MrLonely14/cf-ddns/cloudflare"
+)
+
+// Notification events
+const (
+	EventChange  = "change"  // a record was created or updated
+	EventFailure = "failure" // a record could not be updated
+	EventCycle   = "cycle"   // an update cycle finished
+	EventPage    = "page"    // a failing record reached the page escalation step
+)
+
+// Escalation actions
+const (
+	ActionLog    = "log"    // log a warning
+	ActionNotify = "notify" // send a failure event
+	ActionPage   = "page"   // send a page event
+)
+
+// defaultBudgetWindow is the period failures are counted over
+const defaultBudgetWindow = 30 * time.Minute
+
+// DigestCycle batches change events until the update cycle finishes
+const DigestCycle = "cycle"
+
+// defaultWebhookTimeout bounds a single webhook request
+const defaultWebhookTimeout = 10 * time.Second
+
+// NotificationsConfig lists where events are sent and when failures are
+// worth alerting on
+type NotificationsConfig struct {
+	Webhooks    []WebhookConfig    `yaml:"webhooks"`
+	Discord     *ChatConfig        `yaml:"discord"`      // Discord webhook
+	Slack       *ChatConfig        `yaml:"slack"`        // Slack incoming webhook
+	Telegram    *TelegramConfig    `yaml:"telegram"`     // Telegram bot
+	ErrorBudget *ErrorBudgetConfig `yaml:"error_budget"` // failures tolerated before alerting; records may override it
+	Escalation  []EscalationStep   `yaml:"escalation"`   // actions taken while a record stays over its budget
+	Digest      string             `yaml:"digest"`       // batch change events per cycle, or over a period such as 10m
+}
+
+// DigestWindow returns the period change events are batched over, or 0 if
+// they are batched until the cycle finishes
+func (n NotificationsConfig) DigestWindow() time.Duration {
+	d, _ := time.ParseDuration(n.Digest)
+	return d
+}
+
+// validateDigest checks the digest setting
+func (n NotificationsConfig) validateDigest() error {
+	if n.Digest == "" || n.Digest == DigestCycle {
+		return nil
+	}
+	if d, err := time.ParseDuration(n.Digest); err != nil || d <= 0 {
+		return fmt.Errorf("invalid digest %s (must be cycle or a duration such as 10m)", n.Digest)
+	}
+	return nil
+}
+
+// ChatConfig posts events as messages to a Discord or Slack webhook
+type ChatConfig struct {
+	WebhookURL string   `yaml:"webhook_url"`
+	Events     []string `yaml:"events"` // change, failure, cycle and page (default change and failure)
+}
+
+// TelegramConfig sends events as messages from a Telegram bot
+type TelegramConfig struct {
+	BotToken string   `yaml:"bot_token"`
+	ChatID   string   `yaml:"chat_id"` // numeric chat ID or @channelname
+	Events   []string `yaml:"events"`  // change, failure, cycle and page (default change and failure)
+}
+
+// Wants reports whether the channel receives an event
+func (c ChatConfig) Wants(event string) bool {
+	return wantsEvent(c.Events, event)
+}
+
+// Wants reports whether the bot receives an event
+func (t TelegramConfig) Wants(event string) bool {
+	return wantsEvent(t.Events, event)
+}
+
+// chatChannel is a configured Discord or Slack channel
+type chatChannel struct {
+	name   string
+	config *ChatConfig
+}
+
+// chats returns the configured Discord and Slack channels
+func (n NotificationsConfig) chats() []chatChannel {
+	var chats []chatChannel
+	for _, chat := range []chatChannel{{"discord", n.Discord}, {"slack", n.Slack}} {
+		if chat.config != nil {
+			chats = append(chats, chat)
+		}
+	}
+	return chats
+}
+
+// validate checks a Discord or Slack channel
+func (c ChatConfig) validate() error {
+	u, err := url.Parse(c.WebhookURL)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return fmt.Errorf("invalid webhook_url (must be an https URL)")
+	}
+	return validateEvents(c.Events)
+}
+
+// validate checks the bot settings
+func (t TelegramConfig) validate() error {
+	if t.BotToken == "" {
+		return fmt.Errorf("bot_token is required")
+	}
+	if t.ChatID == "" {
+		return fmt.Errorf("chat_id is required")
+	}
+	return validateEvents(t.Events)
+}
+
+// ErrorBudgetConfig tolerates a number of failures of a record within a window
+type ErrorBudgetConfig struct {
+	Failures int    `yaml:"failures"` // alert once a record fails more often than this
+	Window   string `yaml:"window"`   // period the failures are counted over (default 30m)
+}
+
+// EscalationStep is an action taken once a record has been over its error
+// budget for a while
+type EscalationStep struct {
+	After  string `yaml:"after"`  // time since the budget was exceeded (default 0)
+	Action string `yaml:"action"` // log, notify or page
+}
+
+// GetWindow returns the period failures are counted over
+func (b ErrorBudgetConfig) GetWindow() time.Duration {
+	if d, err := time.ParseDuration(b.Window); err == nil && d > 0 {
+		return d
+	}
+	return defaultBudgetWindow
+}
+
+// GetAfter returns the time since the budget was exceeded before the step is taken
+func (s EscalationStep) GetAfter() time.Duration {
+	d, _ := time.ParseDuration(s.After)
+	return d
+}
+
+// Alerting reports whether failures are subject to error budgets and
+// escalation rather than notified one by one
+func (c *Config) Alerting() bool {
+	if c.Notifications.ErrorBudget != nil || len(c.Notifications.Escalation) > 0 {
+		return true
+	}
+	for _, record := range c.Records {
+		if record.ErrorBudget != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// ErrorBudgetFor returns the error budget of the named record: its own, the
+// notifications default, or none, which alerts on the first failure
+func (c *Config) ErrorBudgetFor(name string) ErrorBudgetConfig {
+	for _, record := range c.Records {
+		if record.ErrorBudget != nil && cloudflare.NormalizeName(record.Name) == cloudflare.NormalizeName(name) {
+			return *record.ErrorBudget
+		}
+	}
+	if c.Notifications.ErrorBudget != nil {
+		return *c.Notifications.ErrorBudget
+	}
+	return ErrorBudgetConfig{}
+}
+
+// EscalationSteps returns the escalation steps, defaulting to a single
+// notification once the budget is exceeded
+func (c *Config) EscalationSteps() []EscalationStep {
+	if len(c.Notifications.Escalation) == 0 {
+		return []EscalationStep{{Action: ActionNotify}}
+	}
+	return c.Notifications.Escalation
+}
+
+// validate checks an error budget
+func (b ErrorBudgetConfig) validate() error {
+	if b.Failures < 0 {
+		return fmt.Errorf("failures must not be negative")
+	}
+	if b.Window != "" {
+		if d, err := time.ParseDuration(b.Window); err != nil || d <= 0 {
+			return fmt.Errorf("invalid window %s", b.Window)
+		}
+	}
+	return nil
+}
+
+// validateEscalation checks that the steps are valid and in order
+func validateEscalation(steps []EscalationStep) error {
+	var previous time.Duration
+	for i, step := range steps {
+		switch step.Action {
+		case ActionLog, ActionNotify, ActionPage:
+		default:
+			return fmt.Errorf("step %d: invalid action %s (must be log, notify or page)", i, step.Action)
+		}
+		after, err := time.ParseDuration(step.After)
+		if step.After == "" {
+			after, err = 0, nil
+		}
+		if err != nil || after < 0 {
+			return fmt.Errorf("step %d: invalid after %s", i, step.After)
+		}
+		if after < previous {
+			return fmt.Errorf("step %d: steps must be listed in order of after", i)
+		}
+		previous = after
+	}
+	return nil
+}
+
+// WebhookConfig sends events as HTTP requests
+type WebhookConfig struct {
+	URL     string            `yaml:"url"`
+	Method  string            `yaml:"method"`  // default POST
+	Headers map[string]string `yaml:"headers"` // e.g. Authorization or Content-Type
+	Payload string            `yaml:"payload"` // Go template of the request body (default: the event as JSON)
+	Events  []string          `yaml:"events"`  // change, failure, cycle and page (default change and failure)
+	Timeout string            `yaml:"timeout"` // default 10s
+}
+
+// GetMethod returns the HTTP method with the default applied
+func (w WebhookConfig) GetMethod() string {
+	if w.Method == "" {
+		return http.MethodPost
+	}
+	return w.Method
+}
+
+// GetTimeout returns how long a request may take
+func (w WebhookConfig) GetTimeout() time.Duration {
+	if d, err := time.ParseDuration(w.Timeout); err == nil && d > 0 {
+		return d
+	}
+	return defaultWebhookTimeout
+}
+
+// Wants reports whether the webhook receives an event
+func (w WebhookConfig) Wants(event string) bool {
+	return wantsEvent(w.Events, event)
+}
+
+// wantsEvent reports whether a list of subscribed events includes an event,
+// defaulting to changes and failures
+func wantsEvent(events []string, event string) bool {
+	if len(events) == 0 {
+		return event == EventChange || event == EventFailure
+	}
+	return slices.Contains(events, event)
+}
+
+// validateEvents checks a list of subscribed events
+func validateEvents(events []string) error {
+	for _, event := range events {
+		if event != EventChange && event != EventFailure && event != EventCycle && event != EventPage {
+			return fmt.Errorf("invalid event %s (must be change, failure, cycle or page)", event)
+		}
+	}
+	return nil
+}
+
+// PayloadTemplate parses the payload template, or returns nil if the event
+// is sent as JSON. Templates can quote values with the json function, e.g.
+// {"text": {{json .Record}}}.
+func (w WebhookConfig) PayloadTemplate() (*template.Template, error) {
+	if w.Payload == "" {
+		return nil, nil
+	}
+	return template.New("payload").Funcs(template.FuncMap{
+		"json": func(v any) (string, error) {
+			var b strings.Builder
+			enc := json.NewEncoder(&b)
+			enc.SetEscapeHTML(false)
+			err := enc.Encode(v)
+			return strings.TrimSuffix(b.String(), "\n"), err
+		},
+	}).Parse(w.Payload)
+}
+
+// validate checks the webhook's settings
+func (w WebhookConfig) validate() error {
+	u, err := url.Parse(w.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid url %s (must be an http or https URL)", w.URL)
+	}
+	switch w.GetMethod() {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodGet:
+	default:
+		return fmt.Errorf("invalid method %s (must be POST, PUT, PATCH or GET)", w.Method)
+	}
+	if err := validateEvents(w.Events); err != nil {
+		return err
+	}
+	if _, err := w.PayloadTemplate(); err != nil {
+		return fmt.Errorf("invalid payload: %w", err)
+	}
+	if w.Timeout != "" {
+		if d, err := time.ParseDuration(w.Timeout); err != nil || d <= 0 {
+			return fmt.Errorf("invalid timeout %s", w.Timeout)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// Rollout defaults
+const (
+	defaultRolloutDelay   = time.Minute
+	defaultRolloutTimeout = 30 * time.Second
+)
+
+// RolloutConfig rolls address changes out one record group at a time,
+// verifying each group before the next one is updated
+type RolloutConfig struct {
+	Groups    []string `yaml:"groups"`     // order of the groups; records of other groups are updated last
+	Delay     string   `yaml:"delay"`      // wait after a group whose records changed (default 1m)
+	VerifyDNS bool     `yaml:"verify_dns"` // also wait until the zone's nameservers return the new addresses
+	Timeout   string   `yaml:"timeout"`    // how long verification of a group may take (default 30s)
+}
+
+// validate checks the rollout's settings
+func (r *RolloutConfig) validate() error {
+	if len(r.Groups) == 0 {
+		return fmt.Errorf("groups is required")
+	}
+	seen := make(map[string]bool)
+	for _, group := range r.Groups {
+		if group == "" {
+			return fmt.Errorf("groups must not be empty names")
+		}
+		if seen[group] {
+			return fmt.Errorf("group %s is listed twice", group)
+		}
+		seen[group] = true
+	}
+	if r.Delay != "" {
+		if d, err := time.ParseDuration(r.Delay); err != nil || d < 0 {
+			return fmt.Errorf("invalid delay %s", r.Delay)
+		}
+	}
+	if r.Timeout != "" {
+		if d, err := time.ParseDuration(r.Timeout); err != nil || d <= 0 {
+			return fmt.Errorf("invalid timeout %s", r.Timeout)
+		}
+	}
+	return nil
+}
+
+// GetDelay returns the wait between groups
+func (r RolloutConfig) GetDelay() time.Duration {
+	if d, err := time.ParseDuration(r.Delay); err == nil && d >= 0 {
+		return d
+	}
+	return defaultRolloutDelay
+}
+
+// GetTimeout returns how long verification of a group may take
+func (r RolloutConfig) GetTimeout() time.Duration {
+	if d, err := time.ParseDuration(r.Timeout); err == nil && d > 0 {
+		return d
+	}
+	return defaultRolloutTimeout
+}
+
+// Stage returns the position of a group in the rollout. Groups that aren't
+// listed share the last stage.
+func (r RolloutConfig) Stage(group string) int {
+	for i, g := range r.Groups {
+		if g == group {
+			return i
+		}
+	}
+	return len(r.Groups)
+}
@@ -0,0 +1,118 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/MrLonely14/cf-ddns/i18n"
+)
+
+// schemaEnums lists the allowed values of enumerated options, keyed by YAML path
+var schemaEnums = map[string][]string{
+	"language":                        i18n.Languages,
+	"startup_update":                  {StartupUpdateAlways, StartupUpdateIfChanged, StartupUpdateNever},
+	"config_source":                   {"git", "url"},
+	"records.types":                   {"A", "AAAA", "TXT"},
+	"records.preserve":                {PreserveTTL, PreserveProxied},
+	"records.spf.all":                 {"-", "~", "?"},
+	"ip_detection.source":             {"http", "trace", "dns", "snmp", "fritzbox", "interface"},
+	"ip_detection.sources.type":       {"http", "trace", "dns", "snmp", "fritzbox", "interface"},
+	"ip_detection.quorum":             {"first-success", "majority", "all-agree"},
+	"ip_detection.snmp.version":       {"2c", "3"},
+	"ip_detection.snmp.auth_protocol": {"MD5", "SHA"},
+	"ip_detection.snmp.priv_protocol": {"DES", "AES"},
+	"notifications.webhooks.method":   {"POST", "PUT", "PATCH", "GET"},
+	"notifications.webhooks.events":   {EventChange, EventFailure, EventCycle, EventPage},
+	"notifications.discord.events":    {EventChange, EventFailure, EventCycle, EventPage},
+	"notifications.slack.events":      {EventChange, EventFailure, EventCycle, EventPage},
+	"notifications.telegram.events":   {EventChange, EventFailure, EventCycle, EventPage},
+	"notifications.escalation.action": {ActionLog, ActionNotify, ActionPage},
+}
+
+// schemaRequired lists the required options of each object, keyed by YAML path
+var schemaRequired = map[string][]string{
+	"":        {"check_interval", "records"},
+	"records": {"name", "types"},
+	"canary":  {"record"},
+
+	"cloudflare.accounts": {"name"},
+
+	"ip_detection.ipv4_urls":   {"url"},
+	"ip_detection.ipv6_urls":   {"url"},
+	"notifications.webhooks":   {"url"},
+	"notifications.escalation": {"action"},
+	"notifications.discord":    {"webhook_url"},
+	"notifications.slack":      {"webhook_url"},
+	"notifications.telegram":   {"bot_token", "chat_id"},
+}
+
+// Schema returns a JSON Schema describing the configuration file, generated
+// from the Config struct
+func Schema() map[string]interface{} {
+	schema := schemaFor(reflect.TypeOf(Config{}), "")
+	schema["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	schema["title"] = "cf-ddns configuration"
+	schema["patternProperties"] = map[string]interface{}{
+		"^" + extensionPrefix: map[string]interface{}{},
+	}
+	return schema
+}
+
+// schemaFor builds the schema of a single type at the given YAML path
+func schemaFor(t reflect.Type, path string) map[string]interface{} {
+	switch t.Kind() {
+	case reflect.Ptr:
+		return schemaFor(t.Elem(), path)
+	case reflect.Struct:
+		properties := make(map[string]interface{})
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			name := strings.Split(field.Tag.Get("yaml"), ",")[0]
+			if name == "" || name == "-" || !field.IsExported() {
+				continue
+			}
+			properties[name] = schemaFor(field.Type, joinPath(path, name))
+		}
+
+		schema := map[string]interface{}{
+			"type":                 "object",
+			"properties":           properties,
+			"additionalProperties": false,
+		}
+		if required, ok := schemaRequired[path]; ok {
+			schema["required"] = required
+		}
+		return schema
+	case reflect.Slice:
+		return map[string]interface{}{
+			"type":  "array",
+			"items": schemaFor(t.Elem(), path),
+		}
+	case reflect.Map:
+		return map[string]interface{}{
+			"type":                 "object",
+			"additionalProperties": schemaFor(t.Elem(), path),
+		}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	default:
+		schema := map[string]interface{}{"type": "string"}
+		if enum, ok := schemaEnums[path]; ok {
+			schema["enum"] = enum
+		}
+		return schema
+	}
+}
+
+// joinPath appends a key to a dotted YAML path
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
@@ -0,0 +1,79 @@
+package config
+
+import (
+	"net/url"
+	"strings"
+)
+
+// defaultSNMPCommunity is well known and not worth hiding
+const defaultSNMPCommunity = "public"
+
+// Secrets returns every credential in the configuration, so they can be
+// removed from logs and error messages
+func (c *Config) Secrets() []string {
+	var secrets []string
+	add := func(values ...string) {
+		for _, value := range values {
+			if value != "" {
+				secrets = append(secrets, value)
+			}
+		}
+	}
+
+	add(c.Cloudflare.APIToken)
+	for _, token := range c.Cloudflare.ZoneTokens {
+		add(token)
+	}
+	for _, account := range c.Cloudflare.Accounts {
+		add(account.APIToken)
+	}
+	for _, value := range c.ProviderOptions {
+		add(value)
+	}
+	for _, token := range c.Server.PushTokens {
+		add(token.Token)
+	}
+	add(c.DynDNS.Password)
+
+	snmp := c.IPDetection.SNMP
+	if snmp.Community != defaultSNMPCommunity {
+		add(snmp.Community)
+	}
+	add(snmp.AuthPassword, snmp.PrivPassword)
+
+	add(urlPassword(c.ConfigURL), urlPassword(c.ConfigGit.Repository))
+	for _, webhook := range c.Notifications.Webhooks {
+		// Webhook URLs commonly embed their secret in the path
+		add(webhook.URL, urlPassword(webhook.URL))
+		for name, value := range webhook.Headers {
+			if strings.EqualFold(name, "Content-Type") {
+				continue
+			}
+			add(value)
+			// "Bearer <token>" and similar schemes
+			if _, credential, ok := strings.Cut(value, " "); ok {
+				add(credential)
+			}
+		}
+	}
+	for _, chat := range c.Notifications.chats() {
+		add(chat.config.WebhookURL)
+	}
+	if c.Notifications.Telegram != nil {
+		add(c.Notifications.Telegram.BotToken)
+	}
+	return secrets
+}
+
+// urlPassword returns the credentials embedded in a URL: the password, or the
+// user name alone as used for tokens, e.g. https://<token>@github.com/...
+func urlPassword(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.User == nil {
+		return ""
+	}
+	if password, ok := u.User.Password(); ok {
+		return password
+	}
+	return u.User.Username()
+}
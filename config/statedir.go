@@ -0,0 +1,70 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// legacyStateFiles are the files earlier versions kept next to the
+// configuration file. Installs that have them keep using that directory.
+var legacyStateFiles = []string{"state.json", "history.jsonl"}
+
+// StateDir returns the directory the state, address history and detection
+// statistics of a configuration file are kept in: override if set, else the
+// state_dir of the local configuration file, else DefaultStateDir
+func StateDir(configPath, override string) string {
+	if override != "" {
+		return override
+	}
+	if cfg, err := loadLocal(configPath); err == nil && cfg.StateDir != "" {
+		return cfg.StateDir
+	}
+	return DefaultStateDir(configPath)
+}
+
+// DefaultStateDir returns the platform's directory for service data:
+// /var/lib/cf-ddns for root on Linux, or the StateDirectory systemd provides,
+// ~/.local/state/cf-ddns for other users, ~/Library/Application
+// Support/cf-ddns on macOS and %ProgramData%\cf-ddns on Windows. State an
+// earlier version left next to the configuration file stays there, as it
+// does on OpenWrt, whose /var is a RAM disk.
+func DefaultStateDir(configPath string) string {
+	configDir := filepath.Dir(configPath)
+	for _, name := range legacyStateFiles {
+		if _, err := os.Stat(filepath.Join(configDir, name)); err == nil {
+			return configDir
+		}
+	}
+
+	switch runtime.GOOS {
+	case "windows":
+		if dir := os.Getenv("ProgramData"); dir != "" {
+			return filepath.Join(dir, "cf-ddns")
+		}
+	case "darwin":
+		if dir, err := os.UserConfigDir(); err == nil {
+			return filepath.Join(dir, "cf-ddns")
+		}
+	default:
+		// Set by systemd for units with StateDirectory=
+		if dirs := os.Getenv("STATE_DIRECTORY"); dirs != "" {
+			dir, _, _ := strings.Cut(dirs, ":")
+			return dir
+		}
+		if _, err := os.Stat("/etc/openwrt_release"); err == nil {
+			return configDir
+		}
+		if os.Geteuid() == 0 {
+			return "/var/lib/cf-ddns"
+		}
+		if dir := os.Getenv("XDG_STATE_HOME"); dir != "" {
+			return filepath.Join(dir, "cf-ddns")
+		}
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, ".local", "state", "cf-ddns")
+		}
+	}
+	return configDir
+}
@@ -0,0 +1,86 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// tokenSources counts the options that set the default API token
+func (c CloudflareConfig) tokenSources() int {
+	return countTokenSources(c.APIToken, c.APITokenEnv, c.APITokenFile)
+}
+
+// countTokenSources counts the options of a token that are set
+func countTokenSources(sources ...string) int {
+	count := 0
+	for _, source := range sources {
+		if source != "" {
+			count++
+		}
+	}
+	return count
+}
+
+// inlineToken reports whether the default token or an account's token is
+// written in the configuration itself rather than read from the environment
+// or a file
+func (c CloudflareConfig) inlineToken() bool {
+	if c.APIToken != "" && c.APITokenEnv == "" && c.APITokenFile == "" {
+		return true
+	}
+	for _, account := range c.Accounts {
+		if account.APIToken != "" && account.APITokenEnv == "" && account.APITokenFile == "" {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveToken reads the default token and the tokens of the accounts from
+// the environment variable or file the configuration names, if any. Exactly
+// one source may be set for each.
+func (c *CloudflareConfig) resolveToken() error {
+	if c.tokenSources() > 1 {
+		return fmt.Errorf("set only one of cloudflare.api_token, cloudflare.api_token_env and cloudflare.api_token_file")
+	}
+	if err := readToken("cloudflare", &c.APIToken, c.APITokenEnv, c.APITokenFile); err != nil {
+		return err
+	}
+
+	for i := range c.Accounts {
+		account := &c.Accounts[i]
+		option := fmt.Sprintf("cloudflare.accounts[%s]", account.Name)
+		if countTokenSources(account.APIToken, account.APITokenEnv, account.APITokenFile) != 1 {
+			return fmt.Errorf("%s: set exactly one of api_token, api_token_env and api_token_file", option)
+		}
+		if err := readToken(option, &account.APIToken, account.APITokenEnv, account.APITokenFile); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readToken sets token from the environment variable env or the file, if
+// either is named. option prefixes the names of the options in errors.
+func readToken(option string, token *string, env, file string) error {
+	switch {
+	case env != "":
+		value := strings.TrimSpace(os.Getenv(env))
+		if value == "" {
+			return fmt.Errorf("environment variable %s named by %s.api_token_env is not set", env, option)
+		}
+		*token = value
+	case file != "":
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("failed to read %s.api_token_file: %w", option, err)
+		}
+		value := strings.TrimSpace(string(data))
+		if value == "" {
+			return fmt.Errorf("%s.api_token_file %s is empty", option, file)
+		}
+		*token = value
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package configsync
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/MrLonely14/cf-ddns/config"
+	"github.com/MrLonely14/cf-ddns/signing"
+)
+
+// RepoDirName is the bare repository that pulled commits are fetched into,
+// kept next to the config file
+const RepoDirName = "config-repo.git"
+
+// gitSource pulls a configuration file from a branch of a git repository.
+// Only the latest commit is fetched, into a bare repository, so the checked
+// out files of the repository are never needed.
+type gitSource struct {
+	cfg    config.ConfigGitConfig
+	dir    string
+	signed bool // also read <path>.sig from the commit
+}
+
+// newGitSource creates a source fetching into dir
+func newGitSource(cfg config.ConfigGitConfig, dir string, signed bool) (*gitSource, error) {
+	if _, err := exec.LookPath("git"); err != nil {
+		return nil, fmt.Errorf("git not found: %w", err)
+	}
+	return &gitSource{cfg: cfg, dir: dir, signed: signed}, nil
+}
+
+// Name identifies the source in logs
+func (g *gitSource) Name() string {
+	return g.cfg.Repository
+}
+
+// Fetch pulls the latest commit of the branch and returns the configuration
+// file in it, with the commit hash as its version
+func (g *gitSource) Fetch(ctx context.Context) (*File, error) {
+	if _, err := os.Stat(filepath.Join(g.dir, "HEAD")); os.IsNotExist(err) {
+		if _, err := g.git(ctx, "init", "--bare", "--quiet", g.dir); err != nil {
+			return nil, err
+		}
+	}
+
+	ref := g.cfg.Branch
+	if ref == "" {
+		ref = "HEAD"
+	}
+	if _, err := g.git(ctx, "-C", g.dir, "fetch", "--quiet", "--depth", "1", "--no-tags", g.cfg.Repository, ref); err != nil {
+		return nil, err
+	}
+	out, err := g.git(ctx, "-C", g.dir, "rev-parse", "FETCH_HEAD")
+	if err != nil {
+		return nil, err
+	}
+	commit := strings.TrimSpace(string(out))
+
+	if g.cfg.VerifyCommits {
+		if _, err := g.git(ctx, "-C", g.dir, "verify-commit", commit); err != nil {
+			return nil, fmt.Errorf("commit %s has no valid signature: %w", shortHash(commit), err)
+		}
+	}
+
+	data, err := g.git(ctx, "-C", g.dir, "show", commit+":"+g.cfg.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	if g.cfg.Checksum {
+		sum, err := g.git(ctx, "-C", g.dir, "show", commit+":"+g.cfg.Path+".sha256")
+		if err != nil {
+			return nil, err
+		}
+		if err := verifyChecksum(data, sum); err != nil {
+			return nil, fmt.Errorf("%s in commit %s: %w", g.cfg.Path, shortHash(commit), err)
+		}
+	}
+
+	file := &File{Data: data, Version: shortHash(commit)}
+	if g.signed {
+		if file.Signature, err = g.git(ctx, "-C", g.dir, "show", commit+":"+g.cfg.Path+signing.Ext); err != nil {
+			return nil, err
+		}
+	}
+	return file, nil
+}
+
+// git runs a git command and returns its output, including stderr in errors
+func (g *gitSource) git(ctx context.Context, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	// Never wait for credentials on a terminal
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		command := args[0]
+		if command == "-C" {
+			command = args[2]
+		}
+		return nil, fmt.Errorf("git %s failed: %w: %s", command, err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
+
+// verifyChecksum compares data with a sha256sum-style checksum file
+func verifyChecksum(data, sum []byte) error {
+	fields := strings.Fields(string(sum))
+	if len(fields) == 0 {
+		return fmt.Errorf("checksum file is empty")
+	}
+	digest := sha256.Sum256(data)
+	if !strings.EqualFold(fields[0], hex.EncodeToString(digest[:])) {
+		return fmt.Errorf("checksum mismatch")
+	}
+	return nil
+}
+
+// shortHash abbreviates a commit hash for logs
+func shortHash(commit string) string {
+	if len(commit) > 12 {
+		return commit[:12]
+	}
+	return commit
+}
@@ -0,0 +1,152 @@
+// Package configsync keeps a local copy of configuration pulled from a remote
+// source, such as a git repository shared by many sites
+package configsync
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/MrLonely14/cf-ddns/config"
+	"github.com/MrLonely14/cf-ddns/signing"
+)
+
+// File is a configuration file fetched from a source
+type File struct {
+	Data      []byte
+	Signature []byte // detached signature, only fetched when signatures are required
+	Version   string // label for logs
+}
+
+// Source fetches a configuration file from a remote location
+type Source interface {
+	// Name identifies the source in logs
+	Name() string
+	// Fetch returns the current file
+	Fetch(ctx context.Context) (*File, error)
+}
+
+// Syncer writes the configuration pulled from a source to the synced copy
+// that config.Load layers over the local file
+type Syncer struct {
+	configPath string
+	source     Source
+	interval   time.Duration
+	key        ed25519.PublicKey // required signer, or nil
+}
+
+// New creates a syncer for the config source set in cfg. With a key, only
+// files carrying a valid detached signature by it are applied.
+func New(configPath string, cfg *config.Config, key ed25519.PublicKey) (*Syncer, error) {
+	signed := key != nil
+	var source Source
+	var err error
+	switch cfg.ConfigSource {
+	case "git":
+		source, err = newGitSource(cfg.ConfigGit, filepath.Join(filepath.Dir(configPath), RepoDirName), signed)
+	case "url":
+		source = newURLSource(cfg.ConfigURL, signed)
+	default:
+		return nil, fmt.Errorf("unknown config source: %s", cfg.ConfigSource)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &Syncer{
+		configPath: configPath,
+		source:     source,
+		interval:   cfg.GetSyncInterval(),
+		key:        key,
+	}, nil
+}
+
+// Sync pulls the configuration once and, if it differs from the synced copy
+// and is valid together with the local file, replaces the copy. It reports
+// whether the copy changed.
+func (s *Syncer) Sync(ctx context.Context) (bool, error) {
+	file, err := s.source.Fetch(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to pull configuration from %s: %w", s.source.Name(), err)
+	}
+
+	path := config.SyncedPath(s.configPath)
+	if current, err := os.ReadFile(path); err == nil && bytes.Equal(current, file.Data) {
+		return false, nil
+	}
+
+	// Never apply a configuration the daemon would refuse to start with
+	if s.key != nil {
+		if err := signing.Verify(file.Data, file.Signature, s.key); err != nil {
+			return false, fmt.Errorf("rejected configuration %s from %s: %w", file.Version, s.source.Name(), err)
+		}
+	}
+	if _, err := config.LoadSynced(s.configPath, file.Data); err != nil {
+		return false, fmt.Errorf("rejected configuration %s from %s: %w", file.Version, s.source.Name(), err)
+	}
+
+	// The signature is kept so the synced copy can be verified again at startup
+	if s.key != nil {
+		if err := writeFile(path+signing.Ext, file.Signature); err != nil {
+			return false, err
+		}
+	}
+	if err := writeFile(path, file.Data); err != nil {
+		return false, err
+	}
+
+	log.Printf("Pulled configuration %s from %s", file.Version, s.source.Name())
+	return true, nil
+}
+
+// writeFile replaces a file atomically
+func writeFile(path string, data []byte) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write synced config file: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to write synced config file: %w", err)
+	}
+	return nil
+}
+
+// Watch pulls the configuration every interval until ctx is cancelled, and
+// signals on the returned channel whenever a new configuration was synced
+func (s *Syncer) Watch(ctx context.Context) <-chan struct{} {
+	changes := make(chan struct{}, 1)
+
+	go func() {
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			changed, err := s.Sync(ctx)
+			if err != nil {
+				if ctx.Err() == nil {
+					log.Printf("Warning: %v", err)
+				}
+				continue
+			}
+			if changed {
+				select {
+				case changes <- struct{}{}:
+				default:
+				}
+			}
+		}
+	}()
+
+	return changes
+}
@@ -0,0 +1,119 @@
+package configsync
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/MrLonely14/cf-ddns/signing"
+)
+
+// maxConfigSize caps the size of a configuration file downloaded from a URL
+const maxConfigSize = 1 << 20
+
+// urlSource polls a configuration file over HTTPS. Conditional requests with
+// the last ETag or Last-Modified date make unchanged polls cheap.
+type urlSource struct {
+	url    string
+	client *http.Client
+	signed bool // also download <url>.sig
+
+	last         *File // last download
+	etag         string
+	lastModified string
+}
+
+// newURLSource creates a source polling url
+func newURLSource(url string, signed bool) *urlSource {
+	return &urlSource{
+		url:    url,
+		client: &http.Client{Timeout: 30 * time.Second},
+		signed: signed,
+	}
+}
+
+// Name identifies the source in logs
+func (u *urlSource) Name() string {
+	return u.url
+}
+
+// Fetch downloads the configuration file, or returns the previous download if
+// the server reports that it hasn't changed
+func (u *urlSource) Fetch(ctx context.Context) (*File, error) {
+	headers := http.Header{}
+	if u.last != nil {
+		if u.etag != "" {
+			headers.Set("If-None-Match", u.etag)
+		}
+		if u.lastModified != "" {
+			headers.Set("If-Modified-Since", u.lastModified)
+		}
+	}
+
+	resp, data, err := u.get(ctx, u.url, headers)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotModified {
+		if u.last == nil {
+			return nil, fmt.Errorf("server returned 304 for an unconditional request")
+		}
+		return u.last, nil
+	}
+
+	file := &File{Data: data, Version: resp.Header.Get("ETag")}
+	if file.Version == "" {
+		digest := sha256.Sum256(data)
+		file.Version = "sha256:" + hex.EncodeToString(digest[:6])
+	}
+	if u.signed {
+		// Fetched whenever the file changed, so the pair always matches
+		if _, file.Signature, err = u.get(ctx, u.url+signing.Ext, nil); err != nil {
+			return nil, fmt.Errorf("failed to download signature: %w", err)
+		}
+	}
+
+	u.last = file
+	u.etag = resp.Header.Get("ETag")
+	u.lastModified = resp.Header.Get("Last-Modified")
+	return file, nil
+}
+
+// get downloads a URL with the given request headers. A 304 response is
+// returned without a body; other statuses than 200 are errors.
+func (u *urlSource) get(ctx context.Context, url string, headers http.Header) (*http.Response, []byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	for key, values := range headers {
+		req.Header[key] = values
+	}
+
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		return resp, nil, nil
+	case http.StatusOK:
+	default:
+		return nil, nil, fmt.Errorf("server returned status %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxConfigSize+1))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if len(data) > maxConfigSize {
+		return nil, nil, fmt.Errorf("file is larger than %d bytes", maxConfigSize)
+	}
+	return resp, data, nil
+}
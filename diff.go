@@ -0,0 +1,261 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/MrLonely14/cf-ddns/audit"
+	"github.com/MrLonely14/cf-ddns/config"
+	"github.com/MrLonely14/cf-ddns/ipdetect"
+	"github.com/MrLonely14/cf-ddns/logging"
+	"github.com/MrLonely14/cf-ddns/provider"
+	"github.com/MrLonely14/cf-ddns/term"
+	"github.com/MrLonely14/cf-ddns/updater"
+)
+
+func diffCommand(args []string) {
+	diffCmd := flag.NewFlagSet("diff", flag.ExitOnError)
+	configPath := diffCmd.String("config", "config.yaml", "Path to configuration file")
+	output := diffCmd.String("output", "text", "Output format: text or json")
+	diffCmd.Parse(args)
+
+	if *output != "text" && *output != "json" {
+		log.Fatalf("Invalid -output %s (must be text or json)", *output)
+	}
+	os.Exit(showDiff(*configPath, *output))
+}
+
+func applyCommand(args []string) {
+	applyCmd := flag.NewFlagSet("apply", flag.ExitOnError)
+	configPath := applyCmd.String("config", "config.yaml", "Path to configuration file")
+	yes := applyCmd.Bool("yes", false, "Apply without asking for confirmation")
+	applyCmd.Parse(args)
+
+	applyDiff(*configPath, *yes)
+}
+
+// showDiff prints how Cloudflare differs from the configuration and the
+// detected addresses. It returns the exit status: 0 if everything matches, 1
+// if records differ, 2 if some could not be compared.
+func showDiff(configPath, output string) int {
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+	logging.SetSecrets(cfg.Secrets())
+	applyProcessSettings(cfg)
+	dnsProvider, err := provider.New(cfg)
+	if err != nil {
+		log.Fatalf("Failed to create DNS provider: %v", err)
+	}
+	if err := resolveZoneNames(context.Background(), cfg, dnsProvider); err != nil {
+		log.Fatalf("Failed to resolve zones: %v", err)
+	}
+	detector, err := ipdetect.NewDetector(cfg.IPDetection)
+	if err != nil {
+		log.Fatalf("Failed to create IP detector: %v", err)
+	}
+
+	diffs := updater.NewUpdater(cfg, dnsProvider, detector).Diff(context.Background())
+	status := diffStatus(diffs)
+
+	if output == "json" {
+		if diffs == nil {
+			diffs = []updater.RecordDiff{}
+		}
+		data, err := json.MarshalIndent(diffs, "", "  ")
+		if err != nil {
+			log.Fatalf("Failed to encode diff: %v", err)
+		}
+		fmt.Println(string(data))
+		return status
+	}
+
+	printDiff(diffs)
+	return status
+}
+
+// diffStatus returns the exit status for a diff: 0 if everything matches, 1
+// if records differ, 2 if some could not be compared
+func diffStatus(diffs []updater.RecordDiff) int {
+	status := 0
+	for _, diff := range diffs {
+		switch diff.Action {
+		case updater.DiffError:
+			status = 2
+		case updater.DiffCreate, updater.DiffUpdate:
+			if status == 0 {
+				status = 1
+			}
+		}
+	}
+	return status
+}
+
+// applyDiff shows the diff, asks for confirmation unless yes is set, and
+// writes the records that differ
+func applyDiff(configPath string, yes bool) {
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+	logging.SetSecrets(cfg.Secrets())
+	applyProcessSettings(cfg)
+	if cfg.Observing() {
+		log.Fatalf("The configuration sets mode: observe, which never writes to Cloudflare")
+	}
+	if cfg.DryRun {
+		log.Fatalf("The configuration sets dry_run; use diff to preview changes")
+	}
+	if cfg.Frozen() {
+		log.Fatalf("Changes are frozen while %s exists", cfg.FreezeFile)
+	}
+	dnsProvider, err := provider.New(cfg)
+	if err != nil {
+		log.Fatalf("Failed to create DNS provider: %v", err)
+	}
+	if err := resolveZoneNames(context.Background(), cfg, dnsProvider); err != nil {
+		log.Fatalf("Failed to resolve zones: %v", err)
+	}
+	detector, err := ipdetect.NewDetector(cfg.IPDetection)
+	if err != nil {
+		log.Fatalf("Failed to create IP detector: %v", err)
+	}
+
+	upd := updater.NewUpdater(cfg, dnsProvider, detector)
+	if cfg.AuditLog != "" {
+		auditLog, err := audit.Open(cfg.AuditLog)
+		if err != nil {
+			log.Fatalf("Failed to open audit log: %v", err)
+		}
+		upd.SetAuditLog(auditLog)
+	}
+	notifier, err := newNotifier(cfg)
+	if err != nil {
+		log.Fatalf("Failed to set up notifications: %v", err)
+	}
+	upd.SetNotifier(notifier)
+
+	ctx := context.Background()
+	diffs := upd.Diff(ctx)
+	printDiff(diffs)
+
+	// Records that could not be compared are left alone, but still fail the run
+	incomplete := diffStatus(diffs) == 2
+	changes := 0
+	for _, diff := range diffs {
+		if diff.Action == updater.DiffCreate || diff.Action == updater.DiffUpdate {
+			changes++
+		}
+	}
+	if changes == 0 {
+		fmt.Println("Nothing to apply")
+		if incomplete {
+			os.Exit(1)
+		}
+		return
+	}
+
+	if !yes {
+		if !term.Interactive() {
+			log.Fatalf("Not applying without confirmation; pass -yes to apply non-interactively")
+		}
+		fmt.Printf("\nApply %d change(s)? [y/N] ", changes)
+		answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		if answer = strings.ToLower(strings.TrimSpace(answer)); answer != "y" && answer != "yes" {
+			fmt.Println("Cancelled")
+			os.Exit(1)
+		}
+	}
+
+	summary, err := upd.Apply(ctx, diffs)
+	notifier.Wait()
+	fmt.Printf("Applied: %s\n", summary)
+	if err != nil || incomplete {
+		os.Exit(1)
+	}
+}
+
+// printDiff prints one line per record, grouped by zone
+func printDiff(diffs []updater.RecordDiff) {
+	counts := make(map[string]int)
+	table := term.NewTable(os.Stdout)
+	zone := ""
+	for _, diff := range diffs {
+		if diff.ZoneID != zone {
+			if zone != "" {
+				table.Flush()
+				fmt.Println()
+			}
+			zone = diff.ZoneID
+			fmt.Println(term.Bold("Zone " + zone))
+		}
+		counts[diff.Action]++
+
+		label := fmt.Sprintf("%s (%s)", diff.Name, diff.Type)
+		switch diff.Action {
+		case updater.DiffNone:
+			fmt.Fprintf(table, "  %s\t%s\n", term.OK(label), term.Dim(describeValues(diff.Current)))
+		case updater.DiffCreate:
+			fmt.Fprintf(table, "  %s\t%s\n", term.Warn(label), "create "+describeValues(diff.Desired))
+		case updater.DiffUpdate:
+			fmt.Fprintf(table, "  %s\t%s\n", term.Warn(label), describeChange(diff.Current, diff.Desired))
+		case updater.DiffPushed:
+			current := "no record yet"
+			if diff.Current != nil {
+				current = describeValues(diff.Current)
+			}
+			fmt.Fprintf(table, "  %s %s\t%s\n", term.Dim("-"), label, term.Dim("pushed by clients, currently "+current))
+		case updater.DiffSkipped:
+			fmt.Fprintf(table, "  %s %s\t%s\n", term.Dim("-"), label, term.Dim("skipped, "+diff.Error))
+		case updater.DiffError:
+			fmt.Fprintf(table, "  %s\t%s\n", term.Fail(label), term.Dim(diff.Error))
+		}
+		// The API request, so that reviewers can audit exactly what is written
+		if diff.Operation != nil {
+			fmt.Fprintf(table, "  \t%s\n", term.Dim(diff.Operation.String()))
+		}
+	}
+	table.Flush()
+
+	fmt.Printf("\n%d to update, %d to create, %d unchanged", counts[updater.DiffUpdate], counts[updater.DiffCreate], counts[updater.DiffNone])
+	if n := counts[updater.DiffSkipped]; n > 0 {
+		fmt.Printf(", %d skipped", n)
+	}
+	if n := counts[updater.DiffError]; n > 0 {
+		fmt.Printf(", %d could not be compared", n)
+	}
+	fmt.Println()
+}
+
+// describeValues formats a record's managed fields
+func describeValues(v *updater.RecordValues) string {
+	if v.Proxied {
+		return v.Content + " (proxied)"
+	}
+	return fmt.Sprintf("%s (ttl %d)", v.Content, v.TTL)
+}
+
+// describeChange lists the fields an update would change
+func describeChange(current, desired *updater.RecordValues) string {
+	var changes []string
+	if normalized, err := ipdetect.NormalizeIP(current.Content); err != nil || normalized != desired.Content {
+		changes = append(changes, current.Content+" -> "+desired.Content)
+	}
+	if current.Proxied != desired.Proxied {
+		changes = append(changes, fmt.Sprintf("proxied %t -> %t", current.Proxied, desired.Proxied))
+	}
+	if !desired.Proxied && current.TTL != desired.TTL {
+		changes = append(changes, fmt.Sprintf("ttl %d -> %d", current.TTL, desired.TTL))
+	}
+	if desired.Comment != "" && current.Comment != desired.Comment {
+		changes = append(changes, fmt.Sprintf("comment %q -> %q", current.Comment, desired.Comment))
+	}
+	return strings.Join(changes, ", ")
+}
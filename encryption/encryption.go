@@ -0,0 +1,139 @@
+// Package encryption encrypts configuration files at rest with AES-256-GCM
+package encryption
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// header starts every encrypted file, followed by the base64-encoded nonce and
+// ciphertext
+const header = "cf-ddns-encrypted:v1\n"
+
+// Key sources, checked in this order
+const (
+	KeyEnv         = "CF_DDNS_CONFIG_KEY"      // base64-encoded key
+	KeyFileEnv     = "CF_DDNS_CONFIG_KEY_FILE" // path of a key file
+	CredentialName = "cf-ddns-config-key"      // systemd credential, see LoadCredential=
+)
+
+// keySize is the AES-256 key length
+const keySize = 32
+
+// ErrNoKey is returned when an encrypted file is read without a key configured
+var ErrNoKey = fmt.Errorf("no decryption key; set %s or %s, or pass the systemd credential %s", KeyEnv, KeyFileEnv, CredentialName)
+
+// ErrWrongKey is returned when a file can't be decrypted with the key
+var ErrWrongKey = errors.New("decryption failed; wrong key or modified file")
+
+// IsEncrypted reports whether data is an encrypted file
+func IsEncrypted(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(header))
+}
+
+// Encrypt seals data with key
+func Encrypt(data, key []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	sealed := gcm.Seal(nonce, nonce, data, []byte(header))
+	return []byte(header + base64.StdEncoding.EncodeToString(sealed) + "\n"), nil
+}
+
+// Decrypt opens an encrypted file with key
+func Decrypt(data, key []byte) ([]byte, error) {
+	if !IsEncrypted(data) {
+		return nil, fmt.Errorf("not an encrypted file")
+	}
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data[len(header):])))
+	if err != nil {
+		return nil, fmt.Errorf("malformed encrypted file: %w", err)
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return nil, fmt.Errorf("malformed encrypted file")
+	}
+	nonce, ciphertext := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	plain, err := gcm.Open(nil, nonce, ciphertext, []byte(header))
+	if err != nil {
+		return nil, ErrWrongKey
+	}
+	return plain, nil
+}
+
+// newGCM creates the AEAD cipher for key
+func newGCM(key []byte) (cipher.AEAD, error) {
+	if len(key) != keySize {
+		return nil, fmt.Errorf("key must be %d bytes, got %d", keySize, len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// GenerateKey returns a new random key, base64-encoded as stored in key files
+func GenerateKey() ([]byte, error) {
+	key := make([]byte, keySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	return []byte(base64.StdEncoding.EncodeToString(key) + "\n"), nil
+}
+
+// LoadKey reads the key from the environment, a key file named by the
+// environment, or the systemd credentials directory
+func LoadKey() ([]byte, error) {
+	if encoded := os.Getenv(KeyEnv); encoded != "" {
+		return parseKey([]byte(encoded))
+	}
+	if path := os.Getenv(KeyFileEnv); path != "" {
+		return ReadKeyFile(path)
+	}
+	if dir := os.Getenv("CREDENTIALS_DIRECTORY"); dir != "" {
+		path := filepath.Join(dir, CredentialName)
+		if _, err := os.Stat(path); err == nil {
+			return ReadKeyFile(path)
+		}
+	}
+	return nil, ErrNoKey
+}
+
+// ReadKeyFile reads a base64-encoded key file
+func ReadKeyFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file: %w", err)
+	}
+	key, err := parseKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return key, nil
+}
+
+// parseKey decodes a base64-encoded key
+func parseKey(encoded []byte) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(encoded)))
+	if err != nil || len(key) != keySize {
+		return nil, fmt.Errorf("malformed key; expected %d base64-encoded bytes", keySize)
+	}
+	return key, nil
+}
@@ -0,0 +1,52 @@
+package fake
+
+import (
+	"context"
+	"net/netip"
+	"sync"
+)
+
+// ChurnSource is an IP detection source whose addresses change on demand,
+// walking through the benchmarking range 198.18.0.0/15 and the documentation
+// prefix 2001:db8::/32
+type ChurnSource struct {
+	mu   sync.Mutex
+	ipv4 netip.Addr
+	ipv6 netip.Addr
+}
+
+// NewChurnSource creates a source at the first synthetic addresses
+func NewChurnSource() *ChurnSource {
+	return &ChurnSource{
+		ipv4: netip.MustParseAddr("198.18.0.1"),
+		ipv6: netip.MustParseAddr("2001:db8::1"),
+	}
+}
+
+// Change moves both families to the next address
+func (s *ChurnSource) Change() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.ipv4 = s.ipv4.Next()
+	if !s.ipv4.IsValid() || s.ipv4.As4()[1] > 19 {
+		s.ipv4 = netip.MustParseAddr("198.18.0.1")
+	}
+	s.ipv6 = s.ipv6.Next()
+}
+
+// Name identifies the source in logs
+func (s *ChurnSource) Name() string {
+	return "churn"
+}
+
+// GetIP returns the current synthetic address
+func (s *ChurnSource) GetIP(ctx context.Context, isIPv6 bool) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if isIPv6 {
+		return s.ipv6.String(), nil
+	}
+	return s.ipv4.String(), nil
+}
@@ -0,0 +1,173 @@
+package fake
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/MrLonely14/cf-ddns/cloudflare"
+)
+
+// Provider is an in-memory stand-in for the Cloudflare API. It accepts every
+// write and remembers the resulting records, so update logic can be
+// exercised without touching live DNS.
+type Provider struct {
+	mu      sync.Mutex
+	records map[string]*cloudflare.DNSRecordInfo // key: "zoneID:name:type:kind"
+	changes []*cloudflare.RecordChange
+	nextID  int
+
+	latency   time.Duration // added to every call
+	errorRate float64       // fraction of writes that fail
+}
+
+// NewProvider creates an empty fake provider
+func NewProvider() *Provider {
+	return &Provider{records: make(map[string]*cloudflare.DNSRecordInfo)}
+}
+
+// ErrInjected is returned by writes that fail because of SetFaults
+var ErrInjected = errors.New("injected fault")
+
+// SetFaults makes every call take latency and a fraction errorRate of writes
+// fail, to exercise timing and retry behaviour
+func (p *Provider) SetFaults(latency time.Duration, errorRate float64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.latency = latency
+	p.errorRate = errorRate
+}
+
+// delay waits for the configured latency, or until ctx is cancelled
+func (p *Provider) delay(ctx context.Context) error {
+	p.mu.Lock()
+	latency := p.latency
+	p.mu.Unlock()
+	if latency == 0 {
+		return nil
+	}
+
+	select {
+	case <-time.After(latency):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func recordKey(zoneID, name, recordType, kind string) string {
+	return fmt.Sprintf("%s:%s:%s:%s", zoneID, cloudflare.NormalizeName(name), recordType, kind)
+}
+
+// GetDNSRecord returns a copy of a stored record
+func (p *Provider) GetDNSRecord(ctx context.Context, zoneID, name, recordType, kind string) (*cloudflare.DNSRecordInfo, error) {
+	if err := p.delay(ctx); err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	record, ok := p.records[recordKey(zoneID, name, recordType, kind)]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s (%s)", cloudflare.ErrRecordNotFound, cloudflare.NormalizeName(name), recordType)
+	}
+	copied := *record
+	return &copied, nil
+}
+
+// ListDNSRecords returns copies of every stored record in a zone
+func (p *Provider) ListDNSRecords(ctx context.Context, zoneID string) ([]*cloudflare.DNSRecordInfo, error) {
+	if err := p.delay(ctx); err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var records []*cloudflare.DNSRecordInfo
+	for _, record := range p.records {
+		if record.ZoneID == zoneID {
+			copied := *record
+			records = append(records, &copied)
+		}
+	}
+	return records, nil
+}
+
+// DeleteDNSRecord removes a stored record
+func (p *Provider) DeleteDNSRecord(ctx context.Context, record *cloudflare.DNSRecordInfo) (*cloudflare.RecordChange, error) {
+	if err := p.delay(ctx); err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for key, stored := range p.records {
+		if stored.ID == record.ID {
+			before := *stored
+			delete(p.records, key)
+			change := &cloudflare.RecordChange{Before: &before}
+			p.changes = append(p.changes, change)
+			return change, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", cloudflare.ErrRecordNotFound, record.ID)
+}
+
+// UpsertDNSRecord stores a record, creating it if it doesn't exist. An empty
+// comment keeps the current one.
+func (p *Provider) UpsertDNSRecord(ctx context.Context, zoneID, name, recordType, content string, ttl int, proxied bool, comment string) (*cloudflare.RecordChange, error) {
+	if err := p.delay(ctx); err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.errorRate > 0 && rand.Float64() < p.errorRate {
+		return nil, fmt.Errorf("%w: write of %s (%s)", ErrInjected, cloudflare.NormalizeName(name), recordType)
+	}
+
+	key := recordKey(zoneID, name, recordType, cloudflare.RecordKind(recordType, content))
+	change := &cloudflare.RecordChange{}
+
+	after := &cloudflare.DNSRecordInfo{
+		ZoneID:  zoneID,
+		Name:    cloudflare.NormalizeName(name),
+		Type:    recordType,
+		Content: content,
+		TTL:     ttl,
+		Proxied: proxied,
+		Comment: comment,
+	}
+	if existing, ok := p.records[key]; ok {
+		before := *existing
+		change.Before = &before
+		after.ID = existing.ID
+		if comment == "" {
+			after.Comment = existing.Comment
+		}
+	} else {
+		p.nextID++
+		after.ID = "fake-" + strconv.Itoa(p.nextID)
+	}
+	if proxied {
+		after.TTL = 1 // automatic, as Cloudflare reports for proxied records
+	}
+
+	p.records[key] = after
+	copied := *after
+	change.After = &copied
+	p.changes = append(p.changes, change)
+
+	return change, nil
+}
+
+// Changes returns every write made so far, in order
+func (p *Provider) Changes() []*cloudflare.RecordChange {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]*cloudflare.RecordChange(nil), p.changes...)
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/MrLonely14/cf-ddns/config"
+	"github.com/MrLonely14/cf-ddns/logging"
+)
+
+// stringList is a flag that may be given several times
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+func forceCommand(args []string) {
+	forceCmd := flag.NewFlagSet("force", flag.ExitOnError)
+	configPath := forceCmd.String("config", "/etc/cf-ddns/config.yaml", "Path to configuration file")
+	var records stringList
+	forceCmd.Var(&records, "record", "Only rewrite this record (repeatable)")
+	forceCmd.Parse(args)
+
+	requestForce(*configPath, records)
+}
+
+// requestForce asks the running daemon, through its control API, to re-detect
+// the IP and rewrite the given records (all if none are given)
+func requestForce(configPath string, records []string) {
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+	logging.SetSecrets(cfg.Secrets())
+	if cfg.Server.Listen == "" {
+		log.Fatalf("server.listen is not configured; enable it or send SIGUSR1 to the daemon to force all records")
+	}
+
+	query := url.Values{}
+	for _, record := range records {
+		query.Add("record", record)
+	}
+	endpoint := url.URL{
+		Scheme:   "http",
+		Host:     localAddress(cfg.Server.Listen),
+		Path:     "/api/v1/force",
+		RawQuery: query.Encode(),
+	}
+
+	client := &http.Client{Timeout: 5 * time.Minute}
+	resp, err := client.Post(endpoint.String(), "application/json", nil)
+	if err != nil {
+		log.Fatalf("Failed to reach the daemon: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		OK    bool   `json:"ok"`
+		Error string `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		log.Fatalf("Failed to read the daemon's response: %v", err)
+	}
+	if !result.OK {
+		fmt.Printf("Forced update failed: %s\n", result.Error)
+		os.Exit(1)
+	}
+	fmt.Println("Forced update completed")
+}
+
+// localAddress turns a listen address into one to connect to, replacing
+// wildcard hosts with the loopback address
+func localAddress(listen string) string {
+	host, port, err := net.SplitHostPort(listen)
+	if err != nil {
+		return listen
+	}
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = "127.0.0.1"
+	}
+	return net.JoinHostPort(host, port)
+}
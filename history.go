@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"github.com/MrLonely14/cf-ddns/config"
+	"github.com/MrLonely14/cf-ddns/history"
+)
+
+func historyCommand(args []string) {
+	if len(args) < 1 || args[0] != "export" {
+		fmt.Println("Usage: cf-ddns history export [-config path] [-state-dir path] [-format csv|json] [-since 30d] [-out path]")
+		os.Exit(1)
+	}
+
+	exportCmd := flag.NewFlagSet("history export", flag.ExitOnError)
+	configPath := exportCmd.String("config", "config.yaml", "Path to configuration file")
+	format := exportCmd.String("format", "csv", "Output format: csv or json")
+	since := exportCmd.String("since", "", "Only export changes since this age (e.g. 30d) or date (default: all)")
+	out := exportCmd.String("out", "", "Path to write the export to (default: standard output)")
+	stateDir := exportCmd.String("state-dir", "", "Directory for state, history and statistics (overrides state_dir)")
+	exportCmd.Parse(args[1:])
+
+	if *format != "csv" && *format != "json" {
+		log.Fatalf("Invalid -format %s (must be csv or json)", *format)
+	}
+	exportHistory(config.StateDir(*configPath, *stateDir), *format, *since, *out)
+}
+
+// exportHistory writes the recorded address changes as CSV or JSON
+func exportHistory(stateDir, format, since, outPath string) {
+	var start time.Time
+	if since != "" {
+		var err error
+		if start, err = history.ParseSince(since, time.Now()); err != nil {
+			log.Fatalf("Invalid -since: %v", err)
+		}
+	}
+
+	path := history.Path(stateDir)
+	entries, err := history.Read(path)
+	if os.IsNotExist(err) {
+		log.Fatalf("No address history at %s yet; it is recorded while the daemon runs", path)
+	}
+	if err != nil {
+		log.Fatalf("Failed to read address history: %v", err)
+	}
+
+	selected := []history.Entry{}
+	for _, e := range entries {
+		if !e.Time.Before(start) {
+			selected = append(selected, e)
+		}
+	}
+
+	var w io.Writer = os.Stdout
+	if outPath != "" {
+		f, err := os.Create(outPath)
+		if err != nil {
+			log.Fatalf("Failed to create %s: %v", outPath, err)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	if format == "json" {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(selected)
+	} else {
+		err = writeHistoryCSV(w, selected)
+	}
+	if err != nil {
+		log.Fatalf("Failed to write export: %v", err)
+	}
+	if outPath != "" {
+		log.Printf("Exported %d change(s) to %s", len(selected), outPath)
+	}
+}
+
+// writeHistoryCSV writes entries with a header row, for spreadsheets
+func writeHistoryCSV(w io.Writer, entries []history.Entry) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"time", "family", "previous", "ip", "source"})
+	for _, e := range entries {
+		cw.Write([]string{e.Time.Format(time.RFC3339), e.Family, e.Previous, e.IP, e.Source})
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,228 @@
+// Package history keeps a persistent record of the detected public addresses
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FileName is the name of the history file, kept in the state directory
+const FileName = "history.jsonl"
+
+// Path returns the history file location in a state directory
+func Path(stateDir string) string {
+	return filepath.Join(stateDir, FileName)
+}
+
+// StatsFileName is the name of the file with daily detection statistics,
+// kept next to the history file
+const StatsFileName = "detections.json"
+
+// StatsPath returns the detection statistics location in a state directory
+func StatsPath(stateDir string) string {
+	return filepath.Join(stateDir, StatsFileName)
+}
+
+// Address families
+const (
+	IPv4 = "ipv4"
+	IPv6 = "ipv6"
+)
+
+// Entry is a change of the detected address of one family. The first
+// detection of a family has no previous address.
+type Entry struct {
+	Time     time.Time `json:"time"`
+	Family   string    `json:"family"`
+	Previous string    `json:"previous,omitempty"`
+	IP       string    `json:"ip"`
+	Source   string    `json:"source"` // detection source that reported it
+}
+
+// Day is the detection statistics of one address family on one day (UTC).
+// Keeping totals per day rather than every detection keeps the file small.
+type Day struct {
+	Date     string        `json:"date"` // 2006-01-02
+	Family   string        `json:"family"`
+	Attempts int64         `json:"attempts"`
+	Failures int64         `json:"failures"`
+	Latency  time.Duration `json:"latency"` // total of the successful detections
+}
+
+// Log appends address changes to the history file and keeps the daily
+// detection statistics
+type Log struct {
+	path      string
+	statsPath string
+	mu        sync.Mutex
+	last      map[string]string // family -> last recorded address
+	days      []Day
+}
+
+// Open opens the history file, remembering the last address of each family
+// so that a restart doesn't record the same address again
+func Open(path string) (*Log, error) {
+	l := &Log{path: path, statsPath: StatsPath(path), last: make(map[string]string)}
+
+	entries, err := Read(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	for _, e := range entries {
+		l.last[e.Family] = e.IP
+	}
+
+	if l.days, err = ReadStats(l.statsPath); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Observed counts a detection and records the detected address if it differs
+// from the last one of its family
+func (l *Log) Observed(isIPv6 bool, ip, source string, took time.Duration, detectErr error) error {
+	family := IPv4
+	if isIPv6 {
+		family = IPv6
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	err := l.count(family, took, detectErr != nil)
+	if detectErr != nil {
+		return err
+	}
+	return errors.Join(err, l.record(family, ip, source))
+}
+
+// record appends an address change to the history file
+func (l *Log) record(family, ip, source string) error {
+	previous := l.last[family]
+	if previous == ip {
+		return nil
+	}
+
+	data, err := json.Marshal(Entry{Time: time.Now().UTC(), Family: family, Previous: previous, IP: ip, Source: source})
+	if err != nil {
+		return fmt.Errorf("failed to encode history entry: %w", err)
+	}
+	f, err := os.OpenFile(l.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open history file: %w", err)
+	}
+	defer f.Close()
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write history file: %w", err)
+	}
+
+	l.last[family] = ip
+	return nil
+}
+
+// count adds a detection to the statistics of its day and writes them
+func (l *Log) count(family string, took time.Duration, failed bool) error {
+	date := time.Now().UTC().Format(time.DateOnly)
+	var day *Day
+	for i := len(l.days) - 1; i >= 0 && l.days[i].Date == date; i-- {
+		if l.days[i].Family == family {
+			day = &l.days[i]
+		}
+	}
+	if day == nil {
+		l.days = append(l.days, Day{Date: date, Family: family})
+		day = &l.days[len(l.days)-1]
+	}
+
+	day.Attempts++
+	if failed {
+		day.Failures++
+	} else {
+		day.Latency += took
+	}
+
+	data, err := json.MarshalIndent(l.days, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode detection statistics: %w", err)
+	}
+	// Write to a temporary file first so a crash never leaves a partial file
+	tmpPath := l.statsPath + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0600); err != nil {
+		return fmt.Errorf("failed to write detection statistics: %w", err)
+	}
+	if err := os.Rename(tmpPath, l.statsPath); err != nil {
+		return fmt.Errorf("failed to replace detection statistics: %w", err)
+	}
+	return nil
+}
+
+// ReadStats returns the daily detection statistics, oldest first
+func ReadStats(path string) ([]Day, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var days []Day
+	if err := json.Unmarshal(data, &days); err != nil {
+		return nil, fmt.Errorf("failed to parse detection statistics: %w", err)
+	}
+	return days, nil
+}
+
+// Read returns the entries of a history file in the order they were recorded
+func Read(path string) ([]Entry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("history line %d: %w", line, err)
+		}
+		entries = append(entries, e)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read history file: %w", err)
+	}
+	return entries, nil
+}
+
+// ParseSince parses the start of a period: an age such as 30d, 2w or 12h
+// before now, or a date (2006-01-02) or RFC 3339 time
+func ParseSince(s string, now time.Time) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", s, time.Local); err == nil {
+		return t, nil
+	}
+
+	// Days and weeks aren't duration units, so they are converted first
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if number, ok := strings.CutSuffix(s, suffix); ok {
+			if n, err := strconv.Atoi(number); err == nil && n >= 0 {
+				return now.Add(-time.Duration(n) * unit), nil
+			}
+		}
+	}
+	if d, err := time.ParseDuration(s); err == nil && d >= 0 {
+		return now.Add(-d), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time %s (use an age such as 30d, 2w or 12h, or a date such as 2006-01-02)", s)
+}
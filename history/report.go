@@ -0,0 +1,106 @@
+package history
+
+import "time"
+
+// Report summarizes the stability of the detected addresses over a period
+type Report struct {
+	Since    time.Time      `json:"since"`
+	Until    time.Time      `json:"until"`
+	Families []FamilyReport `json:"families"`
+}
+
+// FamilyReport is the stability of the addresses of one family
+type FamilyReport struct {
+	Family         string        `json:"family"`
+	Changes        int           `json:"changes"`
+	ChangesPerWeek float64       `json:"changes_per_week"`
+	LongestStable  time.Duration `json:"longest_stable"`
+	StableFrom     time.Time     `json:"stable_from"`
+	StableUntil    time.Time     `json:"stable_until"`
+	Attempts       int64         `json:"detection_attempts"`
+	Failures       int64         `json:"detection_failures"`
+	ErrorRate      float64       `json:"detection_error_rate"` // failures per attempt
+	AverageLatency time.Duration `json:"average_detection_latency"`
+}
+
+// Summarize computes the report for the period from since until until. A
+// zero since starts the period at the oldest recorded data. Detection
+// statistics are counted per day, so the period is rounded to whole days
+// for them.
+func Summarize(entries []Entry, days []Day, since, until time.Time) Report {
+	if since.IsZero() {
+		since = until
+		if len(entries) > 0 && entries[0].Time.Before(since) {
+			since = entries[0].Time
+		}
+		if len(days) > 0 {
+			if t, err := time.Parse(time.DateOnly, days[0].Date); err == nil && t.Before(since) {
+				since = t
+			}
+		}
+	}
+
+	report := Report{Since: since, Until: until}
+	for _, family := range []string{IPv4, IPv6} {
+		f := summarizeFamily(family, entries, days, since, until)
+		if f.Attempts > 0 || f.LongestStable > 0 {
+			report.Families = append(report.Families, f)
+		}
+	}
+	return report
+}
+
+// summarizeFamily computes the report of one family
+func summarizeFamily(family string, entries []Entry, days []Day, since, until time.Time) FamilyReport {
+	f := FamilyReport{Family: family}
+
+	// Each change starts a stable period, which lasts until the next change
+	// or the end of the report. An address detected before the period
+	// starts it as well.
+	var start time.Time
+	known := false
+	stable := func(end time.Time) {
+		if known && end.Sub(start) > f.LongestStable {
+			f.LongestStable = end.Sub(start)
+			f.StableFrom, f.StableUntil = start, end
+		}
+	}
+	for _, e := range entries {
+		if e.Family != family || e.Time.After(until) {
+			continue
+		}
+		if e.Time.Before(since) {
+			start, known = since, true
+			continue
+		}
+		if e.Previous != "" {
+			f.Changes++
+		}
+		stable(e.Time)
+		start, known = e.Time, true
+	}
+	stable(until)
+
+	if weeks := until.Sub(since).Hours() / (7 * 24); weeks > 0 {
+		f.ChangesPerWeek = float64(f.Changes) / weeks
+	}
+
+	var latency time.Duration
+	first := since.UTC().Format(time.DateOnly)
+	last := until.UTC().Format(time.DateOnly)
+	for _, d := range days {
+		if d.Family != family || d.Date < first || d.Date > last {
+			continue
+		}
+		f.Attempts += d.Attempts
+		f.Failures += d.Failures
+		latency += d.Latency
+	}
+	if f.Attempts > 0 {
+		f.ErrorRate = float64(f.Failures) / float64(f.Attempts)
+	}
+	if succeeded := f.Attempts - f.Failures; succeeded > 0 {
+		f.AverageLatency = latency / time.Duration(succeeded)
+	}
+	return f
+}
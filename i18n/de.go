@@ -0,0 +1,98 @@
+package i18n
+
+// de holds the German translations
+var de = map[string]string{
+	// Usage
+	"Cloudflare Dynamic DNS Updater":                             "Cloudflare Dynamic-DNS-Updater",
+	"Usage:":                                                     "Verwendung:",
+	"Run the daemon (default)":                                   "Den Dienst ausführen (Standard)",
+	"Run a single update and exit, e.g. from cron":               "Einmal aktualisieren und beenden, z. B. aus cron",
+	"Install as system service":                                  "Als Systemdienst installieren",
+	"Build a Windows MSI or macOS pkg that installs the service": "Windows-MSI oder macOS-pkg bauen, das den Dienst installiert",
+	"Uninstall system service":                                   "Systemdienst deinstallieren",
+	"Check service status and statistics":                        "Dienststatus und Statistiken anzeigen",
+	"Print the JSON Schema of the configuration file":            "JSON-Schema der Konfigurationsdatei ausgeben",
+	"Create a key pair for signing configuration files":          "Schlüsselpaar zum Signieren von Konfigurationsdateien erzeugen",
+	"Write a detached signature for a configuration file":        "Separate Signatur für eine Konfigurationsdatei schreiben",
+	"Encrypt configuration files at rest":                        "Konfigurationsdateien verschlüsselt speichern",
+	"Print the contents of an encrypted configuration file":      "Inhalt einer verschlüsselten Konfigurationsdatei ausgeben",
+	"Check the configuration file, optionally against the API":   "Konfigurationsdatei prüfen, optional gegen die API",
+	"Compare the token's access with what the config needs":      "Rechte des Tokens mit dem Bedarf der Konfiguration vergleichen",
+	"Check the hash chain of the audit log":                      "Hash-Kette des Audit-Logs prüfen",
+	"Save managed records to a snapshot file":                    "Verwaltete Einträge in einer Snapshot-Datei sichern",
+	"Re-apply managed records from a snapshot file":              "Verwaltete Einträge aus einer Snapshot-Datei wiederherstellen",
+	"Show how Cloudflare differs from the configuration":         "Abweichungen zwischen Cloudflare und der Konfiguration zeigen",
+	"Show the diff and write the records that differ":            "Abweichungen zeigen und abweichende Einträge schreiben",
+	"Ask the running daemon to rewrite records":                  "Laufenden Dienst Einträge neu schreiben lassen",
+	"Replay recorded IP changes against a fake provider":         "Aufgezeichnete IP-Wechsel gegen einen Test-Provider abspielen",
+	"Run against a fake provider with synthetic IP churn":        "Mit künstlichen IP-Wechseln gegen einen Test-Provider laufen",
+	"Export the detected address changes as CSV or JSON":         "Erkannte Adresswechsel als CSV oder JSON exportieren",
+	"Summarize address stability and detection reliability":      "Stabilität der Adresse und Zuverlässigkeit der Erkennung zusammenfassen",
+	"Print the detected public addresses without touching DNS":   "Erkannte öffentliche Adressen ausgeben, ohne DNS zu ändern",
+	"Show version":           "Version anzeigen",
+	"Show this help message": "Diese Hilfe anzeigen",
+	"Run Flags:":             "Optionen von run:",
+	"Once Flags:":            "Optionen von once:",
+	"Install Flags:":         "Optionen von install:",
+	"Package Flags:":         "Optionen von package:",
+	"Status Flags:":          "Optionen von status:",
+	"Backup Flags:":          "Optionen von backup:",
+	"Restore Flags:":         "Optionen von restore:",
+	"Validate Flags:":        "Optionen von validate:",
+	"IP Flags:":              "Optionen von ip:",
+	"Replay Flags:":          "Optionen von replay:",
+	"Path to configuration file (default %q)":                                             "Pfad der Konfigurationsdatei (Standard %q)",
+	"Log format: text or json (default %q)":                                               "Log-Format: text oder json (Standard %q)",
+	"Exit once the first full update succeeds":                                            "Beenden, sobald die erste vollständige Aktualisierung gelingt",
+	"With -until-success, give up after this long (default: retry forever)":               "Mit -until-success nach dieser Dauer aufgeben (Standard: endlos wiederholen)",
+	"Only apply configuration signed by this Ed25519 public key":                          "Nur mit diesem öffentlichen Ed25519-Schlüssel signierte Konfiguration anwenden",
+	"Directory for state, history and statistics (overrides state_dir)":                   "Verzeichnis für Zustand, Verlauf und Statistiken (ersetzt state_dir)",
+	"Log intended changes instead of writing them to Cloudflare":                          "Geplante Änderungen protokollieren, statt sie zu Cloudflare zu schreiben",
+	"Run under the Windows service manager (set by install)":                              "Unter der Windows-Dienstverwaltung laufen (von install gesetzt)",
+	"User to run the service as (default: current user)":                                  "Benutzer, unter dem der Dienst läuft (Standard: aktueller Benutzer)",
+	"Platform to build the package for: windows or darwin (required)":                     "Plattform des Pakets: windows oder darwin (erforderlich)",
+	"cf-ddns executable built for the platform (default: this executable, if it matches)": "Für die Plattform gebautes cf-ddns-Programm (Standard: dieses Programm, falls passend)",
+	"Configuration file to include; the service starts right after installation":          "Mitzuliefernde Konfigurationsdatei; der Dienst startet direkt nach der Installation",
+	"Version of the package (default %q)":                                                 "Version des Pakets (Standard %q)",
+	"Path to write the package to (default %q)":                                           "Pfad, in den das Paket geschrieben wird (Standard %q)",
+	"Write the unit and example config below -destdir instead of installing the service":  "Unit und Beispielkonfiguration unter -destdir schreiben, statt den Dienst zu installieren",
+	"Staging directory for -packaging (default: $DESTDIR)":                                "Staging-Verzeichnis für -packaging (Standard: $DESTDIR)",
+	"Path of the binary on the target system, for -packaging (default %q)":                "Pfad des Programms auf dem Zielsystem, für -packaging (Standard %q)",
+	"Path to write the snapshot to (default %q)":                                          "Pfad, in den der Snapshot geschrieben wird (Standard %q)",
+	"Path to the snapshot file to restore from (required)":                                "Pfad der wiederherzustellenden Snapshot-Datei (erforderlich)",
+	"Only restore the record with this name":                                              "Nur den Eintrag mit diesem Namen wiederherstellen",
+	"Also verify the API token and zone IDs against the provider":                         "Auch API-Token und Zonen-IDs beim Provider prüfen",
+	"Report format: text or json (default %q)":                                            "Format des Berichts: text oder json (Standard %q)",
+	"Treat warnings as errors":                                                            "Warnungen als Fehler behandeln",
+	"Only detect the IPv4 address":                                                        "Nur die IPv4-Adresse erkennen",
+	"Only detect the IPv6 address":                                                        "Nur die IPv6-Adresse erkennen",
+	"Print the result as JSON":                                                            "Ergebnis als JSON ausgeben",
+	"Path to the recorded IP history (required)":                                          "Pfad des aufgezeichneten IP-Verlaufs (erforderlich)",
+
+	// Validation
+	"Validating %s":                             "Prüfe %s",
+	"%d error(s), %d warning(s)":                "%d Fehler, %d Warnung(en)",
+	"token and zones not checked (use -online)": "Token und Zonen nicht geprüft (mit -online prüfen)",
+	"Configuration is valid: %s":                "Konfiguration ist gültig: %s",
+	"Configuration is invalid: %s":              "Konfiguration ist ungültig: %s",
+
+	// Installation
+	"Installing cf-ddns as system service...": "Installiere cf-ddns als Systemdienst...",
+	"Failed to get executable path: %v":       "Pfad des Programms nicht ermittelbar: %v",
+	"Failed to install service: %v":           "Installation des Dienstes fehlgeschlagen: %v",
+	"Service installed successfully!":         "Dienst erfolgreich installiert!",
+	"Next steps:":                             "Nächste Schritte:",
+	"1. Edit the example configuration file:": "1. Beispielkonfiguration anpassen:",
+	"Example: %s":                             "Beispiel: %s",
+	"Copy it to: %s":                          "Kopieren nach: %s",
+	"Command: %s":                             "Befehl: %s",
+	"2. Edit the config file with your Cloudflare API token and zones": "2. Cloudflare-API-Token und Zonen in der Konfiguration eintragen",
+	"3. Start the service:": "3. Dienst starten:",
+	"View logs:":            "Logs ansehen:",
+	"View the service:":     "Dienst ansehen:",
+	"Copied %s to %s":       "%s nach %s kopiert",
+	"Removed the scheduled task %s of an earlier install": "Geplante Aufgabe %s einer früheren Installation entfernt",
+	"Uninstalling cf-ddns system service...":              "Deinstalliere den Systemdienst cf-ddns...",
+	"Failed to uninstall service: %v":                     "Deinstallation des Dienstes fehlgeschlagen: %v",
+	"Service uninstalled successfully!":                   "Dienst erfolgreich deinstalliert!",
+}
@@ -0,0 +1,80 @@
+// Package i18n translates the messages of the command line interface, such
+// as the usage, the install steps and the validation summary. Messages are
+// written in English in the code and looked up in a catalog per language,
+// so a missing translation falls back to English. Log messages of the daemon
+// are not translated, so that they can be searched for and reported as is.
+package i18n
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync/atomic"
+)
+
+// Languages lists the supported languages
+var Languages = []string{"en", "de"}
+
+// catalogs maps the English messages to their translations, per language
+var catalogs = map[string]map[string]string{
+	"de": de,
+}
+
+// language is the selected language
+var language atomic.Value
+
+func init() {
+	language.Store(detect())
+}
+
+// Supported reports whether messages can be shown in lang
+func Supported(lang string) bool {
+	return slices.Contains(Languages, lang)
+}
+
+// SetLanguage selects the language of messages. An empty or unsupported
+// language keeps the one taken from the environment.
+func SetLanguage(lang string) {
+	if Supported(lang) {
+		language.Store(lang)
+	}
+}
+
+// Language returns the selected language
+func Language() string {
+	return language.Load().(string)
+}
+
+// T translates a message to the selected language and, given arguments,
+// formats it like fmt.Sprintf
+func T(message string, args ...any) string {
+	if translated, ok := catalogs[Language()][message]; ok {
+		message = translated
+	}
+	if len(args) == 0 {
+		return message
+	}
+	return fmt.Sprintf(message, args...)
+}
+
+// detect returns the language of the environment: CF_DDNS_LANG, or else the
+// locale in LC_ALL, LC_MESSAGES or LANG, e.g. de_DE.UTF-8. It falls back to
+// English.
+func detect() string {
+	for _, name := range []string{"CF_DDNS_LANG", "LC_ALL", "LC_MESSAGES", "LANG"} {
+		value := os.Getenv(name)
+		if value == "" {
+			continue
+		}
+		lang := strings.ToLower(value)
+		if i := strings.IndexAny(lang, "_-.@"); i >= 0 {
+			lang = lang[:i]
+		}
+		if Supported(lang) {
+			return lang
+		}
+		return "en"
+	}
+	return "en"
+}
@@ -0,0 +1,88 @@
+package installer
+
+import (
+	_ "embed"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+//go:embed templates/cf-ddns.openrc
+var openrcTemplate string
+
+const (
+	openrcInitScript = "/etc/init.d/cf-ddns"
+	openrcRunlevel   = "default"
+	openrcLog        = "/var/log/cf-ddns.log"
+)
+
+// isOpenRC reports whether the system is managed by OpenRC, as on Alpine.
+// Systems that have openrc-run installed but were booted with systemd use
+// systemd.
+func isOpenRC() bool {
+	if _, err := os.Stat("/run/systemd/system"); err == nil {
+		return false
+	}
+	_, err := os.Stat("/sbin/openrc-run")
+	return err == nil
+}
+
+// installOpenRC installs an OpenRC init script, supervised by
+// supervise-daemon, and adds it to the default runlevel
+func installOpenRC(execPath, configPath, user string) error {
+	data := struct {
+		ServiceConfig
+		LogPath string
+	}{
+		ServiceConfig: ServiceConfig{
+			ExecPath:   execPath,
+			ConfigPath: configPath,
+			ConfigDir:  filepath.Dir(configPath),
+			User:       user,
+		},
+		LogPath: openrcLog,
+	}
+	if err := writeTemplate(openrcInitScript, openrcTemplate, data, 0755); err != nil {
+		return err
+	}
+
+	cmd := exec.Command("rc-update", "add", "cf-ddns", openrcRunlevel)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to enable service: %w\n%s", err, output)
+	}
+
+	return nil
+}
+
+// uninstallOpenRC stops the service, removes it from its runlevel and
+// deletes the init script
+func uninstallOpenRC() error {
+	// Stop and disable service
+	exec.Command("rc-service", "cf-ddns", "stop").Run()
+	exec.Command("rc-update", "del", "cf-ddns", openrcRunlevel).Run()
+
+	if err := os.Remove(openrcInitScript); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove init script: %w", err)
+	}
+
+	return nil
+}
+
+// statusOpenRC checks the OpenRC service status and whether it starts at boot
+func statusOpenRC() (string, error) {
+	if _, err := os.Stat(openrcInitScript); os.IsNotExist(err) {
+		return "Service is not installed", nil
+	}
+	output, _ := exec.Command("rc-service", "cf-ddns", "status").CombinedOutput()
+	status := strings.TrimSpace(string(output))
+
+	runlevels, _ := exec.Command("rc-update", "show").Output()
+	for _, line := range strings.Split(string(runlevels), "\n") {
+		if service, levels, ok := strings.Cut(line, "|"); ok && strings.TrimSpace(service) == "cf-ddns" {
+			return fmt.Sprintf("%s\nRunlevels: %s\n", status, strings.TrimSpace(levels)), nil
+		}
+	}
+	return status + "\nNot started at boot (not in any runlevel)\n", nil
+}
@@ -0,0 +1,155 @@
+package installer
+
+import (
+	_ "embed"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/MrLonely14/cf-ddns/i18n"
+)
+
+//go:embed templates/cf-ddns.procd
+var procdTemplate string
+
+const (
+	procdInitScript = "/etc/init.d/cf-ddns"
+	openwrtBinary   = "/usr/bin/cf-ddns"
+	overlayBinary   = "/overlay/cf-ddns/cf-ddns" // used when the root filesystem is read-only
+)
+
+// isOpenWrt reports whether the system runs OpenWrt's procd init system
+func isOpenWrt() bool {
+	if _, err := os.Stat("/etc/openwrt_release"); err == nil {
+		return true
+	}
+	_, err := os.Stat("/sbin/procd")
+	return err == nil
+}
+
+// installOpenWrt installs a procd init script and enables it at boot
+func installOpenWrt(execPath, configPath, user string) error {
+	execPath, err := persistentBinary(execPath)
+	if err != nil {
+		return err
+	}
+
+	tmpl, err := template.New("procd").Parse(procdTemplate)
+	if err != nil {
+		return fmt.Errorf("failed to parse template: %w", err)
+	}
+
+	cfg := ServiceConfig{
+		ExecPath:   execPath,
+		ConfigPath: configPath,
+		ConfigDir:  filepath.Dir(configPath),
+		User:       user,
+	}
+
+	file, err := os.OpenFile(procdInitScript, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0755)
+	if err != nil {
+		return fmt.Errorf("failed to create init script: %w", err)
+	}
+	defer file.Close()
+
+	if err := tmpl.Execute(file, cfg); err != nil {
+		return fmt.Errorf("failed to execute template: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write init script: %w", err)
+	}
+
+	// Enable start at boot
+	cmd := exec.Command(procdInitScript, "enable")
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to enable service: %w\n%s", err, output)
+	}
+
+	return nil
+}
+
+// persistentBinary returns a path to the executable that survives a reboot.
+// Binaries are usually copied to /tmp on OpenWrt, which is a RAM disk, so they
+// are copied to /usr/bin, or to /overlay when the root filesystem is read-only.
+func persistentBinary(execPath string) (string, error) {
+	if !isVolatile(execPath) {
+		return execPath, nil
+	}
+
+	for _, target := range []string{openwrtBinary, overlayBinary} {
+		if err := copyExecutable(execPath, target); err != nil {
+			continue
+		}
+		fmt.Println(i18n.T("Copied %s to %s", execPath, target))
+		return target, nil
+	}
+	return "", fmt.Errorf("%s is on a RAM disk and could not be copied to %s or %s", execPath, openwrtBinary, overlayBinary)
+}
+
+// isVolatile reports whether path is on OpenWrt's RAM disk
+func isVolatile(path string) bool {
+	for _, dir := range []string{"/tmp/", "/var/"} {
+		if strings.HasPrefix(path, dir) {
+			return true
+		}
+	}
+	return false
+}
+
+// copyExecutable copies the binary at src to dst
+func copyExecutable(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", src, err)
+	}
+	defer in.Close()
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(dst), err)
+	}
+	tmp := dst + ".tmp"
+	out, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0755)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", tmp, err)
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(tmp)
+		return fmt.Errorf("failed to copy to %s: %w", tmp, err)
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write %s: %w", tmp, err)
+	}
+	if err := os.Rename(tmp, dst); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to replace %s: %w", dst, err)
+	}
+	return nil
+}
+
+// uninstallOpenWrt stops and removes the procd init script
+func uninstallOpenWrt() error {
+	// Stop and disable service
+	exec.Command(procdInitScript, "stop").Run()
+	exec.Command(procdInitScript, "disable").Run()
+
+	if err := os.Remove(procdInitScript); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove init script: %w", err)
+	}
+
+	return nil
+}
+
+// statusOpenWrt checks the procd service status
+func statusOpenWrt() (string, error) {
+	if _, err := os.Stat(procdInitScript); os.IsNotExist(err) {
+		return "Service is not installed", nil
+	}
+	output, _ := exec.Command(procdInitScript, "status").CombinedOutput()
+	return string(output), nil
+}
@@ -0,0 +1,227 @@
+package installer
+
+import (
+	"debug/macho"
+	"debug/pe"
+	_ "embed"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+//go:embed templates/cf-ddns.wxs
+var wixTemplate string
+
+//go:embed templates/postinstall
+var postinstallTemplate string
+
+// upgradeCode identifies the MSI across versions, so that installing a newer
+// one replaces the older. It must never change.
+const upgradeCode = "6D1C5E4A-3B7F-4E0A-9C2D-8F5A1B3E7C90"
+
+// Where the macOS package installs its files
+const (
+	pkgIdentifier = "com.cf-ddns"
+	pkgBinary     = "/usr/local/bin/cf-ddns"
+	pkgConfigDir  = "/usr/local/etc/cf-ddns"
+	pkgPlist      = "/Library/LaunchDaemons/com.cf-ddns.plist"
+)
+
+// PackageConfig describes an installer package to build
+type PackageConfig struct {
+	Platform string // windows or darwin
+	Binary   string // cf-ddns executable built for the platform
+	Config   string // configuration file to include, if any
+	Version  string // version of the package
+	Output   string // path of the package to write
+}
+
+// BuildPackage builds an MSI for Windows or a pkg for macOS that installs
+// the binary and registers the service, so that it can be installed without
+// a command line. The service is only started if a configuration is included.
+func BuildPackage(cfg PackageConfig) error {
+	if cfg.Platform != "windows" && cfg.Platform != "darwin" {
+		return fmt.Errorf("unsupported platform %s (must be windows or darwin)", cfg.Platform)
+	}
+	if err := checkBinary(cfg.Platform, cfg.Binary); err != nil {
+		return err
+	}
+	if cfg.Config != "" {
+		if _, err := os.Stat(cfg.Config); err != nil {
+			return fmt.Errorf("failed to read configuration: %w", err)
+		}
+	}
+
+	staging, err := os.MkdirTemp("", "cf-ddns-package-*")
+	if err != nil {
+		return fmt.Errorf("failed to create staging directory: %w", err)
+	}
+	defer os.RemoveAll(staging)
+
+	if cfg.Platform == "windows" {
+		return buildMSI(cfg, staging)
+	}
+	return buildPkg(cfg, staging)
+}
+
+// checkBinary makes sure the binary was built for the package's platform
+func checkBinary(platform, path string) error {
+	if platform == "windows" {
+		f, err := pe.Open(path)
+		if err != nil {
+			return fmt.Errorf("%s is not a Windows executable: %w", path, err)
+		}
+		return f.Close()
+	}
+	if f, err := macho.Open(path); err == nil {
+		return f.Close()
+	}
+	// Universal binaries hold one executable per architecture
+	f, err := macho.OpenFat(path)
+	if err != nil {
+		return fmt.Errorf("%s is not a macOS executable: %w", path, err)
+	}
+	return f.Close()
+}
+
+// buildMSI writes a WiX source for the service and builds it with the WiX
+// Toolset. The service is registered like installWindows does.
+func buildMSI(cfg PackageConfig, staging string) error {
+	wix, err := exec.LookPath("wix")
+	if err != nil {
+		return fmt.Errorf("wix not found; install the WiX Toolset on Windows and its util extension (wix extension add -g WixToolset.Util.wixext)")
+	}
+
+	example := filepath.Join(staging, "config.example.yaml")
+	if err := os.WriteFile(example, []byte(configExample), 0644); err != nil {
+		return fmt.Errorf("failed to write example config: %w", err)
+	}
+
+	data := struct {
+		Version, UpgradeCode, Binary, Example, Config string
+	}{msiVersion(cfg.Version), upgradeCode, absolute(cfg.Binary), example, absolute(cfg.Config)}
+	source := filepath.Join(staging, "cf-ddns.wxs")
+	if err := writeTemplate(source, wixTemplate, data, 0644); err != nil {
+		return err
+	}
+
+	cmd := exec.Command(wix, "build", "-arch", "x64", "-ext", "WixToolset.Util.wixext", "-o", cfg.Output, source)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to build MSI: %w\n%s", err, output)
+	}
+	return nil
+}
+
+// buildPkg lays out the files of the package in a staging root and builds it
+// with pkgbuild. Unlike install, which sets up a launch agent for the current
+// user, the package installs a launch daemon for the whole system.
+func buildPkg(cfg PackageConfig, staging string) error {
+	pkgbuild, err := exec.LookPath("pkgbuild")
+	if err != nil {
+		return fmt.Errorf("pkgbuild not found; macOS packages can only be built on macOS")
+	}
+
+	root := filepath.Join(staging, "root")
+	scripts := filepath.Join(staging, "scripts")
+	configPath := pkgConfigDir + "/config.yaml"
+
+	if err := copyExecutable(cfg.Binary, filepath.Join(root, pkgBinary)); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Join(root, pkgConfigDir), 0755); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(root, pkgConfigDir, "config.example.yaml"), []byte(configExample), 0644); err != nil {
+		return fmt.Errorf("failed to write example config: %w", err)
+	}
+	if cfg.Config != "" {
+		content, err := os.ReadFile(cfg.Config)
+		if err != nil {
+			return fmt.Errorf("failed to read configuration: %w", err)
+		}
+		if err := os.WriteFile(filepath.Join(root, configPath), content, 0600); err != nil {
+			return fmt.Errorf("failed to write configuration: %w", err)
+		}
+	}
+	if err := os.MkdirAll(filepath.Join(root, filepath.Dir(pkgPlist)), 0755); err != nil {
+		return fmt.Errorf("failed to create LaunchDaemons directory: %w", err)
+	}
+	service := ServiceConfig{ExecPath: pkgBinary, ConfigPath: configPath}
+	if err := writeTemplate(filepath.Join(root, pkgPlist), launchdTemplate, service, 0644); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(scripts, 0755); err != nil {
+		return fmt.Errorf("failed to create scripts directory: %w", err)
+	}
+	script := struct{ Plist, ConfigPath string }{pkgPlist, configPath}
+	if err := writeTemplate(filepath.Join(scripts, "postinstall"), postinstallTemplate, script, 0755); err != nil {
+		return err
+	}
+
+	cmd := exec.Command(pkgbuild,
+		"--root", root,
+		"--scripts", scripts,
+		"--identifier", pkgIdentifier,
+		"--version", cfg.Version,
+		"--install-location", "/",
+		"--ownership", "recommended",
+		cfg.Output)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to build pkg: %w\n%s", err, output)
+	}
+	return nil
+}
+
+// writeTemplate executes a template into the file at path
+func writeTemplate(path, text string, data any, perm os.FileMode) error {
+	tmpl, err := template.New(filepath.Base(path)).Funcs(template.FuncMap{"xml": xmlEscape}).Parse(text)
+	if err != nil {
+		return fmt.Errorf("failed to parse template: %w", err)
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	defer file.Close()
+
+	if err := tmpl.Execute(file, data); err != nil {
+		return fmt.Errorf("failed to execute template: %w", err)
+	}
+	return file.Close()
+}
+
+// xmlEscape escapes s for an XML attribute
+func xmlEscape(s string) (string, error) {
+	var b strings.Builder
+	if err := xml.EscapeText(&b, []byte(s)); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// absolute returns path made absolute, as the WiX source is built elsewhere
+func absolute(path string) string {
+	if path == "" {
+		return ""
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
+
+// msiVersionPattern matches the numeric part of a version such as v1.2.3-rc1
+var msiVersionPattern = regexp.MustCompile(`^v?(\d+(?:\.\d+){0,2})`)
+
+// msiVersion returns the major.minor.patch numbers MSI versions are made of
+func msiVersion(version string) string {
+	if m := msiVersionPattern.FindStringSubmatch(version); m != nil {
+		return m[1]
+	}
+	return "0.0.0"
+}
@@ -7,8 +7,9 @@ import (
 	"os/exec"
 	"path/filepath"
 	"runtime"
-	"strings"
 	"text/template"
+
+	"github.com/MrLonely14/cf-ddns/i18n"
 )
 
 //go:embed templates/cf-ddns.service
@@ -17,12 +18,12 @@ var systemdTemplate string
 //go:embed templates/cf-ddns.plist
 var launchdTemplate string
 
-//go:embed templates/install.ps1
-var windowsTemplate string
-
 //go:embed templates/config.example.yaml
 var configExample string
 
+// windowsService is the name of the Windows service
+const windowsService = "cf-ddns"
+
 // ServiceConfig holds the configuration for service installation
 type ServiceConfig struct {
 	ExecPath   string
@@ -58,6 +59,12 @@ func Install(execPath, configPath, user string) error {
 
 	switch runtime.GOOS {
 	case "linux":
+		if isOpenWrt() {
+			return installOpenWrt(execPath, configPath, user)
+		}
+		if isOpenRC() {
+			return installOpenRC(execPath, configPath, user)
+		}
 		return installLinux(execPath, configPath, user)
 	case "darwin":
 		return installMacOS(execPath, configPath, user)
@@ -72,6 +79,12 @@ func Install(execPath, configPath, user string) error {
 func Uninstall() error {
 	switch runtime.GOOS {
 	case "linux":
+		if isOpenWrt() {
+			return uninstallOpenWrt()
+		}
+		if isOpenRC() {
+			return uninstallOpenRC()
+		}
 		return uninstallLinux()
 	case "darwin":
 		return uninstallMacOS()
@@ -86,6 +99,12 @@ func Uninstall() error {
 func Status() (string, error) {
 	switch runtime.GOOS {
 	case "linux":
+		if isOpenWrt() {
+			return statusOpenWrt()
+		}
+		if isOpenRC() {
+			return statusOpenRC()
+		}
 		return statusLinux()
 	case "darwin":
 		return statusMacOS()
@@ -100,21 +119,63 @@ func Status() (string, error) {
 func PrintStartCommand() {
 	switch runtime.GOOS {
 	case "linux":
+		if isOpenWrt() {
+			fmt.Println("   /etc/init.d/cf-ddns start")
+			fmt.Println("\n" + i18n.T("View logs:"))
+			fmt.Println("   logread -e cf-ddns -f")
+			return
+		}
+		if isOpenRC() {
+			fmt.Println("   rc-service cf-ddns start")
+			fmt.Println("\n" + i18n.T("View logs:"))
+			fmt.Println("   tail -f " + openrcLog)
+			return
+		}
 		fmt.Println("   sudo systemctl start cf-ddns")
 		fmt.Println("   sudo systemctl enable cf-ddns")
-		fmt.Println("\nView logs:")
+		fmt.Println("\n" + i18n.T("View logs:"))
 		fmt.Println("   sudo journalctl -u cf-ddns -f")
 	case "darwin":
 		fmt.Println("   launchctl load ~/Library/LaunchAgents/com.cf-ddns.plist")
-		fmt.Println("\nView logs:")
+		fmt.Println("\n" + i18n.T("View logs:"))
 		fmt.Println("   tail -f /tmp/cf-ddns.log")
 	case "windows":
-		fmt.Println("   Start-ScheduledTask -TaskName \"CloudflareDDNS\"")
-		fmt.Println("\nView in Task Scheduler:")
-		fmt.Println("   taskschd.msc")
+		fmt.Printf("   Start-Service %s\n", windowsService)
+		fmt.Println("\n" + i18n.T("View the service:"))
+		fmt.Printf("   Get-Service %s\n", windowsService)
 	}
 }
 
+// systemdUnitDir is where packages put systemd units
+const systemdUnitDir = "/usr/lib/systemd/system"
+
+// Stage writes the systemd unit and the example configuration below
+// destDir, as a .deb or .rpm build does, instead of installing the service.
+// Nothing is run; the package's maintainer scripts enable and start the
+// service. It returns the paths of the files written.
+func Stage(destDir, execPath, configPath, user string) ([]string, error) {
+	unitDir := filepath.Join(destDir, systemdUnitDir)
+	if err := os.MkdirAll(unitDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create unit directory: %w", err)
+	}
+	cfg := ServiceConfig{
+		ExecPath:   execPath,
+		ConfigPath: configPath,
+		ConfigDir:  filepath.Dir(configPath),
+		User:       user,
+	}
+	unit := filepath.Join(unitDir, "cf-ddns.service")
+	if err := writeTemplate(unit, systemdTemplate, cfg, 0644); err != nil {
+		return nil, err
+	}
+
+	stagedConfig := filepath.Join(destDir, configPath)
+	if err := createExampleConfig(stagedConfig); err != nil {
+		return nil, fmt.Errorf("failed to create example config: %w", err)
+	}
+	return []string{unit, filepath.Join(filepath.Dir(stagedConfig), "config.example.yaml")}, nil
+}
+
 // installLinux installs the systemd service
 func installLinux(execPath, configPath, user string) error {
 	serviceFile := "/etc/systemd/system/cf-ddns.service"
@@ -271,62 +332,3 @@ func statusMacOS() (string, error) {
 	}
 	return string(output), nil
 }
-
-// installWindows installs the Windows scheduled task
-func installWindows(execPath, configPath, user string) error {
-	// Parse and execute template
-	tmpl, err := template.New("windows").Parse(windowsTemplate)
-	if err != nil {
-		return fmt.Errorf("failed to parse template: %w", err)
-	}
-
-	cfg := ServiceConfig{
-		ExecPath:   execPath,
-		ConfigPath: configPath,
-	}
-
-	// Create temporary PowerShell script
-	tmpFile, err := os.CreateTemp("", "cf-ddns-install-*.ps1")
-	if err != nil {
-		return fmt.Errorf("failed to create temp file: %w", err)
-	}
-	defer os.Remove(tmpFile.Name())
-
-	if err := tmpl.Execute(tmpFile, cfg); err != nil {
-		return fmt.Errorf("failed to execute template: %w", err)
-	}
-	tmpFile.Close()
-
-	// Execute PowerShell script
-	cmd := exec.Command("powershell", "-ExecutionPolicy", "Bypass", "-File", tmpFile.Name())
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("failed to execute install script: %w\n%s", err, output)
-	}
-
-	return nil
-}
-
-// uninstallWindows removes the Windows scheduled task
-func uninstallWindows() error {
-	cmd := exec.Command("schtasks", "/Delete", "/TN", "CloudflareDDNS", "/F")
-	if output, err := cmd.CombinedOutput(); err != nil {
-		return fmt.Errorf("failed to delete scheduled task: %w\n%s", err, output)
-	}
-
-	return nil
-}
-
-// statusWindows checks the Windows scheduled task status
-func statusWindows() (string, error) {
-	cmd := exec.Command("schtasks", "/Query", "/TN", "CloudflareDDNS", "/FO", "LIST", "/V")
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		if strings.Contains(string(output), "cannot find") {
-			return "Service is not installed", nil
-		}
-		return "", fmt.Errorf("failed to query scheduled task: %w", err)
-	}
-
-	return string(output), nil
-}
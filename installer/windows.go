@@ -0,0 +1,197 @@
+//go:build windows
+
+package installer
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"syscall"
+	"time"
+
+	"github.com/MrLonely14/cf-ddns/i18n"
+	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+// legacyTask is the scheduled task earlier versions installed instead of a service
+const legacyTask = "CloudflareDDNS"
+
+// serviceRestartDelay is how long the service manager waits before
+// restarting the service after it failed
+const serviceRestartDelay = time.Minute
+
+// errServiceNotInstalled is returned for a service the service manager doesn't know
+var errServiceNotInstalled = errors.New("service is not installed")
+
+// installWindows registers the Windows service, started at boot and
+// restarted a minute after it fails. A service installed earlier is updated
+// to this executable and configuration. The user is ignored; the service
+// runs as LocalSystem.
+func installWindows(execPath, configPath, user string) error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("failed to connect to the service manager: %w", err)
+	}
+	defer m.Disconnect()
+
+	cfg := mgr.Config{
+		DisplayName: "Cloudflare DDNS",
+		Description: "Keeps Cloudflare DNS records pointed at this host's public addresses",
+		StartType:   mgr.StartAutomatic,
+	}
+	args := []string{"run", "-service", "-config", configPath}
+
+	s, err := m.OpenService(windowsService)
+	if err == nil {
+		err = updateService(s, cfg, execPath, args)
+	} else {
+		s, err = m.CreateService(windowsService, execPath, cfg, args...)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to register service %s: %w", windowsService, err)
+	}
+	defer s.Close()
+
+	// Restart after every failure; the failure count resets after a day
+	actions := []mgr.RecoveryAction{
+		{Type: mgr.ServiceRestart, Delay: serviceRestartDelay},
+		{Type: mgr.ServiceRestart, Delay: serviceRestartDelay},
+		{Type: mgr.ServiceRestart, Delay: serviceRestartDelay},
+	}
+	if err := s.SetRecoveryActions(actions, uint32((24 * time.Hour).Seconds())); err != nil {
+		return fmt.Errorf("failed to set service recovery actions: %w", err)
+	}
+
+	// Replace the scheduled task of an earlier install, which would run a second daemon
+	if removeLegacyTask() {
+		fmt.Println(i18n.T("Removed the scheduled task %s of an earlier install", legacyTask))
+	}
+	return nil
+}
+
+// updateService changes an existing service's configuration and command line
+func updateService(s *mgr.Service, cfg mgr.Config, execPath string, args []string) error {
+	current, err := s.Config()
+	if err != nil {
+		s.Close()
+		return err
+	}
+	current.DisplayName = cfg.DisplayName
+	current.Description = cfg.Description
+	current.StartType = cfg.StartType
+	current.BinaryPathName = syscall.EscapeArg(execPath)
+	for _, arg := range args {
+		current.BinaryPathName += " " + syscall.EscapeArg(arg)
+	}
+	if err := s.UpdateConfig(current); err != nil {
+		s.Close()
+		return err
+	}
+	return nil
+}
+
+// uninstallWindows stops and removes the Windows service, and the
+// scheduled task of an earlier install
+func uninstallWindows() error {
+	err := removeService(30 * time.Second)
+	if removeLegacyTask() && errors.Is(err, errServiceNotInstalled) {
+		return nil
+	}
+	return err
+}
+
+// removeService stops the service, waiting up to timeout for it to stop, and
+// removes its registration
+func removeService(timeout time.Duration) error {
+	m, s, err := openService()
+	if err != nil {
+		return err
+	}
+	defer m.Disconnect()
+	defer s.Close()
+
+	status, err := s.Control(svc.Stop)
+	if err != nil && !errors.Is(err, windows.ERROR_SERVICE_NOT_ACTIVE) {
+		return fmt.Errorf("failed to stop service %s: %w", windowsService, err)
+	}
+	for deadline := time.Now().Add(timeout); err == nil && status.State != svc.Stopped && time.Now().Before(deadline); {
+		time.Sleep(500 * time.Millisecond)
+		status, err = s.Query()
+	}
+
+	if err := s.Delete(); err != nil {
+		return fmt.Errorf("failed to remove service %s: %w", windowsService, err)
+	}
+	return nil
+}
+
+// statusWindows asks the service manager for the state of the service
+func statusWindows() (string, error) {
+	state, err := queryService()
+	if errors.Is(err, errServiceNotInstalled) {
+		if exec.Command("schtasks", "/Query", "/TN", legacyTask).Run() == nil {
+			return fmt.Sprintf("Installed as the scheduled task %s by an earlier version; run install again to replace it with a service", legacyTask), nil
+		}
+		return "Service is not installed", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Service %s is %s", windowsService, state), nil
+}
+
+// queryService returns the state of the service, such as "running" or "stopped"
+func queryService() (string, error) {
+	m, s, err := openService()
+	if err != nil {
+		return "", err
+	}
+	defer m.Disconnect()
+	defer s.Close()
+
+	status, err := s.Query()
+	if err != nil {
+		return "", fmt.Errorf("failed to query service %s: %w", windowsService, err)
+	}
+	switch status.State {
+	case svc.Stopped:
+		return "stopped", nil
+	case svc.StartPending:
+		return "starting", nil
+	case svc.StopPending:
+		return "stopping", nil
+	case svc.Running:
+		return "running", nil
+	default:
+		return fmt.Sprintf("state %d", status.State), nil
+	}
+}
+
+// openService connects to the service manager and opens the service
+func openService() (*mgr.Mgr, *mgr.Service, error) {
+	m, err := mgr.Connect()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to connect to the service manager: %w", err)
+	}
+	s, err := m.OpenService(windowsService)
+	if err != nil {
+		m.Disconnect()
+		if errors.Is(err, windows.ERROR_SERVICE_DOES_NOT_EXIST) {
+			return nil, nil, errServiceNotInstalled
+		}
+		return nil, nil, fmt.Errorf("failed to open service %s: %w", windowsService, err)
+	}
+	return m, s, nil
+}
+
+// removeLegacyTask deletes the scheduled task of an earlier install,
+// reporting whether there was one
+func removeLegacyTask() bool {
+	if exec.Command("schtasks", "/Query", "/TN", legacyTask).Run() != nil {
+		return false
+	}
+	exec.Command("schtasks", "/End", "/TN", legacyTask).Run()
+	return exec.Command("schtasks", "/Delete", "/TN", legacyTask, "/F").Run() == nil
+}
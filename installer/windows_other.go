@@ -0,0 +1,14 @@
+//go:build !windows
+
+package installer
+
+import "errors"
+
+// errNotWindows is returned by the Windows service functions elsewhere
+var errNotWindows = errors.New("Windows services can only be managed on Windows")
+
+func installWindows(execPath, configPath, user string) error { return errNotWindows }
+
+func uninstallWindows() error { return errNotWindows }
+
+func statusWindows() (string, error) { return "", errNotWindows }
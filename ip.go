@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/MrLonely14/cf-ddns/config"
+	"github.com/MrLonely14/cf-ddns/ipdetect"
+	"github.com/MrLonely14/cf-ddns/term"
+)
+
+// detectedAddress is one family's line of the ip command's JSON output
+type detectedAddress struct {
+	Family   string   `json:"family"`
+	IP       string   `json:"ip,omitempty"`
+	Source   string   `json:"source"`
+	Services []string `json:"services,omitempty"`
+	TookMS   int64    `json:"took_ms"`
+	Error    string   `json:"error,omitempty"`
+}
+
+func ipCommand(args []string) {
+	ipCmd := flag.NewFlagSet("ip", flag.ExitOnError)
+	configPath := ipCmd.String("config", "config.yaml", "Path to configuration file (default detection settings if it doesn't exist)")
+	only4 := ipCmd.Bool("4", false, "Only detect the IPv4 address")
+	only6 := ipCmd.Bool("6", false, "Only detect the IPv6 address")
+	asJSON := ipCmd.Bool("json", false, "Print the result as JSON")
+	ipCmd.Parse(args)
+
+	if *only4 && *only6 {
+		log.Fatalf("-4 and -6 cannot be combined")
+	}
+	os.Exit(printDetectedAddresses(*configPath, !*only6, !*only4, *asJSON))
+}
+
+// printDetectedAddresses detects the public addresses with the configured
+// detection settings, without touching DNS, and prints them with the services
+// that answered. It returns the exit status: 1 if a detection failed.
+func printDetectedAddresses(configPath string, ipv4, ipv6, asJSON bool) int {
+	var detection config.IPDetectionConfig
+	cfg, err := config.Load(configPath)
+	switch {
+	case err == nil:
+		applyProcessSettings(cfg)
+		detection = cfg.IPDetection
+	case errors.Is(err, os.ErrNotExist):
+		log.Printf("%s not found, using the default detection settings", configPath)
+	default:
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+
+	detector, err := ipdetect.NewDetector(detection)
+	if err != nil {
+		log.Fatalf("Failed to create IP detector: %v", err)
+	}
+
+	var results []detectedAddress
+	for _, family := range []struct {
+		name   string
+		isIPv6 bool
+		wanted bool
+	}{{"IPv4", false, ipv4}, {"IPv6", true, ipv6}} {
+		if !family.wanted {
+			continue
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		answer, err := detector.Detect(ctx, family.isIPv6)
+		cancel()
+
+		result := detectedAddress{
+			Family:   family.name,
+			IP:       answer.IP,
+			Source:   answer.Source,
+			Services: answer.Services,
+			TookMS:   answer.Took.Milliseconds(),
+		}
+		if err != nil {
+			result.Error = err.Error()
+		}
+		results = append(results, result)
+	}
+
+	status := 0
+	for _, result := range results {
+		if result.Error != "" {
+			status = 1
+		}
+	}
+
+	if asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(results); err != nil {
+			log.Fatalf("Failed to write result: %v", err)
+		}
+		return status
+	}
+
+	for _, result := range results {
+		took := (time.Duration(result.TookMS) * time.Millisecond).String()
+		if result.Error != "" {
+			fmt.Println(term.Fail(fmt.Sprintf("%s: detection failed after %s: %s", result.Family, took, result.Error)))
+			continue
+		}
+		via := result.Source
+		if len(result.Services) > 0 {
+			via += " via " + strings.Join(result.Services, ", ")
+		}
+		fmt.Printf("%s: %s (%s, %s)\n", result.Family, term.Bold(result.IP), via, took)
+	}
+	return status
+}
@@ -0,0 +1,56 @@
+package ipdetect
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Answer is a detected address and what answered the detection
+type Answer struct {
+	IP       string
+	Source   string        // the configured source, e.g. http or majority(http,dns)
+	Services []string      // what answered, e.g. the host of a detection service
+	Took     time.Duration // how long the detection took
+}
+
+// answersKey is the context key of the services noted during Detect
+type answersKey struct{}
+
+// answers collects the services that answered; quorum sources ask several
+// at once
+type answers struct {
+	mu       sync.Mutex
+	services []string
+}
+
+// answeredBy notes which service answered a detection, if Detect asked
+func answeredBy(ctx context.Context, service string) {
+	a, ok := ctx.Value(answersKey{}).(*answers)
+	if !ok {
+		return
+	}
+	a.mu.Lock()
+	a.services = append(a.services, service)
+	a.mu.Unlock()
+}
+
+// Detect detects the address of one family like GetIPv4 and GetIPv6, and
+// reports which services answered, e.g. to debug detection problems
+func (d *Detector) Detect(ctx context.Context, isIPv6 bool) (Answer, error) {
+	a := &answers{}
+	ctx = context.WithValue(ctx, answersKey{}, a)
+
+	start := time.Now()
+	var ip string
+	var err error
+	if isIPv6 {
+		ip, err = d.GetIPv6(ctx)
+	} else {
+		ip, err = d.GetIPv4(ctx)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return Answer{IP: ip, Source: d.source.Name(), Services: a.services, Took: time.Since(start)}, err
+}
@@ -0,0 +1,91 @@
+package ipdetect
+
+import (
+	"sync"
+	"time"
+)
+
+// Circuit breaker backoff bounds for detection services
+const (
+	breakerBaseBackoff = 30 * time.Second
+	breakerMaxBackoff  = 30 * time.Minute
+)
+
+// breaker tracks the health of a single detection service. After a failure
+// the service is skipped for an exponentially growing backoff; once it expires
+// a single half-open probe decides whether the service is healthy again.
+type breaker struct {
+	mu       sync.Mutex
+	failures int
+	openTill time.Time
+	probing  bool
+}
+
+// allow reports whether the service may be called now
+func (b *breaker) allow(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.failures == 0 {
+		return true
+	}
+	if now.Before(b.openTill) || b.probing {
+		return false
+	}
+
+	// Half-open: let exactly one caller probe the service
+	b.probing = true
+	return true
+}
+
+// success closes the breaker
+func (b *breaker) success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures = 0
+	b.probing = false
+}
+
+// failure opens the breaker for the next backoff step
+func (b *breaker) failure(now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	backoff := breakerBaseBackoff << b.failures
+	if backoff > breakerMaxBackoff || backoff <= 0 {
+		backoff = breakerMaxBackoff
+	}
+	b.failures++
+	b.openTill = now.Add(backoff)
+	b.probing = false
+}
+
+// release ends a half-open probe that was cancelled before the service
+// answered, without counting it as a failure, so the next call probes again
+func (b *breaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+}
+
+// breakerSet holds one breaker per service URL
+type breakerSet struct {
+	mu       sync.Mutex
+	breakers map[string]*breaker
+}
+
+// get returns the breaker for a service, creating it on first use
+func (s *breakerSet) get(service string) *breaker {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.breakers == nil {
+		s.breakers = make(map[string]*breaker)
+	}
+	b, ok := s.breakers[service]
+	if !ok {
+		b = &breaker{}
+		s.breakers[service] = b
+	}
+	return b
+}
@@ -0,0 +1,46 @@
+package ipdetect
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBreakerHalfOpenProbe(t *testing.T) {
+	var b breaker
+	now := time.Now()
+	b.failure(now)
+
+	if b.allow(now) {
+		t.Fatal("allow() = true while the breaker is open")
+	}
+	later := now.Add(breakerBaseBackoff)
+	if !b.allow(later) {
+		t.Fatal("allow() = false after the backoff, want a half-open probe")
+	}
+	if b.allow(later) {
+		t.Fatal("allow() = true during a probe, want a single prober")
+	}
+	b.success()
+	if !b.allow(later) {
+		t.Error("allow() = false after a successful probe")
+	}
+}
+
+func TestBreakerReleasedProbe(t *testing.T) {
+	var b breaker
+	now := time.Now()
+	b.failure(now)
+
+	later := now.Add(breakerBaseBackoff)
+	if !b.allow(later) {
+		t.Fatal("allow() = false after the backoff, want a half-open probe")
+	}
+	// The cycle was cancelled while probing
+	b.release()
+	if !b.allow(later) {
+		t.Error("allow() = false after a released probe, want another probe")
+	}
+	if b.failures != 1 {
+		t.Errorf("failures = %d, want the released probe not counted", b.failures)
+	}
+}
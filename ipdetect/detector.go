@@ -3,168 +3,191 @@ package ipdetect
 import (
 	"context"
 	"fmt"
-	"io"
-	"net"
-	"net/http"
+	"log"
+	"net/netip"
 	"strings"
+	"sync"
 	"time"
+
+	"github.com/MrLonely14/cf-ddns/config"
 )
 
+// Source looks up the current public IP address
+type Source interface {
+	// Name identifies the source in logs
+	Name() string
+	// GetIP returns the public IPv4 address, or the IPv6 address if isIPv6 is set
+	GetIP(ctx context.Context, isIPv6 bool) (string, error)
+}
+
+// Observer is told about every detection, e.g. to keep a history. ip is
+// empty if the detection failed with err; took is how long it took.
+type Observer interface {
+	Observed(isIPv6 bool, ip, source string, took time.Duration, err error) error
+}
+
 // Detector handles IP address detection
 type Detector struct {
-	client     *http.Client
+	source     Source
+	observer   Observer
 	ipv4Cache  string
 	ipv6Cache  string
 	lastUpdate time.Time
+	mu         sync.Mutex // guards the cache, as both families are detected concurrently
 }
 
-// NewDetector creates a new IP detector
-func NewDetector() *Detector {
-	return &Detector{
-		client: &http.Client{
-			Timeout: 10 * time.Second,
-		},
+// NewDetector creates a new IP detector using the configured source, or a
+// quorum of sources when several are listed
+func NewDetector(cfg config.IPDetectionConfig) (*Detector, error) {
+	if len(cfg.Sources) == 0 {
+		source, err := newSource(cfg.Source, cfg)
+		if err != nil {
+			return nil, err
+		}
+		return &Detector{source: preferDNSWhenMetered(source, cfg)}, nil
 	}
-}
 
-// IPv4 services to try in order
-var ipv4Services = []string{
-	"https://api.ipify.org",
-	"https://icanhazip.com",
-	"https://ifconfig.me/ip",
-	"https://checkip.amazonaws.com",
-}
+	quorum := &quorumSource{policy: cfg.Quorum}
+	if quorum.policy == "" {
+		quorum.policy = QuorumFirstSuccess
+	}
+	for _, sc := range cfg.Sources {
+		source, err := newSource(sc.Type, cfg)
+		if err != nil {
+			return nil, err
+		}
+		weight := sc.Weight
+		if weight == 0 {
+			weight = 1
+		}
+		quorum.sources = append(quorum.sources, weightedSource{source: source, weight: weight})
+	}
 
-// IPv6 services to try in order
-var ipv6Services = []string{
-	"https://api64.ipify.org",
-	"https://ipv6.icanhazip.com",
-	"https://v6.ident.me",
+	return &Detector{source: preferDNSWhenMetered(quorum, cfg)}, nil
 }
 
-// GetIPv4 detects the current public IPv4 address
-func (d *Detector) GetIPv4(ctx context.Context) (string, error) {
-	for _, service := range ipv4Services {
-		ip, err := d.fetchIP(ctx, service, false)
-		if err == nil && ip != "" {
-			d.ipv4Cache = ip
-			d.lastUpdate = time.Now()
-			return ip, nil
-		}
+// preferDNSWhenMetered wraps a source so metered connections use DNS detection, if enabled
+func preferDNSWhenMetered(source Source, cfg config.IPDetectionConfig) Source {
+	if !cfg.PreferDNSWhenMetered {
+		return source
 	}
-	return "", fmt.Errorf("failed to detect IPv4 address from all services")
+	return &meteredSource{regular: source, dns: guardedSource{newDNSSource(newRouteTracker(cfg.FollowDefaultRoute))}}
 }
 
-// GetIPv6 detects the current public IPv6 address
-func (d *Detector) GetIPv6(ctx context.Context) (string, error) {
-	for _, service := range ipv6Services {
-		ip, err := d.fetchIP(ctx, service, true)
-		if err == nil && ip != "" {
-			d.ipv6Cache = ip
-			d.lastUpdate = time.Now()
-			return ip, nil
-		}
+// NewDetectorFromSource creates a detector that reads addresses from the given
+// source, e.g. a recorded history during replay
+func NewDetectorFromSource(source Source) *Detector {
+	return &Detector{source: source}
+}
+
+// newSource creates a single source of the given type. Its addresses are
+// checked with CheckPublishable.
+func newSource(kind string, cfg config.IPDetectionConfig) (Source, error) {
+	var source Source
+	switch kind {
+	case "", "http":
+		source = newHTTPSource(newRouteTracker(cfg.FollowDefaultRoute), cfg)
+	case "snmp":
+		source = newSNMPSource(cfg.SNMP)
+	case "fritzbox":
+		source = newFritzBoxSource(cfg.FritzBox)
+	case "dns":
+		source = newDNSSource(newRouteTracker(cfg.FollowDefaultRoute))
+	case "trace":
+		source = newTraceSource(newRouteTracker(cfg.FollowDefaultRoute), cfg)
+	case "interface":
+		source = newInterfaceSource(cfg.Interface)
+	default:
+		return nil, fmt.Errorf("unknown IP source: %s", kind)
 	}
-	return "", fmt.Errorf("failed to detect IPv6 address from all services")
+	return guardedSource{source}, nil
 }
 
-// fetchIP fetches IP from a service and validates it
-func (d *Detector) fetchIP(ctx context.Context, url string, isIPv6 bool) (string, error) {
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+// NormalizeIP returns the canonical text form of an address: zone IDs such as
+// %eth0 are stripped, IPv6 is lowercased and compressed, and IPv4-mapped IPv6
+// addresses are unmapped. This keeps differently written forms of the same
+// address from looking like a change.
+func NormalizeIP(ip string) (string, error) {
+	addr, err := netip.ParseAddr(strings.TrimSpace(ip))
 	if err != nil {
-		return "", err
+		return "", fmt.Errorf("invalid IP address: %s", ip)
 	}
+	return addr.WithZone("").Unmap().String(), nil
+}
 
-	// For IPv6, prefer IPv6 transport
-	if isIPv6 {
-		transport := &http.Transport{
-			DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
-				return (&net.Dialer{
-					Timeout: 5 * time.Second,
-				}).DialContext(ctx, "tcp6", addr)
-			},
-		}
-		client := &http.Client{
-			Timeout:   10 * time.Second,
-			Transport: transport,
-		}
-		resp, err := client.Do(req)
-		if err != nil {
-			// Fallback to default client
-			resp, err = d.client.Do(req)
-			if err != nil {
-				return "", err
-			}
-		}
-		defer resp.Body.Close()
-
-		if resp.StatusCode != http.StatusOK {
-			return "", fmt.Errorf("service returned status %d", resp.StatusCode)
-		}
-
-		body, err := io.ReadAll(resp.Body)
-		if err != nil {
-			return "", err
-		}
-
-		ip := strings.TrimSpace(string(body))
-
-		// Validate IP address
-		parsedIP := net.ParseIP(ip)
-		if parsedIP == nil {
-			return "", fmt.Errorf("invalid IP address: %s", ip)
-		}
-
-		// Check if it's the correct IP type
-		if isIPv6 && parsedIP.To4() != nil {
-			return "", fmt.Errorf("expected IPv6 but got IPv4")
-		}
-		if !isIPv6 && parsedIP.To4() == nil {
-			return "", fmt.Errorf("expected IPv4 but got IPv6")
-		}
+// SetObserver registers an observer for detected addresses
+func (d *Detector) SetObserver(o Observer) {
+	d.observer = o
+}
 
-		return ip, nil
+// observe passes the outcome of a detection to the observer, if any.
+// Detections cut short by shutdown are not failures of the source.
+func (d *Detector) observe(ctx context.Context, isIPv6 bool, ip string, took time.Duration, detectErr error) {
+	if d.observer == nil || (detectErr != nil && ctx.Err() != nil) {
+		return
 	}
+	if err := d.observer.Observed(isIPv6, ip, d.source.Name(), took, detectErr); err != nil {
+		log.Printf("Warning: failed to record detected address: %v", err)
+	}
+}
 
-	// For IPv4, use default client
-	resp, err := d.client.Do(req)
+// GetIPv4 detects the current public IPv4 address
+func (d *Detector) GetIPv4(ctx context.Context) (string, error) {
+	start := time.Now()
+	ip, err := d.source.GetIP(ctx, false)
+	if err == nil {
+		ip, err = NormalizeIP(ip)
+	}
 	if err != nil {
+		d.observe(ctx, false, "", time.Since(start), err)
 		return "", err
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("service returned status %d", resp.StatusCode)
+	d.mu.Lock()
+	previous := d.ipv4Cache
+	d.ipv4Cache = ip
+	d.lastUpdate = time.Now()
+	d.mu.Unlock()
+	if ip != previous {
+		warnUnreachable(ip)
 	}
+	d.observe(ctx, false, ip, time.Since(start), nil)
+	return ip, nil
+}
 
-	body, err := io.ReadAll(resp.Body)
+// GetIPv6 detects the current public IPv6 address
+func (d *Detector) GetIPv6(ctx context.Context) (string, error) {
+	start := time.Now()
+	ip, err := d.source.GetIP(ctx, true)
+	if err == nil {
+		ip, err = NormalizeIP(ip)
+	}
 	if err != nil {
+		d.observe(ctx, true, "", time.Since(start), err)
 		return "", err
 	}
-
-	ip := strings.TrimSpace(string(body))
-
-	// Validate IP address
-	parsedIP := net.ParseIP(ip)
-	if parsedIP == nil {
-		return "", fmt.Errorf("invalid IP address: %s", ip)
+	d.mu.Lock()
+	previous := d.ipv6Cache
+	d.ipv6Cache = ip
+	d.lastUpdate = time.Now()
+	d.mu.Unlock()
+	if ip != previous {
+		warnUnreachable(ip)
 	}
-
-	// Check if it's IPv4
-	if parsedIP.To4() == nil {
-		return "", fmt.Errorf("expected IPv4 but got IPv6")
-	}
-
+	d.observe(ctx, true, ip, time.Since(start), nil)
 	return ip, nil
 }
 
 // GetCachedIPv4 returns the last cached IPv4 address
 func (d *Detector) GetCachedIPv4() string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
 	return d.ipv4Cache
 }
 
 // GetCachedIPv6 returns the last cached IPv6 address
 func (d *Detector) GetCachedIPv6() string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
 	return d.ipv6Cache
 }
 
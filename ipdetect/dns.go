@@ -0,0 +1,77 @@
+package ipdetect
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+)
+
+// OpenDNS answers queries for myip.opendns.com with the address of the
+// client asking, which costs a single small UDP exchange instead of an HTTPS
+// request
+const dnsMyIPName = "myip.opendns.com"
+
+var (
+	dnsResolverIPv4 = "208.67.222.222:53"    // resolver1.opendns.com
+	dnsResolverIPv6 = "[2620:119:35::35]:53" // resolver1.opendns.com
+)
+
+// dnsSource detects the public IP with a DNS query to OpenDNS
+type dnsSource struct {
+	route *routeTracker // optional, queries leave through the default route
+	ipv4  *net.Resolver
+	ipv6  *net.Resolver
+}
+
+// newDNSSource creates the source with one resolver per family, reused across
+// lookups
+func newDNSSource(route *routeTracker) *dnsSource {
+	s := &dnsSource{route: route}
+	s.ipv4 = s.resolver(dnsResolverIPv4, "udp4", false)
+	s.ipv6 = s.resolver(dnsResolverIPv6, "udp6", true)
+	return s
+}
+
+// resolver returns a resolver that sends its queries to server, from the
+// default route's address at the time of each query
+func (s *dnsSource) resolver(server, network string, isIPv6 bool) *net.Resolver {
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			dialer := &net.Dialer{
+				Timeout:   5 * time.Second,
+				LocalAddr: s.route.localAddr(isIPv6, network),
+			}
+			return dialer.DialContext(ctx, network, server)
+		},
+	}
+}
+
+// Name identifies the source in logs
+func (*dnsSource) Name() string {
+	return "dns"
+}
+
+// GetIP asks OpenDNS's resolver for myip.opendns.com over the requested family
+func (s *dnsSource) GetIP(ctx context.Context, isIPv6 bool) (string, error) {
+	resolver, family, server := s.ipv4, "ip4", dnsResolverIPv4
+	if isIPv6 {
+		resolver, family, server = s.ipv6, "ip6", dnsResolverIPv6
+	}
+	s.route.refresh(isIPv6)
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	ips, err := resolver.LookupIP(ctx, family, dnsMyIPName)
+	if err != nil {
+		return "", fmt.Errorf("DNS lookup of %s failed: %w", dnsMyIPName, err)
+	}
+	if len(ips) == 0 {
+		return "", fmt.Errorf("DNS lookup of %s returned no address", dnsMyIPName)
+	}
+
+	answeredBy(ctx, server)
+	return ips[0].String(), nil
+}
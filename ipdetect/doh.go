@@ -0,0 +1,207 @@
+package ipdetect
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"sync"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// DoH answers are cached for their TTL, within these bounds
+const (
+	dohMinTTL = 30 * time.Second
+	dohMaxTTL = time.Hour
+)
+
+// maxDoHResponseSize caps how much of a DoH answer is read
+const maxDoHResponseSize = 4096
+
+// dohResolver resolves the host names of detection services with DNS over
+// HTTPS (RFC 8484), for networks whose resolvers are unreliable or tampered with
+type dohResolver struct {
+	url    string
+	client *http.Client
+	mu     sync.Mutex
+	cache  map[string]dohAnswer // keyed by "host/TypeA" or "host/TypeAAAA"
+}
+
+// dohAnswer is a cached lookup result
+type dohAnswer struct {
+	addrs   []netip.Addr
+	expires time.Time
+}
+
+// newDoHResolver creates a resolver using the DoH server at url, or returns
+// nil if url is empty and the system resolver is used. A server named by host
+// name is reached at the bootstrap addresses, so that the system resolver is
+// never asked.
+func newDoHResolver(url string, bootstrap []string) *dohResolver {
+	if url == "" {
+		return nil
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if len(bootstrap) > 0 {
+		transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return dialBootstrap(ctx, network, addr, bootstrap)
+		}
+	}
+	return &dohResolver{
+		url:    url,
+		client: &http.Client{Timeout: 5 * time.Second, Transport: transport},
+		cache:  make(map[string]dohAnswer),
+	}
+}
+
+// dialBootstrap connects to the port of addr at the first bootstrap address
+// that answers. TLS still verifies the server by the host name of its URL.
+func dialBootstrap(ctx context.Context, network, addr string, bootstrap []string) (net.Conn, error) {
+	_, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	dialer := &net.Dialer{Timeout: 5 * time.Second}
+	var dialErr error
+	for _, ip := range bootstrap {
+		conn, err := dialer.DialContext(ctx, network, net.JoinHostPort(ip, port))
+		if err == nil {
+			return conn, nil
+		}
+		dialErr = err
+	}
+	return nil, dialErr
+}
+
+// dial connects to addr, resolving its host with DoH for the dialer's family
+func (r *dohResolver) dial(ctx context.Context, dialer *net.Dialer, network, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := netip.ParseAddr(host); err == nil {
+		return dialer.DialContext(ctx, network, addr)
+	}
+
+	addrs, err := r.lookup(ctx, host, network == "tcp6")
+	if err != nil {
+		return nil, err
+	}
+	var dialErr error
+	for _, ip := range addrs {
+		conn, err := dialer.DialContext(ctx, network, net.JoinHostPort(ip.String(), port))
+		if err == nil {
+			return conn, nil
+		}
+		dialErr = err
+	}
+	return nil, dialErr
+}
+
+// lookup returns the addresses of host of one family, from the cache while
+// the answer's TTL lasts
+func (r *dohResolver) lookup(ctx context.Context, host string, isIPv6 bool) ([]netip.Addr, error) {
+	qtype := dnsmessage.TypeA
+	if isIPv6 {
+		qtype = dnsmessage.TypeAAAA
+	}
+	key := fmt.Sprintf("%s/%s", host, qtype)
+
+	r.mu.Lock()
+	cached, ok := r.cache[key]
+	r.mu.Unlock()
+	if ok && time.Now().Before(cached.expires) {
+		return cached.addrs, nil
+	}
+
+	addrs, ttl, err := r.query(ctx, host, qtype)
+	if err != nil {
+		return nil, fmt.Errorf("DoH lookup of %s failed: %w", host, err)
+	}
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("DoH lookup of %s returned no address", host)
+	}
+
+	ttl = min(max(ttl, dohMinTTL), dohMaxTTL)
+	r.mu.Lock()
+	r.cache[key] = dohAnswer{addrs: addrs, expires: time.Now().Add(ttl)}
+	r.mu.Unlock()
+	return addrs, nil
+}
+
+// query sends one DNS query to the DoH server and returns the addresses in
+// the answer and their lowest TTL
+func (r *dohResolver) query(ctx context.Context, host string, qtype dnsmessage.Type) ([]netip.Addr, time.Duration, error) {
+	name, err := dnsmessage.NewName(dnsName(host))
+	if err != nil {
+		return nil, 0, err
+	}
+	// ID 0 lets HTTP caches share answers, as RFC 8484 recommends
+	query := dnsmessage.Message{
+		Header:    dnsmessage.Header{RecursionDesired: true},
+		Questions: []dnsmessage.Question{{Name: name, Type: qtype, Class: dnsmessage.ClassINET}},
+	}
+	packed, err := query.Pack()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.url, bytes.NewReader(packed))
+	if err != nil {
+		return nil, 0, err
+	}
+	req.Header.Set("Content-Type", "application/dns-message")
+	req.Header.Set("Accept", "application/dns-message")
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, 0, fmt.Errorf("server returned status %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxDoHResponseSize))
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var answer dnsmessage.Message
+	if err := answer.Unpack(body); err != nil {
+		return nil, 0, fmt.Errorf("invalid answer: %w", err)
+	}
+	if answer.RCode != dnsmessage.RCodeSuccess {
+		return nil, 0, fmt.Errorf("server answered %s", answer.RCode)
+	}
+
+	// CNAMEs are followed by the server, which includes the records they point to
+	var addrs []netip.Addr
+	ttl := dohMaxTTL
+	for _, rr := range answer.Answers {
+		var addr netip.Addr
+		switch body := rr.Body.(type) {
+		case *dnsmessage.AResource:
+			addr = netip.AddrFrom4(body.A)
+		case *dnsmessage.AAAAResource:
+			addr = netip.AddrFrom16(body.AAAA)
+		default:
+			continue
+		}
+		addrs = append(addrs, addr)
+		ttl = min(ttl, time.Duration(rr.Header.TTL)*time.Second)
+	}
+	return addrs, ttl, nil
+}
+
+// dnsName returns host as a fully qualified DNS name
+func dnsName(host string) string {
+	if len(host) > 0 && host[len(host)-1] == '.' {
+		return host
+	}
+	return host + "."
+}
@@ -0,0 +1,50 @@
+package ipdetect
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+func TestDoHBootstrap(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var query dnsmessage.Message
+		if err := query.Unpack(body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		answer := dnsmessage.Message{
+			Header:    dnsmessage.Header{Response: true},
+			Questions: query.Questions,
+			Answers: []dnsmessage.Resource{{
+				Header: dnsmessage.ResourceHeader{Name: query.Questions[0].Name, Type: dnsmessage.TypeA, Class: dnsmessage.ClassINET, TTL: 300},
+				Body:   &dnsmessage.AResource{A: [4]byte{198, 51, 100, 4}},
+			}},
+		}
+		packed, _ := answer.Pack()
+		w.Header().Set("Content-Type", "application/dns-message")
+		w.Write(packed)
+	}))
+	defer server.Close()
+
+	// The test certificate is valid for example.com, which must not be
+	// looked up: the server is only reachable at the bootstrap address
+	_, port, _ := strings.Cut(strings.TrimPrefix(server.URL, "https://"), ":")
+	r := newDoHResolver("https://example.com:"+port+"/dns-query", []string{"127.0.0.1"})
+	r.client.Transport.(*http.Transport).TLSClientConfig = server.Client().Transport.(*http.Transport).TLSClientConfig
+
+	addrs, err := r.lookup(context.Background(), "host.example.net", false)
+	if err != nil {
+		t.Fatalf("lookup() error = %v", err)
+	}
+	if want := netip.MustParseAddr("198.51.100.4"); len(addrs) != 1 || addrs[0] != want {
+		t.Errorf("lookup() = %v, want [%s]", addrs, want)
+	}
+}
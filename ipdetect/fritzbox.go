@@ -0,0 +1,136 @@
+package ipdetect
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/MrLonely14/cf-ddns/config"
+)
+
+// Default IGD control endpoint exposed by Fritz!Box routers
+const defaultFritzBoxURL = "http://fritz.box:49000/igdupnp/control/WANIPConn1"
+
+// IGD services and actions used to read the external addresses
+const (
+	igdWANIPConnection = "urn:schemas-upnp-org:service:WANIPConnection:1"
+	igdGetExternalIPv4 = "GetExternalIPAddress"
+	igdGetExternalIPv6 = "X_AVM_DE_GetExternalIPv6Address"
+)
+
+// soapEnvelope is the request body for an IGD action without arguments
+const soapEnvelope = `<?xml version="1.0" encoding="utf-8"?>
+<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/">
+<s:Body><u:%s xmlns:u="%s"/></s:Body>
+</s:Envelope>`
+
+// fritzBoxSource queries a Fritz!Box or another TR-064/IGD router for its
+// external address over UPnP SOAP
+type fritzBoxSource struct {
+	url    string
+	client *http.Client
+}
+
+// newFritzBoxSource creates a source for the configured control URL
+func newFritzBoxSource(cfg config.FritzBoxConfig) *fritzBoxSource {
+	url := cfg.URL
+	if url == "" {
+		url = defaultFritzBoxURL
+	}
+	return &fritzBoxSource{
+		url: url,
+		client: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+	}
+}
+
+// Name identifies the source in logs
+func (s *fritzBoxSource) Name() string {
+	return "fritzbox"
+}
+
+// GetIP asks the router for its external address. IPv6 uses the AVM extension,
+// which generic IGD devices don't implement.
+func (s *fritzBoxSource) GetIP(ctx context.Context, isIPv6 bool) (string, error) {
+	action := igdGetExternalIPv4
+	if isIPv6 {
+		action = igdGetExternalIPv6
+	}
+
+	body := fmt.Sprintf(soapEnvelope, action, igdWANIPConnection)
+	req, err := http.NewRequestWithContext(ctx, "POST", s.url, strings.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", `text/xml; charset="utf-8"`)
+	req.Header.Set("SOAPAction", fmt.Sprintf(`"%s#%s"`, igdWANIPConnection, action))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to query router: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("router returned status %d for %s", resp.StatusCode, action)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if err != nil {
+		return "", err
+	}
+
+	ip, err := parseExternalIP(data, isIPv6)
+	if err != nil {
+		return "", err
+	}
+
+	parsedIP := net.ParseIP(ip)
+	if parsedIP == nil {
+		return "", fmt.Errorf("router returned invalid IP address: %s", ip)
+	}
+	if (parsedIP.To4() == nil) != isIPv6 {
+		return "", fmt.Errorf("router returned an address of the wrong family: %s", ip)
+	}
+
+	answeredBy(ctx, serviceHost(s.url))
+	return ip, nil
+}
+
+// parseExternalIP extracts the address element from the SOAP response
+func parseExternalIP(data []byte, isIPv6 bool) (string, error) {
+	element := "NewExternalIPAddress"
+	if isIPv6 {
+		element = "NewExternalIPv6Address"
+	}
+
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return "", fmt.Errorf("%s missing from router response", element)
+		}
+
+		start, ok := token.(xml.StartElement)
+		if !ok || start.Name.Local != element {
+			continue
+		}
+
+		var value string
+		if err := decoder.DecodeElement(&value, &start); err != nil {
+			return "", fmt.Errorf("failed to parse router response: %w", err)
+		}
+		value = strings.TrimSpace(value)
+		if value == "" {
+			return "", fmt.Errorf("router has no external address")
+		}
+		return value, nil
+	}
+}
@@ -0,0 +1,116 @@
+package ipdetect
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/netip"
+)
+
+// documentationPrefixes are reserved for examples and never in use on the
+// internet (RFC 5737, RFC 3849 and RFC 9637)
+var documentationPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("192.0.2.0/24"),
+	netip.MustParsePrefix("198.51.100.0/24"),
+	netip.MustParsePrefix("203.0.113.0/24"),
+	netip.MustParsePrefix("2001:db8::/32"),
+	netip.MustParsePrefix("3fff::/20"),
+}
+
+// cgnatPrefix is the shared address space of carrier-grade NAT (RFC 6598)
+var cgnatPrefix = netip.MustParsePrefix("100.64.0.0/10")
+
+// Address classes reported by Classify
+const (
+	AddressPublic  = "public"
+	AddressCGNAT   = "cgnat"   // shared by the subscribers of a carrier-grade NAT
+	AddressPrivate = "private" // RFC 1918 and unique local addresses
+)
+
+// Classify tells public addresses from those of a carrier-grade NAT and
+// private ones, neither of which can be reached from the internet. It returns
+// an empty string for invalid addresses.
+func Classify(ip string) string {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return ""
+	}
+	addr = addr.WithZone("").Unmap()
+	switch {
+	case cgnatPrefix.Contains(addr):
+		return AddressCGNAT
+	case addr.IsPrivate():
+		return AddressPrivate
+	default:
+		return AddressPublic
+	}
+}
+
+// warnUnreachable logs a warning for a newly detected address the internet
+// can't reach, as records pointing to it are of no use from outside
+func warnUnreachable(ip string) {
+	switch Classify(ip) {
+	case AddressCGNAT:
+		log.Printf("Warning: detected IPv4 address %s is behind carrier-grade NAT (100.64.0.0/10) and can't be reached from the internet; ask the ISP for a public address, use IPv6, or set ip_detection.skip_on_cgnat to leave A records alone", ip)
+	case AddressPrivate:
+		log.Printf("Warning: detected address %s is private; the detection source may be broken or answering through a proxy", ip)
+	}
+}
+
+// CheckPublishable returns an error for addresses that must never be
+// published: unspecified, loopback, link-local, multicast and documentation
+// addresses, which a misconfigured interface or detection service may report.
+// Private addresses are allowed, as records may point into a LAN.
+func CheckPublishable(ip string) error {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return fmt.Errorf("invalid IP address: %s", ip)
+	}
+	addr = addr.WithZone("").Unmap()
+
+	kind := ""
+	switch {
+	case addr.IsUnspecified():
+		kind = "unspecified"
+	case addr.IsLoopback():
+		kind = "loopback"
+	case addr.IsLinkLocalUnicast():
+		kind = "link-local"
+	case addr.IsMulticast():
+		kind = "multicast"
+	default:
+		for _, prefix := range documentationPrefixes {
+			if prefix.Contains(addr) {
+				kind = "documentation"
+			}
+		}
+	}
+	if kind != "" {
+		return fmt.Errorf("refusing to publish %s: %s address", addr, kind)
+	}
+	return nil
+}
+
+// guardedSource rejects the addresses of a source that CheckPublishable
+// refuses, so that a quorum can fall back to the other sources
+type guardedSource struct {
+	Source
+}
+
+// GetIP returns the source's address in canonical form if it may be
+// published. Quorums compare the canonical forms, so that differently written
+// forms of one address agree.
+func (s guardedSource) GetIP(ctx context.Context, isIPv6 bool) (string, error) {
+	ip, err := s.Source.GetIP(ctx, isIPv6)
+	if err != nil {
+		return "", err
+	}
+	normalized, err := NormalizeIP(ip)
+	if err != nil {
+		return "", err
+	}
+	if err := CheckPublishable(normalized); err != nil {
+		return "", fmt.Errorf("%s: %w", s.Name(), err)
+	}
+	return normalized, nil
+}
@@ -0,0 +1,312 @@
+package ipdetect
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/MrLonely14/cf-ddns/config"
+	"github.com/MrLonely14/cf-ddns/sysproxy"
+)
+
+// IPv4 services to try in order
+var ipv4Services = []string{
+	"https://api.ipify.org",
+	"https://icanhazip.com",
+	"https://ifconfig.me/ip",
+	"https://checkip.amazonaws.com",
+}
+
+// IPv6 services to try in order
+var ipv6Services = []string{
+	"https://api64.ipify.org",
+	"https://ipv6.icanhazip.com",
+	"https://v6.ident.me",
+}
+
+// maxResponseSize caps how much of a response is read. An address is at most
+// 45 bytes; the rest leaves room for services that add a label or a newline,
+// while a misbehaving service answering with a large page is cut off early.
+const maxResponseSize = 256
+
+// idleConnTimeout keeps connections to detection services open between cycles,
+// saving a TCP and TLS handshake per check on slow or metered links
+const idleConnTimeout = 15 * time.Minute
+
+// service is a detection service and its request timeout
+type service struct {
+	url     string
+	timeout time.Duration // 0 uses the client's timeout
+}
+
+// serviceList returns the services of one family: the configured ones
+// followed by the built-in ones, unless configured ones replace them
+func serviceList(configured []config.DetectionURL, builtin []string, replace bool) []service {
+	var services []service
+	for _, c := range configured {
+		services = append(services, service{url: c.URL, timeout: c.GetTimeout()})
+	}
+	if replace && len(services) > 0 {
+		return services
+	}
+	for _, url := range builtin {
+		services = append(services, service{url: url})
+	}
+	return services
+}
+
+// httpSource detects the public IP by asking external HTTP services
+type httpSource struct {
+	name     string
+	ipv4     []service
+	ipv6     []service
+	client   *http.Client // dials IPv4 only
+	client6  *http.Client // dials IPv6 only
+	route    *routeTracker
+	breakers breakerSet
+}
+
+// newHTTPSource creates a source using the configured and built-in service
+// lists. With a route tracker, requests leave through the current default route.
+func newHTTPSource(route *routeTracker, cfg config.IPDetectionConfig) *httpSource {
+	doh := newDoHResolver(cfg.DoHURL, cfg.DoHBootstrap)
+	s := &httpSource{
+		name:    "http",
+		ipv4:    serviceList(cfg.IPv4URLs, ipv4Services, cfg.ReplaceBuiltinURLs),
+		ipv6:    serviceList(cfg.IPv6URLs, ipv6Services, cfg.ReplaceBuiltinURLs),
+		client:  newHTTPClient(route, doh, false),
+		client6: newHTTPClient(route, doh, true),
+		route:   route,
+	}
+	if cfg.HTTP3 {
+		s.enableHTTP3(doh)
+	}
+	if route != nil {
+		// Connections kept alive on the previous uplink must not be reused
+		route.onChange = func() {
+			s.client.CloseIdleConnections()
+			s.client6.CloseIdleConnections()
+		}
+	}
+	return s
+}
+
+// newHTTPClient creates a client that dials only one address family and keeps
+// HTTP/1.1 connections alive. HTTP/2 is disabled: for a single tiny request
+// its connection setup costs more than it saves. With a DoH resolver, service
+// host names are resolved with it instead of the system resolver.
+func newHTTPClient(route *routeTracker, doh *dohResolver, isIPv6 bool) *http.Client {
+	network := "tcp4"
+	if isIPv6 {
+		network = "tcp6"
+	}
+	return &http.Client{
+		Timeout: 10 * time.Second,
+		Transport: &http.Transport{
+			Proxy: sysproxy.Proxy,
+			DialContext: func(ctx context.Context, _, addr string) (net.Conn, error) {
+				dialer := &net.Dialer{
+					Timeout:   5 * time.Second,
+					LocalAddr: route.localAddr(isIPv6, network),
+				}
+				if doh != nil {
+					return doh.dial(ctx, dialer, network, addr)
+				}
+				return dialer.DialContext(ctx, network, addr)
+			},
+			TLSHandshakeTimeout: 5 * time.Second,
+			MaxIdleConnsPerHost: 1,
+			IdleConnTimeout:     idleConnTimeout,
+			TLSNextProto:        map[string]func(string, *tls.Conn) http.RoundTripper{},
+		},
+	}
+}
+
+// Name identifies the source in logs
+func (s *httpSource) Name() string {
+	return s.name
+}
+
+// GetIP tries each service in order until one returns a valid address
+func (s *httpSource) GetIP(ctx context.Context, isIPv6 bool) (string, error) {
+	services, family := s.ipv4, "IPv4"
+	if isIPv6 {
+		services, family = s.ipv6, "IPv6"
+	}
+	s.route.refresh(isIPv6)
+
+	// Skip services whose circuit breaker is open; if every breaker is
+	// open, try them all rather than failing without a single request
+	tried := false
+	for _, svc := range services {
+		b := s.breakers.get(svc.url)
+		if !b.allow(time.Now()) {
+			continue
+		}
+		tried = true
+		if ip, ok := s.try(ctx, b, svc, isIPv6); ok {
+			return ip, nil
+		}
+	}
+	if !tried {
+		for _, svc := range services {
+			if ip, ok := s.try(ctx, s.breakers.get(svc.url), svc, isIPv6); ok {
+				return ip, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("failed to detect %s address from all services", family)
+}
+
+// try calls a service and records the outcome in its breaker
+func (s *httpSource) try(ctx context.Context, b *breaker, svc service, isIPv6 bool) (string, bool) {
+	fetchCtx := ctx
+	if svc.timeout > 0 {
+		var cancel context.CancelFunc
+		fetchCtx, cancel = context.WithTimeout(ctx, svc.timeout)
+		defer cancel()
+	}
+	ip, err := s.fetchIP(fetchCtx, svc.url, isIPv6)
+	if err == nil && ip != "" {
+		b.success()
+		answeredBy(ctx, serviceHost(svc.url))
+		return ip, true
+	}
+	// A timed out service counts as failed, a cancelled cycle does not
+	if ctx.Err() != nil {
+		b.release()
+		return "", false
+	}
+	log.Printf("Warning: detection service %s failed: %v", serviceHost(svc.url), err)
+	b.failure(time.Now())
+	return "", false
+}
+
+// fetchIP fetches IP from a service and validates it
+func (s *httpSource) fetchIP(ctx context.Context, url string, isIPv6 bool) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", "text/plain")
+
+	client := s.client
+	if isIPv6 {
+		client = s.client6
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("service returned status %d", resp.StatusCode)
+	}
+
+	trace := isTraceURL(url)
+	limit := maxResponseSize
+	if trace {
+		limit = maxTraceSize
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, int64(limit+1)))
+	if err != nil {
+		return "", err
+	}
+	if len(body) > limit {
+		return "", fmt.Errorf("response is larger than %d bytes, not an IP address answer", limit)
+	}
+
+	text := string(body)
+	if trace {
+		if text, err = parseTrace(text); err != nil {
+			return "", err
+		}
+	} else if isHTML(resp.Header.Get("Content-Type"), text) {
+		return "", fmt.Errorf("service returned an HTML page instead of an address: %q", excerpt(text))
+	}
+	return extractIP(text, isIPv6)
+}
+
+// isHTML reports whether an answer is a web page, such as a captive portal or
+// a proxy's error page
+func isHTML(contentType, text string) bool {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	return mediaType == "text/html" || mediaType == "application/xhtml+xml" ||
+		strings.HasPrefix(strings.TrimSpace(text), "<")
+}
+
+// extractIP finds the address of the requested family in an answer. Most
+// services answer with the bare address, but some add a label or a comment,
+// so the answer is split into words and exactly one address must be found.
+func extractIP(text string, isIPv6 bool) (string, error) {
+	family, other := "IPv4", "IPv6"
+	if isIPv6 {
+		family, other = "IPv6", "IPv4"
+	}
+
+	var found []string
+	otherFamily := false
+	for _, word := range strings.FieldsFunc(text, isAnswerSeparator) {
+		addr, err := netip.ParseAddr(strings.TrimRight(word, "."))
+		if err != nil {
+			continue
+		}
+		addr = addr.Unmap()
+		if addr.Is6() != isIPv6 {
+			otherFamily = true
+			continue
+		}
+		if ip := addr.String(); !slices.Contains(found, ip) {
+			found = append(found, ip)
+		}
+	}
+
+	switch {
+	case len(found) == 1:
+		return found[0], nil
+	case len(found) > 1:
+		return "", fmt.Errorf("answer contains several %s addresses: %q", family, excerpt(text))
+	case otherFamily:
+		return "", fmt.Errorf("expected %s but got %s", family, other)
+	default:
+		return "", fmt.Errorf("no %s address in answer: %q", family, excerpt(text))
+	}
+}
+
+// isAnswerSeparator splits answers into words; colons are kept, as they are
+// part of IPv6 addresses
+func isAnswerSeparator(r rune) bool {
+	return unicode.IsSpace(r) || strings.ContainsRune(`,;"'=()[]{}<>`, r)
+}
+
+// serviceHost identifies a service in logs without the rest of its URL,
+// which may hold a key
+func serviceHost(raw string) string {
+	if u, err := url.Parse(raw); err == nil && u.Host != "" {
+		return u.Host
+	}
+	return "(invalid URL)"
+}
+
+// excerpt shortens an answer for error messages
+func excerpt(text string) string {
+	const maxExcerpt = 40
+	text = strings.TrimSpace(text)
+	if len(text) > maxExcerpt {
+		return text[:maxExcerpt] + "..."
+	}
+	return text
+}
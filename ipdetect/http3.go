@@ -0,0 +1,136 @@
+//go:build !minimal
+
+package ipdetect
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"time"
+
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/http3"
+)
+
+// http3HandshakeTimeout bounds the QUIC handshake, after which the request
+// is sent over TCP instead
+const http3HandshakeTimeout = 3 * time.Second
+
+// enableHTTP3 sends detection requests over HTTP/3. Services that can't be
+// reached over QUIC are asked over TCP as before.
+func (s *httpSource) enableHTTP3(doh *dohResolver) {
+	s.client.Transport = &http3Transport{h3: newHTTP3Transport(s.route, doh, false), tcp: s.client.Transport}
+	s.client6.Transport = &http3Transport{h3: newHTTP3Transport(s.route, doh, true), tcp: s.client6.Transport}
+}
+
+// http3Transport tries HTTP/3 first and falls back to TCP. A host that fails
+// over HTTP/3 is asked over TCP for a growing backoff, like a failed service,
+// so that networks blocking QUIC don't cost a handshake timeout every check.
+type http3Transport struct {
+	h3       *http3.Transport
+	tcp      http.RoundTripper
+	breakers breakerSet // keyed by host
+}
+
+// RoundTrip sends the request over HTTP/3 unless the host's breaker is open
+func (t *http3Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	b := t.breakers.get(req.URL.Host)
+	if !b.allow(time.Now()) {
+		return t.tcp.RoundTrip(req)
+	}
+
+	resp, err := t.h3.RoundTrip(req)
+	if err == nil {
+		b.success()
+		return resp, nil
+	}
+	if req.Context().Err() != nil {
+		b.release()
+		return nil, err
+	}
+	log.Printf("Warning: HTTP/3 request to %s failed, using TCP: %v", req.URL.Host, err)
+	b.failure(time.Now())
+	return t.tcp.RoundTrip(req)
+}
+
+// CloseIdleConnections closes idle connections of both transports, e.g.
+// after the default route moved
+func (t *http3Transport) CloseIdleConnections() {
+	t.h3.CloseIdleConnections()
+	if c, ok := t.tcp.(interface{ CloseIdleConnections() }); ok {
+		c.CloseIdleConnections()
+	}
+}
+
+// newHTTP3Transport creates an HTTP/3 transport that dials only one address
+// family, from the current default route's address if one is tracked. With a
+// DoH resolver, service host names are resolved with it.
+func newHTTP3Transport(route *routeTracker, doh *dohResolver, isIPv6 bool) *http3.Transport {
+	network := "udp4"
+	if isIPv6 {
+		network = "udp6"
+	}
+	return &http3.Transport{
+		QUICConfig: &quic.Config{HandshakeIdleTimeout: http3HandshakeTimeout},
+		Dial: func(ctx context.Context, addr string, tlsCfg *tls.Config, cfg *quic.Config) (*quic.Conn, error) {
+			ips, port, err := resolveQUICAddr(ctx, doh, addr, isIPv6)
+			if err != nil {
+				return nil, err
+			}
+			local, _ := route.localAddr(isIPv6, network).(*net.UDPAddr)
+
+			var dialErr error
+			for _, ip := range ips {
+				udpConn, err := net.ListenUDP(network, local)
+				if err != nil {
+					return nil, err
+				}
+				conn, err := quic.DialEarly(ctx, udpConn, net.UDPAddrFromAddrPort(netip.AddrPortFrom(ip, port)), tlsCfg, cfg)
+				if err != nil {
+					udpConn.Close()
+					dialErr = err
+					continue
+				}
+				// The socket belongs to this connection alone
+				go func() {
+					<-conn.Context().Done()
+					udpConn.Close()
+				}()
+				return conn, nil
+			}
+			return nil, dialErr
+		},
+	}
+}
+
+// resolveQUICAddr returns the addresses of one family and the port of addr,
+// resolving its host with DoH if configured
+func resolveQUICAddr(ctx context.Context, doh *dohResolver, addr string, isIPv6 bool) ([]netip.Addr, uint16, error) {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, 0, err
+	}
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil {
+		return nil, 0, fmt.Errorf("invalid port %s", portStr)
+	}
+
+	if ip, err := netip.ParseAddr(host); err == nil {
+		return []netip.Addr{ip}, uint16(port), nil
+	}
+	if doh != nil {
+		ips, err := doh.lookup(ctx, host, isIPv6)
+		return ips, uint16(port), err
+	}
+	family := "ip4"
+	if isIPv6 {
+		family = "ip6"
+	}
+	ips, err := net.DefaultResolver.LookupNetIP(ctx, family, host)
+	return ips, uint16(port), err
+}
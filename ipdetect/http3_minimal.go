@@ -0,0 +1,11 @@
+//go:build minimal
+
+package ipdetect
+
+import "log"
+
+// enableHTTP3 warns that HTTP/3, which builds with the minimal tag leave
+// out, is not used
+func (s *httpSource) enableHTTP3(doh *dohResolver) {
+	log.Printf("Warning: this build has no HTTP/3 (built with the minimal tag); ignoring ip_detection.http3")
+}
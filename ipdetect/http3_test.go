@@ -0,0 +1,81 @@
+//go:build !minimal
+
+package ipdetect
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/quic-go/quic-go/http3"
+)
+
+// answerHandler answers detection requests with a fixed address and the
+// protocol they arrived over
+func answerHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("X-Proto", r.Proto)
+	fmt.Fprint(w, "198.51.100.4")
+}
+
+// testTransport returns an HTTP/3 transport with a TCP fallback, both
+// trusting the test server's certificate
+func testTransport(tcp *httptest.Server) *http3Transport {
+	t := &http3Transport{h3: newHTTP3Transport(nil, nil, false), tcp: tcp.Client().Transport}
+	t.h3.TLSClientConfig = tcp.Client().Transport.(*http.Transport).TLSClientConfig
+	return t
+}
+
+func get(t *testing.T, transport http.RoundTripper, url string) string {
+	t.Helper()
+	req, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, url, nil)
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("RoundTrip() error = %v", err)
+	}
+	defer resp.Body.Close()
+	return resp.Header.Get("X-Proto")
+}
+
+func TestHTTP3(t *testing.T) {
+	tcp := httptest.NewTLSServer(http.HandlerFunc(answerHandler))
+	defer tcp.Close()
+
+	// Serve HTTP/3 on the UDP port of the same address
+	udpConn, err := net.ListenUDP("udp4", net.UDPAddrFromAddrPort(tcp.Listener.Addr().(*net.TCPAddr).AddrPort()))
+	if err != nil {
+		t.Skipf("cannot listen on UDP: %v", err)
+	}
+	h3 := &http3.Server{
+		Handler:   http.HandlerFunc(answerHandler),
+		TLSConfig: http3.ConfigureTLSConfig(&tls.Config{Certificates: tcp.TLS.Certificates}),
+	}
+	go h3.Serve(udpConn)
+	defer h3.Close()
+
+	transport := testTransport(tcp)
+	defer transport.h3.Close()
+	if proto := get(t, transport, tcp.URL); proto != "HTTP/3.0" {
+		t.Errorf("request was sent over %s, want HTTP/3.0", proto)
+	}
+}
+
+func TestHTTP3FallsBackToTCP(t *testing.T) {
+	// Nothing answers over QUIC
+	tcp := httptest.NewTLSServer(http.HandlerFunc(answerHandler))
+	defer tcp.Close()
+
+	transport := testTransport(tcp)
+	defer transport.h3.Close()
+	if proto := get(t, transport, tcp.URL); proto != "HTTP/1.1" {
+		t.Errorf("request was sent over %s, want HTTP/1.1", proto)
+	}
+	host := tcp.Listener.Addr().String()
+	if transport.breakers.get(host).allow(time.Now()) {
+		t.Error("HTTP/3 is still tried right after it failed")
+	}
+}
@@ -0,0 +1,66 @@
+package ipdetect
+
+import (
+	"context"
+	"fmt"
+	"net"
+)
+
+// interfaceSource reads the public address from a local network interface,
+// for hosts that hold their public address themselves such as a VPS or a
+// router with a static WAN address
+type interfaceSource struct {
+	name string
+}
+
+// newInterfaceSource creates a source for the named interface
+func newInterfaceSource(name string) *interfaceSource {
+	return &interfaceSource{name: name}
+}
+
+// Name identifies the source in logs
+func (s *interfaceSource) Name() string {
+	return "interface " + s.name
+}
+
+// GetIP returns the first public address of the requested family on the interface
+func (s *interfaceSource) GetIP(ctx context.Context, isIPv6 bool) (string, error) {
+	iface, err := net.InterfaceByName(s.name)
+	if err != nil {
+		return "", fmt.Errorf("failed to find interface %s: %w", s.name, err)
+	}
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return "", fmt.Errorf("failed to list addresses of %s: %w", s.name, err)
+	}
+
+	// Link-local, private, unique local and documentation addresses are
+	// skipped. A carrier-grade NAT address is only used if there is no other.
+	var cgnat net.IP
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		if !ok {
+			continue
+		}
+		ip := ipNet.IP
+		if (ip.To4() == nil) != isIPv6 {
+			continue
+		}
+		if cgnat == nil && isCGNAT(ip) {
+			cgnat = ip
+		}
+		if !isPublicIP(ip) || CheckPublishable(ip.String()) != nil {
+			continue
+		}
+		return ip.String(), nil
+	}
+	if cgnat != nil {
+		return cgnat.String(), nil
+	}
+
+	family := "IPv4"
+	if isIPv6 {
+		family = "IPv6"
+	}
+	return "", fmt.Errorf("no public %s address on interface %s", family, s.name)
+}
@@ -0,0 +1,89 @@
+package ipdetect
+
+import (
+	"context"
+	"log"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// meteredCheckInterval is how long a metered check result is reused
+const meteredCheckInterval = time.Minute
+
+// nmMeteredPattern extracts the NMMetered value from gdbus output, e.g. "(<uint32 1>,)"
+var nmMeteredPattern = regexp.MustCompile(`uint32 (\d+)`)
+
+// meteredSource uses the DNS source while NetworkManager reports the primary
+// connection as metered (e.g. an LTE backup link) and the regular source otherwise
+type meteredSource struct {
+	regular Source
+	dns     Source
+
+	mu        sync.Mutex
+	metered   bool
+	checkedAt time.Time
+}
+
+// Name identifies the source in logs
+func (s *meteredSource) Name() string {
+	return s.regular.Name() + "+dns-when-metered"
+}
+
+// GetIP detects the address with the source suited to the current connection
+func (s *meteredSource) GetIP(ctx context.Context, isIPv6 bool) (string, error) {
+	if s.isMetered(ctx) {
+		return s.dns.GetIP(ctx, isIPv6)
+	}
+	return s.regular.GetIP(ctx, isIPv6)
+}
+
+// isMetered reports whether the connection is metered, logging when that changes
+func (s *meteredSource) isMetered(ctx context.Context) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if time.Since(s.checkedAt) < meteredCheckInterval {
+		return s.metered
+	}
+	s.checkedAt = time.Now()
+
+	metered := networkManagerMetered(ctx)
+	if metered != s.metered {
+		if metered {
+			log.Println("Connection is metered, detecting the IP via DNS")
+		} else {
+			log.Println("Connection is no longer metered, using the regular IP source")
+		}
+	}
+	s.metered = metered
+	return metered
+}
+
+// networkManagerMetered reads NetworkManager's Metered property. Unknown or
+// unavailable values count as not metered.
+func networkManagerMetered(ctx context.Context) bool {
+	if runtime.GOOS != "linux" {
+		return false
+	}
+
+	out, err := exec.CommandContext(ctx, "gdbus", "call", "--system",
+		"--dest", "org.freedesktop.NetworkManager",
+		"--object-path", "/org/freedesktop/NetworkManager",
+		"--method", "org.freedesktop.DBus.Properties.Get",
+		"org.freedesktop.NetworkManager", "Metered",
+	).Output()
+	if err != nil {
+		return false
+	}
+
+	match := nmMeteredPattern.FindSubmatch(out)
+	if match == nil {
+		return false
+	}
+	// NM_METERED_YES (1) or NM_METERED_GUESS_YES (3)
+	value := string(match[1])
+	return value == "1" || value == "3"
+}
@@ -0,0 +1,118 @@
+package ipdetect
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Quorum policies for combining several sources
+const (
+	QuorumFirstSuccess = "first-success"
+	QuorumMajority     = "majority"
+	QuorumAllAgree     = "all-agree"
+)
+
+// weightedSource is a source together with its vote weight
+type weightedSource struct {
+	source Source
+	weight int
+}
+
+// quorumSource combines several sources according to a quorum policy
+type quorumSource struct {
+	sources []weightedSource
+	policy  string
+}
+
+// Name identifies the source in logs
+func (q *quorumSource) Name() string {
+	names := make([]string, len(q.sources))
+	for i, ws := range q.sources {
+		names[i] = ws.source.Name()
+	}
+	return fmt.Sprintf("%s(%s)", q.policy, strings.Join(names, ","))
+}
+
+// GetIP resolves the address according to the configured policy
+func (q *quorumSource) GetIP(ctx context.Context, isIPv6 bool) (string, error) {
+	if q.policy == QuorumFirstSuccess {
+		return q.firstSuccess(ctx, isIPv6)
+	}
+	return q.vote(ctx, isIPv6)
+}
+
+// firstSuccess tries the sources in order and returns the first answer
+func (q *quorumSource) firstSuccess(ctx context.Context, isIPv6 bool) (string, error) {
+	var errs []string
+	for _, ws := range q.sources {
+		ip, err := ws.source.GetIP(ctx, isIPv6)
+		if err == nil {
+			log.Printf("IP quorum %s: %s answered %s", q.policy, ws.source.Name(), ip)
+			return ip, nil
+		}
+		errs = append(errs, fmt.Sprintf("%s: %v", ws.source.Name(), err))
+	}
+	return "", fmt.Errorf("all IP sources failed: %s", strings.Join(errs, "; "))
+}
+
+// vote queries every source concurrently and tallies weighted votes per address
+func (q *quorumSource) vote(ctx context.Context, isIPv6 bool) (string, error) {
+	type answer struct {
+		name string
+		ip   string
+		err  error
+	}
+
+	answers := make([]answer, len(q.sources))
+	var wg sync.WaitGroup
+	for i, ws := range q.sources {
+		wg.Add(1)
+		go func(i int, src Source) {
+			defer wg.Done()
+			ip, err := src.GetIP(ctx, isIPv6)
+			answers[i] = answer{name: src.Name(), ip: ip, err: err}
+		}(i, ws.source)
+	}
+	wg.Wait()
+
+	totalWeight := 0
+	votes := make(map[string]int)
+	var details []string
+	for i, a := range answers {
+		totalWeight += q.sources[i].weight
+		if a.err != nil {
+			details = append(details, fmt.Sprintf("%s failed: %v", a.name, a.err))
+			continue
+		}
+		votes[a.ip] += q.sources[i].weight
+		details = append(details, fmt.Sprintf("%s=%s", a.name, a.ip))
+	}
+
+	// Pick the address with the most weight
+	candidates := make([]string, 0, len(votes))
+	for ip := range votes {
+		candidates = append(candidates, ip)
+	}
+	sort.Slice(candidates, func(i, j int) bool { return votes[candidates[i]] > votes[candidates[j]] })
+
+	if len(candidates) > 0 {
+		best := candidates[0]
+		agreed := false
+		switch q.policy {
+		case QuorumMajority:
+			agreed = votes[best]*2 > totalWeight
+		case QuorumAllAgree:
+			agreed = votes[best] == totalWeight
+		}
+		if agreed {
+			log.Printf("IP quorum %s: %s with %d/%d weight (%s)", q.policy, best, votes[best], totalWeight, strings.Join(details, ", "))
+			return best, nil
+		}
+	}
+
+	return "", fmt.Errorf("IP quorum %s not reached (%s)", q.policy, strings.Join(details, ", "))
+}
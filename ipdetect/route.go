@@ -0,0 +1,119 @@
+package ipdetect
+
+import (
+	"log"
+	"net"
+	"strings"
+	"sync"
+)
+
+// routeProbes are public addresses used to ask the OS which local address,
+// and thus which interface, currently holds the default route. No packets are
+// sent: connecting a UDP socket only performs the route lookup. Documentation
+// prefixes are avoided because some networks use them on-link.
+var routeProbes = map[bool]string{
+	false: "1.1.1.1:53",
+	true:  "[2606:4700:4700::1111]:53",
+}
+
+// routeTracker follows the default route so detection requests leave through
+// the uplink that currently holds it, e.g. the backup WAN after a failover
+type routeTracker struct {
+	mu    sync.Mutex
+	local map[bool]net.IP // by isIPv6
+
+	// onChange is called after the route of a family moved to another address
+	onChange func()
+}
+
+// newRouteTracker creates a tracker, or returns nil when tracking is disabled
+func newRouteTracker(enabled bool) *routeTracker {
+	if !enabled {
+		return nil
+	}
+	return &routeTracker{local: make(map[bool]net.IP)}
+}
+
+// refresh re-evaluates the default route of a family
+func (r *routeTracker) refresh(isIPv6 bool) {
+	if r == nil {
+		return
+	}
+
+	network := "udp4"
+	if isIPv6 {
+		network = "udp6"
+	}
+
+	var local net.IP
+	if conn, err := net.Dial(network, routeProbes[isIPv6]); err == nil {
+		local = conn.LocalAddr().(*net.UDPAddr).IP
+		conn.Close()
+	}
+
+	r.mu.Lock()
+	previous := r.local[isIPv6]
+	r.local[isIPv6] = local
+	onChange := r.onChange
+	r.mu.Unlock()
+
+	if local.Equal(previous) {
+		return
+	}
+	if local == nil {
+		log.Printf("No default route for %s", familyName(isIPv6))
+	} else {
+		log.Printf("Default %s route is via %s (%s)", familyName(isIPv6), interfaceName(local), local)
+	}
+	if previous != nil && onChange != nil {
+		onChange()
+	}
+}
+
+// localAddr returns the address of the current default route for dialing the
+// given network, or nil to let the OS choose
+func (r *routeTracker) localAddr(isIPv6 bool, network string) net.Addr {
+	if r == nil {
+		return nil
+	}
+
+	r.mu.Lock()
+	local := r.local[isIPv6]
+	r.mu.Unlock()
+
+	if local == nil {
+		return nil
+	}
+	if strings.HasPrefix(network, "udp") {
+		return &net.UDPAddr{IP: local}
+	}
+	return &net.TCPAddr{IP: local}
+}
+
+// interfaceName returns the name of the interface holding an address
+func interfaceName(ip net.IP) string {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return "unknown interface"
+	}
+	for _, iface := range ifaces {
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			if ipnet, ok := addr.(*net.IPNet); ok && ipnet.IP.Equal(ip) {
+				return iface.Name
+			}
+		}
+	}
+	return "unknown interface"
+}
+
+// familyName names an address family for logs
+func familyName(isIPv6 bool) string {
+	if isIPv6 {
+		return "IPv6"
+	}
+	return "IPv4"
+}
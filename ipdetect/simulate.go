@@ -0,0 +1,47 @@
+package ipdetect
+
+import (
+	"context"
+	"net/netip"
+)
+
+// simulatedSource reports a fixed address for one family and asks the real
+// source for the other
+type simulatedSource struct {
+	real   Source
+	ip     string
+	isIPv6 bool
+}
+
+// Name identifies the source in logs
+func (s *simulatedSource) Name() string {
+	return "simulated"
+}
+
+// GetIP returns the simulated address if it has the requested family
+func (s *simulatedSource) GetIP(ctx context.Context, isIPv6 bool) (string, error) {
+	if isIPv6 == s.isIPv6 {
+		return s.ip, nil
+	}
+	return s.real.GetIP(ctx, isIPv6)
+}
+
+// Simulate makes the detector report ip in place of the detected address of
+// the same family until restore is called. It must not be called while a
+// detection is running.
+func (d *Detector) Simulate(ip string) (restore func(), err error) {
+	normalized, err := NormalizeIP(ip)
+	if err != nil {
+		return nil, err
+	}
+
+	// Simulated addresses are kept out of the history
+	real, observer := d.source, d.observer
+	d.source = &simulatedSource{
+		real:   real,
+		ip:     normalized,
+		isIPv6: netip.MustParseAddr(normalized).Is6(),
+	}
+	d.observer = nil
+	return func() { d.source, d.observer = real, observer }, nil
+}
@@ -0,0 +1,249 @@
+package ipdetect
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/MrLonely14/cf-ddns/config"
+)
+
+// SNMP objects used to find the router's WAN address
+const (
+	oidIfDescr          = ".1.3.6.1.2.1.2.2.1.2"  // IF-MIB::ifDescr
+	oidIPAdEntIfIndex   = ".1.3.6.1.2.1.4.20.1.2" // IP-MIB::ipAdEntIfIndex (IPv4)
+	oidIPAddressIfIndex = ".1.3.6.1.2.1.4.34.1.3" // IP-MIB::ipAddressIfIndex (IPv4 and IPv6)
+)
+
+// snmpSource reads the WAN interface address from a router via SNMP.
+// Queries are made with the net-snmp snmpwalk tool, which reads the
+// credentials from a temporary snmp.conf rather than its command line.
+type snmpSource struct {
+	cfg config.SNMPConfig
+}
+
+// newSNMPSource creates a source for the configured router
+func newSNMPSource(cfg config.SNMPConfig) *snmpSource {
+	return &snmpSource{cfg: cfg}
+}
+
+// Name identifies the source in logs
+func (s *snmpSource) Name() string {
+	return "snmp"
+}
+
+// GetIP returns the public address of the configured interface, or the first
+// public address on the router if no interface is configured
+func (s *snmpSource) GetIP(ctx context.Context, isIPv6 bool) (string, error) {
+	addresses, err := s.addresses(ctx, isIPv6)
+	if err != nil {
+		return "", err
+	}
+
+	var ifIndex string
+	if s.cfg.Interface != "" {
+		names, err := s.walk(ctx, oidIfDescr)
+		if err != nil {
+			return "", err
+		}
+		for index, name := range names {
+			if name == s.cfg.Interface {
+				ifIndex = index
+				break
+			}
+		}
+		if ifIndex == "" {
+			return "", fmt.Errorf("interface %s not found on %s", s.cfg.Interface, s.cfg.Host)
+		}
+	}
+
+	var cgnat net.IP
+	for _, addr := range addresses {
+		if ifIndex != "" && addr.ifIndex != ifIndex {
+			continue
+		}
+		if isPublicIP(addr.ip) {
+			answeredBy(ctx, s.cfg.Host)
+			return addr.ip.String(), nil
+		}
+		if cgnat == nil && isCGNAT(addr.ip) {
+			cgnat = addr.ip
+		}
+	}
+	if cgnat != nil {
+		answeredBy(ctx, s.cfg.Host)
+		return cgnat.String(), nil
+	}
+
+	family := "IPv4"
+	if isIPv6 {
+		family = "IPv6"
+	}
+	return "", fmt.Errorf("no public %s address found via SNMP on %s", family, s.cfg.Host)
+}
+
+// snmpAddress is an address assigned to a router interface
+type snmpAddress struct {
+	ip      net.IP
+	ifIndex string
+}
+
+// addresses lists the router's addresses of one family with their interface index
+func (s *snmpSource) addresses(ctx context.Context, isIPv6 bool) ([]snmpAddress, error) {
+	var addresses []snmpAddress
+
+	// ipAddressTable covers both families: the index is type.length.octets
+	entries, err := s.walk(ctx, oidIPAddressIfIndex)
+	if err == nil {
+		for index, ifIndex := range entries {
+			if ip := parseIndexedAddress(index); ip != nil && (ip.To4() == nil) == isIPv6 {
+				addresses = append(addresses, snmpAddress{ip: ip, ifIndex: ifIndex})
+			}
+		}
+	}
+
+	// Older agents only implement the IPv4 ipAddrTable: the index is the address itself
+	if len(addresses) == 0 && !isIPv6 {
+		entries, err = s.walk(ctx, oidIPAdEntIfIndex)
+		if err != nil {
+			return nil, err
+		}
+		for index, ifIndex := range entries {
+			if ip := net.ParseIP(index).To4(); ip != nil {
+				addresses = append(addresses, snmpAddress{ip: ip, ifIndex: ifIndex})
+			}
+		}
+	}
+
+	return addresses, err
+}
+
+// walk runs snmpwalk on a subtree and returns the values keyed by the OID
+// suffix after the subtree
+func (s *snmpSource) walk(ctx context.Context, oid string) (map[string]string, error) {
+	// Credentials are passed in a private snmp.conf, as the command line
+	// can be read by every local user
+	confDir, err := os.MkdirTemp("", "cf-ddns-snmp-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create snmp.conf directory: %w", err)
+	}
+	defer os.RemoveAll(confDir)
+	if err := s.writeConf(filepath.Join(confDir, "snmp.conf")); err != nil {
+		return nil, err
+	}
+
+	args := append(s.authArgs(), "-On", "-Oq", "-t", "5", "-r", "1", s.cfg.Host, oid)
+	cmd := exec.CommandContext(ctx, "snmpwalk", args...)
+	cmd.Env = append(os.Environ(), "SNMPCONFPATH="+confDir)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("snmpwalk failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	values := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		fields := strings.SplitN(scanner.Text(), " ", 2)
+		if len(fields) != 2 || !strings.HasPrefix(fields[0], oid+".") {
+			continue
+		}
+		values[strings.TrimPrefix(fields[0], oid+".")] = strings.Trim(fields[1], "\"")
+	}
+
+	return values, nil
+}
+
+// authArgs builds the snmpwalk flags for the configured version and
+// security level. The secrets themselves are in the file of writeConf.
+func (s *snmpSource) authArgs() []string {
+	if s.cfg.Version != "3" {
+		return []string{"-v2c"}
+	}
+
+	args := []string{"-v3", "-u", s.cfg.Username}
+	switch {
+	case s.cfg.PrivPassword != "":
+		args = append(args, "-l", "authPriv", "-a", s.cfg.AuthProtocol, "-x", s.cfg.PrivProtocol)
+	case s.cfg.AuthPassword != "":
+		args = append(args, "-l", "authNoPriv", "-a", s.cfg.AuthProtocol)
+	default:
+		args = append(args, "-l", "noAuthNoPriv")
+	}
+	return args
+}
+
+// writeConf writes the community or the v3 passphrases to an snmp.conf
+// only the current user can read
+func (s *snmpSource) writeConf(path string) error {
+	var lines []string
+	if s.cfg.Version != "3" {
+		community := s.cfg.Community
+		if community == "" {
+			community = "public"
+		}
+		lines = append(lines, "defCommunity "+community)
+	} else {
+		if s.cfg.AuthPassword != "" {
+			lines = append(lines, "defAuthPassphrase "+s.cfg.AuthPassword)
+		}
+		if s.cfg.PrivPassword != "" {
+			lines = append(lines, "defPrivPassphrase "+s.cfg.PrivPassword)
+		}
+	}
+	for _, line := range lines {
+		if strings.ContainsAny(line, "\r\n") {
+			return fmt.Errorf("SNMP credentials must not contain line breaks")
+		}
+	}
+
+	content := strings.Join(lines, "\n") + "\n"
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		return fmt.Errorf("failed to write snmp.conf: %w", err)
+	}
+	return nil
+}
+
+// parseIndexedAddress decodes an ipAddressTable index (type.length.octets)
+func parseIndexedAddress(index string) net.IP {
+	parts := strings.Split(index, ".")
+	if len(parts) < 2 {
+		return nil
+	}
+
+	length, err := strconv.Atoi(parts[1])
+	if err != nil || (length != net.IPv4len && length != net.IPv6len) || len(parts) != length+2 {
+		return nil
+	}
+
+	ip := make(net.IP, length)
+	for i, part := range parts[2:] {
+		octet, err := strconv.Atoi(part)
+		if err != nil || octet < 0 || octet > 255 {
+			return nil
+		}
+		ip[i] = byte(octet)
+	}
+	return ip
+}
+
+// isPublicIP reports whether an address is routable on the public internet.
+// Carrier-grade NAT addresses are not, though they are global unicast.
+func isPublicIP(ip net.IP) bool {
+	return ip.IsGlobalUnicast() && !ip.IsPrivate() && Classify(ip.String()) != AddressCGNAT
+}
+
+// isCGNAT reports whether an address is in the shared address space of
+// carrier-grade NAT. Sources that read the WAN address fall back to one, so
+// that the detector warns about it.
+func isCGNAT(ip net.IP) bool {
+	return Classify(ip.String()) == AddressCGNAT
+}
@@ -0,0 +1,46 @@
+package ipdetect
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/MrLonely14/cf-ddns/config"
+)
+
+// Cloudflare trace endpoints to try in order. Both hosts have IPv4 and IPv6
+// addresses, so they serve either family.
+var traceServices = []string{
+	"https://one.one.one.one/cdn-cgi/trace",
+	"https://www.cloudflare.com/cdn-cgi/trace",
+}
+
+// maxTraceSize caps how much of a trace response is read; the answer is a
+// dozen short key=value lines
+const maxTraceSize = 1024
+
+// newTraceSource creates a source that asks Cloudflare's trace endpoints,
+// run by the same provider the records are published with
+func newTraceSource(route *routeTracker, cfg config.IPDetectionConfig) *httpSource {
+	s := newHTTPSource(route, cfg)
+	s.name = "trace"
+	s.ipv4 = serviceList(nil, traceServices, false)
+	s.ipv6 = serviceList(nil, traceServices, false)
+	return s
+}
+
+// isTraceURL reports whether a service answers in the trace format
+func isTraceURL(raw string) bool {
+	u, err := url.Parse(raw)
+	return err == nil && strings.HasSuffix(u.Path, "/cdn-cgi/trace")
+}
+
+// parseTrace returns the ip= field of a trace response
+func parseTrace(body string) (string, error) {
+	for line := range strings.Lines(body) {
+		if value, ok := strings.CutPrefix(strings.TrimSpace(line), "ip="); ok {
+			return value, nil
+		}
+	}
+	return "", fmt.Errorf("trace response has no ip field")
+}
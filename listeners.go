@@ -0,0 +1,74 @@
+//go:build !minimal
+
+package main
+
+import (
+	"context"
+	"log"
+
+	"github.com/MrLonely14/cf-ddns/config"
+	"github.com/MrLonely14/cf-ddns/ipdetect"
+	"github.com/MrLonely14/cf-ddns/server"
+	"github.com/MrLonely14/cf-ddns/updater"
+)
+
+// listeners are the daemon's HTTP servers, all optional
+type listeners struct {
+	control *server.Server // health probes and control API
+	all     []*server.Server
+}
+
+// startListeners starts the HTTP listeners enabled in cfg. Control requests
+// that update records are handed to the daemon loop through calls.
+func startListeners(ctx context.Context, cfg *config.Config, opts runOptions, detector *ipdetect.Detector, upd *updater.Updater, calls chan<- loopCall) *listeners {
+	l := &listeners{}
+
+	if cfg.Server.Listen != "" {
+		srv := server.New(cfg.Server.Listen, upd)
+		srv.HandleForce(func(reqCtx context.Context, names []string) error {
+			return callLoop(reqCtx, calls, func() error { return forceUpdate(ctx, upd, names) })
+		})
+		// Simulated changes set any address on every record, so they need a
+		// push token scoped to all of them
+		simulateTokens := cfg.SimulateTokens()
+		switch {
+		case opts.simulateIP == "":
+		case len(simulateTokens) == 0:
+			log.Printf("Warning: /api/v1/simulate is disabled until one of server.push_tokens lists every record")
+		default:
+			srv.HandleSimulate(simulateTokens, func(reqCtx context.Context, ip string) error {
+				return callLoop(reqCtx, calls, func() error { return simulateChange(ctx, detector, upd, ip) })
+			})
+		}
+		if len(cfg.Server.PushTokens) > 0 {
+			srv.HandlePush(cfg.Server.PushTokens)
+		}
+		if cfg.Server.Pprof {
+			srv.HandlePprof()
+		}
+		srv.Start(ctx)
+		l.control = srv
+		l.all = append(l.all, srv)
+	}
+	if cfg.DynDNS.Listen != "" {
+		dyndns := server.NewDynDNS(cfg.DynDNS, upd)
+		dyndns.Start(ctx)
+		l.all = append(l.all, dyndns)
+	}
+
+	return l
+}
+
+// setReady marks startup as finished for readiness probes
+func (l *listeners) setReady() {
+	if l.control != nil {
+		l.control.SetReady()
+	}
+}
+
+// wait blocks until every listener has shut down after its context was cancelled
+func (l *listeners) wait() {
+	for _, s := range l.all {
+		s.Wait()
+	}
+}
@@ -0,0 +1,28 @@
+//go:build minimal
+
+package main
+
+import (
+	"context"
+	"log"
+
+	"github.com/MrLonely14/cf-ddns/config"
+	"github.com/MrLonely14/cf-ddns/ipdetect"
+	"github.com/MrLonely14/cf-ddns/updater"
+)
+
+// listeners stands in for the HTTP servers, which builds with the minimal
+// tag leave out
+type listeners struct{}
+
+// startListeners warns about listeners the configuration asks for
+func startListeners(ctx context.Context, cfg *config.Config, opts runOptions, detector *ipdetect.Detector, upd *updater.Updater, calls chan<- loopCall) *listeners {
+	if cfg.Server.Listen != "" || cfg.DynDNS.Listen != "" {
+		log.Printf("Warning: this build has no HTTP listeners (built with the minimal tag); ignoring server.listen and dyndns.listen")
+	}
+	return &listeners{}
+}
+
+func (l *listeners) setReady() {}
+
+func (l *listeners) wait() {}
@@ -0,0 +1,77 @@
+package logging
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+	"sync"
+)
+
+// lifecycleEvents tags well-known log messages with an event name, so
+// consumers can react to them without matching message text
+var lifecycleEvents = []struct {
+	prefix string
+	event  string
+}{
+	{"Starting Cloudflare DDNS Updater", "start"},
+	{"Daemon started", "ready"},
+	{"Cycle complete", "cycle"},
+	{"diff ", "change"},
+	{"Received signal", "stopping"},
+	{"Shutdown complete", "stopped"},
+}
+
+// entry is one JSON log line
+type entry struct {
+	Level   string `json:"level"` // info, warning or error
+	Event   string `json:"event,omitempty"`
+	Message string `json:"msg"`
+}
+
+// JSONWriter turns each log line into a JSON object on its own line. Use it
+// with log.SetFlags(0) so the message carries no timestamp prefix.
+type JSONWriter struct {
+	mu  sync.Mutex
+	out io.Writer
+}
+
+// NewJSONWriter creates a writer that emits JSON lines to out
+func NewJSONWriter(out io.Writer) *JSONWriter {
+	return &JSONWriter{out: out}
+}
+
+// Write encodes every line of p as a JSON object
+func (w *JSONWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	enc := json.NewEncoder(w.out)
+	for _, line := range strings.Split(strings.TrimRight(string(p), "\n"), "\n") {
+		if err := enc.Encode(parse(line)); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// parse derives the level and event of a log message
+func parse(line string) entry {
+	e := entry{Level: "info", Message: line}
+
+	switch {
+	case strings.HasPrefix(line, "ERROR: "):
+		e.Level, e.Message = "error", strings.TrimPrefix(line, "ERROR: ")
+	case strings.HasPrefix(line, "Warning: "):
+		e.Level, e.Message = "warning", strings.TrimPrefix(line, "Warning: ")
+	case strings.HasPrefix(line, "Failed to "):
+		e.Level = "error"
+	}
+
+	for _, le := range lifecycleEvents {
+		if strings.HasPrefix(e.Message, le.prefix) {
+			e.Event = le.event
+			break
+		}
+	}
+	return e
+}
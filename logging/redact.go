@@ -0,0 +1,81 @@
+package logging
+
+import (
+	"io"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// redactedText replaces secrets in log output and error strings
+const redactedText = "[redacted]"
+
+// minSecretLength skips values too short to be secrets, which would otherwise
+// garble ordinary words in every log line
+const minSecretLength = 4
+
+var (
+	secretsMu sync.RWMutex
+	replacer  *strings.Replacer
+)
+
+// SetSecrets sets the values that Redact and Redactor remove, replacing the
+// previous set. Their URL-escaped forms are removed as well, as they appear in
+// request URLs quoted by HTTP client errors.
+func SetSecrets(secrets []string) {
+	var values []string
+	seen := make(map[string]bool)
+	for _, secret := range secrets {
+		for _, value := range []string{secret, url.QueryEscape(secret), url.PathEscape(secret)} {
+			if len(value) >= minSecretLength && !seen[value] {
+				seen[value] = true
+				values = append(values, value)
+			}
+		}
+	}
+
+	// Longer secrets first, so one that contains another is removed whole
+	slices.SortFunc(values, func(a, b string) int { return len(b) - len(a) })
+	var pairs []string
+	for _, value := range values {
+		pairs = append(pairs, value, redactedText)
+	}
+
+	secretsMu.Lock()
+	defer secretsMu.Unlock()
+	replacer = nil
+	if len(pairs) > 0 {
+		replacer = strings.NewReplacer(pairs...)
+	}
+}
+
+// Redact returns s with every secret replaced
+func Redact(s string) string {
+	secretsMu.RLock()
+	defer secretsMu.RUnlock()
+	if replacer == nil {
+		return s
+	}
+	return replacer.Replace(s)
+}
+
+// Redactor removes secrets from everything written through it. Installed as
+// the log output, it covers every log line including wrapped errors from
+// libraries, which may quote credentials they were given.
+type Redactor struct {
+	out io.Writer
+}
+
+// NewRedactor creates a writer that redacts secrets before writing to out
+func NewRedactor(out io.Writer) *Redactor {
+	return &Redactor{out: out}
+}
+
+// Write redacts p and writes it to the underlying writer
+func (r *Redactor) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(r.out, Redact(string(p))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -0,0 +1,96 @@
+package logging
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestRedact(t *testing.T) {
+	const secret = "s3cr/et+to ken&x"
+	t.Cleanup(func() { SetSecrets(nil) })
+	SetSecrets([]string{secret})
+
+	tests := []struct {
+		name string
+		in   string
+	}{
+		{"raw", "token " + secret + " rejected"},
+		{"query escaped", "GET https://api.example.com/?token=" + url.QueryEscape(secret)},
+		{"path escaped", "GET https://api.example.com/" + url.PathEscape(secret) + "/records"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Redact(tt.in)
+			if !strings.Contains(got, redactedText) {
+				t.Errorf("Redact(%q) = %q, want %s", tt.in, got, redactedText)
+			}
+			for _, form := range []string{secret, url.QueryEscape(secret), url.PathEscape(secret)} {
+				if strings.Contains(got, form) {
+					t.Errorf("Redact(%q) = %q, still contains %q", tt.in, got, form)
+				}
+			}
+		})
+	}
+}
+
+func TestRedactLongestFirst(t *testing.T) {
+	t.Cleanup(func() { SetSecrets(nil) })
+	// The shorter secret is listed first and is part of the longer one
+	SetSecrets([]string{"abcd", "abcdefgh"})
+
+	if got, want := Redact("token=abcdefgh"), "token="+redactedText; got != want {
+		t.Errorf("Redact() = %q, want %q", got, want)
+	}
+	if got, want := Redact("token=abcdxyz"), "token="+redactedText+"xyz"; got != want {
+		t.Errorf("Redact() = %q, want %q", got, want)
+	}
+}
+
+func TestRedactMinLength(t *testing.T) {
+	t.Cleanup(func() { SetSecrets(nil) })
+	short := strings.Repeat("x", minSecretLength-1)
+	long := strings.Repeat("y", minSecretLength)
+	SetSecrets([]string{short, long})
+
+	if got := Redact("value " + short); got != "value "+short {
+		t.Errorf("Redact() = %q, want secrets shorter than %d kept", got, minSecretLength)
+	}
+	if got := Redact("value " + long); got != "value "+redactedText {
+		t.Errorf("Redact() = %q, want secrets of %d characters removed", got, minSecretLength)
+	}
+}
+
+func TestRedactNoSecrets(t *testing.T) {
+	SetSecrets(nil)
+	if got := Redact("nothing to hide"); got != "nothing to hide" {
+		t.Errorf("Redact() = %q, want the input unchanged", got)
+	}
+}
+
+func TestRedactorWrappedURLError(t *testing.T) {
+	const token = "tok/en+secret"
+	t.Cleanup(func() { SetSecrets(nil) })
+	SetSecrets([]string{token})
+
+	var out bytes.Buffer
+	logger := log.New(NewRedactor(&out), "", 0)
+	err := fmt.Errorf("failed to detect IP: %w", &url.Error{
+		Op:  "Get",
+		URL: "https://ip.example.com/?key=" + url.QueryEscape(token),
+		Err: errors.New("connection refused"),
+	})
+	logger.Printf("ERROR: %v", err)
+
+	got := out.String()
+	if strings.Contains(got, token) || strings.Contains(got, url.QueryEscape(token)) {
+		t.Errorf("log line %q contains the token", got)
+	}
+	if !strings.Contains(got, "key="+redactedText) {
+		t.Errorf("log line %q, want the key redacted", got)
+	}
+}
@@ -2,37 +2,94 @@ package main
 
 import (
 	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
 	"os"
 	"os/signal"
-	"path/filepath"
-	"syscall"
+	"sort"
+	"strings"
 	"time"
 
+	"github.com/MrLonely14/cf-ddns/audit"
+	"github.com/MrLonely14/cf-ddns/backup"
 	"github.com/MrLonely14/cf-ddns/cloudflare"
 	"github.com/MrLonely14/cf-ddns/config"
-	"github.com/MrLonely14/cf-ddns/installer"
+	"github.com/MrLonely14/cf-ddns/configsync"
+	"github.com/MrLonely14/cf-ddns/encryption"
+	"github.com/MrLonely14/cf-ddns/history"
+	"github.com/MrLonely14/cf-ddns/i18n"
 	"github.com/MrLonely14/cf-ddns/ipdetect"
+	"github.com/MrLonely14/cf-ddns/logging"
+	"github.com/MrLonely14/cf-ddns/provider"
+	"github.com/MrLonely14/cf-ddns/sdnotify"
+	"github.com/MrLonely14/cf-ddns/signing"
+	"github.com/MrLonely14/cf-ddns/store"
+	"github.com/MrLonely14/cf-ddns/sysproxy"
+	"github.com/MrLonely14/cf-ddns/term"
+	"github.com/MrLonely14/cf-ddns/trigger"
 	"github.com/MrLonely14/cf-ddns/updater"
+	"github.com/MrLonely14/cf-ddns/zones"
 )
 
 const version = "1.0.0"
 
 func main() {
+	// Credentials are removed from every log line once a command has loaded
+	// the configuration
+	log.SetOutput(logging.NewRedactor(os.Stderr))
+
 	// Define commands and flags
 	runCmd := flag.NewFlagSet("run", flag.ExitOnError)
+	onceCmd := flag.NewFlagSet("once", flag.ExitOnError)
 	installCmd := flag.NewFlagSet("install", flag.ExitOnError)
 	uninstallCmd := flag.NewFlagSet("uninstall", flag.ExitOnError)
 	statusCmd := flag.NewFlagSet("status", flag.ExitOnError)
+	backupCmd := flag.NewFlagSet("backup", flag.ExitOnError)
+	restoreCmd := flag.NewFlagSet("restore", flag.ExitOnError)
 
 	// Flags for run command
 	configPath := runCmd.String("config", "config.yaml", "Path to configuration file")
+	runOutput := runCmd.String("output", "text", "Log format: text or json")
+	runUntilSuccess := runCmd.Bool("until-success", false, "Exit once the first full update succeeds")
+	runTimeout := runCmd.Duration("timeout", 0, "With -until-success, give up after this long (0 retries forever)")
+	runPublicKey := runCmd.String("public-key", "", "Only apply configuration signed by this Ed25519 public key (PEM)")
+	runDryRun := runCmd.Bool("dry-run", false, "Log intended changes instead of writing them to Cloudflare")
+	runService := runCmd.Bool("service", false, "Run under the Windows service manager (set by install)")
+	runStateDir := runCmd.String("state-dir", "", "Directory for state, history and statistics (overrides state_dir)")
+	// Development aid, deliberately left out of the usage text
+	runSimulateIP := runCmd.String("simulate-ip-change", "", "Run one update cycle with this address in place of the detected one")
+	hideFlag(runCmd, "simulate-ip-change")
+
+	// Flags for once command
+	onceConfigPath := onceCmd.String("config", "config.yaml", "Path to configuration file")
+	onceOutput := onceCmd.String("output", "text", "Log format: text or json")
+	oncePublicKey := onceCmd.String("public-key", "", "Only apply configuration signed by this Ed25519 public key (PEM)")
+	onceDryRun := onceCmd.Bool("dry-run", false, "Log intended changes instead of writing them to Cloudflare")
+	onceStateDir := onceCmd.String("state-dir", "", "Directory for state, history and statistics (overrides state_dir)")
 
 	// Flags for install command
 	installConfigPath := installCmd.String("config", "/etc/cf-ddns/config.yaml", "Path to configuration file")
 	installUser := installCmd.String("user", os.Getenv("USER"), "User to run the service as")
+	installPackaging := installCmd.Bool("packaging", false, "Write the unit and example config below -destdir instead of installing the service")
+	installDestDir := installCmd.String("destdir", os.Getenv("DESTDIR"), "Staging directory for -packaging")
+	installExecPath := installCmd.String("exec-path", "/usr/bin/cf-ddns", "Path of the binary on the target system, for -packaging")
+
+	// Flags for status command
+	statusConfigPath := statusCmd.String("config", "/etc/cf-ddns/config.yaml", "Path to configuration file")
+	statusStateDir := statusCmd.String("state-dir", "", "Directory for state, history and statistics (overrides state_dir)")
+
+	// Flags for backup command
+	backupConfigPath := backupCmd.String("config", "config.yaml", "Path to configuration file")
+	backupOut := backupCmd.String("out", "cf-ddns-snapshot.json", "Path to write the snapshot to")
+
+	// Flags for restore command
+	restoreConfigPath := restoreCmd.String("config", "config.yaml", "Path to configuration file")
+	restoreSnapshot := restoreCmd.String("snapshot", "", "Path to the snapshot file to restore from")
+	restoreRecord := restoreCmd.String("record", "", "Only restore the record with this name")
 
 	// Parse command
 	if len(os.Args) < 2 {
@@ -43,149 +100,1208 @@ func main() {
 	switch os.Args[1] {
 	case "run":
 		runCmd.Parse(os.Args[2:])
-		runDaemon(*configPath)
+		opts := runOptions{
+			output:       *runOutput,
+			untilSuccess: *runUntilSuccess,
+			timeout:      *runTimeout,
+			publicKey:    *runPublicKey,
+			simulateIP:   *runSimulateIP,
+			dryRun:       *runDryRun,
+			stateDir:     *runStateDir,
+		}
+		if *runService {
+			runWindowsService(*configPath, opts)
+		} else {
+			runDaemon(*configPath, opts)
+		}
+	case "once":
+		onceCmd.Parse(os.Args[2:])
+		runDaemon(*onceConfigPath, runOptions{
+			output:    *onceOutput,
+			once:      true,
+			publicKey: *oncePublicKey,
+			dryRun:    *onceDryRun,
+			stateDir:  *onceStateDir,
+		})
 	case "install":
 		installCmd.Parse(os.Args[2:])
-		installService(*installConfigPath, *installUser)
+		if *installPackaging {
+			// The builder's user means nothing on the target system
+			user := "root"
+			installCmd.Visit(func(f *flag.Flag) {
+				if f.Name == "user" {
+					user = *installUser
+				}
+			})
+			stageService(*installDestDir, *installExecPath, *installConfigPath, user)
+		} else {
+			installService(*installConfigPath, *installUser)
+		}
+	case "package":
+		packageCommand(os.Args[2:])
 	case "uninstall":
 		uninstallCmd.Parse(os.Args[2:])
 		uninstallService()
 	case "status":
 		statusCmd.Parse(os.Args[2:])
-		checkStatus()
+		checkStatus(*statusConfigPath, *statusStateDir)
+	case "config":
+		configCommand(os.Args[2:])
+	case "token":
+		tokenCommand(os.Args[2:])
+	case "audit":
+		auditCommand(os.Args[2:])
+	case "replay":
+		replayCommand(os.Args[2:])
+	case "history":
+		historyCommand(os.Args[2:])
+	case "validate":
+		validateCommand(os.Args[2:])
+	case "report":
+		reportCommand(os.Args[2:])
+	case "ip":
+		ipCommand(os.Args[2:])
+	case "diff":
+		diffCommand(os.Args[2:])
+	case "apply":
+		applyCommand(os.Args[2:])
+	case "force":
+		forceCommand(os.Args[2:])
+	case "soak":
+		soakCommand(os.Args[2:])
+	case "backup":
+		backupCmd.Parse(os.Args[2:])
+		backupRecords(*backupConfigPath, *backupOut)
+	case "restore":
+		restoreCmd.Parse(os.Args[2:])
+		restoreRecords(*restoreConfigPath, *restoreSnapshot, *restoreRecord)
 	case "version", "-v", "--version":
 		fmt.Printf("cf-ddns version %s\n", version)
 	case "help", "-h", "--help":
 		printUsage()
 	default:
 		// Default to run command if no subcommand specified
-		runDaemon("config.yaml")
+		runDaemon("config.yaml", runOptions{output: "text"})
+	}
+}
+
+// hideFlag keeps a flag out of the -h output of its flag set. The flag
+// still works.
+func hideFlag(fs *flag.FlagSet, name string) {
+	fs.Usage = func() {
+		visible := flag.NewFlagSet(fs.Name(), flag.ContinueOnError)
+		visible.SetOutput(fs.Output())
+		fs.VisitAll(func(f *flag.Flag) {
+			if f.Name == name {
+				return
+			}
+			visible.Var(f.Value, f.Name, f.Usage)
+			visible.Lookup(f.Name).DefValue = f.DefValue
+		})
+		fmt.Fprintf(fs.Output(), "Usage of %s:\n", fs.Name())
+		visible.PrintDefaults()
 	}
 }
 
 func printUsage() {
-	fmt.Println("Cloudflare Dynamic DNS Updater")
-	fmt.Println("\nUsage:")
-	fmt.Println("  cf-ddns run [flags]          Run the daemon (default)")
-	fmt.Println("  cf-ddns install [flags]      Install as system service")
-	fmt.Println("  cf-ddns uninstall            Uninstall system service")
-	fmt.Println("  cf-ddns status               Check service status")
-	fmt.Println("  cf-ddns version              Show version")
-	fmt.Println("  cf-ddns help                 Show this help message")
-	fmt.Println("\nRun Flags:")
-	fmt.Println("  -config string    Path to configuration file (default \"config.yaml\")")
-	fmt.Println("\nInstall Flags:")
-	fmt.Println("  -config string    Path to configuration file (default \"/etc/cf-ddns/config.yaml\")")
-	fmt.Println("  -user string      User to run the service as (default: current user)")
-}
-
-func runDaemon(configPath string) {
+	fmt.Println(i18n.T("Cloudflare Dynamic DNS Updater"))
+	fmt.Println("\n" + i18n.T("Usage:"))
+	fmt.Println("  cf-ddns run [flags]          " + i18n.T("Run the daemon (default)"))
+	fmt.Println("  cf-ddns once [flags]         " + i18n.T("Run a single update and exit, e.g. from cron"))
+	fmt.Println("  cf-ddns install [flags]      " + i18n.T("Install as system service"))
+	fmt.Println("  cf-ddns package [flags]      " + i18n.T("Build a Windows MSI or macOS pkg that installs the service"))
+	fmt.Println("  cf-ddns uninstall            " + i18n.T("Uninstall system service"))
+	fmt.Println("  cf-ddns status [flags]       " + i18n.T("Check service status and statistics"))
+	fmt.Println("  cf-ddns config schema        " + i18n.T("Print the JSON Schema of the configuration file"))
+	fmt.Println("  cf-ddns config keygen        " + i18n.T("Create a key pair for signing configuration files"))
+	fmt.Println("  cf-ddns config sign [flags]  " + i18n.T("Write a detached signature for a configuration file"))
+	fmt.Println("  cf-ddns config encrypt       " + i18n.T("Encrypt configuration files at rest"))
+	fmt.Println("  cf-ddns config decrypt       " + i18n.T("Print the contents of an encrypted configuration file"))
+	fmt.Println("  cf-ddns validate [flags]     " + i18n.T("Check the configuration file, optionally against the API"))
+	fmt.Println("  cf-ddns token check [flags]  " + i18n.T("Compare the token's access with what the config needs"))
+	fmt.Println("  cf-ddns audit verify [flags] " + i18n.T("Check the hash chain of the audit log"))
+	fmt.Println("  cf-ddns backup [flags]       " + i18n.T("Save managed records to a snapshot file"))
+	fmt.Println("  cf-ddns restore [flags]      " + i18n.T("Re-apply managed records from a snapshot file"))
+	fmt.Println("  cf-ddns diff [flags]         " + i18n.T("Show how Cloudflare differs from the configuration"))
+	fmt.Println("  cf-ddns apply [flags]        " + i18n.T("Show the diff and write the records that differ"))
+	fmt.Println("  cf-ddns force [flags]        " + i18n.T("Ask the running daemon to rewrite records"))
+	fmt.Println("  cf-ddns replay [flags]       " + i18n.T("Replay recorded IP changes against a fake provider"))
+	fmt.Println("  cf-ddns soak [flags]         " + i18n.T("Run against a fake provider with synthetic IP churn"))
+	fmt.Println("  cf-ddns history export       " + i18n.T("Export the detected address changes as CSV or JSON"))
+	fmt.Println("  cf-ddns report [flags]       " + i18n.T("Summarize address stability and detection reliability"))
+	fmt.Println("  cf-ddns ip [flags]           " + i18n.T("Print the detected public addresses without touching DNS"))
+	fmt.Println("  cf-ddns version              " + i18n.T("Show version"))
+	fmt.Println("  cf-ddns help                 " + i18n.T("Show this help message"))
+	fmt.Println("\n" + i18n.T("Run Flags:"))
+	fmt.Println("  -config string    " + i18n.T("Path to configuration file (default %q)", "config.yaml"))
+	fmt.Println("  -output string    " + i18n.T("Log format: text or json (default %q)", "text"))
+	fmt.Println("  -until-success    " + i18n.T("Exit once the first full update succeeds"))
+	fmt.Println("  -timeout duration " + i18n.T("With -until-success, give up after this long (default: retry forever)"))
+	fmt.Println("  -public-key string " + i18n.T("Only apply configuration signed by this Ed25519 public key"))
+	fmt.Println("  -dry-run          " + i18n.T("Log intended changes instead of writing them to Cloudflare"))
+	fmt.Println("  -service          " + i18n.T("Run under the Windows service manager (set by install)"))
+	fmt.Println("  -state-dir string " + i18n.T("Directory for state, history and statistics (overrides state_dir)"))
+	fmt.Println("\n" + i18n.T("Once Flags:"))
+	fmt.Println("  -config string    " + i18n.T("Path to configuration file (default %q)", "config.yaml"))
+	fmt.Println("  -output string    " + i18n.T("Log format: text or json (default %q)", "text"))
+	fmt.Println("  -public-key string " + i18n.T("Only apply configuration signed by this Ed25519 public key"))
+	fmt.Println("  -dry-run          " + i18n.T("Log intended changes instead of writing them to Cloudflare"))
+	fmt.Println("  -state-dir string " + i18n.T("Directory for state, history and statistics (overrides state_dir)"))
+	fmt.Println("\n" + i18n.T("Install Flags:"))
+	fmt.Println("  -config string    " + i18n.T("Path to configuration file (default %q)", "/etc/cf-ddns/config.yaml"))
+	fmt.Println("  -user string      " + i18n.T("User to run the service as (default: current user)"))
+	fmt.Println("  -packaging        " + i18n.T("Write the unit and example config below -destdir instead of installing the service"))
+	fmt.Println("  -destdir string   " + i18n.T("Staging directory for -packaging (default: $DESTDIR)"))
+	fmt.Println("  -exec-path string " + i18n.T("Path of the binary on the target system, for -packaging (default %q)", "/usr/bin/cf-ddns"))
+	fmt.Println("\n" + i18n.T("Package Flags:"))
+	fmt.Println("  -platform string  " + i18n.T("Platform to build the package for: windows or darwin (required)"))
+	fmt.Println("  -binary string    " + i18n.T("cf-ddns executable built for the platform (default: this executable, if it matches)"))
+	fmt.Println("  -config string    " + i18n.T("Configuration file to include; the service starts right after installation"))
+	fmt.Println("  -version string   " + i18n.T("Version of the package (default %q)", version))
+	fmt.Println("  -out string       " + i18n.T("Path to write the package to (default %q)", "cf-ddns-<version>.msi|.pkg"))
+	fmt.Println("\n" + i18n.T("Status Flags:"))
+	fmt.Println("  -config string    " + i18n.T("Path to configuration file (default %q)", "/etc/cf-ddns/config.yaml"))
+	fmt.Println("  -state-dir string " + i18n.T("Directory for state, history and statistics (overrides state_dir)"))
+	fmt.Println("\n" + i18n.T("Backup Flags:"))
+	fmt.Println("  -config string    " + i18n.T("Path to configuration file (default %q)", "config.yaml"))
+	fmt.Println("  -out string       " + i18n.T("Path to write the snapshot to (default %q)", "cf-ddns-snapshot.json"))
+	fmt.Println("\n" + i18n.T("Restore Flags:"))
+	fmt.Println("  -config string    " + i18n.T("Path to configuration file (default %q)", "config.yaml"))
+	fmt.Println("  -snapshot string  " + i18n.T("Path to the snapshot file to restore from (required)"))
+	fmt.Println("  -record string    " + i18n.T("Only restore the record with this name"))
+	fmt.Println("\n" + i18n.T("Validate Flags:"))
+	fmt.Println("  -config string    " + i18n.T("Path to configuration file (default %q)", "config.yaml"))
+	fmt.Println("  -online           " + i18n.T("Also verify the API token and zone IDs against the provider"))
+	fmt.Println("  -output string    " + i18n.T("Report format: text or json (default %q)", "text"))
+	fmt.Println("  -strict           " + i18n.T("Treat warnings as errors"))
+	fmt.Println("\n" + i18n.T("IP Flags:"))
+	fmt.Println("  -config string    " + i18n.T("Path to configuration file (default %q)", "config.yaml"))
+	fmt.Println("  -4                " + i18n.T("Only detect the IPv4 address"))
+	fmt.Println("  -6                " + i18n.T("Only detect the IPv6 address"))
+	fmt.Println("  -json             " + i18n.T("Print the result as JSON"))
+	fmt.Println("\n" + i18n.T("Replay Flags:"))
+	fmt.Println("  -config string    " + i18n.T("Path to configuration file (default %q)", "config.yaml"))
+	fmt.Println("  -history string   " + i18n.T("Path to the recorded IP history (required)"))
+}
+
+// runOptions are the flags of the run command
+type runOptions struct {
+	output       string        // text or json
+	untilSuccess bool          // exit after the first successful full update
+	once         bool          // run a single update cycle and exit
+	timeout      time.Duration // deadline for untilSuccess, 0 for none
+	publicKey    string        // path of the key configuration must be signed with, if any
+	simulateIP   string        // fake address for testing the change path, if any
+	dryRun       bool          // log intended writes instead of making them
+	stateDir     string        // directory for state files, overriding state_dir
+}
+
+func runDaemon(configPath string, opts runOptions) {
+	switch opts.output {
+	case "text":
+	case "json":
+		// One JSON object per line on stdout; supervisors add their own timestamps
+		log.SetFlags(0)
+		log.SetOutput(logging.NewRedactor(logging.NewJSONWriter(os.Stdout)))
+		term.Disable()
+	default:
+		log.Fatalf("Invalid -output %s (must be text or json)", opts.output)
+	}
+
+	for serve(configPath, opts) {
+		// Restarted with a configuration pulled from the config source
+	}
+}
+
+// serve starts the daemon with the current configuration and runs until it
+// is shut down. It returns true if it stopped to apply a configuration pulled
+// from the config source.
+func serve(configPath string, opts runOptions) bool {
 	log.Printf("Starting Cloudflare DDNS Updater v%s", version)
 
+	// Refuse configuration that isn't signed by the trusted key
+	var key ed25519.PublicKey
+	if opts.publicKey != "" {
+		var err error
+		if key, err = signing.LoadPublicKey(opts.publicKey); err != nil {
+			log.Fatalf("Failed to load public key: %v", err)
+		}
+		if err := signing.VerifyFile(configPath, key); err != nil {
+			log.Fatalf("Refusing to start with unverified configuration: %v", err)
+		}
+		log.Printf("Verified the signature of %s", configPath)
+	}
+
+	// Pull the configuration first if it comes from a remote source
+	syncer := syncConfig(configPath, key)
+
 	// Load configuration
 	cfg, err := config.Load(configPath)
 	if err != nil {
 		log.Fatalf("Failed to load configuration: %v", err)
 	}
+	logging.SetSecrets(cfg.Secrets())
+	applyProcessSettings(cfg)
 	log.Printf("Loaded configuration from %s", configPath)
-	log.Printf("Check interval: %s", cfg.CheckInterval)
+	for _, warning := range cfg.Lint(configPath) {
+		log.Printf("Warning: %s", warning)
+	}
 	log.Printf("Monitoring %d DNS record(s)", len(cfg.Records))
+	if cfg.Observing() {
+		log.Println("Observe mode: differences are reported but never written to Cloudflare")
+	}
+	if opts.dryRun {
+		cfg.DryRun = true
+	}
+	if cfg.DryRun {
+		log.Println("Dry run: intended changes are logged but not written to Cloudflare")
+	}
+	effective := cfg.Effective()
+	log.Println("Effective configuration:")
+	for _, line := range effective {
+		log.Printf("  %s", line)
+	}
 
-	// Create Cloudflare client
-	cfClient, err := cloudflare.NewClient(cfg.Cloudflare.APIToken)
+	// Create the DNS provider
+	dnsProvider, err := provider.New(cfg)
 	if err != nil {
-		log.Fatalf("Failed to create Cloudflare client: %v", err)
+		log.Fatalf("Failed to create DNS provider: %v", err)
 	}
 
 	// Create IP detector
-	detector := ipdetect.NewDetector()
+	detector, err := ipdetect.NewDetector(cfg.IPDetection)
+	if err != nil {
+		log.Fatalf("Failed to create IP detector: %v", err)
+	}
+
+	// Open the state file for statistics and caches
+	stateDir := config.StateDir(configPath, opts.stateDir)
+	if err := os.MkdirAll(stateDir, 0755); err != nil {
+		log.Printf("Warning: failed to create state directory: %v", err)
+	}
+	log.Printf("Keeping state in %s", stateDir)
+	st, err := store.Open(store.Path(stateDir))
+	if err == nil {
+		now := time.Now()
+		err = st.Update(func(d *store.Data) {
+			d.Stats.Session = store.Counters{Since: now}
+			d.Effective = effective
+			if d.Stats.Total.Since.IsZero() {
+				d.Stats.Total.Since = now
+			}
+		})
+	}
+	if err != nil {
+		log.Printf("Warning: state will not be persisted: %v", err)
+		st = nil
+	}
+
+	// Keep a history of the detected addresses next to the state file
+	if hist, err := history.Open(history.Path(stateDir)); err != nil {
+		log.Printf("Warning: address history will not be recorded: %v", err)
+	} else {
+		detector.SetObserver(hist)
+	}
 
 	// Create updater
-	upd := updater.NewUpdater(cfg, cfClient, detector)
+	upd := updater.NewUpdater(cfg, dnsProvider, detector)
+	if st != nil {
+		upd.SetStore(st)
+	}
+	if cfg.AuditLog != "" {
+		auditLog, err := audit.Open(cfg.AuditLog)
+		if err != nil {
+			log.Fatalf("Failed to open audit log: %v", err)
+		}
+		upd.SetAuditLog(auditLog)
+		log.Printf("Recording API writes in %s", cfg.AuditLog)
+	}
+	notifier, err := newNotifier(cfg)
+	if err != nil {
+		log.Fatalf("Failed to set up notifications: %v", err)
+	}
+	upd.SetNotifier(notifier)
+	// Pending notifications are delivered before exiting
+	defer notifier.Wait()
 
 	// Initialize state from existing DNS records
-	ctx := context.Background()
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if opts.untilSuccess && opts.timeout > 0 {
+		ctx, cancel = context.WithTimeout(context.Background(), opts.timeout)
+	} else {
+		ctx, cancel = context.WithCancel(context.Background())
+	}
+	defer cancel()
+
+	// Serve health probes while starting up, so /readyz can report progress.
+	// One-shot runs leave the ports to a daemon that may be running.
+	calls := make(chan loopCall)
+	servers := &listeners{}
+	if !opts.once {
+		servers = startListeners(ctx, cfg, opts, detector, upd, calls)
+	}
+
+	// Look up zone metadata, served from the state file cache when fresh
+	if err := resolveZoneNames(ctx, cfg, dnsProvider); err != nil {
+		log.Fatalf("Failed to resolve zones: %v", err)
+	}
+	checkAccess(ctx, cfg, dnsProvider)
+	if inspector, ok := dnsProvider.(provider.ZoneInspector); ok {
+		zoneCache := zones.NewCache(inspector, st, zones.DefaultTTL)
+		logZones(ctx, cfg, zoneCache)
+		if err := checkRecordZones(ctx, cfg, zoneCache); err != nil {
+			log.Fatalf("Invalid configuration: %v", err)
+		}
+	}
 	if err := upd.InitializeState(ctx); err != nil {
+		if cfg.StrictStartup {
+			log.Fatalf("Failed to initialize state (strict_startup is enabled): %v", err)
+		}
 		log.Printf("Warning: Failed to initialize state: %v", err)
 	}
+	if !opts.once {
+		notifySystemd(sdnotify.Ready)
+	}
 
-	// Run initial update
-	log.Println("Running initial DNS update...")
-	if err := upd.UpdateAll(ctx); err != nil {
-		log.Printf("Initial update completed with errors: %v", err)
-	} else {
-		log.Println("Initial update completed successfully")
+	if opts.once {
+		err := withProgress(cfg, upd, func() error { return upd.UpdateAll(ctx) })
+		notifier.Wait()
+		if err != nil {
+			log.Fatalf("Update failed: %v", err)
+		}
+		log.Println("Update complete, exiting")
+		return false
+	}
+
+	if opts.untilSuccess {
+		err := retryUntilSuccess(ctx, cfg, upd)
+		notifier.Wait()
+		if err != nil {
+			log.Fatalf("Failed to update DNS: %v", err)
+		}
+		log.Println("All records are up to date, exiting")
+		return false
 	}
 
+	// Run initial update according to the startup policy
+	switch cfg.GetStartupUpdate() {
+	case config.StartupUpdateNever:
+		log.Println("Skipping initial DNS update (startup_update: never)")
+	case config.StartupUpdateAlways:
+		log.Println("Running initial DNS update for all records...")
+		if err := withProgress(cfg, upd, func() error { return upd.ForceUpdateAll(ctx) }); err != nil {
+			log.Printf("Initial update completed with errors: %v", err)
+		} else {
+			log.Println("Initial update completed successfully")
+		}
+	default:
+		log.Println("Running initial DNS update...")
+		if err := withProgress(cfg, upd, func() error { return upd.UpdateAll(ctx) }); err != nil {
+			log.Printf("Initial update completed with errors: %v", err)
+		} else {
+			log.Println("Initial update completed successfully")
+		}
+	}
+
+	if opts.simulateIP != "" {
+		simulateChange(ctx, detector, upd, opts.simulateIP)
+	}
+
+	servers.setReady()
+
 	// Set up signal handling for graceful shutdown
 	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	notifyShutdown(ctx, sigChan)
+	defer signal.Stop(sigChan)
+	forceSig := make(chan os.Signal, 1)
+	if len(forceSignals) > 0 {
+		signal.Notify(forceSig, forceSignals...)
+		defer signal.Stop(forceSig)
+	}
 
 	// Start daemon loop
-	ticker := time.NewTicker(cfg.GetCheckInterval())
+	interval := cfg.EffectiveCheckInterval()
+	if interval != cfg.GetCheckInterval() {
+		log.Printf("Warning: check_interval %s would exceed Cloudflare's API rate limit for this many records; using %s instead", cfg.CheckInterval, interval)
+	}
+	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
+	// Re-read the records from Cloudflare to correct changes made elsewhere
+	var reconcile <-chan time.Time
+	if interval := cfg.GetReconcileInterval(); interval > 0 {
+		reconcileTicker := time.NewTicker(interval)
+		defer reconcileTicker.Stop()
+		reconcile = reconcileTicker.C
+	}
+
+	// Start event triggers for immediate checks
+	triggers := trigger.Start(ctx, cfg.Triggers)
+	var sleepEvents <-chan bool
+	if cfg.Triggers.Sleep {
+		sleepEvents = trigger.WatchSleep(ctx)
+	}
+	var asleep bool
+	var resumedAt time.Time
+	var configChanges <-chan struct{}
+	if syncer != nil {
+		configChanges = syncer.Watch(ctx)
+	}
+
+	// Pinged from the loop, so systemd restarts the daemon if the loop hangs
+	var watchdog <-chan time.Time
+	if interval := sdnotify.WatchdogInterval(); interval > 0 {
+		log.Printf("Watchdog enabled: pinging systemd every %s", interval)
+		watchdogTicker := time.NewTicker(interval)
+		defer watchdogTicker.Stop()
+		watchdog = watchdogTicker.C
+		notifySystemd(sdnotify.Watchdog)
+	}
+
 	log.Println("Daemon started, waiting for IP changes...")
 
 	for {
+		// Apply changes held back by a maintenance window once it ends
+		var windowEnd <-chan time.Time
+		if until := upd.WithheldUntil(); !until.IsZero() {
+			windowEnd = time.After(time.Until(until))
+		}
+
 		select {
+		case <-windowEnd:
+			log.Println("Maintenance window ended, applying withheld changes...")
+			if err := upd.UpdateAll(ctx); err != nil {
+				log.Printf("Update failed: %v", err)
+			}
+		case sleeping := <-sleepEvents:
+			if sleeping {
+				log.Println("System is going to sleep, pausing checks")
+				asleep = true
+				ticker.Stop()
+				continue
+			}
+			log.Println("System resumed, checking for IP changes...")
+			asleep = false
+			resumedAt = time.Now()
+			ticker.Reset(interval)
+			if err := upd.UpdateAll(ctx); err != nil {
+				log.Printf("Update failed: %v", err)
+			}
+		case <-reconcile:
+			if asleep {
+				continue
+			}
+			if err := upd.Reconcile(ctx); err != nil {
+				log.Printf("Update failed: %v", err)
+			}
+		case <-watchdog:
+			notifySystemd(sdnotify.Watchdog)
+		case <-forceSig:
+			forceUpdate(ctx, upd, nil)
+		case call := <-calls:
+			call.done <- call.run()
 		case <-ticker.C:
 			log.Println("Checking for IP changes...")
 			if err := upd.UpdateAll(ctx); err != nil {
 				log.Printf("Update failed: %v", err)
 			}
+		case source := <-triggers:
+			if asleep {
+				continue
+			}
+			if source == trigger.ClockJump && time.Since(resumedAt) < time.Minute {
+				// Already handled by the resume notification
+				continue
+			}
+			if source == trigger.ClockJump {
+				// Timers didn't run during the jump; check now and restart the interval
+				log.Println("Clock jump detected, checking for IP changes...")
+				ticker.Reset(interval)
+			} else {
+				log.Printf("Network change reported by %s trigger, checking for IP changes...", source)
+			}
+			if err := upd.UpdateAll(ctx); err != nil {
+				log.Printf("Update failed: %v", err)
+			}
+		case <-configChanges:
+			log.Println("Configuration changed, restarting with the new configuration...")
+			notifySystemd(sdnotify.Reloading)
+			cancel()
+			servers.wait()
+			return true
 		case sig := <-sigChan:
 			log.Printf("Received signal %v, shutting down gracefully...", sig)
+			notifySystemd(sdnotify.Stopping)
 			log.Println("Performing final DNS update before shutdown...")
 			if err := upd.UpdateAll(ctx); err != nil {
 				log.Printf("Final update failed: %v", err)
 			}
 			log.Println("Shutdown complete")
-			return
+			return false
+		}
+	}
+}
+
+// notifySystemd reports the daemon's state when it runs as a systemd
+// Type=notify service
+func notifySystemd(state string) {
+	if err := sdnotify.Send(state); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+}
+
+// applyProcessSettings applies the options that affect the whole process:
+// the language of messages and the use of the system proxy
+func applyProcessSettings(cfg *config.Config) {
+	i18n.SetLanguage(cfg.Language)
+	if err := sysproxy.Enable(cfg.SystemProxy); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+}
+
+// syncConfig pulls the configuration from the config source, if the config
+// file sets one, and returns the syncer for watching it. If the source can't
+// be reached, the last synced configuration is used.
+func syncConfig(configPath string, key ed25519.PublicKey) *configsync.Syncer {
+	cfg, err := config.LoadSource(configPath)
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+	applyProcessSettings(cfg)
+	if cfg.ConfigSource == "" {
+		return nil
+	}
+
+	syncer, err := configsync.New(configPath, cfg, key)
+	if err != nil {
+		log.Fatalf("Failed to set up config source: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if _, err := syncer.Sync(ctx); err != nil {
+		log.Printf("Warning: %v; using the last synced configuration", err)
+	}
+
+	// The synced copy may be an older one from disk, so check it as well
+	if key != nil {
+		if err := signing.VerifyFile(config.SyncedPath(configPath), key); err != nil {
+			log.Fatalf("Refusing to start with unverified configuration: %v", err)
 		}
 	}
+	return syncer
+}
+
+// loopCall is work requested through the control API, run by the daemon
+// loop so it never overlaps an update cycle
+type loopCall struct {
+	run  func() error
+	done chan error
 }
 
-func installService(configPath, user string) {
-	log.Println("Installing cf-ddns as system service...")
+// callLoop hands fn to the daemon loop and waits for its result
+func callLoop(ctx context.Context, calls chan<- loopCall, fn func() error) error {
+	call := loopCall{run: fn, done: make(chan error, 1)}
+	select {
+	case calls <- call:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case err := <-call.done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
 
-	// Get executable path
-	exePath, err := os.Executable()
+// forceUpdate rewrites the named records, or all records if names is empty
+func forceUpdate(ctx context.Context, upd *updater.Updater, names []string) error {
+	var err error
+	if len(names) == 0 {
+		log.Println("Forced update of all records requested")
+		err = upd.ForceUpdateAll(ctx)
+	} else {
+		log.Printf("Forced update requested for %s", strings.Join(names, ", "))
+		err = upd.ForceUpdateRecords(ctx, names)
+	}
 	if err != nil {
-		log.Fatalf("Failed to get executable path: %v", err)
+		log.Printf("Forced update failed: %v", err)
 	}
+	return err
+}
 
-	// Install service
-	if err := installer.Install(exePath, configPath, user); err != nil {
-		log.Fatalf("Failed to install service: %v", err)
+// simulateChange runs one update cycle in which detection reports ip instead
+// of the real address of its family, so provider writes and everything that
+// follows a change can be tested end to end. The next regular check restores
+// the real address.
+func simulateChange(ctx context.Context, detector *ipdetect.Detector, upd *updater.Updater, ip string) error {
+	restore, err := detector.Simulate(ip)
+	if err != nil {
+		return err
 	}
+	defer restore()
 
-	log.Println("Service installed successfully!")
-	log.Println("\nNext steps:")
-	log.Printf("1. Edit the example configuration file:")
-	log.Printf("   Example: %s/config.example.yaml", filepath.Dir(configPath))
-	log.Printf("   Copy it to: %s", configPath)
-	log.Printf("   Command: sudo cp %s/config.example.yaml %s", filepath.Dir(configPath), configPath)
-	log.Println("2. Edit the config file with your Cloudflare API token and zones")
-	log.Println("3. Start the service:")
-	installer.PrintStartCommand()
+	log.Printf("Simulating an IP change to %s", ip)
+	if err := upd.UpdateAll(ctx); err != nil {
+		log.Printf("Simulated update failed: %v", err)
+		return err
+	}
+	return nil
 }
 
-func uninstallService() {
-	log.Println("Uninstalling cf-ddns system service...")
+const (
+	// retryDelayMin and retryDelayMax bound the wait between attempts of -until-success
+	retryDelayMin = 5 * time.Second
+	retryDelayMax = time.Minute
+)
+
+// retryUntilSuccess repeats full update cycles until one succeeds for every
+// record, or ctx expires. The startup_update policy decides whether records
+// that already match are rewritten.
+func retryUntilSuccess(ctx context.Context, cfg *config.Config, upd *updater.Updater) error {
+	delay := retryDelayMin
+	for attempt := 1; ; attempt++ {
+		var err error
+		if cfg.GetStartupUpdate() == config.StartupUpdateAlways {
+			err = upd.ForceUpdateAll(ctx)
+		} else {
+			err = upd.UpdateAll(ctx)
+		}
+		if err == nil {
+			return nil
+		}
+
+		log.Printf("Attempt %d failed: %v; retrying in %s", attempt, err, delay)
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("gave up after %d attempt(s): %w", attempt, ctx.Err())
+		case <-time.After(delay):
+		}
+
+		delay *= 2
+		if delay > retryDelayMax {
+			delay = retryDelayMax
+		}
+	}
+}
+
+// logZones prints the name and plan of every configured zone
+func logZones(ctx context.Context, cfg *config.Config, zoneCache *zones.Cache) {
+	seen := make(map[string]bool)
+	for _, record := range cfg.Records {
+		if seen[record.ZoneID] {
+			continue
+		}
+		seen[record.ZoneID] = true
+
+		zone, err := zoneCache.Zone(ctx, record.ZoneID)
+		if err != nil {
+			log.Printf("Warning: failed to look up zone %s: %v", record.ZoneID, err)
+			continue
+		}
+		log.Printf("Zone %s: %s (%s plan, %s)", zone.ID, zone.Name, zone.Plan, zone.Status)
+	}
+}
+
+// checkAccess verifies the API tokens and their permissions on every
+// configured zone before the first update. Missing permissions stop the
+// daemon; other failures, such as network errors, only with strict_startup.
+// Write access isn't needed, and not checked, when nothing is written.
+func checkAccess(ctx context.Context, cfg *config.Config, dnsProvider provider.Provider) {
+	checker, ok := dnsProvider.(provider.AccessChecker)
+	if !ok {
+		return
+	}
+
+	var zoneIDs []string
+	seen := make(map[string]bool)
+	for _, record := range cfg.Records {
+		if !seen[record.ZoneID] {
+			seen[record.ZoneID] = true
+			zoneIDs = append(zoneIDs, record.ZoneID)
+		}
+	}
+
+	err := checker.CheckAccess(ctx, zoneIDs, !cfg.Observing() && !cfg.DryRun)
+	switch {
+	case err == nil:
+		log.Printf("Verified API token access to %d zone(s)", len(zoneIDs))
+	case errors.Is(err, cloudflare.ErrAccessDenied):
+		log.Fatalf("Insufficient API token permissions:\n%v", err)
+	case cfg.StrictStartup:
+		log.Fatalf("Failed to verify API token permissions (strict_startup is enabled):\n%v", err)
+	default:
+		log.Printf("Warning: failed to verify API token permissions:\n%v", err)
+	}
+}
 
-	if err := installer.Uninstall(); err != nil {
-		log.Fatalf("Failed to uninstall service: %v", err)
+// checkRecordZones verifies that every record name lies within the zone its
+// zone_id refers to, catching names copied next to the wrong zone ID. Zones
+// that can't be looked up are skipped.
+func checkRecordZones(ctx context.Context, cfg *config.Config, zoneCache *zones.Cache) error {
+	for i, record := range cfg.Records {
+		zone, err := zoneCache.Zone(ctx, record.ZoneID)
+		if err != nil {
+			continue
+		}
+		if !zones.Contains(zone.Name, record.Name) {
+			return fmt.Errorf("record %d: %s is not within zone %s (%s); check the zone_id", i, cloudflare.DisplayName(record.Name), zone.Name, zone.ID)
+		}
+		if record.ReverseHint != "" && !zones.Contains(zone.Name, record.ReverseHint) {
+			return fmt.Errorf("record %d: reverse_hint %s is not within zone %s (%s)", i, cloudflare.DisplayName(record.ReverseHint), zone.Name, zone.ID)
+		}
 	}
+	return nil
+}
 
-	log.Println("Service uninstalled successfully!")
+// resolveZoneNames sets the zone ID of records configured by zone name, looked
+// up by the provider, for Cloudflare in the zones visible to the default token
+func resolveZoneNames(ctx context.Context, cfg *config.Config, dnsProvider provider.Provider) error {
+	for i := range cfg.Records {
+		record := &cfg.Records[i]
+		if record.Zone == "" {
+			continue
+		}
+		var zoneID string
+		var err error
+		if record.Account != "" {
+			resolver, ok := dnsProvider.(provider.AccountZoneResolver)
+			if !ok {
+				return fmt.Errorf("record %d: provider %s has no accounts", i, cfg.GetProvider())
+			}
+			zoneID, err = resolver.AccountZoneID(ctx, record.Account, record.Zone)
+		} else {
+			resolver, ok := dnsProvider.(provider.ZoneResolver)
+			if !ok {
+				return fmt.Errorf("record %d: provider %s can't look up zones by name; set zone_id instead", i, cfg.GetProvider())
+			}
+			zoneID, err = resolver.ZoneID(ctx, record.Zone)
+		}
+		if err != nil {
+			return fmt.Errorf("record %d: %w", i, err)
+		}
+		record.ZoneID = zoneID
+	}
+	return nil
 }
 
-func checkStatus() {
-	status, err := installer.Status()
+func checkStatus(configPath, stateDir string) {
+	status, err := serviceStatus()
 	if err != nil {
 		log.Fatalf("Failed to check status: %v", err)
 	}
 
 	fmt.Println(status)
+
+	// Statistics are only available once the daemon has run
+	st, err := store.Open(store.Path(config.StateDir(configPath, stateDir)))
+	if err != nil {
+		log.Printf("Failed to read statistics: %v", err)
+		return
+	}
+	data := st.View()
+
+	labels := make([]string, 0, len(data.Unhealthy))
+	for label := range data.Unhealthy {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+
+	if !term.Interactive() {
+		if stats := data.Stats.String(); stats != "" {
+			fmt.Println("Statistics:")
+			fmt.Print(stats)
+		}
+		if len(labels) > 0 {
+			fmt.Println("Unhealthy records:")
+			for _, label := range labels {
+				fmt.Printf("  %s: %s\n", label, data.Unhealthy[label])
+			}
+		}
+		if len(data.Effective) > 0 {
+			fmt.Println("Effective configuration (at last start):")
+			for _, line := range data.Effective {
+				fmt.Printf("  %s\n", line)
+			}
+		}
+		return
+	}
+
+	// Aligned tables with markers for interactive use
+	if !data.Stats.Session.Since.IsZero() || !data.Stats.Total.Since.IsZero() {
+		fmt.Println(term.Bold("Statistics"))
+		table := term.NewTable(os.Stdout)
+		fmt.Fprintln(table, "  PERIOD\tSINCE\tCYCLES\tCHANGES\tAVG CYCLE\tMAX CYCLE\tSLOW\tERRORS")
+		for _, period := range []struct {
+			label    string
+			counters store.Counters
+		}{
+			{"start", data.Stats.Session},
+			{"install", data.Stats.Total},
+		} {
+			c := period.counters
+			if c.Since.IsZero() {
+				continue
+			}
+			errCount := term.Green("0")
+			if c.Errors > 0 {
+				errCount = term.Red(fmt.Sprint(c.Errors))
+			}
+			fmt.Fprintf(table, "  %s\t%s\t%d\t%d\t%s\t%s\t%d\t%s\n", period.label, c.Since.Format("2006-01-02 15:04"),
+				c.Cycles, c.Changes, c.AverageCycle().Round(time.Millisecond), c.MaxCycle.Round(time.Millisecond), c.Slow, errCount)
+		}
+		table.Flush()
+		fmt.Println()
+	}
+
+	fmt.Println(term.Bold("Records"))
+	if len(labels) == 0 {
+		fmt.Println("  " + term.OK("all records healthy"))
+	} else {
+		table := term.NewTable(os.Stdout)
+		for _, label := range labels {
+			fmt.Fprintf(table, "  %s\t%s\n", term.Fail(label), term.Dim(data.Unhealthy[label]))
+		}
+		table.Flush()
+	}
+
+	if len(data.Effective) > 0 {
+		fmt.Println("\n" + term.Bold("Effective configuration (at last start)"))
+		table := term.NewTable(os.Stdout)
+		for _, line := range data.Effective {
+			key, value, _ := strings.Cut(line, ": ")
+			fmt.Fprintf(table, "  %s\t%s\n", key, term.Dim(value))
+		}
+		table.Flush()
+	}
+}
+
+func configCommand(args []string) {
+	if len(args) < 1 {
+		fmt.Println("Usage: cf-ddns config schema|keygen|sign|encrypt|decrypt")
+		os.Exit(1)
+	}
+
+	switch args[0] {
+	case "schema":
+		data, err := json.MarshalIndent(config.Schema(), "", "  ")
+		if err != nil {
+			log.Fatalf("Failed to generate schema: %v", err)
+		}
+		fmt.Println(string(data))
+	case "keygen":
+		keygenCmd := flag.NewFlagSet("config keygen", flag.ExitOnError)
+		out := keygenCmd.String("out", "cf-ddns-signing", "Base name of the key files (.pem and .pub)")
+		keygenCmd.Parse(args[1:])
+		generateSigningKey(*out)
+	case "sign":
+		signCmd := flag.NewFlagSet("config sign", flag.ExitOnError)
+		key := signCmd.String("key", "cf-ddns-signing.pem", "Path to the Ed25519 private key (PEM)")
+		signCmd.Parse(args[1:])
+		signConfigFiles(*key, signCmd.Args())
+	case "encrypt":
+		encryptCmd := flag.NewFlagSet("config encrypt", flag.ExitOnError)
+		key := encryptCmd.String("key", "", "Path to the key file, created if missing (default: "+encryption.KeyEnv+" or "+encryption.KeyFileEnv+")")
+		encryptCmd.Parse(args[1:])
+		encryptConfigFiles(*key, encryptCmd.Args())
+	case "decrypt":
+		decryptCmd := flag.NewFlagSet("config decrypt", flag.ExitOnError)
+		key := decryptCmd.String("key", "", "Path to the key file (default: "+encryption.KeyEnv+" or "+encryption.KeyFileEnv+")")
+		decryptCmd.Parse(args[1:])
+		if decryptCmd.NArg() != 1 {
+			log.Fatalf("Usage: cf-ddns config decrypt [-key file] config.yaml")
+		}
+		decryptConfigFile(*key, decryptCmd.Arg(0))
+	default:
+		fmt.Println("Usage: cf-ddns config schema|keygen|sign|encrypt|decrypt")
+		os.Exit(1)
+	}
+}
+
+// generateSigningKey writes a new key pair to <base>.pem and <base>.pub
+func generateSigningKey(base string) {
+	public, private, err := signing.GenerateKey()
+	if err != nil {
+		log.Fatalf("Failed to generate key: %v", err)
+	}
+	if err := os.WriteFile(base+".pem", private, 0600); err != nil {
+		log.Fatalf("Failed to write private key: %v", err)
+	}
+	if err := os.WriteFile(base+".pub", public, 0644); err != nil {
+		log.Fatalf("Failed to write public key: %v", err)
+	}
+	fmt.Printf("Private key: %s.pem (keep it off the devices)\n", base)
+	fmt.Printf("Public key:  %s.pub (pass it to run with -public-key)\n", base)
+}
+
+// signConfigFiles writes a detached signature next to each file
+func signConfigFiles(keyPath string, paths []string) {
+	if len(paths) == 0 {
+		log.Fatalf("No files to sign given")
+	}
+	key, err := signing.LoadPrivateKey(keyPath)
+	if err != nil {
+		log.Fatalf("Failed to load private key: %v", err)
+	}
+
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			log.Fatalf("Failed to read %s: %v", path, err)
+		}
+		if err := os.WriteFile(path+signing.Ext, signing.Sign(data, key), 0644); err != nil {
+			log.Fatalf("Failed to write signature: %v", err)
+		}
+		fmt.Printf("Signed %s -> %s%s\n", path, path, signing.Ext)
+	}
+}
+
+// encryptConfigFiles encrypts each file in place
+func encryptConfigFiles(keyPath string, paths []string) {
+	if len(paths) == 0 {
+		log.Fatalf("No files to encrypt given")
+	}
+	if keyPath != "" {
+		if _, err := os.Stat(keyPath); os.IsNotExist(err) {
+			data, err := encryption.GenerateKey()
+			if err != nil {
+				log.Fatalf("Failed to generate key: %v", err)
+			}
+			if err := os.WriteFile(keyPath, data, 0600); err != nil {
+				log.Fatalf("Failed to write key: %v", err)
+			}
+			fmt.Printf("Created key %s (keep a copy off the device)\n", keyPath)
+		}
+	}
+	key, err := encryptionKey(keyPath)
+	if err != nil {
+		log.Fatalf("Failed to load key: %v", err)
+	}
+
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			log.Fatalf("Failed to read %s: %v", path, err)
+		}
+		if encryption.IsEncrypted(data) {
+			fmt.Printf("%s is already encrypted\n", path)
+			continue
+		}
+		sealed, err := encryption.Encrypt(data, key)
+		if err != nil {
+			log.Fatalf("Failed to encrypt %s: %v", path, err)
+		}
+		if err := os.WriteFile(path, sealed, 0600); err != nil {
+			log.Fatalf("Failed to write %s: %v", path, err)
+		}
+		fmt.Printf("Encrypted %s\n", path)
+	}
+}
+
+// decryptConfigFile prints the plaintext of an encrypted file
+func decryptConfigFile(keyPath, path string) {
+	key, err := encryptionKey(keyPath)
+	if err != nil {
+		log.Fatalf("Failed to load key: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		log.Fatalf("Failed to read %s: %v", path, err)
+	}
+	plain, err := encryption.Decrypt(data, key)
+	if err != nil {
+		log.Fatalf("Failed to decrypt %s: %v", path, err)
+	}
+	os.Stdout.Write(plain)
+}
+
+// encryptionKey reads the key file if given, otherwise the key the daemon uses
+func encryptionKey(keyPath string) ([]byte, error) {
+	if keyPath != "" {
+		return encryption.ReadKeyFile(keyPath)
+	}
+	return encryption.LoadKey()
+}
+
+func backupRecords(configPath, outPath string) {
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+	logging.SetSecrets(cfg.Secrets())
+	applyProcessSettings(cfg)
+
+	dnsProvider, err := provider.New(cfg)
+	if err != nil {
+		log.Fatalf("Failed to create DNS provider: %v", err)
+	}
+	if err := resolveZoneNames(context.Background(), cfg, dnsProvider); err != nil {
+		log.Fatalf("Failed to resolve zones: %v", err)
+	}
+
+	ctx := context.Background()
+	snap := &backup.Snapshot{CreatedAt: time.Now().UTC()}
+
+	total := recordCount(cfg)
+	var bar *term.Progress
+	var restoreLog func()
+	if term.Interactive() && total >= progressThreshold {
+		bar = term.NewProgress("Reading records", total)
+		restoreLog = captureLog()
+	}
+
+	skipped := 0
+	for _, record := range cfg.Records {
+		for _, recordType := range record.Types {
+			if bar != nil {
+				bar.Set(len(snap.Records)+skipped, total)
+			}
+
+			existing, err := dnsProvider.GetDNSRecord(ctx, record.ZoneID, record.Name, recordType, cloudflare.RecordKind(recordType, record.Template()))
+			if err != nil {
+				log.Printf("Warning: skipping %s (%s): %v", cloudflare.DisplayName(record.Name), recordType, err)
+				skipped++
+				continue
+			}
+
+			snap.Records = append(snap.Records, backup.Record{
+				ZoneID:  record.ZoneID,
+				Name:    record.Name,
+				Type:    recordType,
+				Content: existing.Content,
+				TTL:     existing.TTL,
+				Proxied: existing.Proxied,
+			})
+		}
+	}
+
+	if bar != nil {
+		bar.Done()
+		restoreLog()
+	}
+
+	if err := backup.Save(outPath, snap); err != nil {
+		log.Fatalf("Failed to save snapshot: %v", err)
+	}
+
+	if bar != nil {
+		printSummary("Saved to "+outPath, []summaryRow{
+			{label: "saved", count: len(snap.Records)},
+			{label: "skipped", count: skipped, bad: true},
+		})
+		return
+	}
+	log.Printf("Saved %d record(s) to %s", len(snap.Records), outPath)
+}
+
+func restoreRecords(configPath, snapshotPath, recordName string) {
+	if snapshotPath == "" {
+		log.Fatalf("The -snapshot flag is required")
+	}
+
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+	logging.SetSecrets(cfg.Secrets())
+	applyProcessSettings(cfg)
+
+	if cfg.Observing() {
+		log.Fatalf("The configuration sets mode: observe, which never writes to Cloudflare")
+	}
+	if cfg.DryRun {
+		log.Fatalf("The configuration sets dry_run, so nothing would be restored")
+	}
+	if cfg.Frozen() {
+		log.Fatalf("Changes are frozen while %s exists", cfg.FreezeFile)
+	}
+
+	snap, err := backup.Load(snapshotPath)
+	if err != nil {
+		log.Fatalf("Failed to load snapshot: %v", err)
+	}
+	log.Printf("Restoring from snapshot taken at %s", snap.CreatedAt.Format(time.RFC3339))
+
+	dnsProvider, err := provider.New(cfg)
+	if err != nil {
+		log.Fatalf("Failed to create DNS provider: %v", err)
+	}
+	if err := resolveZoneNames(context.Background(), cfg, dnsProvider); err != nil {
+		log.Fatalf("Failed to resolve zones: %v", err)
+	}
+
+	var auditLog *audit.Log
+	if cfg.AuditLog != "" {
+		if auditLog, err = audit.Open(cfg.AuditLog); err != nil {
+			log.Fatalf("Failed to open audit log: %v", err)
+		}
+	}
+
+	// Only records that are still managed by the configuration are restored
+	var selected []config.DNSRecord
+	total := 0
+	for _, record := range cfg.Records {
+		if recordName != "" && cloudflare.NormalizeName(record.Name) != cloudflare.NormalizeName(recordName) {
+			continue
+		}
+		selected = append(selected, record)
+		total += len(record.Types)
+	}
+
+	var bar *term.Progress
+	var restoreLog func()
+	if term.Interactive() && total >= progressThreshold {
+		bar = term.NewProgress("Restoring records", total)
+		restoreLog = captureLog()
+	}
+
+	ctx := context.Background()
+	restored, skipped, failed := 0, 0, 0
+
+	for _, record := range selected {
+		for _, recordType := range record.Types {
+			if bar != nil {
+				bar.Set(restored+skipped+failed, total)
+			}
+
+			saved := snap.Find(record.ZoneID, record.Name, recordType)
+			if saved == nil {
+				log.Printf("No snapshot entry for %s (%s), skipping", cloudflare.DisplayName(record.Name), recordType)
+				skipped++
+				continue
+			}
+
+			log.Printf("Restoring %s (%s) to %s", cloudflare.DisplayName(record.Name), recordType, saved.Content)
+			change, err := dnsProvider.UpsertDNSRecord(ctx, saved.ZoneID, saved.Name, saved.Type, saved.Content, saved.TTL, saved.Proxied, "")
+			if err != nil {
+				log.Printf("ERROR: failed to restore %s (%s): %v", cloudflare.DisplayName(record.Name), recordType, err)
+				failed++
+				continue
+			}
+			log.Println(change)
+			if auditLog != nil {
+				if err := auditLog.Record("restore", change); err != nil {
+					log.Printf("ERROR: failed to write audit log: %v", err)
+				}
+			}
+			restored++
+		}
+	}
+
+	if bar != nil {
+		bar.Done()
+		restoreLog()
+		printSummary("Restore complete", []summaryRow{
+			{label: "restored", count: restored},
+			{label: "skipped", count: skipped},
+			{label: "failed", count: failed, bad: true},
+		})
+	} else {
+		log.Printf("Restore complete: %d restored, %d skipped, %d failed", restored, skipped, failed)
+	}
+	if failed > 0 {
+		os.Exit(1)
+	}
 }
@@ -0,0 +1,14 @@
+//go:build !minimal
+
+package main
+
+import (
+	"github.com/MrLonely14/cf-ddns/config"
+	"github.com/MrLonely14/cf-ddns/notify"
+	"github.com/MrLonely14/cf-ddns/updater"
+)
+
+// newNotifier sets up the configured webhooks and chat channels
+func newNotifier(cfg *config.Config) (*updater.Notifier, error) {
+	return notify.New(cfg)
+}
@@ -0,0 +1,20 @@
+//go:build minimal
+
+package main
+
+import (
+	"log"
+
+	"github.com/MrLonely14/cf-ddns/config"
+	"github.com/MrLonely14/cf-ddns/updater"
+)
+
+// newNotifier warns about notifications the configuration asks for, which
+// builds with the minimal tag leave out
+func newNotifier(cfg *config.Config) (*updater.Notifier, error) {
+	n := cfg.Notifications
+	if len(n.Webhooks) > 0 || n.Discord != nil || n.Slack != nil || n.Telegram != nil || len(n.Escalation) > 0 {
+		log.Printf("Warning: this build has no notifications (built with the minimal tag); ignoring the notifications section")
+	}
+	return &updater.Notifier{}, nil
+}
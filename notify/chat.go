@@ -0,0 +1,64 @@
+package notify
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/MrLonely14/cf-ddns/config"
+)
+
+// telegramAPI is the Telegram Bot API endpoint; the bot token is part of the path
+const telegramAPI = "https://api.telegram.org/bot%s/sendMessage"
+
+// chatChannels returns the configured Discord, Slack and Telegram channels
+// as webhooks that post the event message in each service's format
+func chatChannels(cfg config.NotificationsConfig) []webhook {
+	var hooks []webhook
+	if d := cfg.Discord; d != nil {
+		hooks = append(hooks, webhook{
+			cfg:  config.WebhookConfig{URL: d.WebhookURL, Events: d.Events},
+			chat: func(message string) any { return map[string]string{"content": message} },
+		})
+	}
+	if s := cfg.Slack; s != nil {
+		hooks = append(hooks, webhook{
+			cfg:  config.WebhookConfig{URL: s.WebhookURL, Events: s.Events},
+			chat: func(message string) any { return map[string]string{"text": message} },
+		})
+	}
+	if t := cfg.Telegram; t != nil {
+		hooks = append(hooks, webhook{
+			cfg:  config.WebhookConfig{URL: fmt.Sprintf(telegramAPI, t.BotToken), Events: t.Events},
+			chat: func(message string) any { return map[string]string{"chat_id": t.ChatID, "text": message} },
+		})
+	}
+	return hooks
+}
+
+// Message describes the event in a sentence, for chat channels and payload
+// templates
+func (e Event) Message() string {
+	record := fmt.Sprintf("%s (%s)", e.Record, e.RecordType)
+	switch e.Event {
+	case config.EventChange:
+		if len(e.Changes) > 0 {
+			lines := []string{e.Summary + ":"}
+			for _, change := range e.Changes {
+				lines = append(lines, "- "+change.Message())
+			}
+			return strings.Join(lines, "\n")
+		}
+		if e.OldIP == "" {
+			return fmt.Sprintf("%s was set to %s", record, e.NewIP)
+		}
+		return fmt.Sprintf("%s changed from %s to %s", record, e.OldIP, e.NewIP)
+	case config.EventFailure:
+		return fmt.Sprintf("%s failed to update: %s", record, e.Error)
+	case config.EventPage:
+		return fmt.Sprintf("%s keeps failing to update: %s", record, e.Error)
+	case config.EventCycle:
+		return "Update cycle complete: " + e.Summary
+	default:
+		return e.Event
+	}
+}
@@ -0,0 +1,80 @@
+package notify
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/MrLonely14/cf-ddns/config"
+)
+
+// digest batches change events, so that many records moving to a new address
+// are reported in one message rather than one per record
+type digest struct {
+	window  time.Duration // 0 batches until the cycle finishes
+	mu      sync.Mutex
+	changes []Event
+	timer   *time.Timer // flushes a window's batch; nil while empty or per cycle
+}
+
+// newDigest creates the batch, or returns nil if every change is sent on its own
+func newDigest(cfg *config.Config) *digest {
+	if cfg.Notifications.Digest == "" {
+		return nil
+	}
+	return &digest{window: cfg.Notifications.DigestWindow()}
+}
+
+// batches reports whether a change is held for the digest. Per cycle, only
+// changes made by a cycle or by apply are, as a cycle event or the end of the
+// run follows them; others, such as pushes, would wait for the next cycle.
+func (d *digest) batches(event Event) bool {
+	return d.window > 0 || event.Source == "update" || event.Source == "apply"
+}
+
+// add queues a change. The first change of a window starts its timer, which
+// calls flush once the window has passed.
+func (d *digest) add(event Event, flush func()) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.changes = append(d.changes, event)
+	if d.window > 0 && d.timer == nil {
+		d.timer = time.AfterFunc(d.window, flush)
+	}
+}
+
+// take empties the batch and returns the event that reports it: the change
+// itself if there was only one, otherwise a change event listing them all
+func (d *digest) take() (Event, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.timer != nil {
+		d.timer.Stop()
+		d.timer = nil
+	}
+	changes := d.changes
+	d.changes = nil
+
+	switch len(changes) {
+	case 0:
+		return Event{}, false
+	case 1:
+		return changes[0], true
+	}
+	return Event{
+		Event:   config.EventChange,
+		Summary: fmt.Sprintf("%d records changed", len(changes)),
+		Changes: changes,
+		Time:    time.Now(),
+	}, true
+}
+
+// flushDigest delivers the batched changes, if any
+func (n *Notifier) flushDigest() {
+	if n.digest == nil {
+		return
+	}
+	if event, ok := n.digest.take(); ok {
+		n.deliver(event)
+	}
+}
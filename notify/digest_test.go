@@ -0,0 +1,136 @@
+package notify
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/MrLonely14/cf-ddns/config"
+)
+
+// recorder is a webhook endpoint that keeps the events it receives
+type recorder struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+func (r *recorder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	var event Event
+	if err := json.NewDecoder(req.Body).Decode(&event); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	r.mu.Lock()
+	r.events = append(r.events, event)
+	r.mu.Unlock()
+}
+
+func (r *recorder) received() []Event {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Event(nil), r.events...)
+}
+
+func newTestNotifier(t *testing.T, digest string) (*Notifier, *recorder) {
+	t.Helper()
+	rec := &recorder{}
+	server := httptest.NewServer(rec)
+	t.Cleanup(server.Close)
+
+	cfg := &config.Config{}
+	cfg.Notifications.Digest = digest
+	cfg.Notifications.Webhooks = []config.WebhookConfig{{URL: server.URL, Events: []string{config.EventChange, config.EventCycle}}}
+	n, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	return n, rec
+}
+
+func change(record string) Event {
+	return Event{Event: config.EventChange, Record: record, RecordType: "A", OldIP: "192.0.2.1", NewIP: "192.0.2.2", Source: "update"}
+}
+
+func TestDigestCycle(t *testing.T) {
+	n, rec := newTestNotifier(t, config.DigestCycle)
+	n.Notify(change("a.example.com"))
+	n.Notify(change("b.example.com"))
+	n.Notify(change("c.example.com"))
+	n.Notify(Event{Event: config.EventCycle, Summary: "3 updated"})
+	n.Wait()
+
+	events := rec.received()
+	if len(events) != 2 {
+		t.Fatalf("received %d events, want a digest and the cycle", len(events))
+	}
+	var digest *Event
+	for i := range events {
+		if events[i].Event == config.EventChange {
+			digest = &events[i]
+		}
+	}
+	if digest == nil || len(digest.Changes) != 3 {
+		t.Fatalf("events = %+v, want one change event batching 3 changes", events)
+	}
+	if digest.Summary != "3 records changed" {
+		t.Errorf("summary = %q, want %q", digest.Summary, "3 records changed")
+	}
+}
+
+func TestDigestSingleChange(t *testing.T) {
+	n, rec := newTestNotifier(t, config.DigestCycle)
+	n.Notify(change("a.example.com"))
+	n.Wait()
+
+	events := rec.received()
+	if len(events) != 1 || events[0].Record != "a.example.com" || len(events[0].Changes) != 0 {
+		t.Errorf("events = %+v, want the change sent as is", events)
+	}
+}
+
+func TestDigestCyclePush(t *testing.T) {
+	n, rec := newTestNotifier(t, config.DigestCycle)
+	pushed := change("a.example.com")
+	pushed.Source = "dyndns"
+	n.Notify(pushed)
+
+	// Sent without waiting for a cycle, which may be check_interval away
+	deadline := time.Now().Add(5 * time.Second)
+	for len(rec.received()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if events := rec.received(); len(events) != 1 || events[0].Source != "dyndns" {
+		t.Errorf("events = %+v, want the pushed change sent right away", events)
+	}
+	n.Wait()
+}
+
+func TestDigestWindow(t *testing.T) {
+	n, rec := newTestNotifier(t, "50ms")
+	n.Notify(change("a.example.com"))
+	n.Notify(change("b.example.com"))
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(rec.received()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	n.Wait()
+
+	events := rec.received()
+	if len(events) != 1 || len(events[0].Changes) != 2 {
+		t.Errorf("events = %+v, want one digest of 2 changes after the window", events)
+	}
+}
+
+func TestDigestMessage(t *testing.T) {
+	event := Event{Event: config.EventChange, Summary: "2 records changed", Changes: []Event{change("a.example.com"), change("b.example.com")}}
+	want := "2 records changed:\n" +
+		"- a.example.com (A) changed from 192.0.2.1 to 192.0.2.2\n" +
+		"- b.example.com (A) changed from 192.0.2.1 to 192.0.2.2"
+	if got := event.Message(); got != want {
+		t.Errorf("Message() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,115 @@
+package notify
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/MrLonely14/cf-ddns/config"
+)
+
+// alert tracks the failures of one record against its error budget
+type alert struct {
+	failures []time.Time // failures within the budget window
+	since    time.Time   // when the budget was exceeded; zero while within it
+	taken    int         // escalation steps taken since then
+}
+
+// escalation applies error budgets and escalation steps to failure events,
+// so every sender sees failures according to the same policy
+type escalation struct {
+	cfg    *config.Config
+	steps  []config.EscalationStep
+	mu     sync.Mutex
+	alerts map[string]*alert // keyed by "name (type)"
+}
+
+// newEscalation creates the policy, or returns nil if failures are not
+// subject to error budgets and every failure is notified
+func newEscalation(cfg *config.Config) *escalation {
+	if !cfg.Alerting() {
+		return nil
+	}
+	return &escalation{
+		cfg:    cfg,
+		steps:  cfg.EscalationSteps(),
+		alerts: make(map[string]*alert),
+	}
+}
+
+// failed records a failure and returns the actions that are now due
+func (e *escalation) failed(event Event) []string {
+	budget := e.cfg.ErrorBudgetFor(event.Record)
+	window := budget.GetWindow()
+	now := event.Time
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	key := alertKey(event.Record, event.RecordType)
+	a, ok := e.alerts[key]
+	if !ok {
+		a = &alert{}
+		e.alerts[key] = a
+	}
+
+	// Only failures within the window count against the budget
+	recent := a.failures[:0]
+	for _, t := range a.failures {
+		if now.Sub(t) < window {
+			recent = append(recent, t)
+		}
+	}
+	a.failures = append(recent, now)
+	if len(a.failures) <= budget.Failures {
+		return nil
+	}
+
+	if a.since.IsZero() {
+		a.since = now
+	}
+	var actions []string
+	for a.taken < len(e.steps) && now.Sub(a.since) >= e.steps[a.taken].GetAfter() {
+		actions = append(actions, e.steps[a.taken].Action)
+		a.taken++
+	}
+	return actions
+}
+
+// recovered forgets the failures of a record that updated successfully. It
+// reports whether an alert had been raised for it.
+func (e *escalation) recovered(record, recordType string) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	key := alertKey(record, recordType)
+	a, ok := e.alerts[key]
+	if !ok {
+		return false
+	}
+	delete(e.alerts, key)
+	return a.taken > 0
+}
+
+// escalate takes the due actions for a failure
+func (n *Notifier) escalate(event Event, actions []string) {
+	for _, action := range actions {
+		switch action {
+		case config.ActionLog:
+			budget := n.escalation.cfg.ErrorBudgetFor(event.Record)
+			log.Printf("Warning: %s (%s) exceeded its error budget of %d failure(s) in %s: %s",
+				event.Record, event.RecordType, budget.Failures, budget.GetWindow(), event.Error)
+		case config.ActionNotify:
+			n.deliver(event)
+		case config.ActionPage:
+			page := event
+			page.Event = config.EventPage
+			n.deliver(page)
+		}
+	}
+}
+
+// alertKey identifies a record and type
+func alertKey(record, recordType string) string {
+	return record + " (" + recordType + ")"
+}
@@ -0,0 +1,177 @@
+// Package notify sends update events such as address changes and failures to
+// webhooks and chat services
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/MrLonely14/cf-ddns/config"
+	"github.com/MrLonely14/cf-ddns/logging"
+)
+
+// Event is the data available to payload templates
+type Event struct {
+	Event      string    `json:"event"` // change, failure or cycle
+	Record     string    `json:"record,omitempty"`
+	RecordType string    `json:"type,omitempty"`
+	OldIP      string    `json:"old_ip,omitempty"`
+	NewIP      string    `json:"new_ip,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	Source     string    `json:"source,omitempty"`  // what caused the change, e.g. detection or a push client
+	Summary    string    `json:"summary,omitempty"` // outcome counts of a finished cycle, or the size of a digest
+	Changes    []Event   `json:"changes,omitempty"` // the changes batched into a digest
+	Time       time.Time `json:"time"`
+}
+
+// webhook is one configured webhook with its parsed payload template, or a
+// chat channel, which is sent the message of the event
+type webhook struct {
+	cfg     config.WebhookConfig
+	payload *template.Template
+	chat    func(message string) any // request body of a chat channel
+}
+
+// Notifier delivers events to the configured webhooks in the background
+type Notifier struct {
+	webhooks   []webhook
+	escalation *escalation // nil if every failure is notified
+	digest     *digest     // nil if every change is sent on its own
+	client     *http.Client
+	wg         sync.WaitGroup
+}
+
+// New creates a notifier for the configured webhooks and alerting policy
+func New(cfg *config.Config) (*Notifier, error) {
+	n := &Notifier{
+		escalation: newEscalation(cfg),
+		digest:     newDigest(cfg),
+		client:     &http.Client{},
+	}
+	for i, hook := range cfg.Notifications.Webhooks {
+		payload, err := hook.PayloadTemplate()
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse payload of webhook %d: %w", i, err)
+		}
+		n.webhooks = append(n.webhooks, webhook{cfg: hook, payload: payload})
+	}
+	n.webhooks = append(n.webhooks, chatChannels(cfg.Notifications)...)
+	return n, nil
+}
+
+// Notify sends an event to every webhook that wants it without waiting for
+// delivery. Failures are subject to the error budgets and escalation steps,
+// and changes are batched into a digest, if configured. Failed deliveries
+// are logged and not retried.
+func (n *Notifier) Notify(event Event) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	event.Error = logging.Redact(event.Error)
+
+	if event.Event == config.EventFailure && n.escalation != nil {
+		n.escalate(event, n.escalation.failed(event))
+		return
+	}
+	if n.digest != nil {
+		if event.Event == config.EventChange && n.digest.batches(event) {
+			n.digest.add(event, n.flushDigest)
+			return
+		}
+		// The cycle's changes are reported before the cycle itself
+		if event.Event == config.EventCycle {
+			n.flushDigest()
+		}
+	}
+	n.deliver(event)
+}
+
+// Recovered resets the error budget of a record that updated successfully
+func (n *Notifier) Recovered(record, recordType string) {
+	if n.escalation != nil && n.escalation.recovered(record, recordType) {
+		log.Printf("%s (%s) recovered after exceeding its error budget", record, recordType)
+	}
+}
+
+// deliver sends an event to every webhook that wants it
+func (n *Notifier) deliver(event Event) {
+	for _, hook := range n.webhooks {
+		if !hook.cfg.Wants(event.Event) {
+			continue
+		}
+		n.wg.Add(1)
+		go func() {
+			defer n.wg.Done()
+			if err := n.send(hook, event); err != nil {
+				log.Printf("Warning: failed to send %s notification: %v", event.Event, err)
+			}
+		}()
+	}
+}
+
+// Wait blocks until all pending notifications are delivered or have failed.
+// Changes batched into a digest are sent first.
+func (n *Notifier) Wait() {
+	n.flushDigest()
+	n.wg.Wait()
+}
+
+// send delivers an event to one webhook
+func (n *Notifier) send(hook webhook, event Event) error {
+	var body bytes.Buffer
+	if hook.payload != nil {
+		if err := hook.payload.Execute(&body, event); err != nil {
+			return fmt.Errorf("failed to render payload: %w", err)
+		}
+	} else {
+		var v any = event
+		if hook.chat != nil {
+			v = hook.chat(event.Message())
+		}
+		enc := json.NewEncoder(&body)
+		enc.SetEscapeHTML(false)
+		if err := enc.Encode(v); err != nil {
+			return fmt.Errorf("failed to encode event: %w", err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), hook.cfg.GetTimeout())
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, hook.cfg.GetMethod(), hook.cfg.URL, &body)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "cf-ddns")
+	for name, value := range hook.cfg.Headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		// The URL may carry a secret, so only the host is reported
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("request to %s failed: %w", req.URL.Host, err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("%s answered with status %d", req.URL.Host, resp.StatusCode)
+	}
+	return nil
+}
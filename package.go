@@ -0,0 +1,64 @@
+//go:build !minimal
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"runtime"
+
+	"github.com/MrLonely14/cf-ddns/installer"
+)
+
+func packageCommand(args []string) {
+	packageCmd := flag.NewFlagSet("package", flag.ExitOnError)
+	platform := packageCmd.String("platform", "", "Platform to build the package for: windows or darwin (required)")
+	binary := packageCmd.String("binary", "", "cf-ddns executable built for the platform (default: this executable, if it matches)")
+	configPath := packageCmd.String("config", "", "Configuration file to include; the service starts right after installation")
+	pkgVersion := packageCmd.String("version", version, "Version of the package")
+	out := packageCmd.String("out", "", "Path to write the package to (default cf-ddns-<version>.msi or .pkg)")
+	packageCmd.Parse(args)
+
+	var extension string
+	switch *platform {
+	case "windows":
+		extension = ".msi"
+	case "darwin":
+		extension = ".pkg"
+	case "":
+		log.Fatalf("-platform is required")
+	default:
+		log.Fatalf("Invalid -platform %s (must be windows or darwin)", *platform)
+	}
+
+	if *binary == "" {
+		if *platform != runtime.GOOS {
+			log.Fatalf("-binary is required to package for %s from %s", *platform, runtime.GOOS)
+		}
+		exePath, err := os.Executable()
+		if err != nil {
+			log.Fatalf("Failed to get executable path: %v", err)
+		}
+		*binary = exePath
+	}
+	if *out == "" {
+		*out = "cf-ddns-" + *pkgVersion + extension
+	}
+
+	err := installer.BuildPackage(installer.PackageConfig{
+		Platform: *platform,
+		Binary:   *binary,
+		Config:   *configPath,
+		Version:  *pkgVersion,
+		Output:   *out,
+	})
+	if err != nil {
+		log.Fatalf("Failed to build package: %v", err)
+	}
+	fmt.Printf("Wrote %s\n", *out)
+	if *configPath == "" {
+		fmt.Println("No configuration included: the service starts once config.yaml is added next to config.example.yaml")
+	}
+}
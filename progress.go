@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/MrLonely14/cf-ddns/config"
+	"github.com/MrLonely14/cf-ddns/term"
+	"github.com/MrLonely14/cf-ddns/updater"
+)
+
+// progressThreshold is the number of record updates from which long
+// operations show a progress bar instead of the per-record log stream
+const progressThreshold = 10
+
+// summaryRow is one line of a summary table
+type summaryRow struct {
+	label string
+	count int
+	bad   bool // highlight a non-zero count as a problem
+}
+
+// printSummary prints outcome counts as an aligned table
+func printSummary(title string, rows []summaryRow) {
+	fmt.Println(term.Bold(title))
+	table := term.NewTable(os.Stdout)
+	for _, row := range rows {
+		count := fmt.Sprint(row.count)
+		if row.bad && row.count > 0 {
+			count = term.Red(count)
+		}
+		fmt.Fprintf(table, "  %s\t%s\n", row.label, count)
+	}
+	table.Flush()
+}
+
+// captureLog buffers log output while a progress bar is shown. The returned
+// function restores normal logging and replays buffered warnings and errors.
+func captureLog() func() {
+	var buf bytes.Buffer
+	prev := log.Writer()
+	log.SetOutput(&buf)
+
+	return func() {
+		log.SetOutput(prev)
+		scanner := bufio.NewScanner(&buf)
+		for scanner.Scan() {
+			line := scanner.Text()
+			if strings.Contains(line, "ERROR") || strings.Contains(line, "Warning") {
+				fmt.Fprintln(prev, line)
+			}
+		}
+	}
+}
+
+// recordCount returns the number of record updates in a cycle
+func recordCount(cfg *config.Config) int {
+	total := 0
+	for _, record := range cfg.Records {
+		total += len(record.Types)
+	}
+	return total
+}
+
+// withProgress runs an update cycle behind a progress bar and prints a summary
+// table afterwards, when running in a terminal with many records. Otherwise
+// the cycle runs with the regular log output.
+func withProgress(cfg *config.Config, upd *updater.Updater, cycle func() error) error {
+	total := recordCount(cfg)
+	if !term.Interactive() || total < progressThreshold {
+		return cycle()
+	}
+
+	bar := term.NewProgress("Updating records", total)
+	restoreLog := captureLog()
+	upd.SetProgress(bar.Set)
+
+	err := cycle()
+
+	upd.SetProgress(nil)
+	bar.Done()
+	restoreLog()
+
+	summary := upd.LastSummary()
+	printSummary(fmt.Sprintf("Initial update (%s)", summary.Duration.Round(time.Millisecond)), []summaryRow{
+		{label: "created", count: summary.Created},
+		{label: "updated", count: summary.Updated},
+		{label: "unchanged", count: summary.Unchanged},
+		{label: "failed", count: summary.Failed, bad: true},
+	})
+	return err
+}
@@ -0,0 +1,51 @@
+package provider
+
+import (
+	"maps"
+	"time"
+
+	"github.com/MrLonely14/cf-ddns/cloudflare"
+	"github.com/MrLonely14/cf-ddns/config"
+)
+
+func init() {
+	Register(config.ProviderCloudflare, newCloudflare)
+}
+
+// newCloudflare creates a Cloudflare client with the configured tokens and
+// retry policy. The zones of records that name an account are served with
+// the account's token.
+func newCloudflare(cfg *config.Config) (Provider, error) {
+	zoneTokens := maps.Clone(cfg.Cloudflare.ZoneTokens)
+	accountTokens := make(map[string]string)
+	for _, account := range cfg.Cloudflare.Accounts {
+		accountTokens[account.Name] = account.APIToken
+	}
+	for _, record := range cfg.Records {
+		if record.Account != "" && record.ZoneID != "" {
+			if zoneTokens == nil {
+				zoneTokens = make(map[string]string)
+			}
+			zoneTokens[record.ZoneID] = accountTokens[record.Account]
+		}
+	}
+
+	client, err := cloudflare.NewClient(cfg.Cloudflare.APIToken, zoneTokens, accountTokens)
+	if err != nil {
+		return nil, err
+	}
+
+	policy := cloudflare.DefaultRetryPolicy
+	retry := cfg.Cloudflare.Retry
+	if retry.MaxAttempts > 0 {
+		policy.MaxAttempts = retry.MaxAttempts
+	}
+	if d, err := time.ParseDuration(retry.InitialDelay); err == nil {
+		policy.InitialDelay = d
+	}
+	if d, err := time.ParseDuration(retry.MaxDelay); err == nil {
+		policy.MaxDelay = d
+	}
+	client.SetRetryPolicy(policy)
+	return client, nil
+}
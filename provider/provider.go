@@ -0,0 +1,106 @@
+// Package provider defines what the updater needs from a DNS backend and
+// keeps a registry of the available backends, selected by name with the
+// provider option. Backends register themselves from an init function.
+package provider
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/MrLonely14/cf-ddns/cloudflare"
+	"github.com/MrLonely14/cf-ddns/config"
+)
+
+// Record and Change are the records and writes exchanged with providers.
+// They are defined by the cloudflare package, the first backend.
+type (
+	Record = cloudflare.DNSRecordInfo
+	Change = cloudflare.RecordChange
+)
+
+// ErrRecordNotFound must be returned, wrapped or not, by GetDNSRecord when
+// no record matches
+var ErrRecordNotFound = cloudflare.ErrRecordNotFound
+
+// Provider reads and writes the DNS records of zones. Names are passed in
+// the form returned by cloudflare.NormalizeName, and kind tells apart TXT
+// records sharing a name, see cloudflare.RecordKind.
+type Provider interface {
+	GetDNSRecord(ctx context.Context, zoneID, name, recordType, kind string) (*Record, error)
+	ListDNSRecords(ctx context.Context, zoneID string) ([]*Record, error)
+	UpsertDNSRecord(ctx context.Context, zoneID, name, recordType, content string, ttl int, proxied bool, comment string) (*Change, error)
+	DeleteDNSRecord(ctx context.Context, record *Record) (*Change, error)
+}
+
+// ZoneResolver is implemented by providers that can look up zone IDs by
+// name, which records with zone instead of zone_id need
+type ZoneResolver interface {
+	ZoneID(ctx context.Context, name string) (string, error)
+}
+
+// AccountZoneResolver is implemented by providers with several accounts,
+// which look up the zones of records that name an account with its
+// credentials
+type AccountZoneResolver interface {
+	AccountZoneID(ctx context.Context, account, name string) (string, error)
+}
+
+// ZoneInspector is implemented by providers that can describe a zone, which
+// is used to check that record names are within their zone
+type ZoneInspector interface {
+	GetZone(ctx context.Context, zoneID string) (*cloudflare.ZoneInfo, error)
+}
+
+// AccessChecker is implemented by providers that can check their
+// credentials before the first update
+type AccessChecker interface {
+	CheckAccess(ctx context.Context, zoneIDs []string, edit bool) error
+}
+
+// Factory creates a provider from the configuration
+type Factory func(cfg *config.Config) (Provider, error)
+
+var (
+	mu        sync.RWMutex
+	factories = make(map[string]Factory)
+)
+
+// Register makes a provider available under a name. It panics if the name
+// is already taken.
+func Register(name string, factory Factory) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if _, taken := factories[name]; taken {
+		panic("provider: " + name + " is registered twice")
+	}
+	factories[name] = factory
+}
+
+// Names returns the names of the registered providers, sorted
+func Names() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	names := make([]string, 0, len(factories))
+	for name := range factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// New creates the provider the configuration selects
+func New(cfg *config.Config) (Provider, error) {
+	mu.RLock()
+	factory, ok := factories[cfg.GetProvider()]
+	mu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown provider %s (available: %s)", cfg.GetProvider(), strings.Join(Names(), ", "))
+	}
+	return factory(cfg)
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/MrLonely14/cf-ddns/cloudflare"
+	"github.com/MrLonely14/cf-ddns/config"
+	"github.com/MrLonely14/cf-ddns/fake"
+	"github.com/MrLonely14/cf-ddns/ipdetect"
+	"github.com/MrLonely14/cf-ddns/logging"
+	"github.com/MrLonely14/cf-ddns/replay"
+	"github.com/MrLonely14/cf-ddns/updater"
+)
+
+func replayCommand(args []string) {
+	replayCmd := flag.NewFlagSet("replay", flag.ExitOnError)
+	configPath := replayCmd.String("config", "config.yaml", "Path to configuration file")
+	historyPath := replayCmd.String("history", "", "Path to the recorded IP history")
+	replayCmd.Parse(args)
+
+	if *historyPath == "" {
+		log.Fatalf("The -history flag is required")
+	}
+
+	replayHistory(*configPath, *historyPath)
+}
+
+// replayHistory feeds recorded address changes through the updater against a
+// fake provider, running one update cycle per event, and summarizes the writes
+// the configuration would have made
+func replayHistory(configPath, historyPath string) {
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+	logging.SetSecrets(cfg.Secrets())
+
+	events, err := replay.Load(historyPath)
+	if err != nil {
+		log.Fatalf("Failed to load history: %v", err)
+	}
+	if len(events) == 0 {
+		log.Fatalf("No events in %s", historyPath)
+	}
+
+	provider := fake.NewProvider()
+	source := &replay.Source{}
+	upd := updater.NewUpdater(cfg, provider, ipdetect.NewDetectorFromSource(source))
+
+	ctx := context.Background()
+	failedCycles := 0
+	for _, event := range events {
+		log.Printf("Replaying %s: %s", event.Time.Format(time.RFC3339), event.IP)
+		source.Apply(event)
+		if err := upd.UpdateAll(ctx); err != nil {
+			failedCycles++
+		}
+	}
+
+	// Count the writes per record
+	writes := make(map[string]int)
+	for _, change := range provider.Changes() {
+		record := change.After
+		if record == nil {
+			record = change.Before // deleted
+		}
+		writes[fmt.Sprintf("%s (%s)", cloudflare.DisplayName(record.Name), record.Type)]++
+	}
+	labels := make([]string, 0, len(writes))
+	for label := range writes {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+
+	span := events[len(events)-1].Time.Sub(events[0].Time)
+	fmt.Printf("\nReplayed %d event(s) spanning %s\n", len(events), span.Round(time.Second))
+	fmt.Printf("Cycles with errors: %d\n", failedCycles)
+	fmt.Printf("Writes: %d\n", len(provider.Changes()))
+	for _, label := range labels {
+		fmt.Printf("  %s: %d\n", label, writes[label])
+	}
+
+	if failedCycles > 0 {
+		os.Exit(1)
+	}
+}
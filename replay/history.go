@@ -0,0 +1,101 @@
+package replay
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net/netip"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Event is one recorded public address observation
+type Event struct {
+	Time time.Time
+	IP   string
+}
+
+// IsIPv6 reports whether the event is for an IPv6 address
+func (e Event) IsIPv6() bool {
+	addr, err := netip.ParseAddr(e.IP)
+	return err == nil && addr.Unmap().Is6()
+}
+
+// Load reads a history file. Each line holds an RFC 3339 timestamp and an
+// address separated by whitespace; blank lines and lines starting with #
+// are ignored. Events are returned in file order.
+func Load(path string) ([]Event, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open history file: %w", err)
+	}
+	defer f.Close()
+
+	var events []Event
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		fields := strings.Fields(text)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("line %d: expected \"<time> <ip>\"", line)
+		}
+		t, err := time.Parse(time.RFC3339, fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid time: %w", line, err)
+		}
+		if _, err := netip.ParseAddr(fields[1]); err != nil {
+			return nil, fmt.Errorf("line %d: invalid IP address %s", line, fields[1])
+		}
+		events = append(events, Event{Time: t, IP: fields[1]})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read history file: %w", err)
+	}
+
+	return events, nil
+}
+
+// Source is an IP detection source that returns the addresses of the events
+// replayed so far
+type Source struct {
+	mu   sync.Mutex
+	ipv4 string
+	ipv6 string
+}
+
+// Apply makes the event's address the current one for its family
+func (s *Source) Apply(e Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e.IsIPv6() {
+		s.ipv6 = e.IP
+	} else {
+		s.ipv4 = e.IP
+	}
+}
+
+// Name identifies the source in logs
+func (s *Source) Name() string {
+	return "replay"
+}
+
+// GetIP returns the most recently replayed address of the requested family
+func (s *Source) GetIP(ctx context.Context, isIPv6 bool) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ip := s.ipv4
+	if isIPv6 {
+		ip = s.ipv6
+	}
+	if ip == "" {
+		return "", fmt.Errorf("no address recorded yet")
+	}
+	return ip, nil
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/MrLonely14/cf-ddns/config"
+	"github.com/MrLonely14/cf-ddns/history"
+	"github.com/MrLonely14/cf-ddns/term"
+)
+
+func reportCommand(args []string) {
+	reportCmd := flag.NewFlagSet("report", flag.ExitOnError)
+	configPath := reportCmd.String("config", "config.yaml", "Path to configuration file")
+	since := reportCmd.String("since", "", "Only report on the period since this age (e.g. 30d) or date (default: all)")
+	output := reportCmd.String("output", "text", "Output format: text or json")
+	stateDir := reportCmd.String("state-dir", "", "Directory for state, history and statistics (overrides state_dir)")
+	reportCmd.Parse(args)
+
+	if *output != "text" && *output != "json" {
+		log.Fatalf("Invalid -output %s (must be text or json)", *output)
+	}
+	stabilityReport(config.StateDir(*configPath, *stateDir), *since, *output)
+}
+
+// stabilityReport summarizes the recorded address changes and detection
+// statistics, e.g. to document an unreliable connection to an ISP
+func stabilityReport(stateDir, since, output string) {
+	now := time.Now()
+	var start time.Time
+	if since != "" {
+		var err error
+		if start, err = history.ParseSince(since, now); err != nil {
+			log.Fatalf("Invalid -since: %v", err)
+		}
+	}
+
+	entries, err := history.Read(history.Path(stateDir))
+	if err != nil && !os.IsNotExist(err) {
+		log.Fatalf("Failed to read address history: %v", err)
+	}
+	days, err := history.ReadStats(history.StatsPath(stateDir))
+	if err != nil && !os.IsNotExist(err) {
+		log.Fatalf("Failed to read detection statistics: %v", err)
+	}
+	if len(entries) == 0 && len(days) == 0 {
+		log.Fatalf("No address history in %s yet; it is recorded while the daemon runs", stateDir)
+	}
+
+	report := history.Summarize(entries, days, start, now)
+	if output == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			log.Fatalf("Failed to write report: %v", err)
+		}
+		return
+	}
+
+	fmt.Println(term.Bold(fmt.Sprintf("Address stability from %s to %s (%s)",
+		report.Since.Format(time.DateOnly), report.Until.Format(time.DateOnly), formatDays(report.Until.Sub(report.Since)))))
+	if len(report.Families) == 0 {
+		fmt.Println("No addresses were detected in this period")
+		return
+	}
+	for _, f := range report.Families {
+		fmt.Println()
+		fmt.Println(term.Bold(f.Family))
+		fmt.Printf("  Changes:             %d (%.1f per week)\n", f.Changes, f.ChangesPerWeek)
+		if f.LongestStable > 0 {
+			fmt.Printf("  Longest stable:      %s (%s to %s)\n", formatDays(f.LongestStable),
+				f.StableFrom.Local().Format(time.DateTime), f.StableUntil.Local().Format(time.DateTime))
+		}
+		if f.Attempts == 0 {
+			fmt.Println("  Detections:          no statistics recorded")
+			continue
+		}
+		fmt.Printf("  Detections:          %d, %d failed (%.2f%% error rate)\n", f.Attempts, f.Failures, f.ErrorRate*100)
+		fmt.Printf("  Avg detection time:  %s\n", f.AverageLatency.Round(time.Millisecond))
+	}
+}
+
+// formatDays formats a long duration in days and hours, e.g. "12d 4h"
+func formatDays(d time.Duration) string {
+	d = d.Round(time.Hour)
+	days, hours := int(d.Hours())/24, int(d.Hours())%24
+	if days == 0 {
+		return fmt.Sprintf("%dh", hours)
+	}
+	return fmt.Sprintf("%dd %dh", days, hours)
+}
@@ -0,0 +1,56 @@
+// Package sdnotify reports the daemon's state to systemd, for services of
+// Type=notify
+package sdnotify
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"time"
+)
+
+// States sent to the service manager
+const (
+	Ready     = "READY=1"     // startup finished
+	Reloading = "RELOADING=1" // restarting with a new configuration; followed by Ready
+	Stopping  = "STOPPING=1"  // shutting down
+	Watchdog  = "WATCHDOG=1"  // still alive
+)
+
+// Send sends a state to the service manager. It does nothing when the process
+// wasn't started by systemd with a notification socket.
+func Send(state string) error {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return nil
+	}
+	// A leading @ names a socket in the abstract namespace
+	if socket[0] == '@' {
+		socket = "\x00" + socket[1:]
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return fmt.Errorf("failed to connect to the systemd notification socket: %w", err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte(state)); err != nil {
+		return fmt.Errorf("failed to notify systemd: %w", err)
+	}
+	return nil
+}
+
+// WatchdogInterval returns how often Watchdog must be sent: half the timeout
+// set by WatchdogSec=, so a late ping doesn't get the service restarted. It
+// returns 0 if the watchdog is disabled or meant for another process.
+func WatchdogInterval() time.Duration {
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0
+	}
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+	return time.Duration(usec) * time.Microsecond / 2
+}
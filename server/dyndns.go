@@ -0,0 +1,96 @@
+package server
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/MrLonely14/cf-ddns/config"
+	"github.com/MrLonely14/cf-ddns/updater"
+)
+
+// NewDynDNS creates a server implementing the DynDNS2 update protocol, so
+// routers with a built-in DDNS client can push their address for push records
+func NewDynDNS(cfg config.DynDNSConfig, upd *updater.Updater) *Server {
+	s := newServer(cfg.Listen, upd)
+	s.mux.HandleFunc("/nic/update", func(w http.ResponseWriter, r *http.Request) {
+		s.handleDynDNSUpdate(w, r, cfg)
+	})
+	return s
+}
+
+// handleDynDNSUpdate serves /nic/update?hostname=...&myip=..., answering with
+// one DynDNS2 return code per hostname
+func (s *Server) handleDynDNSUpdate(w http.ResponseWriter, r *http.Request, cfg config.DynDNSConfig) {
+	w.Header().Set("Content-Type", "text/plain")
+
+	username, password, ok := r.BasicAuth()
+	if !ok || !secretEqual(username, cfg.Username) || !secretEqual(password, cfg.Password) {
+		w.Header().Set("WWW-Authenticate", `Basic realm="cf-ddns"`)
+		w.WriteHeader(http.StatusUnauthorized)
+		fmt.Fprintln(w, "badauth")
+		return
+	}
+
+	hostnames := splitList(r.URL.Query().Get("hostname"))
+	if len(hostnames) == 0 {
+		fmt.Fprintln(w, "notfqdn")
+		return
+	}
+
+	// Without myip, the address the request came from is used
+	ips := splitList(r.URL.Query().Get("myip"))
+	if len(ips) == 0 {
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			host = r.RemoteAddr
+		}
+		ips = []string{host}
+	}
+
+	for _, hostname := range hostnames {
+		fmt.Fprintln(w, s.pushDynDNS(r, hostname, ips))
+	}
+}
+
+// pushDynDNS applies the addresses to one hostname and returns its return code
+func (s *Server) pushDynDNS(r *http.Request, hostname string, ips []string) string {
+	changed := false
+	for _, ip := range ips {
+		ok, err := s.upd.PushIP(r.Context(), "dyndns", hostname, ip)
+		if errors.Is(err, updater.ErrNotPushable) {
+			log.Printf("Warning: DynDNS2 update from %s rejected: %v", r.RemoteAddr, err)
+			return "nohost"
+		}
+		if err != nil {
+			log.Printf("ERROR: DynDNS2 update from %s failed: %v", r.RemoteAddr, err)
+			return "dnserr"
+		}
+		changed = changed || ok
+	}
+
+	if changed {
+		return "good " + strings.Join(ips, ",")
+	}
+	return "nochg " + strings.Join(ips, ",")
+}
+
+// splitList splits a comma-separated query value, dropping empty entries
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// secretEqual compares credentials in constant time
+func secretEqual(given, want string) bool {
+	return subtle.ConstantTimeCompare([]byte(given), []byte(want)) == 1
+}
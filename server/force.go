@@ -0,0 +1,56 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+)
+
+// ForceFunc runs a forced update of the named records, or of all records if
+// names is empty, and returns once it has finished
+type ForceFunc func(ctx context.Context, names []string) error
+
+// forceRequest is the optional body of POST /api/v1/force
+type forceRequest struct {
+	Records []string `json:"records"`
+}
+
+// resultResponse is the body returned by the control endpoints
+type resultResponse struct {
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+// HandleForce enables POST /api/v1/force, which re-detects the IP and rewrites
+// records even if they appear up to date. Records may be selected with a JSON
+// body {"records": [...]} or repeated ?record= query parameters.
+func (s *Server) HandleForce(fn ForceFunc) {
+	s.mux.HandleFunc("/api/v1/force", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			writeJSON(w, http.StatusMethodNotAllowed, resultResponse{Error: "use POST"})
+			return
+		}
+
+		var req forceRequest
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxBodySize))
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, resultResponse{Error: err.Error()})
+			return
+		}
+		if len(body) > 0 {
+			if err := json.Unmarshal(body, &req); err != nil {
+				writeJSON(w, http.StatusBadRequest, resultResponse{Error: "invalid JSON body: " + err.Error()})
+				return
+			}
+		}
+		req.Records = append(req.Records, r.URL.Query()["record"]...)
+
+		if err := fn(r.Context(), req.Records); err != nil {
+			writeJSON(w, http.StatusInternalServerError, resultResponse{Error: err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, resultResponse{OK: true})
+	})
+}
@@ -0,0 +1,15 @@
+package server
+
+import (
+	"net/http/pprof"
+)
+
+// HandlePprof serves Go's runtime profiles under /debug/pprof/, for
+// diagnosing memory and CPU use on the device itself
+func (s *Server) HandlePprof() {
+	s.mux.HandleFunc("/debug/pprof/", pprof.Index)
+	s.mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	s.mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	s.mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	s.mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+}
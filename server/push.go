@@ -0,0 +1,104 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"github.com/MrLonely14/cf-ddns/cloudflare"
+	"github.com/MrLonely14/cf-ddns/config"
+	"github.com/MrLonely14/cf-ddns/updater"
+)
+
+// pushRequest is the body of POST /api/v1/push
+type pushRequest struct {
+	Record string `json:"record"`
+	IP     string `json:"ip"`
+}
+
+// pushResponse is the body returned by /api/v1/push
+type pushResponse struct {
+	OK      bool   `json:"ok"`
+	Changed bool   `json:"changed"`
+	Error   string `json:"error,omitempty"`
+}
+
+// HandlePush enables POST /api/v1/push, which sets a push record to an address
+// known to the client. Each request must carry one of tokens as a bearer
+// token, and may only set the records that token lists.
+func (s *Server) HandlePush(tokens []config.PushToken) {
+	s.mux.HandleFunc("/api/v1/push", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			writeJSON(w, http.StatusMethodNotAllowed, pushResponse{Error: "use POST"})
+			return
+		}
+
+		token := matchToken(tokens, r.Header.Get("Authorization"))
+		if token == nil {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeJSON(w, http.StatusUnauthorized, pushResponse{Error: "missing or invalid token"})
+			return
+		}
+
+		var req pushRequest
+		if err := json.NewDecoder(io.LimitReader(r.Body, maxBodySize)).Decode(&req); err != nil {
+			writeJSON(w, http.StatusBadRequest, pushResponse{Error: "invalid JSON body: " + err.Error()})
+			return
+		}
+		if req.Record == "" {
+			writeJSON(w, http.StatusBadRequest, pushResponse{Error: "record is required"})
+			return
+		}
+		if _, err := netip.ParseAddr(strings.TrimSpace(req.IP)); err != nil {
+			writeJSON(w, http.StatusBadRequest, pushResponse{Error: "invalid IP address: " + req.IP})
+			return
+		}
+		if !tokenAllows(token, req.Record) {
+			log.Printf("Warning: push for %s from %s rejected: not allowed for this token", req.Record, r.RemoteAddr)
+			writeJSON(w, http.StatusForbidden, pushResponse{Error: "token may not set " + req.Record})
+			return
+		}
+
+		changed, err := s.upd.PushIP(r.Context(), "push", req.Record, req.IP)
+		if errors.Is(err, updater.ErrNotPushable) {
+			writeJSON(w, http.StatusNotFound, pushResponse{Error: err.Error()})
+			return
+		}
+		if err != nil {
+			log.Printf("ERROR: push from %s failed: %v", r.RemoteAddr, err)
+			writeJSON(w, http.StatusInternalServerError, pushResponse{Error: err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, pushResponse{OK: true, Changed: changed})
+	})
+}
+
+// matchToken returns the push token presented in an Authorization header, or
+// nil if it matches none
+func matchToken(tokens []config.PushToken, header string) *config.PushToken {
+	presented, ok := strings.CutPrefix(header, "Bearer ")
+	if !ok {
+		return nil
+	}
+	for i := range tokens {
+		if secretEqual(presented, tokens[i].Token) {
+			return &tokens[i]
+		}
+	}
+	return nil
+}
+
+// tokenAllows reports whether a token is scoped to the named record
+func tokenAllows(token *config.PushToken, name string) bool {
+	for _, allowed := range token.Records {
+		if cloudflare.NormalizeName(allowed) == cloudflare.NormalizeName(name) {
+			return true
+		}
+	}
+	return false
+}
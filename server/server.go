@@ -0,0 +1,148 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/MrLonely14/cf-ddns/logging"
+	"github.com/MrLonely14/cf-ddns/updater"
+)
+
+// maxBodySize limits request bodies accepted by the API endpoints
+const maxBodySize = 64 * 1024
+
+// Server is the daemon's local HTTP endpoint for health probes and control requests
+type Server struct {
+	httpServer *http.Server
+	mux        *http.ServeMux
+	upd        *updater.Updater
+	ready      atomic.Bool   // startup (state initialization and first update) finished
+	stopped    chan struct{} // closed once shut down
+}
+
+// New creates a server listening on addr that reports the updater's health
+func New(addr string, upd *updater.Updater) *Server {
+	s := newServer(addr, upd)
+	s.mux.HandleFunc("/healthz", s.handleHealth)
+	s.mux.HandleFunc("/readyz", s.handleReady)
+
+	return s
+}
+
+// newServer creates a server on addr without any handlers
+func newServer(addr string, upd *updater.Updater) *Server {
+	s := &Server{
+		mux:     http.NewServeMux(),
+		upd:     upd,
+		stopped: make(chan struct{}),
+	}
+	s.httpServer = &http.Server{
+		Addr:              addr,
+		Handler:           s.mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	return s
+}
+
+// Start serves requests in the background until ctx is cancelled
+func (s *Server) Start(ctx context.Context) {
+	go func() {
+		log.Printf("Listening on %s", s.httpServer.Addr)
+		if err := s.httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("ERROR: HTTP server stopped: %v", err)
+		}
+	}()
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		s.httpServer.Shutdown(shutdownCtx)
+		close(s.stopped)
+	}()
+}
+
+// Wait blocks until the server has shut down after its context was cancelled
+func (s *Server) Wait() {
+	<-s.stopped
+}
+
+// SetReady marks the daemon as ready once startup has finished
+func (s *Server) SetReady() {
+	s.ready.Store(true)
+}
+
+// failingRecord is a record in the health response
+type failingRecord struct {
+	Record   string `json:"record"`
+	Category string `json:"category"`
+	Error    string `json:"error"`
+}
+
+// healthResponse is the body of /healthz
+type healthResponse struct {
+	Status     string          `json:"status"` // ok or failing
+	Failing    []failingRecord `json:"failing"`
+	Categories map[string]int  `json:"categories"` // failing records per error category
+	Frozen     bool            `json:"frozen"`     // writes are paused by the freeze file
+}
+
+// handleHealth reports 200 when every record's last update succeeded, and 503
+// with the failing records and their error categories otherwise
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	resp := healthResponse{
+		Status:     "ok",
+		Failing:    []failingRecord{},
+		Categories: map[string]int{},
+		Frozen:     s.upd.Frozen(),
+	}
+	for label, health := range s.upd.Unhealthy() {
+		resp.Failing = append(resp.Failing, failingRecord{Record: label, Category: health.Category, Error: health.Error})
+		resp.Categories[health.Category]++
+	}
+	sort.Slice(resp.Failing, func(i, j int) bool { return resp.Failing[i].Record < resp.Failing[j].Record })
+
+	status := http.StatusOK
+	if len(resp.Failing) > 0 {
+		resp.Status = "failing"
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, resp)
+}
+
+// readyResponse is the body of /readyz
+type readyResponse struct {
+	Ready  bool   `json:"ready"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// handleReady reports 200 once startup has finished, 503 before
+func (s *Server) handleReady(w http.ResponseWriter, r *http.Request) {
+	if s.ready.Load() {
+		writeJSON(w, http.StatusOK, readyResponse{Ready: true})
+		return
+	}
+	writeJSON(w, http.StatusServiceUnavailable, readyResponse{Reason: "startup has not finished"})
+}
+
+// writeJSON writes a JSON response body with the given status code
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	// Error messages in responses may quote credentials
+	data, err := json.Marshal(body)
+	if err != nil {
+		log.Printf("Warning: failed to encode response: %v", err)
+		status, data = http.StatusInternalServerError, []byte(`{"error":"failed to encode response"}`)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if _, err := io.WriteString(w, logging.Redact(string(data))+"\n"); err != nil {
+		log.Printf("Warning: failed to write response: %v", err)
+	}
+}
@@ -0,0 +1,54 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/netip"
+
+	"github.com/MrLonely14/cf-ddns/config"
+)
+
+// SimulateFunc runs an update cycle with ip in place of the detected address
+type SimulateFunc func(ctx context.Context, ip string) error
+
+// simulateRequest is the body of POST /api/v1/simulate
+type simulateRequest struct {
+	IP string `json:"ip"`
+}
+
+// HandleSimulate enables POST /api/v1/simulate, which injects a fake address
+// through IP detection to test the change path end to end. It publishes any
+// address to every record, so each request must carry one of tokens as a
+// bearer token, which should only be those scoped to every record.
+func (s *Server) HandleSimulate(tokens []config.PushToken, fn SimulateFunc) {
+	s.mux.HandleFunc("/api/v1/simulate", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			writeJSON(w, http.StatusMethodNotAllowed, resultResponse{Error: "use POST"})
+			return
+		}
+		if matchToken(tokens, r.Header.Get("Authorization")) == nil {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeJSON(w, http.StatusUnauthorized, resultResponse{Error: "missing or invalid token"})
+			return
+		}
+
+		var req simulateRequest
+		if err := json.NewDecoder(io.LimitReader(r.Body, maxBodySize)).Decode(&req); err != nil {
+			writeJSON(w, http.StatusBadRequest, resultResponse{Error: "invalid JSON body: " + err.Error()})
+			return
+		}
+		if _, err := netip.ParseAddr(req.IP); err != nil {
+			writeJSON(w, http.StatusBadRequest, resultResponse{Error: "invalid IP address: " + req.IP})
+			return
+		}
+
+		if err := fn(r.Context(), req.IP); err != nil {
+			writeJSON(w, http.StatusInternalServerError, resultResponse{Error: err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, resultResponse{OK: true})
+	})
+}
@@ -0,0 +1,72 @@
+//go:build !minimal
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/MrLonely14/cf-ddns/i18n"
+	"github.com/MrLonely14/cf-ddns/installer"
+)
+
+func installService(configPath, user string) {
+	log.Println(i18n.T("Installing cf-ddns as system service..."))
+
+	// Get executable path
+	exePath, err := os.Executable()
+	if err != nil {
+		log.Fatal(i18n.T("Failed to get executable path: %v", err))
+	}
+
+	// Install service
+	if err := installer.Install(exePath, configPath, user); err != nil {
+		log.Fatal(i18n.T("Failed to install service: %v", err))
+	}
+
+	example := filepath.Dir(configPath) + "/config.example.yaml"
+	log.Println(i18n.T("Service installed successfully!"))
+	log.Println("\n" + i18n.T("Next steps:"))
+	log.Println(i18n.T("1. Edit the example configuration file:"))
+	log.Println("   " + i18n.T("Example: %s", example))
+	log.Println("   " + i18n.T("Copy it to: %s", configPath))
+	log.Println("   " + i18n.T("Command: %s", "sudo cp "+example+" "+configPath))
+	log.Println(i18n.T("2. Edit the config file with your Cloudflare API token and zones"))
+	log.Println(i18n.T("3. Start the service:"))
+	installer.PrintStartCommand()
+}
+
+// stageService writes the service files below destDir for a .deb or .rpm
+// build, leaving this system untouched
+func stageService(destDir, execPath, configPath, user string) {
+	if destDir == "" {
+		log.Fatalf("-packaging needs -destdir or DESTDIR")
+	}
+	if !filepath.IsAbs(configPath) || !filepath.IsAbs(execPath) {
+		log.Fatalf("-config and -exec-path must be absolute paths on the target system")
+	}
+	files, err := installer.Stage(destDir, execPath, configPath, user)
+	if err != nil {
+		log.Fatalf("Failed to stage service files: %v", err)
+	}
+	for _, file := range files {
+		fmt.Println(file)
+	}
+}
+
+func uninstallService() {
+	log.Println(i18n.T("Uninstalling cf-ddns system service..."))
+
+	if err := installer.Uninstall(); err != nil {
+		log.Fatal(i18n.T("Failed to uninstall service: %v", err))
+	}
+
+	log.Println(i18n.T("Service uninstalled successfully!"))
+}
+
+// serviceStatus reports whether the system service is installed and running
+func serviceStatus() (string, error) {
+	return installer.Status()
+}
@@ -0,0 +1,29 @@
+//go:build minimal
+
+package main
+
+import "log"
+
+// Builds with the minimal tag leave out service management to save space on
+// embedded devices, which use their own init scripts
+
+func installService(configPath, user string) {
+	log.Fatalf("install is not available in this build (built with the minimal tag)")
+}
+
+func stageService(destDir, execPath, configPath, user string) {
+	log.Fatalf("install is not available in this build (built with the minimal tag)")
+}
+
+func uninstallService() {
+	log.Fatalf("uninstall is not available in this build (built with the minimal tag)")
+}
+
+func packageCommand(args []string) {
+	log.Fatalf("package is not available in this build (built with the minimal tag)")
+}
+
+// serviceStatus reports that service management is not included
+func serviceStatus() (string, error) {
+	return "Service management is not included in this build", nil
+}
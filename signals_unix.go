@@ -0,0 +1,18 @@
+//go:build !windows
+
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// forceSignals request a forced update of all records
+var forceSignals = []os.Signal{syscall.SIGUSR1}
+
+// notifyShutdown relays the signals that shut the daemon down to c
+func notifyShutdown(ctx context.Context, c chan<- os.Signal) {
+	signal.Notify(c, syscall.SIGINT, syscall.SIGTERM)
+}
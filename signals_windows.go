@@ -0,0 +1,31 @@
+//go:build windows
+
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// forceSignals request a forced update of all records; Windows has no
+// user-defined signals, so use the control API or CLI instead
+var forceSignals []os.Signal
+
+// notifyShutdown relays the signals that shut the daemon down to c, and,
+// when running as a service, stop requests from the service manager until
+// ctx is done
+func notifyShutdown(ctx context.Context, c chan<- os.Signal) {
+	signal.Notify(c, syscall.SIGINT, syscall.SIGTERM)
+	if serviceStop == nil {
+		return
+	}
+	go func() {
+		select {
+		case <-serviceStop:
+			c <- syscall.SIGTERM
+		case <-ctx.Done():
+		}
+	}()
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"runtime"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/MrLonely14/cf-ddns/config"
+	"github.com/MrLonely14/cf-ddns/fake"
+	"github.com/MrLonely14/cf-ddns/ipdetect"
+	"github.com/MrLonely14/cf-ddns/logging"
+	"github.com/MrLonely14/cf-ddns/updater"
+)
+
+// soakOptions are the flags of the soak command
+type soakOptions struct {
+	duration    time.Duration // total run time
+	changeEvery time.Duration // interval between synthetic IP changes
+	checkEvery  time.Duration // interval between update cycles, 0 for the config's
+	latency     time.Duration // added to every fake provider call
+	errorRate   float64       // fraction of fake provider writes that fail
+	verbose     bool          // log every record update, not just problems
+}
+
+func soakCommand(args []string) {
+	soakCmd := flag.NewFlagSet("soak", flag.ExitOnError)
+	configPath := soakCmd.String("config", "config.yaml", "Path to configuration file")
+	var opts soakOptions
+	soakCmd.DurationVar(&opts.duration, "duration", time.Hour, "How long to run")
+	soakCmd.DurationVar(&opts.changeEvery, "change-every", 2*time.Minute, "Interval between synthetic IP changes")
+	soakCmd.DurationVar(&opts.checkEvery, "check-every", 0, "Interval between update cycles (default: the config's check interval)")
+	soakCmd.DurationVar(&opts.latency, "latency", 0, "Latency added to every fake provider call")
+	soakCmd.Float64Var(&opts.errorRate, "error-rate", 0, "Fraction of fake provider writes that fail (0-1)")
+	soakCmd.BoolVar(&opts.verbose, "verbose", false, "Log every record update instead of only warnings and errors")
+	soakCmd.Parse(args)
+
+	if opts.duration <= 0 || opts.changeEvery <= 0 || opts.checkEvery < 0 {
+		log.Fatalf("-duration and -change-every must be positive")
+	}
+	if opts.errorRate < 0 || opts.errorRate > 1 {
+		log.Fatalf("-error-rate must be between 0 and 1")
+	}
+
+	soak(*configPath, opts)
+}
+
+// soakStats are the measurements of a soak run
+type soakStats struct {
+	cycles       []time.Duration // duration of every cycle
+	failedCycles int
+	changes      int
+	propagations []time.Duration // time from an IP change to the first cycle that wrote it everywhere
+	unpropagated int             // changes overtaken by the next one before being written
+}
+
+// soak runs the update pipeline against a fake provider for a while,
+// changing the detected addresses at a fixed rate, and reports timing and
+// error statistics
+func soak(configPath string, opts soakOptions) {
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+	logging.SetSecrets(cfg.Secrets())
+	checkEvery := opts.checkEvery
+	if checkEvery == 0 {
+		checkEvery = cfg.EffectiveCheckInterval()
+	}
+
+	provider := fake.NewProvider()
+	provider.SetFaults(opts.latency, opts.errorRate)
+	source := fake.NewChurnSource()
+	upd := updater.NewUpdater(cfg, provider, ipdetect.NewDetectorFromSource(source))
+
+	ctx, cancel := context.WithTimeout(context.Background(), opts.duration)
+	defer cancel()
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigChan)
+
+	log.Printf("Soaking %d record(s) for %s: IP change every %s, update cycle every %s",
+		len(cfg.Records), opts.duration, opts.changeEvery, checkEvery)
+
+	// A long run would otherwise log every record of every cycle
+	if !opts.verbose {
+		previous := log.Writer()
+		log.SetOutput(problemWriter{w: previous})
+		defer log.SetOutput(previous)
+	}
+
+	var memStart runtime.MemStats
+	runtime.ReadMemStats(&memStart)
+	goroutinesStart := runtime.NumGoroutine()
+	start := time.Now()
+
+	var stats soakStats
+	var changedAt time.Time
+	pending := false
+	cycle := func() {
+		err := upd.UpdateAll(ctx)
+		if ctx.Err() != nil {
+			return // cut short by the end of the run
+		}
+		stats.cycles = append(stats.cycles, upd.LastSummary().Duration)
+		if err != nil {
+			stats.failedCycles++
+			return
+		}
+		if pending {
+			stats.propagations = append(stats.propagations, time.Since(changedAt))
+			pending = false
+		}
+	}
+
+	changes := time.NewTicker(opts.changeEvery)
+	defer changes.Stop()
+	checks := time.NewTicker(checkEvery)
+	defer checks.Stop()
+
+	cycle()
+loop:
+	for {
+		select {
+		case <-changes.C:
+			if pending {
+				stats.unpropagated++
+			}
+			source.Change()
+			stats.changes++
+			changedAt, pending = time.Now(), true
+		case <-checks.C:
+			cycle()
+		case <-sigChan:
+			log.Println("Interrupted, reporting results so far")
+			break loop
+		case <-ctx.Done():
+			break loop
+		}
+	}
+
+	var memEnd runtime.MemStats
+	runtime.ReadMemStats(&memEnd)
+
+	fmt.Printf("\nSoaked for %s\n", time.Since(start).Round(time.Second))
+	fmt.Printf("Cycles: %d (%d with errors)\n", len(stats.cycles), stats.failedCycles)
+	fmt.Printf("Cycle time: %s\n", durationStats(stats.cycles))
+	fmt.Printf("IP changes: %d (%d overtaken before being written)\n", stats.changes, stats.unpropagated)
+	fmt.Printf("Change to DNS: %s\n", durationStats(stats.propagations))
+	fmt.Printf("Writes: %d\n", len(provider.Changes()))
+	fmt.Printf("Heap: %.1f MiB -> %.1f MiB, goroutines: %d -> %d\n",
+		float64(memStart.HeapAlloc)/(1<<20), float64(memEnd.HeapAlloc)/(1<<20), goroutinesStart, runtime.NumGoroutine())
+
+	// Failures are expected when they were injected
+	if stats.failedCycles > 0 && opts.errorRate == 0 {
+		os.Exit(1)
+	}
+}
+
+// problemWriter passes on only warning and error log lines
+type problemWriter struct {
+	w io.Writer
+}
+
+func (p problemWriter) Write(b []byte) (int, error) {
+	line := string(b)
+	if strings.Contains(line, "ERROR") || strings.Contains(line, "Warning") {
+		return p.w.Write(b)
+	}
+	return len(b), nil
+}
+
+// durationStats summarizes a set of durations as min/avg/p95/max
+func durationStats(durations []time.Duration) string {
+	if len(durations) == 0 {
+		return "n/a"
+	}
+
+	sorted := append([]time.Duration(nil), durations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	var total time.Duration
+	for _, d := range sorted {
+		total += d
+	}
+	p95 := sorted[(len(sorted)*95+99)/100-1]
+
+	return fmt.Sprintf("min %s, avg %s, p95 %s, max %s",
+		sorted[0].Round(time.Microsecond), (total / time.Duration(len(sorted))).Round(time.Microsecond),
+		p95.Round(time.Microsecond), sorted[len(sorted)-1].Round(time.Microsecond))
+}
@@ -0,0 +1,67 @@
+package store
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Counters are update cycle totals over a period
+type Counters struct {
+	Since     time.Time     `json:"since"`
+	Cycles    int64         `json:"cycles"`
+	Changes   int64         `json:"changes"`
+	Errors    int64         `json:"errors"`
+	CycleTime time.Duration `json:"cycle_time"`
+	MaxCycle  time.Duration `json:"max_cycle"`
+	Slow      int64         `json:"slow"` // cycles over the latency budget
+}
+
+// Add records a finished update cycle
+func (c *Counters) Add(duration time.Duration, changes, errors int, slow bool) {
+	c.Cycles++
+	c.Changes += int64(changes)
+	c.Errors += int64(errors)
+	c.CycleTime += duration
+	if duration > c.MaxCycle {
+		c.MaxCycle = duration
+	}
+	if slow {
+		c.Slow++
+	}
+}
+
+// AverageCycle returns the mean update cycle duration
+func (c Counters) AverageCycle() time.Duration {
+	if c.Cycles == 0 {
+		return 0
+	}
+	return c.CycleTime / time.Duration(c.Cycles)
+}
+
+// Stats holds counters since the daemon last started and since installation
+type Stats struct {
+	Session Counters `json:"session"`
+	Total   Counters `json:"total"`
+}
+
+// String formats the statistics for the status command
+func (s Stats) String() string {
+	var b strings.Builder
+	for _, period := range []struct {
+		label    string
+		counters Counters
+	}{
+		{"Since start", s.Session},
+		{"Since install", s.Total},
+	} {
+		c := period.counters
+		if c.Since.IsZero() {
+			continue
+		}
+		fmt.Fprintf(&b, "%s (%s, up %s):\n", period.label, c.Since.Format(time.RFC3339), time.Since(c.Since).Round(time.Second))
+		fmt.Fprintf(&b, "  Cycles: %d  Changes: %d  Errors: %d  Avg cycle: %s  Max cycle: %s  Slow: %d\n",
+			c.Cycles, c.Changes, c.Errors, c.AverageCycle().Round(time.Millisecond), c.MaxCycle.Round(time.Millisecond), c.Slow)
+	}
+	return b.String()
+}
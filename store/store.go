@@ -0,0 +1,96 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// FileName is the name of the persistent state file
+const FileName = "state.json"
+
+// Path returns the state file location in a state directory
+func Path(stateDir string) string {
+	return filepath.Join(stateDir, FileName)
+}
+
+// Data is the persisted content of the state file
+type Data struct {
+	Stats Stats           `json:"stats"`
+	Zones map[string]Zone `json:"zones,omitempty"` // keyed by zone ID
+
+	// Unhealthy maps "name (type)" to the last error for records that are failing
+	Unhealthy map[string]string `json:"unhealthy,omitempty"`
+
+	// Effective is the resolved configuration the daemon last started with
+	Effective []string `json:"effective,omitempty"`
+}
+
+// Zone is cached zone metadata
+type Zone struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Plan      string    `json:"plan"`
+	Status    string    `json:"status"`
+	FetchedAt time.Time `json:"fetched_at"`
+}
+
+// Store is the daemon's persistent state file
+type Store struct {
+	path string
+	mu   sync.Mutex
+	data Data
+}
+
+// Open loads the state file, starting empty if it doesn't exist yet
+func Open(path string) (*Store, error) {
+	s := &Store{path: path}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return s, nil
+		}
+		return nil, fmt.Errorf("failed to read state file: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &s.data); err != nil {
+		return nil, fmt.Errorf("failed to parse state file: %w", err)
+	}
+
+	return s, nil
+}
+
+// View returns a copy of the current data
+func (s *Store) View() Data {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.data
+}
+
+// Update applies fn to the data and writes the state file
+func (s *Store) Update(fn func(*Data)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	fn(&s.data)
+
+	data, err := json.MarshalIndent(s.data, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode state: %w", err)
+	}
+
+	// Write to a temporary file first so a crash never leaves a partial file
+	tmpPath := s.path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0600); err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	if err := os.Rename(tmpPath, s.path); err != nil {
+		return fmt.Errorf("failed to replace state file: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,211 @@
+// Package sysproxy sends outbound requests through the proxy configured in
+// the desktop's network settings, including proxy auto-config (PAC) files,
+// so that the updater works on machines behind a mandatory proxy. The
+// settings are read from WinHTTP on Windows and from scutil on macOS.
+package sysproxy
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// cacheTTL is how long the proxy chosen for a host is reused. Evaluating a
+// PAC file is too slow to repeat for every request.
+const cacheTTL = 5 * time.Minute
+
+var (
+	enabled atomic.Bool
+
+	mu    sync.Mutex
+	cache = make(map[string]cachedProxy) // keyed by scheme://host
+)
+
+// cachedProxy is the proxy chosen for a host, nil for a direct connection
+type cachedProxy struct {
+	proxy   *url.URL
+	expires time.Time
+}
+
+// The API, webhook and config source clients use the default transport
+func init() {
+	if t, ok := http.DefaultTransport.(*http.Transport); ok {
+		t.Proxy = Proxy
+	}
+}
+
+// Enable selects whether requests use the system proxy settings. It returns
+// an error if they can't be read on this platform.
+func Enable(on bool) error {
+	if on && !supported {
+		return fmt.Errorf("system proxy settings are not supported on this platform; set HTTPS_PROXY instead")
+	}
+	enabled.Store(on)
+	mu.Lock()
+	clear(cache)
+	mu.Unlock()
+	return nil
+}
+
+// Proxy returns the proxy for a request, for use as http.Transport.Proxy.
+// The HTTPS_PROXY, HTTP_PROXY and NO_PROXY environment variables take
+// precedence; without them the system proxy is used if enabled. A nil URL
+// means a direct connection.
+func Proxy(req *http.Request) (*url.URL, error) {
+	if !enabled.Load() || fromEnvironment() || isLocal(req.URL.Hostname()) {
+		return http.ProxyFromEnvironment(req)
+	}
+
+	key := req.URL.Scheme + "://" + req.URL.Host
+	mu.Lock()
+	cached, ok := cache[key]
+	mu.Unlock()
+	if ok && time.Now().Before(cached.expires) {
+		return cached.proxy, nil
+	}
+
+	proxy, err := systemProxy(req.URL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the system proxy settings: %w", err)
+	}
+	mu.Lock()
+	cache[key] = cachedProxy{proxy: proxy, expires: time.Now().Add(cacheTTL)}
+	mu.Unlock()
+	return proxy, nil
+}
+
+// fromEnvironment reports whether a proxy is configured by environment variables
+func fromEnvironment() bool {
+	for _, name := range []string{"HTTPS_PROXY", "https_proxy", "HTTP_PROXY", "http_proxy"} {
+		if os.Getenv(name) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// isLocal reports whether host is this machine, which is never proxied
+func isLocal(host string) bool {
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// parseProxyList returns the proxy for scheme from a WinHTTP or Internet
+// Options proxy list: "host:port", or "http=host:port;https=host:port" with
+// proxies per scheme. Of several proxies the first is used.
+func parseProxyList(list, scheme string) (*url.URL, error) {
+	var generic, socks string
+	for _, entry := range strings.FieldsFunc(list, isListSeparator) {
+		name, proxy, found := strings.Cut(entry, "=")
+		switch {
+		case !found:
+			if generic == "" {
+				generic = entry
+			}
+		case strings.EqualFold(name, scheme):
+			return proxyURL("http", proxy)
+		case strings.EqualFold(name, "socks"):
+			if socks == "" {
+				socks = proxy
+			}
+		}
+	}
+	switch {
+	case generic != "":
+		return proxyURL("http", generic)
+	case socks != "":
+		return proxyURL("socks5", socks)
+	}
+	return nil, nil
+}
+
+// parsePACResult returns the proxy of the first usable entry of a PAC result,
+// such as "PROXY proxy.example.com:8080; DIRECT". Later entries are fallbacks,
+// which can't be tried once a request is under way, so they are ignored.
+func parsePACResult(result string) (*url.URL, error) {
+	for entry := range strings.SplitSeq(result, ";") {
+		fields := strings.Fields(entry)
+		if len(fields) == 0 {
+			continue
+		}
+		switch strings.ToUpper(fields[0]) {
+		case "DIRECT":
+			return nil, nil
+		case "PROXY", "HTTP":
+			if len(fields) == 2 {
+				return proxyURL("http", fields[1])
+			}
+		case "HTTPS":
+			if len(fields) == 2 {
+				return proxyURL("https", fields[1])
+			}
+		case "SOCKS", "SOCKS5":
+			if len(fields) == 2 {
+				return proxyURL("socks5", fields[1])
+			}
+		}
+	}
+	return nil, fmt.Errorf("no usable entry in PAC result %q", result)
+}
+
+// proxyURL parses a proxy address, adding scheme if it has none
+func proxyURL(scheme, proxy string) (*url.URL, error) {
+	if !strings.Contains(proxy, "://") {
+		proxy = scheme + "://" + proxy
+	}
+	u, err := url.Parse(proxy)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy %s: %w", proxy, err)
+	}
+	return u, nil
+}
+
+// bypassed reports whether host matches an entry of a proxy bypass list, such
+// as "*.example.com;10.*;<local>". <local> matches names without a dot.
+func bypassed(host string, list []string) bool {
+	for _, pattern := range list {
+		pattern = strings.TrimSpace(pattern)
+		switch {
+		case pattern == "":
+		case pattern == "<local>":
+			if !strings.Contains(host, ".") {
+				return true
+			}
+		case matchWildcard(strings.ToLower(pattern), strings.ToLower(host)):
+			return true
+		}
+	}
+	return false
+}
+
+// matchWildcard matches s against a pattern in which * stands for any text
+func matchWildcard(pattern, s string) bool {
+	head, rest, found := strings.Cut(pattern, "*")
+	if !found {
+		return pattern == s
+	}
+	if !strings.HasPrefix(s, head) {
+		return false
+	}
+	s = s[len(head):]
+	for i := 0; i <= len(s); i++ {
+		if matchWildcard(rest, s[i:]) {
+			return true
+		}
+	}
+	return false
+}
+
+// isListSeparator separates the entries of a proxy list
+func isListSeparator(r rune) bool {
+	return r == ';' || r == ' ' || r == '\t' || r == '\n' || r == '\r'
+}
@@ -0,0 +1,190 @@
+//go:build darwin
+
+package sysproxy
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// supported reports whether system proxy settings can be read here
+const supported = true
+
+// maxPACSize caps how much of a PAC file is read
+const maxPACSize = 1 << 20
+
+// pacClient downloads PAC files. It must not use Proxy, which needs the file.
+var pacClient = &http.Client{
+	Timeout:   10 * time.Second,
+	Transport: &http.Transport{},
+}
+
+// systemProxy returns the proxy for u from the network settings shown by
+// scutil --proxy: the result of the PAC file if one is configured, else the
+// static proxy of u's scheme unless u's host is an exception
+func systemProxy(u *url.URL) (*url.URL, error) {
+	out, err := exec.Command("scutil", "--proxy").Output()
+	if err != nil {
+		return nil, fmt.Errorf("scutil failed: %w", err)
+	}
+	settings, exceptions := parseScutil(string(out))
+	host := u.Hostname()
+
+	if settings["ProxyAutoConfigEnable"] == "1" && settings["ProxyAutoConfigURLString"] != "" {
+		result, err := evaluatePAC(settings["ProxyAutoConfigURLString"], u)
+		if err != nil {
+			return nil, err
+		}
+		return parsePACResult(result)
+	}
+
+	if bypassed(host, exceptions) || (settings["ExcludeSimpleHostnames"] == "1" && !strings.Contains(host, ".")) {
+		return nil, nil
+	}
+	prefix := "HTTP"
+	if u.Scheme == "https" {
+		prefix = "HTTPS"
+	}
+	for _, p := range []struct{ prefix, scheme string }{{prefix, "http"}, {"SOCKS", "socks5"}} {
+		if settings[p.prefix+"Enable"] == "1" && settings[p.prefix+"Proxy"] != "" {
+			return proxyURL(p.scheme, net.JoinHostPort(settings[p.prefix+"Proxy"], settings[p.prefix+"Port"]))
+		}
+	}
+	return nil, nil
+}
+
+// parseScutil returns the "key : value" settings of scutil --proxy and the
+// entries of its ExceptionsList
+func parseScutil(out string) (map[string]string, []string) {
+	settings := make(map[string]string)
+	var exceptions []string
+	inExceptions := false
+
+	scanner := bufio.NewScanner(strings.NewReader(out))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if inExceptions {
+			if line == "}" {
+				inExceptions = false
+			} else if _, value, ok := strings.Cut(line, " : "); ok {
+				exceptions = append(exceptions, value)
+			}
+			continue
+		}
+		key, value, ok := strings.Cut(line, " : ")
+		if !ok {
+			continue
+		}
+		if key == "ExceptionsList" {
+			inExceptions = true
+			continue
+		}
+		settings[key] = value
+	}
+	return settings, exceptions
+}
+
+// pacFunctions implements the helper functions PAC files may call. DNS
+// lookups are limited to the request's host, which is resolved beforehand,
+// and conditions on the date and time are always true.
+const pacFunctions = `
+function isPlainHostName(h) { return h.indexOf('.') < 0; }
+function dnsDomainIs(h, d) { return h.length >= d.length && h.substring(h.length - d.length) == d; }
+function localHostOrDomainIs(h, hd) { return h == hd || hd.indexOf(h + '.') == 0; }
+function dnsResolve(h) { return h == __host ? __address : null; }
+function isResolvable(h) { return dnsResolve(h) != null; }
+function myIpAddress() { return __myAddress; }
+function dnsDomainLevels(h) { return h.split('.').length - 1; }
+function convert_addr(a) { var p = a.split('.'); return ((p[0] << 24) | (p[1] << 16) | (p[2] << 8) | p[3]) >>> 0; }
+function isInNet(h, pattern, mask) {
+	var a = /^\d+\.\d+\.\d+\.\d+$/.test(h) ? h : dnsResolve(h);
+	return a != null && (convert_addr(a) & convert_addr(mask)) >>> 0 == (convert_addr(pattern) & convert_addr(mask)) >>> 0;
+}
+function shExpMatch(s, p) {
+	return new RegExp('^' + p.replace(/[.+^${}()|[\]\\]/g, '\\$&').replace(/\*/g, '.*').replace(/\?/g, '.') + '$').test(s);
+}
+function weekdayRange() { return true; }
+function dateRange() { return true; }
+function timeRange() { return true; }
+`
+
+// evaluatePAC downloads the PAC file at pacURL and runs its FindProxyForURL
+// for u with JavaScript for Automation, which every macOS has
+func evaluatePAC(pacURL string, u *url.URL) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	script, err := fetchPAC(ctx, pacURL)
+	if err != nil {
+		return "", fmt.Errorf("failed to read PAC file %s: %w", pacURL, err)
+	}
+
+	host := u.Hostname()
+	var address string
+	if addrs, err := net.DefaultResolver.LookupIP(ctx, "ip4", host); err == nil && len(addrs) > 0 {
+		address = addrs[0].String()
+	}
+	values, err := json.Marshal([]string{u.String(), host, address, localAddress()})
+	if err != nil {
+		return "", err
+	}
+
+	var program strings.Builder
+	program.WriteString(pacFunctions)
+	program.WriteString(script)
+	fmt.Fprintf(&program, "\nvar __v = %s, __host = __v[1], __address = __v[2] || null, __myAddress = __v[3];\nFindProxyForURL(__v[0], __host);\n", values)
+
+	cmd := exec.CommandContext(ctx, "osascript", "-l", "JavaScript")
+	cmd.Stdin = strings.NewReader(program.String())
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to evaluate PAC file %s: %w", pacURL, err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// fetchPAC downloads a PAC file, or reads it if it is a file:// URL
+func fetchPAC(ctx context.Context, pacURL string) (string, error) {
+	if path, ok := strings.CutPrefix(pacURL, "file://"); ok {
+		body, err := os.ReadFile(path)
+		return string(body), err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, pacURL, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := pacClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("server returned status %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxPACSize))
+	if err != nil {
+		return "", err
+	}
+	return string(body), nil
+}
+
+// localAddress returns the IPv4 address of the interface holding the default
+// route, as myIpAddress does in browsers
+func localAddress() string {
+	conn, err := net.Dial("udp4", "192.0.2.1:9") // no packet is sent
+	if err != nil {
+		return "127.0.0.1"
+	}
+	defer conn.Close()
+	return conn.LocalAddr().(*net.UDPAddr).IP.String()
+}
@@ -0,0 +1,13 @@
+//go:build !windows && !darwin
+
+package sysproxy
+
+import "net/url"
+
+// supported reports whether system proxy settings can be read here
+const supported = false
+
+// systemProxy is never called, as Enable refuses to enable it here
+func systemProxy(*url.URL) (*url.URL, error) {
+	return nil, nil
+}
@@ -0,0 +1,178 @@
+//go:build windows
+
+package sysproxy
+
+import (
+	"net/url"
+	"strings"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	winhttp                                   = syscall.NewLazyDLL("winhttp.dll")
+	procWinHttpOpen                           = winhttp.NewProc("WinHttpOpen")
+	procWinHttpGetIEProxyConfigForCurrentUser = winhttp.NewProc("WinHttpGetIEProxyConfigForCurrentUser")
+	procWinHttpGetProxyForUrl                 = winhttp.NewProc("WinHttpGetProxyForUrl")
+
+	kernel32       = syscall.NewLazyDLL("kernel32.dll")
+	procGlobalFree = kernel32.NewProc("GlobalFree")
+)
+
+// supported reports whether system proxy settings can be read here
+const supported = true
+
+const (
+	winhttpAccessTypeNoProxy    = 1
+	winhttpAccessTypeNamedProxy = 3
+
+	winhttpAutoproxyAutoDetect = 0x1
+	winhttpAutoproxyConfigURL  = 0x2
+	winhttpAutoDetectTypeDHCP  = 0x1
+	winhttpAutoDetectTypeDNSA  = 0x2
+)
+
+// ieProxyConfig is WINHTTP_CURRENT_USER_IE_PROXY_CONFIG
+type ieProxyConfig struct {
+	AutoDetect    int32
+	AutoConfigURL *uint16
+	Proxy         *uint16
+	ProxyBypass   *uint16
+}
+
+// autoProxyOptions is WINHTTP_AUTOPROXY_OPTIONS
+type autoProxyOptions struct {
+	Flags                 uint32
+	AutoDetectFlags       uint32
+	AutoConfigURL         *uint16
+	Reserved              uintptr
+	ReservedFlags         uint32
+	AutoLogonIfChallenged int32
+}
+
+// proxyInfo is WINHTTP_PROXY_INFO
+type proxyInfo struct {
+	AccessType  uint32
+	Proxy       *uint16
+	ProxyBypass *uint16
+}
+
+// The WinHTTP session PAC files are evaluated in, opened once
+var (
+	openSession sync.Once
+	session     uintptr
+	sessionErr  error
+)
+
+// systemProxy returns the proxy for u from the current user's Internet
+// Options: the result of the PAC file or of automatic detection (WPAD) if
+// either is set up, else the static proxy unless u's host is bypassed
+func systemProxy(u *url.URL) (*url.URL, error) {
+	var ie ieProxyConfig
+	if _, err := call(procWinHttpGetIEProxyConfigForCurrentUser, ptr(&ie)); err != nil {
+		return nil, err
+	}
+	defer globalFree(ie.AutoConfigURL)
+	defer globalFree(ie.Proxy)
+	defer globalFree(ie.ProxyBypass)
+
+	if ie.AutoDetect != 0 || ie.AutoConfigURL != nil {
+		proxy, ok, err := autoProxy(u, ie)
+		if err != nil || ok {
+			return proxy, err
+		}
+	}
+
+	if ie.Proxy == nil {
+		return nil, nil
+	}
+	if ie.ProxyBypass != nil && bypassed(u.Hostname(), strings.FieldsFunc(utf16String(ie.ProxyBypass), isListSeparator)) {
+		return nil, nil
+	}
+	return parseProxyList(utf16String(ie.Proxy), u.Scheme)
+}
+
+// autoProxy asks WinHTTP to evaluate the PAC file for u. It reports false if
+// no PAC file was found, in which case the static settings apply.
+func autoProxy(u *url.URL, ie ieProxyConfig) (*url.URL, bool, error) {
+	openSession.Do(func() {
+		session, sessionErr = call(procWinHttpOpen, ptr(utf16("cf-ddns")), winhttpAccessTypeNoProxy, 0, 0, 0)
+	})
+	if sessionErr != nil {
+		return nil, false, sessionErr
+	}
+
+	options := autoProxyOptions{AutoLogonIfChallenged: 1}
+	if ie.AutoConfigURL != nil {
+		options.Flags |= winhttpAutoproxyConfigURL
+		options.AutoConfigURL = ie.AutoConfigURL
+	}
+	if ie.AutoDetect != 0 {
+		options.Flags |= winhttpAutoproxyAutoDetect
+		options.AutoDetectFlags = winhttpAutoDetectTypeDHCP | winhttpAutoDetectTypeDNSA
+	}
+
+	var info proxyInfo
+	if _, err := call(procWinHttpGetProxyForUrl, session, ptr(utf16(u.String())), ptr(&options), ptr(&info)); err != nil {
+		// Without a reachable PAC file, browsers fall back to the static settings too
+		return nil, false, nil
+	}
+	defer globalFree(info.Proxy)
+	defer globalFree(info.ProxyBypass)
+
+	if info.AccessType != winhttpAccessTypeNamedProxy || info.Proxy == nil {
+		return nil, true, nil
+	}
+	proxy, err := parseProxyList(utf16String(info.Proxy), u.Scheme)
+	return proxy, true, err
+}
+
+// globalFree releases a string allocated by WinHTTP
+func globalFree(p *uint16) {
+	if p != nil {
+		procGlobalFree.Call(uintptr(unsafe.Pointer(p)))
+	}
+}
+
+// call invokes a procedure returning a BOOL or handle, turning a zero
+// result into its last error
+func call(proc *syscall.LazyProc, args ...uintptr) (uintptr, error) {
+	if err := proc.Find(); err != nil {
+		return 0, err
+	}
+	r, _, err := proc.Call(args...)
+	if r == 0 {
+		if errno, ok := err.(syscall.Errno); ok && errno != 0 {
+			return 0, errno
+		}
+		return 0, syscall.EINVAL
+	}
+	return r, nil
+}
+
+// utf16 converts s for a call
+func utf16(s string) *uint16 {
+	p, err := syscall.UTF16PtrFromString(s)
+	if err != nil {
+		return nil
+	}
+	return p
+}
+
+// utf16String converts a NUL-terminated string returned by a call
+func utf16String(p *uint16) string {
+	if p == nil {
+		return ""
+	}
+	var chars []uint16
+	for ; *p != 0; p = (*uint16)(unsafe.Add(unsafe.Pointer(p), 2)) {
+		chars = append(chars, *p)
+	}
+	return syscall.UTF16ToString(chars)
+}
+
+// ptr passes a pointer as a call argument
+func ptr[T any](p *T) uintptr {
+	return uintptr(unsafe.Pointer(p))
+}
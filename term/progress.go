@@ -0,0 +1,52 @@
+package term
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// progressWidth is the number of characters in the bar
+const progressWidth = 30
+
+// Progress renders a single-line progress bar on an interactive terminal.
+// On pipes and files it prints nothing.
+type Progress struct {
+	label string
+	total int
+	mu    sync.Mutex
+}
+
+// NewProgress creates a progress bar for total steps
+func NewProgress(label string, total int) *Progress {
+	return &Progress{label: label, total: total}
+}
+
+// Set redraws the bar with done steps completed
+func (p *Progress) Set(done, total int) {
+	if !interactive {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if total > 0 {
+		p.total = total
+	}
+	filled := 0
+	if p.total > 0 {
+		filled = progressWidth * done / p.total
+	}
+	fmt.Fprintf(os.Stdout, "\r%s [%s%s] %d/%d", p.label,
+		Green(strings.Repeat("#", filled)), strings.Repeat("-", progressWidth-filled), done, p.total)
+}
+
+// Done clears the bar so following output starts on a clean line
+func (p *Progress) Done() {
+	if !interactive {
+		return
+	}
+	fmt.Fprint(os.Stdout, "\r\033[K")
+}
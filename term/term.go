@@ -0,0 +1,77 @@
+package term
+
+import (
+	"io"
+	"os"
+	"text/tabwriter"
+)
+
+// ANSI escape sequences
+const (
+	reset  = "\033[0m"
+	bold   = "\033[1m"
+	red    = "\033[31m"
+	green  = "\033[32m"
+	yellow = "\033[33m"
+	dim    = "\033[2m"
+)
+
+// interactive is whether stdout is a terminal that should get colors
+var interactive = detect()
+
+// detect checks for a character device on stdout, honoring NO_COLOR and TERM=dumb
+func detect() bool {
+	if os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	info, err := os.Stdout.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// Interactive reports whether output goes to a terminal rather than a pipe or file
+func Interactive() bool {
+	return interactive
+}
+
+// Disable turns off colors and progress bars, e.g. for machine-readable output
+func Disable() {
+	interactive = false
+}
+
+// color wraps s in an escape sequence when writing to a terminal
+func color(code, s string) string {
+	if !interactive {
+		return s
+	}
+	return code + s + reset
+}
+
+// Bold highlights headings
+func Bold(s string) string { return color(bold, s) }
+
+// Red marks failures
+func Red(s string) string { return color(red, s) }
+
+// Green marks success
+func Green(s string) string { return color(green, s) }
+
+// Yellow marks warnings
+func Yellow(s string) string { return color(yellow, s) }
+
+// Dim de-emphasizes secondary details
+func Dim(s string) string { return color(dim, s) }
+
+// OK prefixes s with a green check mark
+func OK(s string) string { return Green("✓") + " " + s }
+
+// Fail prefixes s with a red cross
+func Fail(s string) string { return Red("✗") + " " + s }
+
+// Warn prefixes s with a yellow exclamation mark
+func Warn(s string) string { return Yellow("!") + " " + s }
+
+// NewTable returns a writer that aligns tab-separated columns; call Flush when
+// done. Only color the last column, since escape codes count toward a cell's width.
+func NewTable(w io.Writer) *tabwriter.Writer {
+	return tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+}
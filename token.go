@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/MrLonely14/cf-ddns/cloudflare"
+	"github.com/MrLonely14/cf-ddns/config"
+	"github.com/MrLonely14/cf-ddns/logging"
+	"github.com/MrLonely14/cf-ddns/provider"
+	"github.com/MrLonely14/cf-ddns/term"
+)
+
+func tokenCommand(args []string) {
+	if len(args) < 1 || args[0] != "check" {
+		fmt.Println("Usage: cf-ddns token check [-config path]")
+		os.Exit(1)
+	}
+
+	checkCmd := flag.NewFlagSet("token check", flag.ExitOnError)
+	configPath := checkCmd.String("config", "config.yaml", "Path to configuration file")
+	checkCmd.Parse(args[1:])
+
+	checkTokens(*configPath)
+}
+
+// checkTokens checks every configured token against the zones it is used for
+func checkTokens(configPath string) {
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+	logging.SetSecrets(cfg.Secrets())
+	applyProcessSettings(cfg)
+
+	if cfg.GetProvider() != config.ProviderCloudflare {
+		log.Fatalf("token check only applies to Cloudflare tokens, not to provider %s", cfg.GetProvider())
+	}
+
+	ctx := context.Background()
+	dnsProvider, err := provider.New(cfg)
+	if err != nil {
+		log.Fatalf("Failed to create DNS provider: %v", err)
+	}
+	if err := resolveZoneNames(ctx, cfg, dnsProvider); err != nil {
+		log.Fatalf("Failed to resolve zones: %v", err)
+	}
+
+	// Group the configured zones by the token that serves them, in config order
+	var tokens []string
+	zonesByToken := make(map[string][]string)
+	seen := make(map[string]bool)
+	for _, record := range cfg.Records {
+		if seen[record.ZoneID] {
+			continue
+		}
+		seen[record.ZoneID] = true
+
+		token := cfg.Cloudflare.TokenForRecord(record)
+		if _, ok := zonesByToken[token]; !ok {
+			tokens = append(tokens, token)
+		}
+		zonesByToken[token] = append(zonesByToken[token], record.ZoneID)
+	}
+
+	problems := 0
+	for i, token := range tokens {
+		if i > 0 {
+			fmt.Println()
+		}
+		problems += checkToken(ctx, token, zonesByToken[token])
+	}
+
+	if problems > 0 {
+		os.Exit(1)
+	}
+}
+
+// checkToken reports what a token can access compared to the zones it is
+// used for, recommends a minimal policy, and returns the number of problems found
+func checkToken(ctx context.Context, apiToken string, zoneIDs []string) int {
+	cfClient, err := cloudflare.NewClient(apiToken, nil, nil)
+	if err != nil {
+		log.Fatalf("Failed to create Cloudflare client: %v", err)
+	}
+
+	problems := 0
+
+	token, err := cfClient.VerifyToken(ctx)
+	if err != nil {
+		fmt.Println(term.Fail(fmt.Sprintf("Token used for %s failed verification: %v", strings.Join(zoneIDs, ", "), err)))
+		return 1
+	}
+	fmt.Println(term.Bold(fmt.Sprintf("Token %s is %s", token.ID, token.Status)))
+	if !token.ExpiresOn.IsZero() {
+		fmt.Printf("Expires on %s\n", token.ExpiresOn.Format("2006-01-02"))
+	}
+
+	// Policies are only readable if the token may read API tokens, which is
+	// itself more than a DDNS token needs
+	fmt.Println("\n" + term.Bold("Policies:"))
+	if policies, err := cfClient.TokenPolicies(ctx, token.ID); err != nil {
+		fmt.Println("  Not readable with this token (expected for a least-privilege token)")
+	} else {
+		for _, p := range policies {
+			fmt.Printf("  %s: %s on %s\n", p.Effect, strings.Join(p.Permissions, ", "), strings.Join(p.Resources, ", "))
+		}
+		fmt.Println("  " + term.Warn("this token can read API tokens, which cf-ddns does not need"))
+	}
+
+	// Check access to every zone this token is used for
+	needed := make(map[string]bool)
+	var neededNames []string
+	fmt.Println("\n" + term.Bold("Configured zones:"))
+	for _, zoneID := range zoneIDs {
+		needed[zoneID] = true
+
+		zone, err := cfClient.GetZone(ctx, zoneID)
+		if err != nil {
+			fmt.Println("  " + term.Fail(fmt.Sprintf("%s: cannot read zone (needs Zone:Read or DNS:Edit on this zone)", zoneID)))
+			problems++
+			continue
+		}
+		neededNames = append(neededNames, zone.Name)
+
+		if err := cfClient.CheckDNSRead(ctx, zoneID); err != nil {
+			fmt.Println("  " + term.Fail(fmt.Sprintf("%s (%s): cannot read DNS records (needs DNS:Edit)", zone.Name, zone.ID)))
+			problems++
+			continue
+		}
+		if err := cfClient.CheckDNSEdit(ctx, zoneID); err != nil {
+			fmt.Println("  " + term.Fail(fmt.Sprintf("%s (%s): cannot edit DNS records (needs DNS:Edit)", zone.Name, zone.ID)))
+			problems++
+			continue
+		}
+		fmt.Println("  " + term.OK(fmt.Sprintf("%s (%s): zone readable, DNS records editable", zone.Name, zone.ID)))
+	}
+
+	// Zones visible beyond the configured ones indicate an over-broad token
+	if visible, err := cfClient.ListZones(ctx); err == nil {
+		var extra []string
+		for _, zone := range visible {
+			if !needed[zone.ID] {
+				extra = append(extra, zone.Name)
+			}
+		}
+		if len(extra) > 0 {
+			fmt.Println("\n" + term.Warn(fmt.Sprintf("The token can also access %d zone(s) it is not used for:", len(extra))))
+			for _, name := range extra {
+				fmt.Printf("  - %s\n", name)
+			}
+			problems++
+		}
+	}
+
+	fmt.Println("\n" + term.Bold("Recommended minimal policy:"))
+	fmt.Println("  Permissions:     Zone → DNS → Edit")
+	fmt.Printf("  Zone Resources:  Include → Specific zone → %s\n", strings.Join(neededNames, ", "))
+
+	return problems
+}
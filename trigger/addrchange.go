@@ -0,0 +1,10 @@
+package trigger
+
+// addressSource triggers checks when the operating system reports that an
+// interface address was added or removed
+type addressSource struct{}
+
+// Name identifies the source in logs
+func (addressSource) Name() string {
+	return "address_change"
+}
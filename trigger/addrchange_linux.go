@@ -0,0 +1,144 @@
+//go:build linux
+
+package trigger
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"syscall"
+	"time"
+)
+
+// Netlink multicast groups of IPv4 and IPv6 address changes, which the
+// syscall package doesn't define
+const (
+	rtmgrpIPv4IfAddr = 0x10
+	rtmgrpIPv6IfAddr = 0x100
+)
+
+// netlinkReadTimeout is how often a blocked read wakes up to check whether
+// ctx was cancelled
+const netlinkReadTimeout = time.Second
+
+// Run listens for RTM_NEWADDR and RTM_DELADDR messages on a netlink socket
+// until ctx is cancelled. The kernel repeats RTM_NEWADDR whenever a router
+// advertisement refreshes the lifetime of an IPv6 address, so only addresses
+// that appear or disappear fire.
+func (addressSource) Run(ctx context.Context, fire func()) error {
+	fd, err := syscall.Socket(syscall.AF_NETLINK, syscall.SOCK_RAW|syscall.SOCK_CLOEXEC, syscall.NETLINK_ROUTE)
+	if err != nil {
+		return fmt.Errorf("failed to open netlink socket: %w", err)
+	}
+	defer syscall.Close(fd)
+
+	groups := &syscall.SockaddrNetlink{
+		Family: syscall.AF_NETLINK,
+		Groups: rtmgrpIPv4IfAddr | rtmgrpIPv6IfAddr,
+	}
+	if err := syscall.Bind(fd, groups); err != nil {
+		return fmt.Errorf("failed to subscribe to address changes: %w", err)
+	}
+	timeout := syscall.NsecToTimeval(netlinkReadTimeout.Nanoseconds())
+	if err := syscall.SetsockoptTimeval(fd, syscall.SOL_SOCKET, syscall.SO_RCVTIMEO, &timeout); err != nil {
+		return fmt.Errorf("failed to set netlink read timeout: %w", err)
+	}
+
+	// Listed after subscribing, so that no change falls in between
+	known, err := currentAddresses()
+	if err != nil {
+		return err
+	}
+
+	buf := make([]byte, 1<<16)
+	for ctx.Err() == nil {
+		n, _, err := syscall.Recvfrom(fd, buf, 0)
+		switch {
+		case err == syscall.EAGAIN || err == syscall.EINTR:
+			continue
+		case err == syscall.ENOBUFS:
+			// Messages were dropped; one of them may have been a change
+			fire()
+			continue
+		case err != nil:
+			return fmt.Errorf("failed to read from netlink socket: %w", err)
+		}
+
+		msgs, err := syscall.ParseNetlinkMessage(buf[:n])
+		if err != nil {
+			continue
+		}
+		changed := false
+		for _, msg := range msgs {
+			key, ok := addressKey(msg)
+			if !ok {
+				continue
+			}
+			switch msg.Header.Type {
+			case syscall.RTM_NEWADDR:
+				if !known[key] {
+					known[key] = true
+					changed = true
+				}
+			case syscall.RTM_DELADDR:
+				if known[key] {
+					delete(known, key)
+					changed = true
+				}
+			}
+		}
+		if changed {
+			fire()
+		}
+	}
+	return ctx.Err()
+}
+
+// currentAddresses lists the addresses the interfaces have now
+func currentAddresses() (map[string]bool, error) {
+	rib, err := syscall.NetlinkRIB(syscall.RTM_GETADDR, syscall.AF_UNSPEC)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list addresses: %w", err)
+	}
+	msgs, err := syscall.ParseNetlinkMessage(rib)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse addresses: %w", err)
+	}
+	known := make(map[string]bool)
+	for _, msg := range msgs {
+		if key, ok := addressKey(msg); ok {
+			known[key] = true
+		}
+	}
+	return known, nil
+}
+
+// addressKey identifies the address of an RTM_NEWADDR or RTM_DELADDR
+// message by interface and address. Loopback and link-local addresses are
+// skipped, as they never lead to a public address.
+func addressKey(msg syscall.NetlinkMessage) (string, bool) {
+	if msg.Header.Type != syscall.RTM_NEWADDR && msg.Header.Type != syscall.RTM_DELADDR {
+		return "", false
+	}
+	if len(msg.Data) < syscall.SizeofIfAddrmsg {
+		return "", false
+	}
+	// struct ifaddrmsg: family, prefix length, flags, scope, interface index
+	scope := msg.Data[3]
+	if scope == syscall.RT_SCOPE_HOST || scope == syscall.RT_SCOPE_LINK {
+		return "", false
+	}
+	index := binary.NativeEndian.Uint32(msg.Data[4:8])
+
+	attrs, err := syscall.ParseNetlinkRouteAttr(&msg)
+	if err != nil {
+		return "", false
+	}
+	for _, attr := range attrs {
+		if attr.Attr.Type == syscall.IFA_ADDRESS {
+			return fmt.Sprintf("%d/%s", index, net.IP(attr.Value)), true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,14 @@
+//go:build !windows && !linux
+
+package trigger
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+)
+
+// Run reports that address change notifications are unavailable here
+func (addressSource) Run(ctx context.Context, fire func()) error {
+	return fmt.Errorf("address change triggers are not supported on %s", runtime.GOOS)
+}
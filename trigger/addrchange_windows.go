@@ -0,0 +1,76 @@
+//go:build windows
+
+package trigger
+
+import (
+	"context"
+	"fmt"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	iphlpapi                 = syscall.NewLazyDLL("iphlpapi.dll")
+	procNotifyAddrChange     = iphlpapi.NewProc("NotifyAddrChange")
+	procCancelIPChangeNotify = iphlpapi.NewProc("CancelIPChangeNotify")
+	kernel32                 = syscall.NewLazyDLL("kernel32.dll")
+	procCreateEventW         = kernel32.NewProc("CreateEventW")
+)
+
+// addrChangeWaitMillis is how often cancellation is checked while waiting
+const addrChangeWaitMillis = 1000
+
+// Run waits on NotifyAddrChange until ctx is cancelled. The overlapped form of
+// the call signals an event when the IPv4/IPv6 address table changes, which
+// is waited on in steps so that cancellation is noticed. The pending
+// notification is cancelled on exit, so no thread is left blocked.
+func (addressSource) Run(ctx context.Context, fire func()) error {
+	if err := procNotifyAddrChange.Find(); err != nil {
+		return fmt.Errorf("NotifyAddrChange unavailable: %w", err)
+	}
+
+	// Auto-reset, so that each wait consumes one change
+	event, _, callErr := procCreateEventW.Call(0, 0, 0, 0)
+	if event == 0 {
+		return fmt.Errorf("failed to create event: %w", callErr)
+	}
+	defer syscall.CloseHandle(syscall.Handle(event))
+
+	overlapped := &syscall.Overlapped{HEvent: syscall.Handle(event)}
+	var handle syscall.Handle
+	for {
+		ret, _, _ := procNotifyAddrChange.Call(uintptr(unsafe.Pointer(&handle)), uintptr(unsafe.Pointer(overlapped)))
+		if errno := syscall.Errno(ret); errno != syscall.ERROR_IO_PENDING {
+			return fmt.Errorf("NotifyAddrChange failed: %w", errno)
+		}
+
+		if err := waitAddrChange(ctx, overlapped); err != nil {
+			return err
+		}
+		fire()
+	}
+}
+
+// waitAddrChange waits until the pending notification signals its event. If
+// ctx is cancelled first, the notification is cancelled and ctx's error
+// returned.
+func waitAddrChange(ctx context.Context, overlapped *syscall.Overlapped) error {
+	for {
+		result, err := syscall.WaitForSingleObject(overlapped.HEvent, addrChangeWaitMillis)
+		switch result {
+		case syscall.WAIT_OBJECT_0:
+			return nil
+		case syscall.WAIT_TIMEOUT:
+			if ctx.Err() == nil {
+				continue
+			}
+			// The cancelled request signals the event once the system no
+			// longer uses overlapped
+			procCancelIPChangeNotify.Call(uintptr(unsafe.Pointer(overlapped)))
+			syscall.WaitForSingleObject(overlapped.HEvent, addrChangeWaitMillis)
+			return ctx.Err()
+		default:
+			return fmt.Errorf("failed to wait for address changes: %w", err)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package trigger
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// ClockJump is the name of the trigger that fires when the wall clock jumps,
+// so the daemon loop can tell it apart from network events
+const ClockJump = "clock"
+
+const (
+	// clockPollInterval is how often the wall clock is compared with the monotonic clock
+	clockPollInterval = 10 * time.Second
+	// clockJumpThreshold is the drift between both clocks treated as a jump
+	clockJumpThreshold = time.Minute
+)
+
+// clockSource detects wall-clock jumps caused by suspend/resume or an NTP
+// step. The monotonic clock stops while the system is asleep and is not
+// affected by clock adjustments, so a gap between the two means the network
+// may have changed while the timers weren't running.
+type clockSource struct{}
+
+// Name identifies the source in logs
+func (clockSource) Name() string {
+	return ClockJump
+}
+
+// Run compares both clocks periodically until ctx is cancelled
+func (clockSource) Run(ctx context.Context, fire func()) error {
+	ticker := time.NewTicker(clockPollInterval)
+	defer ticker.Stop()
+
+	last := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+
+		now := time.Now()
+		elapsed := now.Sub(last)                // monotonic
+		wall := now.Round(0).Sub(last.Round(0)) // wall clock only
+		last = now
+
+		if drift := wall - elapsed; drift > clockJumpThreshold || drift < -clockJumpThreshold {
+			log.Printf("Wall clock jumped by %s (suspend/resume or clock adjustment)", drift.Round(time.Second))
+			fire()
+		}
+	}
+}
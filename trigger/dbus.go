@@ -0,0 +1,81 @@
+package trigger
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"log"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"sync"
+)
+
+// dbusServices are the network managers whose signals are monitored
+var dbusServices = []string{
+	"org.freedesktop.NetworkManager",
+	"org.freedesktop.network1", // systemd-networkd
+}
+
+// dbusSignalPattern matches connectivity and address changes in gdbus monitor output
+var dbusSignalPattern = regexp.MustCompile(`StateChanged|Ip4Config|Ip6Config|AddressState|OperationalState`)
+
+// dbusSource triggers checks on NetworkManager and systemd-networkd D-Bus signals.
+// It uses gdbus as a regular bus client, which needs no extra privileges.
+type dbusSource struct{}
+
+// Name identifies the source in logs
+func (dbusSource) Name() string {
+	return "dbus"
+}
+
+// Run monitors every known network manager until ctx is cancelled
+func (dbusSource) Run(ctx context.Context, fire func()) error {
+	if runtime.GOOS != "linux" {
+		return fmt.Errorf("D-Bus triggers are only supported on Linux")
+	}
+
+	if _, err := exec.LookPath("gdbus"); err != nil {
+		return fmt.Errorf("gdbus not found: %w", err)
+	}
+
+	var wg sync.WaitGroup
+	for _, service := range dbusServices {
+		wg.Add(1)
+		go func(service string) {
+			defer wg.Done()
+			err := monitorDBus(ctx, service, func(line string) {
+				if dbusSignalPattern.MatchString(line) {
+					fire()
+				}
+			})
+			if err != nil && ctx.Err() == nil {
+				log.Printf("Warning: D-Bus monitor for %s stopped: %v", service, err)
+			}
+		}(service)
+	}
+	wg.Wait()
+
+	return ctx.Err()
+}
+
+// monitorDBus follows the signals of a single bus name, passing every line of
+// gdbus output to onLine. Services that are not running simply produce no signals.
+func monitorDBus(ctx context.Context, service string, onLine func(string)) error {
+	cmd := exec.CommandContext(ctx, "gdbus", "monitor", "--system", "--dest", service)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start gdbus monitor: %w", err)
+	}
+
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		onLine(scanner.Text())
+	}
+
+	return cmd.Wait()
+}
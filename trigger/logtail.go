@@ -0,0 +1,159 @@
+package trigger
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/MrLonely14/cf-ddns/config"
+)
+
+// logPollInterval is how often a followed file is checked for new lines
+const logPollInterval = time.Second
+
+// logTailSource triggers checks when a reconnect message appears in a log
+// file or in syslog messages forwarded from a router
+type logTailSource struct {
+	cfg     config.LogTailConfig
+	pattern *regexp.Regexp
+}
+
+// newLogTailSource compiles the configured pattern
+func newLogTailSource(cfg config.LogTailConfig) (*logTailSource, error) {
+	pattern, err := regexp.Compile(cfg.Pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid log_tail pattern: %w", err)
+	}
+	return &logTailSource{cfg: cfg, pattern: pattern}, nil
+}
+
+// Name identifies the source in logs
+func (s *logTailSource) Name() string {
+	return "log_tail"
+}
+
+// Run follows the configured file and/or syslog listener until ctx is
+// cancelled or either input fails, which stops the other one too
+func (s *logTailSource) Run(ctx context.Context, fire func()) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	errs := make(chan error, 2)
+	running := 0
+
+	if s.cfg.Path != "" {
+		running++
+		go func() { errs <- s.followFile(ctx, fire) }()
+	}
+	if s.cfg.SyslogListen != "" {
+		running++
+		go func() { errs <- s.listenSyslog(ctx, fire) }()
+	}
+
+	// Stop both inputs as soon as either fails, and wait for them to return,
+	// so that no input keeps firing after the trigger is reported as stopped
+	var err error
+	for range running {
+		inputErr := <-errs
+		if err == nil {
+			err = inputErr
+			cancel()
+		}
+	}
+	return err
+}
+
+// match fires when a line looks like a reconnect announcement
+func (s *logTailSource) match(line string, fire func()) {
+	if s.pattern.MatchString(line) {
+		fire()
+	}
+}
+
+// followFile reads lines appended to the file, reopening it after rotation
+func (s *logTailSource) followFile(ctx context.Context, fire func()) error {
+	file, err := os.Open(s.cfg.Path)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+	defer func() { file.Close() }()
+
+	// Only react to new lines, not to history already in the file
+	offset, err := file.Seek(0, io.SeekEnd)
+	if err != nil {
+		return fmt.Errorf("failed to seek log file: %w", err)
+	}
+	reader := bufio.NewReader(file)
+	var partial string
+
+	ticker := time.NewTicker(logPollInterval)
+	defer ticker.Stop()
+
+	for {
+		for {
+			chunk, err := reader.ReadString('\n')
+			offset += int64(len(chunk))
+			if err != nil {
+				partial += chunk
+				break
+			}
+			s.match(partial+chunk, fire)
+			partial = ""
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+
+		// Reopen the file if it was rotated or truncated
+		info, err := os.Stat(s.cfg.Path)
+		if err != nil {
+			continue
+		}
+		current, err := file.Stat()
+		if err != nil || !os.SameFile(info, current) || info.Size() < offset {
+			reopened, err := os.Open(s.cfg.Path)
+			if err != nil {
+				continue
+			}
+			file.Close()
+			file = reopened
+			reader.Reset(file)
+			offset, partial = 0, ""
+		}
+	}
+}
+
+// listenSyslog receives forwarded syslog messages over UDP, one per packet
+func (s *logTailSource) listenSyslog(ctx context.Context, fire func()) error {
+	conn, err := net.ListenPacket("udp", s.cfg.SyslogListen)
+	if err != nil {
+		return fmt.Errorf("failed to listen for syslog: %w", err)
+	}
+
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+
+	buf := make([]byte, 8192)
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return fmt.Errorf("failed to read syslog message: %w", err)
+		}
+		for _, line := range strings.Split(string(buf[:n]), "\n") {
+			s.match(line, fire)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package trigger
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/MrLonely14/cf-ddns/config"
+)
+
+func TestLogTailStopsBothInputs(t *testing.T) {
+	// Find a free UDP port for the syslog listener
+	probe, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("cannot listen on UDP: %v", err)
+	}
+	addr := probe.LocalAddr().String()
+	probe.Close()
+
+	src, err := newLogTailSource(config.LogTailConfig{
+		Path:         filepath.Join(t.TempDir(), "missing.log"),
+		SyslogListen: addr,
+		Pattern:      "up",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = src.Run(context.Background(), func() {})
+	if err == nil || !strings.Contains(err.Error(), "failed to open log file") {
+		t.Fatalf("Run() error = %v, want the log file error", err)
+	}
+
+	// The syslog listener must have been closed before Run returned
+	conn, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		t.Fatalf("syslog listener still running after Run returned: %v", err)
+	}
+	conn.Close()
+}
@@ -0,0 +1,56 @@
+//go:build !darwin
+
+package trigger
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// logindService is the systemd-logind bus name that announces suspend and resume
+const logindService = "org.freedesktop.login1"
+
+// WatchSleep reports suspend and resume from systemd-logind's PrepareForSleep
+// signal: true is sent just before the system sleeps, false after it resumes.
+// It returns nil when watching isn't possible, which blocks forever in a select.
+func WatchSleep(ctx context.Context) <-chan bool {
+	if err := sleepSupported(); err != nil {
+		log.Printf("Warning: sleep trigger unavailable, relying on clock jump detection: %v", err)
+		return nil
+	}
+
+	events := make(chan bool, 1)
+	go func() {
+		log.Println("Started sleep trigger")
+		err := monitorDBus(ctx, logindService, func(line string) {
+			if !strings.Contains(line, "PrepareForSleep") {
+				return
+			}
+			sleeping := strings.Contains(line, "(true")
+			select {
+			case events <- sleeping:
+			case <-ctx.Done():
+			}
+		})
+		if err != nil && ctx.Err() == nil {
+			log.Printf("Warning: sleep trigger stopped, relying on clock jump detection: %v", err)
+		}
+	}()
+
+	return events
+}
+
+// sleepSupported checks that logind signals can be monitored
+func sleepSupported() error {
+	if runtime.GOOS != "linux" {
+		return fmt.Errorf("sleep notifications are only supported on Linux (systemd-logind) and macOS")
+	}
+	if _, err := exec.LookPath("gdbus"); err != nil {
+		return fmt.Errorf("gdbus not found: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+//go:build darwin
+
+package trigger
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"log"
+	"syscall"
+	"time"
+)
+
+// wakePollInterval is how often the kernel's last wake time is read
+const wakePollInterval = 10 * time.Second
+
+// WatchSleep reports resume from the kernel's last wake time (kern.waketime),
+// which changes every time the system wakes: false is sent after it resumes.
+// Unlike logind, macOS announces sleep only to IOKit clients, which needs
+// cgo, so true is never sent and checks are not paused while asleep. It
+// returns nil when watching isn't possible, which blocks forever in a select.
+func WatchSleep(ctx context.Context) <-chan bool {
+	last, err := wakeTime()
+	if err != nil {
+		log.Printf("Warning: sleep trigger unavailable, relying on clock jump detection: %v", err)
+		return nil
+	}
+
+	events := make(chan bool, 1)
+	go func() {
+		log.Println("Started sleep trigger")
+		ticker := time.NewTicker(wakePollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			woke, err := wakeTime()
+			if err != nil {
+				log.Printf("Warning: sleep trigger stopped, relying on clock jump detection: %v", err)
+				return
+			}
+			if woke == last {
+				continue
+			}
+			last = woke
+			select {
+			case events <- false:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return events
+}
+
+// wakeTime returns the seconds of the kernel's last wake time, which stay
+// zero until the system first sleeps
+func wakeTime() (int64, error) {
+	value, err := syscall.Sysctl("kern.waketime")
+	if err != nil {
+		return 0, fmt.Errorf("failed to read kern.waketime: %w", err)
+	}
+	// A struct timeval, whose seconds come first; Sysctl drops a trailing
+	// zero byte, which is only padding
+	if len(value) < 8 {
+		return 0, fmt.Errorf("unexpected kern.waketime of %d bytes", len(value))
+	}
+	return int64(binary.LittleEndian.Uint64([]byte(value[:8]))), nil
+}
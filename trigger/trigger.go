@@ -0,0 +1,90 @@
+package trigger
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/MrLonely14/cf-ddns/config"
+)
+
+// settleDelay is how long to wait after an event before firing, so a burst of
+// related events (link down/up, address removed/added) causes a single check
+const settleDelay = 3 * time.Second
+
+// Source watches for events that suggest the public IP may have changed
+type Source interface {
+	// Name identifies the source in logs
+	Name() string
+	// Run blocks until ctx is cancelled, calling fire for every relevant event
+	Run(ctx context.Context, fire func()) error
+}
+
+// Start runs the trigger sources enabled in cfg, plus clock jump detection, and
+// returns a channel that receives the source name whenever an immediate check
+// should be performed
+func Start(ctx context.Context, cfg config.TriggersConfig) <-chan string {
+	sources := []Source{clockSource{}}
+	if cfg.DBus {
+		sources = append(sources, dbusSource{})
+	}
+	if cfg.AddressChange {
+		sources = append(sources, addressSource{})
+	}
+	if cfg.LogTail.Enabled() {
+		if src, err := newLogTailSource(cfg.LogTail); err != nil {
+			log.Printf("Warning: %v", err)
+		} else {
+			sources = append(sources, src)
+		}
+	}
+
+	events := make(chan string, 1)
+	for _, src := range sources {
+		go run(ctx, src, events)
+	}
+
+	return events
+}
+
+// run drives a single source and debounces its events into the shared channel
+func run(ctx context.Context, src Source, events chan<- string) {
+	raw := make(chan struct{}, 1)
+
+	go func() {
+		log.Printf("Started %s trigger", src.Name())
+		err := src.Run(ctx, func() {
+			select {
+			case raw <- struct{}{}:
+			default:
+			}
+		})
+		if err != nil && ctx.Err() == nil {
+			log.Printf("Warning: %s trigger stopped, falling back to polling: %v", src.Name(), err)
+		}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-raw:
+		}
+
+		// Let the burst settle before requesting a check
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(settleDelay):
+		}
+		select {
+		case <-raw:
+		default:
+		}
+
+		select {
+		case events <- src.Name():
+		default:
+		}
+	}
+}
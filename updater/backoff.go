@@ -0,0 +1,73 @@
+package updater
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	// createBackoffBase is the wait after the first failed create
+	createBackoffBase = 5 * time.Minute
+	// createBackoffMax caps the wait between create attempts
+	createBackoffMax = 6 * time.Hour
+	// createEscalateAfter is the number of failed creates after which the
+	// warning becomes an error pointing at the token's permissions
+	createEscalateAfter = 3
+)
+
+// createBackoff spaces out attempts to create records whose creation keeps
+// failing, so a token without DNS:Edit doesn't try to create every cycle forever
+type createBackoff struct {
+	mu       sync.Mutex
+	failures map[string]createFailure // keyed by record label
+}
+
+// createFailure tracks consecutive failed creates of one record
+type createFailure struct {
+	count int
+	next  time.Time
+}
+
+// newCreateBackoff creates an empty backoff tracker
+func newCreateBackoff() *createBackoff {
+	return &createBackoff{failures: make(map[string]createFailure)}
+}
+
+// wait returns how long until the record may be created again, or 0
+func (b *createBackoff) wait(label string, now time.Time) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if f, ok := b.failures[label]; ok && now.Before(f.next) {
+		return f.next.Sub(now)
+	}
+	return 0
+}
+
+// failed records a failed create and logs an escalating warning
+func (b *createBackoff) failed(label string, err error, now time.Time) {
+	b.mu.Lock()
+	f := b.failures[label]
+	f.count++
+	delay := createBackoffBase << (f.count - 1)
+	if delay > createBackoffMax || delay <= 0 {
+		delay = createBackoffMax
+	}
+	f.next = now.Add(delay)
+	b.failures[label] = f
+	b.mu.Unlock()
+
+	if f.count >= createEscalateAfter {
+		log.Printf("ERROR: creating %s has failed %d times in a row (%v); check that the token has DNS:Edit on the zone. Next attempt in %s", label, f.count, err, delay)
+		return
+	}
+	log.Printf("Warning: failed to create %s (attempt %d): %v; retrying in %s", label, f.count, err, delay)
+}
+
+// succeeded clears the failures of a record
+func (b *createBackoff) succeeded(label string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.failures, label)
+}
@@ -0,0 +1,205 @@
+package updater
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/MrLonely14/cf-ddns/cloudflare"
+	"github.com/MrLonely14/cf-ddns/config"
+)
+
+// errCanary marks records held back because the canary record failed
+var errCanary = errors.New("held back by the canary record")
+
+// canaryPollInterval is how often DNS and HTTP verification are retried
+const canaryPollInterval = 2 * time.Second
+
+// canaryHold is the result reported for records held back by the canary
+type canaryHold struct {
+	outcome string
+	err     error
+}
+
+// runCanary updates the canary record and verifies each new address before
+// the other records get it. It returns the record types whose other records
+// must be held back this cycle.
+func (u *Updater) runCanary(ctx context.Context, ips *cycleDetection, canary config.DNSRecord, force bool, report func(config.DNSRecord, string, string, error)) map[string]canaryHold {
+	held := make(map[string]canaryHold)
+	name := cloudflare.DisplayName(canary.Name)
+
+	for _, recordType := range canary.Types {
+		outcome, err := u.updateRecord(ctx, ips, canary, recordType, force)
+		report(canary, recordType, outcome, err)
+
+		switch {
+		case errors.Is(err, errDrift) || outcome == outcomeDrifted:
+			// Nothing is written in observe mode and dry runs, so there is nothing to verify
+			continue
+		case err != nil:
+			held[recordType] = canaryHold{err: fmt.Errorf("%w: %s (%s) could not be updated", errCanary, name, recordType)}
+			continue
+		case outcome == outcomeWithheld:
+			// The others wait until the canary has the address
+			held[recordType] = canaryHold{outcome: outcomeWithheld}
+			continue
+		}
+
+		content, err := u.desiredContent(ctx, ips, canary, recordType)
+		if err != nil {
+			held[recordType] = canaryHold{err: fmt.Errorf("%w: %w", errCanary, err)}
+			continue
+		}
+		u.mu.RLock()
+		verified := u.verified[recordType] == content
+		u.mu.RUnlock()
+		if verified {
+			continue
+		}
+
+		log.Printf("Verifying canary %s (%s) at %s", name, recordType, content)
+		verifyCtx, cancel := context.WithTimeout(ctx, u.cfg.Canary.GetTimeout())
+		err = u.verify(verifyCtx, canary, recordType, content, u.cfg.Canary.VerifyDNS, u.cfg.Canary.HTTPProbe)
+		cancel()
+		if err != nil {
+			log.Printf("ERROR: canary %s (%s) failed verification at %s: %v; holding back the other %s records", name, recordType, content, err, recordType)
+			held[recordType] = canaryHold{err: fmt.Errorf("%w: %s (%s) failed verification at %s: %w", errCanary, name, recordType, content, err)}
+			continue
+		}
+		log.Printf("Canary %s (%s) verified at %s", name, recordType, content)
+
+		u.mu.Lock()
+		u.verified[recordType] = content
+		u.mu.Unlock()
+	}
+	return held
+}
+
+// verify checks that a written record has its new content in Cloudflare.
+// With dns set, it also waits until the zone's nameservers return the
+// address, and with a probe URL until the URL answers from the address.
+func (u *Updater) verify(ctx context.Context, record config.DNSRecord, recordType, content string, dns bool, probeURL string) error {
+	remote, err := u.provider.GetDNSRecord(ctx, record.ZoneID, record.Name, recordType, recordKind(record, recordType))
+	if err != nil {
+		return fmt.Errorf("failed to read back the record: %w", err)
+	}
+	if remoteContent(remote) != content {
+		return fmt.Errorf("Cloudflare returned %s", remote.Content)
+	}
+
+	// Proxied records resolve to Cloudflare's addresses, not the origin's
+	if dns && !remote.Proxied && (recordType == "A" || recordType == "AAAA") {
+		if err := poll(ctx, func() error { return checkNameservers(ctx, record.Name, recordType, content) }); err != nil {
+			return fmt.Errorf("DNS check failed: %w", err)
+		}
+	}
+	if probeURL != "" {
+		if err := poll(ctx, func() error { return probeHTTP(ctx, probeURL, content) }); err != nil {
+			return fmt.Errorf("HTTP probe failed: %w", err)
+		}
+	}
+	return nil
+}
+
+// poll runs check until it succeeds or ctx expires, returning its last error
+func poll(ctx context.Context, check func() error) error {
+	for {
+		err := check()
+		if err == nil {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(canaryPollInterval):
+		}
+	}
+}
+
+// checkNameservers asks a nameserver of the zone holding name, bypassing
+// caching resolvers, whether it returns the address
+func checkNameservers(ctx context.Context, name, recordType, content string) error {
+	server, err := authoritativeServer(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	resolver := &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network, server)
+		},
+	}
+	network := "ip4"
+	if recordType == "AAAA" {
+		network = "ip6"
+	}
+	addrs, err := resolver.LookupNetIP(ctx, network, cloudflare.NormalizeName(name))
+	if err != nil {
+		return fmt.Errorf("failed to resolve %s at %s: %w", name, server, err)
+	}
+
+	want := netip.MustParseAddr(content)
+	if slices.ContainsFunc(addrs, func(a netip.Addr) bool { return a.Unmap() == want }) {
+		return nil
+	}
+	return fmt.Errorf("%s still returns %v", server, addrs)
+}
+
+// authoritativeServer finds a nameserver of the zone holding name by looking
+// up NS records from the name towards the root
+func authoritativeServer(ctx context.Context, name string) (string, error) {
+	labels := strings.Split(cloudflare.NormalizeName(name), ".")
+	for i := 0; i < len(labels)-1; i++ {
+		servers, err := net.DefaultResolver.LookupNS(ctx, strings.Join(labels[i:], "."))
+		if err == nil && len(servers) > 0 {
+			return net.JoinHostPort(strings.TrimSuffix(servers[0].Host, "."), "53"), nil
+		}
+	}
+	return "", fmt.Errorf("no nameserver found for %s", name)
+}
+
+// probeHTTP requests a URL from the given address instead of the one its
+// host resolves to, expecting a status below 400
+func probeHTTP(ctx context.Context, probeURL, ip string) error {
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			_, port, err := net.SplitHostPort(addr)
+			if err != nil {
+				return nil, err
+			}
+			var d net.Dialer
+			return d.DialContext(ctx, network, net.JoinHostPort(ip, port))
+		},
+	}
+	defer transport.CloseIdleConnections()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, probeURL, nil)
+	if err != nil {
+		return err
+	}
+	client := &http.Client{
+		Transport: transport,
+		Timeout:   10 * time.Second,
+		// Redirects may lead to other hosts, which aren't served by the address
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("%s answered with HTTP %d", probeURL, resp.StatusCode)
+	}
+	return nil
+}
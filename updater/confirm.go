@@ -0,0 +1,69 @@
+package updater
+
+import (
+	"log"
+	"sync"
+)
+
+// confirmation holds back a new address until it has been detected on
+// several consecutive checks, so that an address handed out briefly while the
+// ISP reconnects doesn't cause two changes
+type confirmation struct {
+	checks int // consecutive detections needed
+	mu     sync.Mutex
+	ipv4   familyConfirmation
+	ipv6   familyConfirmation
+}
+
+// familyConfirmation tracks the confirmed and the candidate address of one family
+type familyConfirmation struct {
+	confirmed string // address records are set to
+	candidate string // differing address waiting for confirmation
+	seen      int    // consecutive checks the candidate was detected on
+}
+
+// newConfirmation requires a new address to be seen on checks consecutive
+// checks. It returns nil if checks is 1 or less, when addresses apply at once.
+func newConfirmation(checks int) *confirmation {
+	if checks <= 1 {
+		return nil
+	}
+	return &confirmation{checks: checks}
+}
+
+// confirm returns the address records should be set to after detecting ip:
+// ip once it is confirmed, else the previously confirmed address. The first
+// address detected after start is confirmed at once.
+func (c *confirmation) confirm(isIPv6 bool, ip string) string {
+	if c == nil {
+		return ip
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	f, family := &c.ipv4, "IPv4"
+	if isIPv6 {
+		f, family = &c.ipv6, "IPv6"
+	}
+	if f.confirmed == "" || ip == f.confirmed {
+		if f.candidate != "" {
+			log.Printf("%s address %s was not confirmed; keeping %s", family, f.candidate, ip)
+		}
+		*f = familyConfirmation{confirmed: ip}
+		return ip
+	}
+
+	if ip == f.candidate {
+		f.seen++
+	} else {
+		f.candidate, f.seen = ip, 1
+	}
+	if f.seen < c.checks {
+		log.Printf("New %s address %s detected on %d of %d checks; keeping %s until it is confirmed", family, ip, f.seen, c.checks, f.confirmed)
+		return f.confirmed
+	}
+
+	log.Printf("New %s address %s confirmed on %d consecutive checks", family, ip, f.seen)
+	*f = familyConfirmation{confirmed: ip}
+	return ip
+}
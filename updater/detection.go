@@ -0,0 +1,46 @@
+package updater
+
+import (
+	"context"
+	"sync"
+
+	"github.com/MrLonely14/cf-ddns/ipdetect"
+)
+
+// cycleDetection detects each address family at most once per update cycle
+// and shares the result with every record of that type
+type cycleDetection struct {
+	detector *ipdetect.Detector
+	confirm  *confirmation // holds back new addresses until confirmed; nil applies them at once
+	ipv4     detection
+	ipv6     detection
+}
+
+// detection is the shared result of detecting one address family
+type detection struct {
+	once sync.Once
+	ip   string
+	err  error
+}
+
+// newCycleDetection starts a cycle with no addresses detected yet. With a
+// confirmation, new addresses only apply once they are confirmed.
+func newCycleDetection(detector *ipdetect.Detector, confirm *confirmation) *cycleDetection {
+	return &cycleDetection{detector: detector, confirm: confirm}
+}
+
+// get returns the cycle's address of the requested family, detecting it on
+// the first call. Concurrent callers wait for that detection.
+func (c *cycleDetection) get(ctx context.Context, isIPv6 bool) (string, error) {
+	d, detect := &c.ipv4, c.detector.GetIPv4
+	if isIPv6 {
+		d, detect = &c.ipv6, c.detector.GetIPv6
+	}
+	d.once.Do(func() {
+		// Failed detections neither confirm a new address nor reset its count
+		if d.ip, d.err = detect(ctx); d.err == nil {
+			d.ip = c.confirm.confirm(isIPv6, d.ip)
+		}
+	})
+	return d.ip, d.err
+}
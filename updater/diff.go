@@ -0,0 +1,197 @@
+package updater
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/MrLonely14/cf-ddns/cloudflare"
+	"github.com/MrLonely14/cf-ddns/config"
+)
+
+// Diff actions
+const (
+	DiffNone    = "none"    // the record already matches
+	DiffCreate  = "create"  // the record doesn't exist yet
+	DiffUpdate  = "update"  // content, TTL, proxy setting or comment differ
+	DiffPushed  = "pushed"  // the address comes from clients, so there is nothing to compare
+	DiffSkipped = "skipped" // the address is behind carrier-grade NAT and skip_on_cgnat is set
+	DiffError   = "error"   // detection or the zone listing failed
+)
+
+// RecordValues are the fields of a record the updater manages
+type RecordValues struct {
+	Content string `json:"content"`
+	TTL     int    `json:"ttl"`
+	Proxied bool   `json:"proxied"`
+	Comment string `json:"comment,omitempty"`
+}
+
+// RecordOperation is the API request a create or update would issue
+type RecordOperation struct {
+	Method   string   `json:"method"`
+	Path     string   `json:"path"`
+	RecordID string   `json:"record_id,omitempty"`
+	Fields   []string `json:"fields,omitempty"` // fields that are set or changed
+}
+
+// String formats the operation for logs, e.g. "PATCH /zones/z/dns_records/r
+// (content, ttl)"
+func (o *RecordOperation) String() string {
+	if len(o.Fields) == 0 {
+		return o.Method + " " + o.Path
+	}
+	return fmt.Sprintf("%s %s (%s)", o.Method, o.Path, strings.Join(o.Fields, ", "))
+}
+
+// RecordDiff compares a managed record in Cloudflare with what an update
+// cycle would write
+type RecordDiff struct {
+	ZoneID  string        `json:"zone_id"`
+	Name    string        `json:"name"`
+	Type    string        `json:"type"`
+	Action  string        `json:"action"`
+	Current *RecordValues `json:"current,omitempty"`
+	Desired *RecordValues `json:"desired,omitempty"`
+	// Operation is the request a create or update would issue
+	Operation *RecordOperation `json:"operation,omitempty"`
+	Error     string           `json:"error,omitempty"` // why the record could not be compared or is skipped
+
+	record config.DNSRecord // configured record, for Apply
+}
+
+// Diff compares every configured record with Cloudflare without changing
+// anything. Each zone is listed once and each address family detected once.
+func (u *Updater) Diff(ctx context.Context) []RecordDiff {
+	ips := newCycleDetection(u.detector, nil)
+	zones := make(map[string]map[string]*cloudflare.DNSRecordInfo)
+	zoneErrs := make(map[string]error)
+
+	var diffs []RecordDiff
+	for _, record := range u.cfg.Records {
+		if _, ok := zones[record.ZoneID]; !ok && zoneErrs[record.ZoneID] == nil {
+			existing, err := u.provider.ListDNSRecords(ctx, record.ZoneID)
+			if err != nil {
+				zoneErrs[record.ZoneID] = err
+			} else {
+				zones[record.ZoneID] = indexRecords(record.ZoneID, existing)
+			}
+		}
+
+		for _, recordType := range record.Types {
+			diff := RecordDiff{ZoneID: record.ZoneID, Name: cloudflare.DisplayName(record.Name), Type: recordType, record: record}
+			if err := zoneErrs[record.ZoneID]; err != nil {
+				diff.Action, diff.Error = DiffError, fmt.Sprintf("failed to list records: %v", err)
+				diffs = append(diffs, diff)
+				continue
+			}
+
+			remote := lookupIndex(zones[record.ZoneID], record, recordType)
+			if remote != nil {
+				diff.Current = &RecordValues{Content: remote.Content, TTL: remote.TTL, Proxied: remote.Proxied, Comment: remote.Comment}
+			}
+
+			if record.Push {
+				diff.Action = DiffPushed
+				diffs = append(diffs, diff)
+				continue
+			}
+
+			content, err := u.desiredContent(ctx, ips, record, recordType)
+			if err != nil {
+				diff.Action, diff.Error = DiffError, err.Error()
+				diffs = append(diffs, diff)
+				continue
+			}
+			if u.skipsCGNAT(recordType, content) {
+				diff.Action, diff.Error = DiffSkipped, content+" is behind carrier-grade NAT"
+				diffs = append(diffs, diff)
+				continue
+			}
+			ttl, proxied := recordSettings(record, remote)
+			diff.Desired = &RecordValues{Content: content, TTL: ttl, Proxied: proxied, Comment: record.Comment}
+
+			switch {
+			case remote == nil:
+				diff.Action = DiffCreate
+			case recordMatches(remote, content, record):
+				diff.Action = DiffNone
+			default:
+				diff.Action = DiffUpdate
+			}
+			if diff.Action != DiffNone {
+				diff.Operation = plannedOperation(record, recordType, remote, content)
+			}
+			diffs = append(diffs, diff)
+		}
+	}
+	return diffs
+}
+
+// plannedOperation returns the request that writes content to the record:
+// a POST that sets every field if remote doesn't exist, else a PATCH of the
+// remote record naming the fields that differ
+func plannedOperation(record config.DNSRecord, recordType string, remote *cloudflare.DNSRecordInfo, content string) *RecordOperation {
+	path := "/zones/" + record.ZoneID + "/dns_records"
+	ttl, proxied := recordSettings(record, remote)
+	if remote == nil {
+		fields := []string{"name", "type", "content", "ttl", "proxied"}
+		if record.Comment != "" {
+			fields = append(fields, "comment")
+		}
+		return &RecordOperation{Method: http.MethodPost, Path: path, Fields: fields}
+	}
+
+	var fields []string
+	if remoteContent(remote) != content {
+		fields = append(fields, "content")
+	}
+	if !proxied && remote.TTL != ttl {
+		fields = append(fields, "ttl")
+	}
+	if remote.Proxied != proxied {
+		fields = append(fields, "proxied")
+	}
+	if record.Comment != "" && remote.Comment != record.Comment {
+		fields = append(fields, "comment")
+	}
+	return &RecordOperation{Method: http.MethodPatch, Path: path + "/" + remote.ID, RecordID: remote.ID, Fields: fields}
+}
+
+// Apply writes the creates and updates of a diff with the addresses it was
+// computed with, one record at a time. Records changed in Cloudflare since
+// the diff are only written if they still differ. Writes are logged to the
+// audit log as "apply".
+func (u *Updater) Apply(ctx context.Context, diffs []RecordDiff) (Summary, error) {
+	start := time.Now()
+	var summary Summary
+	for _, diff := range diffs {
+		if diff.Action != DiffCreate && diff.Action != DiffUpdate {
+			continue
+		}
+
+		outcome, err := u.writeRecord(ctx, diff.record, diff.Type, diff.Desired.Content, false, "apply")
+		switch {
+		case err != nil:
+			log.Printf("ERROR: failed to apply %s (%s): %v", diff.Name, diff.Type, err)
+			summary.Failed++
+		case outcome == outcomeWithheld:
+			summary.Withheld++
+		case outcome == outcomeCreated:
+			summary.Created++
+		case outcome == outcomeUpdated:
+			summary.Updated++
+		default:
+			summary.Unchanged++
+		}
+	}
+	summary.Duration = time.Since(start)
+
+	if summary.Failed > 0 {
+		return summary, fmt.Errorf("failed to apply %d record(s)", summary.Failed)
+	}
+	return summary, nil
+}
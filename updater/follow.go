@@ -0,0 +1,59 @@
+package updater
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+
+	"github.com/MrLonely14/cf-ddns/cloudflare"
+	"github.com/MrLonely14/cf-ddns/config"
+)
+
+// followedContent returns the address of the record a follower follows. A
+// detected primary gets the cycle's shared detection, so both are given the
+// same address; a pushed primary's address is the one Cloudflare has.
+func (u *Updater) followedContent(ctx context.Context, ips *cycleDetection, record config.DNSRecord, recordType string) (string, error) {
+	primary, ok := u.cfg.Primary(record, recordType)
+	if !ok {
+		return "", fmt.Errorf("followed record %s (%s) is not configured", record.Follow, recordType)
+	}
+	if !primary.Push {
+		return u.desiredContent(ctx, ips, primary, recordType)
+	}
+
+	remote := u.state.Get(primary.ZoneID, primary.Name, recordType)
+	if remote == nil {
+		existing, err := u.provider.GetDNSRecord(ctx, primary.ZoneID, primary.Name, recordType, "")
+		if errors.Is(err, cloudflare.ErrRecordNotFound) {
+			return "", fmt.Errorf("followed record %s (%s) has not been pushed yet", cloudflare.DisplayName(primary.Name), recordType)
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to look up followed record: %w", err)
+		}
+		u.state.Set(primary.ZoneID, primary.Name, recordType, existing)
+		remote = existing
+	}
+	return remoteContent(remote), nil
+}
+
+// updateFollowers gives the records following a pushed record its new
+// address right away, instead of waiting for the next cycle. Failures are
+// logged and retried by the next cycle.
+func (u *Updater) updateFollowers(ctx context.Context, primary config.DNSRecord, recordType, ip, source string) {
+	for _, record := range u.cfg.Records {
+		if record.Follow == "" || !slices.Contains(record.Types, recordType) {
+			continue
+		}
+		if p, ok := u.cfg.Primary(record, recordType); !ok || p.ZoneID != primary.ZoneID || cloudflare.NormalizeName(p.Name) != cloudflare.NormalizeName(primary.Name) {
+			continue
+		}
+
+		_, err := u.writeRecord(ctx, record, recordType, ip, false, source)
+		u.setHealth(record.Name, recordType, err)
+		if err != nil && !errors.Is(err, errDrift) {
+			log.Printf("ERROR: failed to update %s (%s) following %s: %v", cloudflare.DisplayName(record.Name), recordType, cloudflare.DisplayName(primary.Name), err)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package updater
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/netip"
+	"strconv"
+	"strings"
+
+	"github.com/MrLonely14/cf-ddns/cloudflare"
+	"github.com/MrLonely14/cf-ddns/config"
+)
+
+// hintName returns the name of the reverse hint for an address: its labels
+// reversed as in in-addr.arpa and ip6.arpa, below the configured domain
+func hintName(ip, domain string) (string, error) {
+	addr, err := netip.ParseAddr(strings.Trim(ip, `"`))
+	if err != nil {
+		return "", err
+	}
+	addr = addr.Unmap()
+
+	var labels []string
+	if addr.Is4() {
+		b := addr.As4()
+		for i := len(b) - 1; i >= 0; i-- {
+			labels = append(labels, strconv.Itoa(int(b[i])))
+		}
+	} else {
+		b := addr.As16()
+		for i := len(b) - 1; i >= 0; i-- {
+			labels = append(labels, strconv.FormatUint(uint64(b[i]&0x0f), 16), strconv.FormatUint(uint64(b[i]>>4), 16))
+		}
+	}
+	return strings.Join(labels, ".") + "." + cloudflare.NormalizeName(domain), nil
+}
+
+// writeHint publishes the reverse hint of a record's address, a TXT record
+// holding the hostname, and removes the hint of the address it replaced.
+// It does nothing for records without reverse_hint.
+func (u *Updater) writeHint(ctx context.Context, record config.DNSRecord, ip, previous, source string) error {
+	if record.ReverseHint == "" {
+		return nil
+	}
+
+	name, err := hintName(ip, record.ReverseHint)
+	if err != nil {
+		return fmt.Errorf("failed to name reverse hint: %w", err)
+	}
+	hint := config.DNSRecord{
+		ZoneID:  record.ZoneID,
+		Name:    name,
+		Types:   []string{"TXT"},
+		TTL:     record.GetTTL(),
+		Comment: record.Comment,
+		Group:   record.Group,
+	}
+	if _, err := u.writeRecord(ctx, hint, "TXT", cloudflare.NormalizeName(record.Name), false, source); err != nil {
+		return fmt.Errorf("failed to publish reverse hint: %w", err)
+	}
+
+	if previous == "" || previous == ip {
+		return nil
+	}
+	if err := u.deleteHint(ctx, record, previous, source); err != nil {
+		return fmt.Errorf("failed to remove reverse hint of %s: %w", previous, err)
+	}
+	return nil
+}
+
+// deleteHint removes the reverse hint of an address the record no longer
+// has. Hints that meanwhile name another host are left alone, since the
+// address may have moved to one of its records.
+func (u *Updater) deleteHint(ctx context.Context, record config.DNSRecord, ip, source string) error {
+	if u.Frozen() {
+		return nil
+	}
+
+	name, err := hintName(ip, record.ReverseHint)
+	if err != nil {
+		return nil // the record held something other than an address
+	}
+
+	existing := u.state.Get(record.ZoneID, name, "TXT")
+	if existing == nil {
+		existing, err = u.provider.GetDNSRecord(ctx, record.ZoneID, name, "TXT", "")
+		if errors.Is(err, cloudflare.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to look up record: %w", err)
+		}
+	}
+	if remoteContent(existing) != cloudflare.NormalizeName(record.Name) {
+		return nil
+	}
+
+	change, err := u.provider.DeleteDNSRecord(ctx, existing)
+	if err != nil {
+		return err
+	}
+	u.state.Delete(record.ZoneID, name, "TXT")
+
+	log.Println(change)
+	if u.audit != nil {
+		if err := u.audit.Record(source, change); err != nil {
+			log.Printf("ERROR: failed to write audit log: %v", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,11 @@
+//go:build !minimal
+
+package updater
+
+import "github.com/MrLonely14/cf-ddns/notify"
+
+// Notifier sends events to the configured webhooks and chat channels
+type Notifier = notify.Notifier
+
+// Event is a notification about a record or a finished cycle
+type Event = notify.Event
@@ -0,0 +1,31 @@
+//go:build minimal
+
+package updater
+
+import "time"
+
+// Notifier stands in for notifications, which builds with the minimal tag
+// leave out
+type Notifier struct{}
+
+// Event is a notification about a record or a finished cycle
+type Event struct {
+	Event      string
+	Record     string
+	RecordType string
+	OldIP      string
+	NewIP      string
+	Error      string
+	Source     string
+	Summary    string
+	Time       time.Time
+}
+
+// Notify discards the event
+func (n *Notifier) Notify(event Event) {}
+
+// Recovered does nothing, as there is no error budget to reset
+func (n *Notifier) Recovered(record, recordType string) {}
+
+// Wait returns immediately, as nothing is ever pending
+func (n *Notifier) Wait() {}
@@ -0,0 +1,92 @@
+package updater
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/MrLonely14/cf-ddns/cloudflare"
+	"github.com/MrLonely14/cf-ddns/config"
+)
+
+// errRollout marks records held back because an earlier rollout group failed
+var errRollout = errors.New("held back by the rollout")
+
+// written is a record type that a rollout stage created or updated
+type written struct {
+	record     config.DNSRecord
+	recordType string
+}
+
+// rolloutStages splits records into the groups of the rollout, in order.
+// Without a rollout, all records form a single stage.
+func (u *Updater) rolloutStages(records []config.DNSRecord) [][]config.DNSRecord {
+	rollout := u.cfg.Rollout
+	if rollout == nil {
+		return [][]config.DNSRecord{records}
+	}
+
+	stages := make([][]config.DNSRecord, len(rollout.Groups)+1)
+	for _, record := range records {
+		stage := rollout.Stage(record.Group)
+		stages[stage] = append(stages[stage], record)
+	}
+	return slices.DeleteFunc(stages, func(stage []config.DNSRecord) bool { return len(stage) == 0 })
+}
+
+// stageName describes a rollout stage in logs
+func (u *Updater) stageName(stage []config.DNSRecord) string {
+	if i := u.cfg.Rollout.Stage(stage[0].Group); i < len(u.cfg.Rollout.Groups) {
+		return "group " + u.cfg.Rollout.Groups[i]
+	}
+	return "the remaining records"
+}
+
+// advanceRollout decides whether the rollout continues after a stage. A
+// stage that wrote records is verified, then the rollout waits for the
+// configured delay. It returns the error to hold back later stages with.
+func (u *Updater) advanceRollout(ctx context.Context, ips *cycleDetection, stage []config.DNSRecord, writes []written, failed bool) error {
+	name := u.stageName(stage)
+	if failed {
+		log.Printf("ERROR: rollout halted: %s has failed records", name)
+		return fmt.Errorf("%w: %s has failed records", errRollout, name)
+	}
+	if len(writes) == 0 {
+		return nil
+	}
+
+	if err := u.verifyStage(ctx, ips, writes); err != nil {
+		log.Printf("ERROR: rollout halted: %s failed verification: %v", name, err)
+		return fmt.Errorf("%w: %s failed verification: %w", errRollout, name, err)
+	}
+
+	delay := u.cfg.Rollout.GetDelay()
+	log.Printf("Rollout: %s verified, continuing in %s", name, delay)
+	select {
+	case <-ctx.Done():
+		return fmt.Errorf("%w: %w", errRollout, ctx.Err())
+	case <-time.After(delay):
+	}
+	return nil
+}
+
+// verifyStage checks the records a stage wrote against Cloudflare and, if
+// configured, the zone's nameservers
+func (u *Updater) verifyStage(ctx context.Context, ips *cycleDetection, writes []written) error {
+	ctx, cancel := context.WithTimeout(ctx, u.cfg.Rollout.GetTimeout())
+	defer cancel()
+
+	for _, w := range writes {
+		content, err := u.desiredContent(ctx, ips, w.record, w.recordType)
+		if err != nil {
+			return err
+		}
+		if err := u.verify(ctx, w.record, w.recordType, content, u.cfg.Rollout.VerifyDNS, ""); err != nil {
+			return fmt.Errorf("%s (%s): %w", cloudflare.DisplayName(w.record.Name), w.recordType, err)
+		}
+	}
+	return nil
+}
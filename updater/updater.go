@@ -2,81 +2,532 @@ package updater
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"net/netip"
+	"slices"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/MrLonely14/cf-ddns/audit"
 	"github.com/MrLonely14/cf-ddns/cloudflare"
 	"github.com/MrLonely14/cf-ddns/config"
 	"github.com/MrLonely14/cf-ddns/ipdetect"
+	"github.com/MrLonely14/cf-ddns/logging"
+	"github.com/MrLonely14/cf-ddns/provider"
+	"github.com/MrLonely14/cf-ddns/store"
 )
 
 // Updater manages DNS record updates
 type Updater struct {
 	cfg      *config.Config
-	cfClient *cloudflare.Client
+	provider provider.Provider
 	detector *ipdetect.Detector
 	state    *State
+	store    *store.Store
+	audit    *audit.Log
+	notifier *Notifier
+	health   map[string]Health    // record label -> last failure, for unhealthy records only
+	withheld map[string]time.Time // record label -> end of the maintenance window holding its change
+	verified map[string]string    // record type -> canary address that passed verification
+	frozen   bool                 // the freeze file existed when last checked
+	creates  *createBackoff
+	confirm  *confirmation // nil unless confirm_checks holds back new addresses
+	progress func(done, total int)
+	pushMu   sync.Mutex // serializes pushed updates
 	mu       sync.RWMutex
+
+	lastSummary Summary
 }
 
-// State tracks the last known IPs for each record
+// State caches the last known remote record for each managed record
 type State struct {
-	Records map[string]string // key: "zoneID:name:type", value: last known IP
+	Records map[string]*cloudflare.DNSRecordInfo // key: "zoneID:name:type"
 	mu      sync.RWMutex
 }
 
 // NewState creates a new state tracker
 func NewState() *State {
 	return &State{
-		Records: make(map[string]string),
+		Records: make(map[string]*cloudflare.DNSRecordInfo),
 	}
 }
 
-// Get retrieves the last known IP for a record
-func (s *State) Get(zoneID, name, recordType string) string {
+// Get retrieves the cached remote record, or nil if it isn't known
+func (s *State) Get(zoneID, name, recordType string) *cloudflare.DNSRecordInfo {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
-	key := fmt.Sprintf("%s:%s:%s", zoneID, name, recordType)
-	return s.Records[key]
+	return s.Records[stateKey(zoneID, name, recordType)]
+}
+
+// Set caches the remote record
+func (s *State) Set(zoneID, name, recordType string, record *cloudflare.DNSRecordInfo) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Records[stateKey(zoneID, name, recordType)] = record
 }
 
-// Set stores the last known IP for a record
-func (s *State) Set(zoneID, name, recordType, ip string) {
+// Delete forgets the cached remote record
+func (s *State) Delete(zoneID, name, recordType string) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	key := fmt.Sprintf("%s:%s:%s", zoneID, name, recordType)
-	s.Records[key] = ip
+	delete(s.Records, stateKey(zoneID, name, recordType))
+}
+
+// stateKey builds the state map key, normalizing the name so differently
+// written forms of the same hostname share one entry
+func stateKey(zoneID, name, recordType string) string {
+	return fmt.Sprintf("%s:%s:%s", zoneID, cloudflare.NormalizeName(name), recordType)
+}
+
+// recordKind returns the kind of the remote record a configured record
+// manages, which selects among TXT records sharing its name
+func recordKind(record config.DNSRecord, recordType string) string {
+	return cloudflare.RecordKind(recordType, record.Template())
+}
+
+// indexRecords indexes a zone listing by name, type and kind. Of several
+// records with the same key, the first is used, as GetDNSRecord does.
+func indexRecords(zoneID string, records []*cloudflare.DNSRecordInfo) map[string]*cloudflare.DNSRecordInfo {
+	index := make(map[string]*cloudflare.DNSRecordInfo)
+	for _, rec := range records {
+		key := stateKey(zoneID, rec.Name, rec.Type) + ":" + cloudflare.RecordKind(rec.Type, rec.Content)
+		if _, ok := index[key]; !ok {
+			index[key] = rec
+		}
+	}
+	return index
+}
+
+// lookupIndex finds the remote record of a configured record in an index
+// built by indexRecords
+func lookupIndex(index map[string]*cloudflare.DNSRecordInfo, record config.DNSRecord, recordType string) *cloudflare.DNSRecordInfo {
+	return index[stateKey(record.ZoneID, record.Name, recordType)+":"+recordKind(record, recordType)]
 }
 
 // NewUpdater creates a new DNS updater
-func NewUpdater(cfg *config.Config, cfClient *cloudflare.Client, detector *ipdetect.Detector) *Updater {
+func NewUpdater(cfg *config.Config, dnsProvider provider.Provider, detector *ipdetect.Detector) *Updater {
 	return &Updater{
 		cfg:      cfg,
-		cfClient: cfClient,
+		provider: dnsProvider,
 		detector: detector,
 		state:    NewState(),
+		health:   make(map[string]Health),
+		withheld: make(map[string]time.Time),
+		verified: make(map[string]string),
+		creates:  newCreateBackoff(),
+		confirm:  newConfirmation(cfg.ConfirmChecks),
+	}
+}
+
+// recordLabel identifies a record and type in health reports
+func recordLabel(name, recordType string) string {
+	return fmt.Sprintf("%s (%s)", cloudflare.DisplayName(name), recordType)
+}
+
+// Health describes the last failure of an unhealthy record
+type Health struct {
+	Error    string `json:"error"`
+	Category string `json:"category"` // detection, drift, canary, rollout, frozen, network, or a cloudflare.Category* value
+}
+
+// errFrozen marks pushed changes rejected during a change freeze
+var errFrozen = errors.New("changes are frozen")
+
+// Frozen reports whether writes are paused by the freeze file, logging when
+// a freeze starts or ends
+func (u *Updater) Frozen() bool {
+	frozen := u.cfg.Frozen()
+
+	u.mu.Lock()
+	changed := frozen != u.frozen
+	u.frozen = frozen
+	u.mu.Unlock()
+
+	switch {
+	case changed && frozen:
+		log.Printf("Change freeze: %s exists, withholding all writes to Cloudflare", u.cfg.FreezeFile)
+	case changed:
+		log.Printf("Change freeze lifted: %s was removed, resuming writes", u.cfg.FreezeFile)
+	}
+	return frozen
+}
+
+// errDetection marks errors from IP detection, as opposed to API calls
+var errDetection = errors.New("failed to detect IP")
+
+// errDrift marks records that differ from the detected address in observe
+// mode, where they are reported instead of updated
+var errDrift = errors.New("record differs from the detected address")
+
+// errorCategory classifies a record update error for health reports
+func errorCategory(err error) string {
+	if errors.Is(err, errDetection) {
+		return "detection"
+	}
+	if errors.Is(err, errDrift) {
+		return "drift"
+	}
+	if errors.Is(err, errCanary) {
+		return "canary"
+	}
+	if errors.Is(err, errRollout) {
+		return "rollout"
+	}
+	if errors.Is(err, errFrozen) {
+		return "frozen"
+	}
+	if category := cloudflare.ErrorCategory(err); category != "" {
+		return category
+	}
+	return "network"
+}
+
+// setHealth records the last error for a record, or clears it when err is nil.
+// Changes are mirrored to the state file so the status command can show them.
+func (u *Updater) setHealth(name, recordType string, err error) {
+	label := recordLabel(name, recordType)
+
+	u.mu.Lock()
+	_, wasUnhealthy := u.health[label]
+	if err != nil {
+		// Health is served over HTTP and saved in the state file
+		u.health[label] = Health{Error: logging.Redact(err.Error()), Category: errorCategory(err)}
+	} else {
+		delete(u.health, label)
+		if u.notifier != nil {
+			u.notifier.Recovered(cloudflare.DisplayName(name), recordType)
+		}
+	}
+	changed := err != nil || wasUnhealthy
+	unhealthy := make(map[string]string, len(u.health))
+	for k, v := range u.health {
+		unhealthy[k] = v.Error
+	}
+	u.mu.Unlock()
+
+	if !changed || u.store == nil {
+		return
+	}
+	if err := u.store.Update(func(d *store.Data) { d.Unhealthy = unhealthy }); err != nil {
+		log.Printf("Warning: failed to save record health: %v", err)
+	}
+}
+
+// setWithheld records the end of the maintenance window holding back a
+// record's change, or clears it for the zero time
+func (u *Updater) setWithheld(label string, until time.Time) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if until.IsZero() {
+		delete(u.withheld, label)
+	} else {
+		u.withheld[label] = until
+	}
+}
+
+// WithheldUntil returns when the first maintenance window holding back a
+// change ends, or the zero time if no change is withheld
+func (u *Updater) WithheldUntil() time.Time {
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+
+	var first time.Time
+	for _, until := range u.withheld {
+		if first.IsZero() || until.Before(first) {
+			first = until
+		}
+	}
+	return first
+}
+
+// Unhealthy returns the records whose last operation failed, with the failure
+func (u *Updater) Unhealthy() map[string]Health {
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+
+	unhealthy := make(map[string]Health, len(u.health))
+	for k, v := range u.health {
+		unhealthy[k] = v
+	}
+	return unhealthy
+}
+
+// SetStore enables persisting cycle statistics to the state file
+func (u *Updater) SetStore(s *store.Store) {
+	u.store = s
+}
+
+// SetAuditLog enables recording every API write in an audit log
+func (u *Updater) SetAuditLog(l *audit.Log) {
+	u.audit = l
+}
+
+// SetNotifier enables sending change, failure and cycle events
+func (u *Updater) SetNotifier(n *Notifier) {
+	u.notifier = n
+}
+
+// notify sends an event if notifications are enabled
+func (u *Updater) notify(event Event) {
+	if u.notifier != nil {
+		u.notifier.Notify(event)
 	}
 }
 
 // UpdateAll checks and updates all configured DNS records
 func (u *Updater) UpdateAll(ctx context.Context) error {
+	return u.updateAll(ctx, false)
+}
+
+// ForceUpdateAll writes all configured DNS records, even those that already
+// match the detected IP
+func (u *Updater) ForceUpdateAll(ctx context.Context) error {
+	return u.updateAll(ctx, true)
+}
+
+// ErrNotPushable is returned by PushIP for names that are not configured as
+// push records of the address's type
+var ErrNotPushable = errors.New("no push record for this name and address type")
+
+// PushIP sets a push record to an address reported by a client instead of a
+// detected one. source names the client in the audit log. It reports whether
+// the record was created or changed.
+func (u *Updater) PushIP(ctx context.Context, source, name, ip string) (bool, error) {
+	normalized, err := ipdetect.NormalizeIP(ip)
+	if err != nil {
+		return false, err
+	}
+	recordType := "A"
+	if netip.MustParseAddr(normalized).Is6() {
+		recordType = "AAAA"
+	}
+
+	for _, record := range u.cfg.Records {
+		if !record.Push || cloudflare.NormalizeName(record.Name) != cloudflare.NormalizeName(name) || !slices.Contains(record.Types, recordType) {
+			continue
+		}
+
+		// Pushes for the same record may arrive concurrently
+		u.pushMu.Lock()
+		defer u.pushMu.Unlock()
+
+		outcome, err := u.writeRecord(ctx, record, recordType, normalized, false, source)
+		u.setHealth(record.Name, recordType, err)
+		if errors.Is(err, errDrift) {
+			return false, nil
+		}
+		if err != nil {
+			u.notify(Event{Event: config.EventFailure, Record: cloudflare.DisplayName(record.Name), RecordType: recordType, NewIP: normalized, Error: err.Error(), Source: source})
+			return false, fmt.Errorf("failed to update %s (%s): %w", cloudflare.DisplayName(record.Name), recordType, err)
+		}
+		u.updateFollowers(ctx, record, recordType, normalized, source)
+		return outcome != outcomeUnchanged, nil
+	}
+	return false, fmt.Errorf("%w: %s (%s)", ErrNotPushable, name, recordType)
+}
+
+// Outcomes of a single record update
+const (
+	outcomeUnchanged = "unchanged"
+	outcomeUpdated   = "updated"
+	outcomeCreated   = "created"
+	outcomeDrifted   = "drifted"  // differs, but not written in a dry run
+	outcomeWithheld  = "withheld" // held back by a maintenance window, freeze or skip_on_cgnat
+)
+
+// Summary counts the outcomes of an update cycle
+type Summary struct {
+	Created   int
+	Updated   int
+	Unchanged int
+	Drifted   int // differ, but were not written in observe mode or a dry run
+	Withheld  int // differ, but are held back by a maintenance window or freeze
+	Failed    int
+	Duration  time.Duration
+}
+
+// Total returns the number of record updates in the cycle
+func (s Summary) Total() int {
+	return s.Created + s.Updated + s.Unchanged + s.Drifted + s.Withheld + s.Failed
+}
+
+// String formats the summary as a single log line
+func (s Summary) String() string {
+	counts := fmt.Sprintf("%d created, %d updated, %d unchanged", s.Created, s.Updated, s.Unchanged)
+	if s.Drifted > 0 {
+		counts += fmt.Sprintf(", %d drifted", s.Drifted)
+	}
+	if s.Withheld > 0 {
+		counts += fmt.Sprintf(", %d withheld", s.Withheld)
+	}
+	return fmt.Sprintf("%s, %d failed in %s", counts, s.Failed, s.Duration.Round(time.Millisecond))
+}
+
+// SetProgress registers a callback invoked after each record of a cycle
+// finishes, for progress indicators on large configurations
+func (u *Updater) SetProgress(fn func(done, total int)) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.progress = fn
+}
+
+// LastSummary returns the outcome counts of the most recent cycle
+func (u *Updater) LastSummary() Summary {
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+	return u.lastSummary
+}
+
+// ForceUpdateRecords re-detects the IP and writes only the named records, even
+// if they appear to match, e.g. after one was changed by hand in the dashboard
+func (u *Updater) ForceUpdateRecords(ctx context.Context, names []string) error {
+	only := make(map[string]bool)
+	for _, name := range names {
+		only[cloudflare.NormalizeName(name)] = true
+	}
+
+	configured := make(map[string]bool)
+	for _, record := range u.cfg.Records {
+		// Pushed records have no detected address to force
+		configured[cloudflare.NormalizeName(record.Name)] = !record.Push
+	}
+	for _, name := range names {
+		pushed, ok := configured[cloudflare.NormalizeName(name)]
+		if !ok {
+			return fmt.Errorf("record %s is not configured", name)
+		}
+		if !pushed {
+			return fmt.Errorf("record %s only receives pushed addresses", name)
+		}
+	}
+
+	return u.updateRecords(ctx, true, only)
+}
+
+// updateAll runs one update cycle over all records
+func (u *Updater) updateAll(ctx context.Context, force bool) error {
+	return u.updateRecords(ctx, force, nil)
+}
+
+// updateRecords runs one update cycle over the records whose normalized names
+// are in only, or over all records if only is nil
+func (u *Updater) updateRecords(ctx context.Context, force bool, only map[string]bool) error {
+	start := time.Now()
 	var wg sync.WaitGroup
 	errChan := make(chan error, len(u.cfg.Records)*2) // max 2 types per record
 
+	var records []config.DNSRecord
+	total := 0
 	for _, record := range u.cfg.Records {
-		for _, recordType := range record.Types {
-			wg.Add(1)
-			go func(rec config.DNSRecord, recType string) {
-				defer wg.Done()
-				if err := u.updateRecord(ctx, rec, recType); err != nil {
-					errChan <- fmt.Errorf("failed to update %s (%s): %w", rec.Name, recType, err)
-				}
-			}(record, recordType)
+		if record.Push || (only != nil && !only[cloudflare.NormalizeName(record.Name)]) {
+			continue
 		}
+		records = append(records, record)
+		total += len(record.Types)
 	}
 
-	wg.Wait()
+	u.mu.RLock()
+	progress := u.progress
+	u.mu.RUnlock()
+
+	var summaryMu sync.Mutex
+	var summary Summary
+	ips := newCycleDetection(u.detector, u.confirm)
+
+	// report records the result of updating one record type
+	report := func(rec config.DNSRecord, recType, outcome string, err error) {
+		u.setHealth(rec.Name, recType, err)
+		drifted := errors.Is(err, errDrift) || outcome == outcomeDrifted
+		if err != nil && !drifted {
+			errChan <- fmt.Errorf("failed to update %s (%s): %w", cloudflare.DisplayName(rec.Name), recType, err)
+			u.notify(Event{Event: config.EventFailure, Record: cloudflare.DisplayName(rec.Name), RecordType: recType, Error: err.Error(), Source: "update"})
+		}
+
+		summaryMu.Lock()
+		switch {
+		case drifted:
+			summary.Drifted++
+		case err != nil:
+			summary.Failed++
+		case outcome == outcomeWithheld:
+			summary.Withheld++
+		case outcome == outcomeCreated:
+			summary.Created++
+		case outcome == outcomeUpdated:
+			summary.Updated++
+		default:
+			summary.Unchanged++
+		}
+		done := summary.Total()
+		if progress != nil {
+			progress(done, total)
+		}
+		summaryMu.Unlock()
+	}
+
+	// The canary is updated and verified first; a failure holds back the
+	// other records of its types
+	held := make(map[string]canaryHold)
+	if canary, ok := u.cfg.CanaryRecord(); ok {
+		for i, record := range records {
+			if record.ZoneID == canary.ZoneID && cloudflare.NormalizeName(record.Name) == cloudflare.NormalizeName(canary.Name) {
+				held = u.runCanary(ctx, ips, record, force, report)
+				records = slices.Delete(records, i, i+1)
+				break
+			}
+		}
+	}
+
+	// Records are updated concurrently, one rollout stage after another
+	stages := u.rolloutStages(records)
+	for i, stage := range stages {
+		var stageMu sync.Mutex
+		var writes []written
+		failed := false
+
+		for _, record := range stage {
+			for _, recordType := range record.Types {
+				if hold, ok := held[recordType]; ok {
+					report(record, recordType, hold.outcome, hold.err)
+					continue
+				}
+
+				wg.Add(1)
+				go func(rec config.DNSRecord, recType string) {
+					defer wg.Done()
+					outcome, err := u.updateRecord(ctx, ips, rec, recType, force)
+					report(rec, recType, outcome, err)
+
+					stageMu.Lock()
+					defer stageMu.Unlock()
+					if err != nil && !errors.Is(err, errDrift) {
+						failed = true
+					} else if outcome == outcomeCreated || outcome == outcomeUpdated {
+						writes = append(writes, written{rec, recType})
+					}
+				}(record, recordType)
+			}
+		}
+		wg.Wait()
+
+		if i == len(stages)-1 {
+			break
+		}
+		if err := u.advanceRollout(ctx, ips, stage, writes, failed); err != nil {
+			for _, rest := range stages[i+1:] {
+				for _, record := range rest {
+					for _, recordType := range record.Types {
+						report(record, recordType, "", err)
+					}
+				}
+			}
+			break
+		}
+	}
 	close(errChan)
 
 	// Collect all errors
@@ -86,6 +537,15 @@ func (u *Updater) UpdateAll(ctx context.Context) error {
 		log.Printf("ERROR: %v", err)
 	}
 
+	summary.Duration = time.Since(start)
+	u.mu.Lock()
+	u.lastSummary = summary
+	u.mu.Unlock()
+	log.Printf("Cycle complete: %s", summary)
+	u.notify(Event{Event: config.EventCycle, Summary: summary.String()})
+
+	u.recordCycle(summary.Duration, summary.Created+summary.Updated, len(errors), u.checkCycleTime(summary.Duration))
+
 	if len(errors) > 0 {
 		return fmt.Errorf("encountered %d error(s) during update", len(errors))
 	}
@@ -93,72 +553,399 @@ func (u *Updater) UpdateAll(ctx context.Context) error {
 	return nil
 }
 
-// updateRecord updates a single DNS record if the IP has changed
-func (u *Updater) updateRecord(ctx context.Context, record config.DNSRecord, recordType string) error {
-	// Get current IP
-	var currentIP string
-	var err error
+// checkCycleTime warns when a cycle exceeded the latency budget or the check
+// interval itself, and reports whether it counts as slow. Cycles longer than
+// the interval run back to back and delay every later check.
+func (u *Updater) checkCycleTime(duration time.Duration) bool {
+	if interval := u.cfg.EffectiveCheckInterval(); duration > interval {
+		log.Printf("Warning: update cycle took %s, longer than the check interval %s; checks are running back to back", duration.Round(time.Millisecond), interval)
+		return true
+	}
+	if budget := u.cfg.GetCycleBudget(); budget > 0 && duration > budget {
+		log.Printf("Warning: update cycle took %s, over the cycle_budget of %s", duration.Round(time.Millisecond), budget)
+		return true
+	}
+	return false
+}
 
-	if recordType == "A" {
-		currentIP, err = u.detector.GetIPv4(ctx)
-	} else if recordType == "AAAA" {
-		currentIP, err = u.detector.GetIPv6(ctx)
-	} else {
-		return fmt.Errorf("invalid record type: %s", recordType)
+// recordCycle adds a finished cycle to the persisted statistics
+func (u *Updater) recordCycle(duration time.Duration, changes, errors int, slow bool) {
+	if u.store == nil {
+		return
 	}
 
+	err := u.store.Update(func(d *store.Data) {
+		d.Stats.Session.Add(duration, changes, errors, slow)
+		d.Stats.Total.Add(duration, changes, errors, slow)
+	})
+	if err != nil {
+		log.Printf("Warning: failed to save statistics: %v", err)
+	}
+}
+
+// updateRecord updates a single DNS record if the IP has changed, or
+// unconditionally if force is set. The address comes from the cycle's shared
+// detection. It returns the outcome of the update.
+func (u *Updater) updateRecord(ctx context.Context, ips *cycleDetection, record config.DNSRecord, recordType string, force bool) (string, error) {
+	content, err := u.desiredContent(ctx, ips, record, recordType)
 	if err != nil {
-		return fmt.Errorf("failed to detect IP: %w", err)
+		return "", err
+	}
+
+	// The internet can't reach a carrier-grade NAT address, so publishing it
+	// would only replace a working address with a useless one
+	if u.skipsCGNAT(recordType, content) {
+		log.Printf("Skipping %s (A): %s is behind carrier-grade NAT (skip_on_cgnat)", cloudflare.DisplayName(record.Name), content)
+		return outcomeWithheld, nil
+	}
+
+	return u.writeRecord(ctx, record, recordType, content, force, "update")
+}
+
+// skipsCGNAT reports whether skip_on_cgnat leaves a record of recordType
+// alone rather than setting it to content
+func (u *Updater) skipsCGNAT(recordType, content string) bool {
+	return recordType == "A" && u.cfg.IPDetection.SkipOnCGNAT && ipdetect.Classify(content) == ipdetect.AddressCGNAT
+}
+
+// desiredContent returns the content a record should have: the detected
+// address of its type, its content template or SPF policy filled in with the
+// detected addresses it references, or the address of the record it follows
+func (u *Updater) desiredContent(ctx context.Context, ips *cycleDetection, record config.DNSRecord, recordType string) (string, error) {
+	if record.Follow != "" {
+		return u.followedContent(ctx, ips, record, recordType)
+	}
+
+	template := record.Template()
+	if template == "" {
+		if recordType != "A" && recordType != "AAAA" {
+			return "", fmt.Errorf("invalid record type: %s", recordType)
+		}
+		ip, err := ips.get(ctx, recordType == "AAAA")
+		if err != nil {
+			return "", fmt.Errorf("%w: %w", errDetection, err)
+		}
+		return ip, nil
+	}
+
+	content := template
+	for _, placeholder := range []string{config.PlaceholderIPv4, config.PlaceholderIPv6} {
+		if !strings.Contains(content, placeholder) {
+			continue
+		}
+		ip, err := ips.get(ctx, placeholder == config.PlaceholderIPv6)
+		if err != nil {
+			return "", fmt.Errorf("%w: %w", errDetection, err)
+		}
+		content = strings.ReplaceAll(content, placeholder, ip)
+	}
+	return content, nil
+}
+
+// writeRecord sets a record to the given address if Cloudflare has a
+// different one, or unconditionally if force is set. Writes are logged to the
+// audit log under source. It returns the outcome of the update.
+func (u *Updater) writeRecord(ctx context.Context, record config.DNSRecord, recordType, currentIP string, force bool, source string) (string, error) {
+	label := recordLabel(record.Name, recordType)
+	u.setWithheld(label, time.Time{})
+
+	// Compare against the cached remote record, fetching it if it isn't known.
+	// Lookup errors such as a missing permission are not the same as a missing
+	// record and must not lead to a create attempt.
+	remote := u.state.Get(record.ZoneID, record.Name, recordType)
+	if remote == nil {
+		existing, err := u.provider.GetDNSRecord(ctx, record.ZoneID, record.Name, recordType, recordKind(record, recordType))
+		if err != nil && !errors.Is(err, cloudflare.ErrRecordNotFound) {
+			return "", fmt.Errorf("failed to look up record: %w", err)
+		}
+		if err == nil {
+			remote = existing
+			u.state.Set(record.ZoneID, record.Name, recordType, existing)
+		}
+	}
+	matches := recordMatches(remote, currentIP, record)
+	if matches && (!force || u.cfg.Observing()) {
+		log.Printf("No change for %s (%s): %s", cloudflare.DisplayName(record.Name), recordType, currentIP)
+		return outcomeUnchanged, u.writeHint(ctx, record, currentIP, "", source)
+	}
+
+	if remote == nil {
+		if wait := u.creates.wait(label, time.Now()); wait > 0 {
+			return "", fmt.Errorf("creation is backed off after repeated failures, next attempt in %s", wait.Round(time.Second))
+		}
+	}
+
+	lastKnownIP := ""
+	if remote != nil {
+		lastKnownIP = remote.Content
+	}
+
+	// Observe mode reports the difference without touching the record
+	if u.cfg.Observing() {
+		detail := driftDetail(remote, currentIP)
+		log.Printf("Drift on %s (%s): %s; not updating in observe mode", cloudflare.DisplayName(record.Name), recordType, detail)
+		return "", fmt.Errorf("%w: %s", errDrift, detail)
+	}
+
+	// A freeze holds back every change while the freeze file exists. Pushed
+	// addresses fail instead, so that clients send them again later.
+	if u.Frozen() {
+		if record.Push {
+			return "", fmt.Errorf("%w while %s exists", errFrozen, u.cfg.FreezeFile)
+		}
+		log.Printf("Withholding change of %s (%s) to %s while %s exists", cloudflare.DisplayName(record.Name), recordType, currentIP, u.cfg.FreezeFile)
+		return outcomeWithheld, nil
+	}
+
+	// Maintenance windows hold the change back until they end. Pushed
+	// addresses are not detected again later, so they are never held back.
+	if !record.Push {
+		if until, window := u.cfg.MaintenanceUntil(record, time.Now()); !until.IsZero() {
+			log.Printf("Withholding change of %s (%s) to %s during maintenance window %s until %s", cloudflare.DisplayName(record.Name), recordType, currentIP, window, until.Format("Mon 15:04 MST"))
+			u.setWithheld(label, until)
+			return outcomeWithheld, nil
+		}
+	}
+	if u.cfg.DryRun {
+		operation := plannedOperation(record, recordType, remote, currentIP)
+		if remote == nil {
+			log.Printf("Dry run: would create %s %s %s: %s", cloudflare.DisplayName(record.Name), recordType, currentIP, operation)
+		} else {
+			log.Printf("Dry run: would update %s %s %s -> %s: %s", cloudflare.DisplayName(record.Name), recordType, lastKnownIP, currentIP, operation)
+		}
+		return outcomeDrifted, nil
 	}
 
-	// Check if IP has changed
-	lastKnownIP := u.state.Get(record.ZoneID, record.Name, recordType)
-	if currentIP == lastKnownIP && lastKnownIP != "" {
-		log.Printf("No change for %s (%s): %s", record.Name, recordType, currentIP)
-		return nil
+	// Preserved settings are taken from the record as it is now, not as it
+	// was cached, so that changes made in the dashboard since are kept
+	if remote != nil && len(record.Preserve) > 0 {
+		current, err := u.provider.GetDNSRecord(ctx, record.ZoneID, record.Name, recordType, recordKind(record, recordType))
+		switch {
+		case errors.Is(err, cloudflare.ErrRecordNotFound):
+			remote = nil
+		case err != nil:
+			return "", fmt.Errorf("failed to read the settings to preserve: %w", err)
+		default:
+			remote = current
+		}
 	}
+	ttl, proxied := recordSettings(record, remote)
 
-	// IP has changed or this is the first run, update DNS record
-	log.Printf("Updating %s (%s): %s -> %s", record.Name, recordType, lastKnownIP, currentIP)
+	// IP or settings differ from Cloudflare, update DNS record
+	log.Printf("Updating %s (%s): %s -> %s", cloudflare.DisplayName(record.Name), recordType, lastKnownIP, currentIP)
 
-	err = u.cfClient.UpsertDNSRecord(
+	change, err := u.provider.UpsertDNSRecord(
 		ctx,
 		record.ZoneID,
 		record.Name,
 		recordType,
 		currentIP,
-		record.TTL,
-		record.Proxied,
+		ttl,
+		proxied,
+		record.Comment,
 	)
 	if err != nil {
-		return fmt.Errorf("failed to update Cloudflare DNS: %w", err)
+		if remote == nil {
+			u.creates.failed(label, err, time.Now())
+		}
+		return "", fmt.Errorf("failed to update Cloudflare DNS: %w", err)
+	}
+	if remote == nil {
+		u.creates.succeeded(label)
+	}
+
+	log.Println(change)
+	if u.audit != nil {
+		if err := u.audit.Record(source, change); err != nil {
+			log.Printf("ERROR: failed to write audit log: %v", err)
+		}
 	}
 
 	// Update state
-	u.state.Set(record.ZoneID, record.Name, recordType, currentIP)
-	log.Printf("Successfully updated %s (%s) to %s", record.Name, recordType, currentIP)
+	u.state.Set(record.ZoneID, record.Name, recordType, change.After)
+	log.Printf("Successfully updated %s (%s) to %s", cloudflare.DisplayName(record.Name), recordType, currentIP)
+	u.notify(Event{Event: config.EventChange, Record: cloudflare.DisplayName(record.Name), RecordType: recordType, OldIP: lastKnownIP, NewIP: currentIP, Source: source})
 
-	return nil
+	if change.Before == nil {
+		return outcomeCreated, u.writeHint(ctx, record, currentIP, "", source)
+	}
+	return outcomeUpdated, u.writeHint(ctx, record, currentIP, change.Before.Content, source)
+}
+
+// driftDetail describes how a remote record differs from the desired one
+func driftDetail(remote *cloudflare.DNSRecordInfo, content string) string {
+	if remote == nil {
+		return fmt.Sprintf("record does not exist, detected %s", content)
+	}
+	if remoteContent(remote) == content {
+		return fmt.Sprintf("TTL, proxy setting or comment differs (ttl %d, proxied %t, comment %q)", remote.TTL, remote.Proxied, remote.Comment)
+	}
+	return fmt.Sprintf("Cloudflare has %s, detected %s", remote.Content, content)
+}
+
+// remoteContent returns a remote record's content in the form it is
+// compared in: addresses normalized, TXT content without quotes
+func remoteContent(remote *cloudflare.DNSRecordInfo) string {
+	if remote.Type == "TXT" {
+		return strings.Trim(remote.Content, `"`)
+	}
+	if normalized, err := ipdetect.NormalizeIP(remote.Content); err == nil {
+		return normalized
+	}
+	return remote.Content
 }
 
-// InitializeState loads the current DNS records from Cloudflare to populate initial state
+// recordMatches reports whether the remote record already has the desired
+// content and the record's settings, in which case no write is needed
+func recordMatches(remote *cloudflare.DNSRecordInfo, content string, record config.DNSRecord) bool {
+	if remote == nil {
+		return false
+	}
+
+	ttl, proxied := recordSettings(record, remote)
+	if remoteContent(remote) != content || remote.Proxied != proxied {
+		return false
+	}
+	if record.Comment != "" && remote.Comment != record.Comment {
+		return false
+	}
+
+	// Proxied records always use automatic TTL
+	return proxied || remote.TTL == ttl
+}
+
+// recordSettings returns the TTL and proxy setting to write: the record's,
+// except for those it preserves from the existing remote record
+func recordSettings(record config.DNSRecord, remote *cloudflare.DNSRecordInfo) (int, bool) {
+	ttl, proxied := record.GetTTL(), record.Proxied
+	if remote == nil {
+		return ttl, proxied
+	}
+	if record.Preserves(config.PreserveTTL) {
+		ttl = remote.TTL
+	}
+	if record.Preserves(config.PreserveProxied) {
+		proxied = remote.Proxied
+	}
+	return ttl, proxied
+}
+
+// initWorkers bounds how many zones are listed concurrently during initialization
+const initWorkers = 4
+
+// InitializeState loads the current DNS records from Cloudflare to populate initial state.
+// Each zone is fetched with a single list call, several zones at a time.
 func (u *Updater) InitializeState(ctx context.Context) error {
 	log.Println("Initializing state from Cloudflare...")
+	if err := u.loadState(ctx, false); err != nil {
+		return err
+	}
+	log.Println("State initialization complete")
+	return nil
+}
+
+// Reconcile re-reads the managed records from Cloudflare in place of the
+// cached state and runs an update cycle, so records edited or deleted outside
+// the daemon, e.g. in the dashboard, are corrected
+func (u *Updater) Reconcile(ctx context.Context) error {
+	log.Println("Reconciling records with Cloudflare...")
+	if err := u.loadState(ctx, true); err != nil {
+		// Records of the zones that could be read are still corrected
+		log.Printf("Warning: reconciliation is incomplete: %v", err)
+	}
+	return u.UpdateAll(ctx)
+}
 
+// loadState lists every configured zone and caches the records managed in it.
+// When reconciling, differences from the cached state are logged.
+func (u *Updater) loadState(ctx context.Context, reconcile bool) error {
+	// Group the configured records by zone
+	byZone := make(map[string][]config.DNSRecord)
 	for _, record := range u.cfg.Records {
+		byZone[record.ZoneID] = append(byZone[record.ZoneID], record)
+	}
+
+	zoneIDs := make(chan string)
+	var failed int64
+	var wg sync.WaitGroup
+	for i := 0; i < initWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for zoneID := range zoneIDs {
+				if err := u.initializeZone(ctx, zoneID, byZone[zoneID], reconcile); err != nil {
+					log.Printf("Failed to initialize zone %s: %v", zoneID, err)
+					atomic.AddInt64(&failed, 1)
+				}
+			}
+		}()
+	}
+
+	for zoneID := range byZone {
+		zoneIDs <- zoneID
+	}
+	close(zoneIDs)
+	wg.Wait()
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d zone(s) are inaccessible", failed, len(byZone))
+	}
+	return nil
+}
+
+// initializeZone lists a zone once and caches the records managed in it.
+// If the zone can't be listed, all of its records are marked unhealthy.
+func (u *Updater) initializeZone(ctx context.Context, zoneID string, records []config.DNSRecord, reconcile bool) error {
+	existing, err := u.provider.ListDNSRecords(ctx, zoneID)
+	if err != nil {
+		for _, record := range records {
+			for _, recordType := range record.Types {
+				u.setHealth(record.Name, recordType, err)
+			}
+		}
+		return err
+	}
+
+	index := indexRecords(zoneID, existing)
+	for _, record := range records {
 		for _, recordType := range record.Types {
-			existing, err := u.cfClient.GetDNSRecord(ctx, record.ZoneID, record.Name, recordType)
-			if err != nil {
+			rec := lookupIndex(index, record, recordType)
+			if reconcile {
+				u.reconcileRecord(record, recordType, rec)
+				continue
+			}
+			if rec == nil {
 				// Record doesn't exist yet, skip
-				log.Printf("Record %s (%s) not found in Cloudflare, will be created on first update", record.Name, recordType)
+				log.Printf("Record %s (%s) not found in Cloudflare, will be created on first update", cloudflare.DisplayName(record.Name), recordType)
 				continue
 			}
 
-			u.state.Set(record.ZoneID, record.Name, recordType, existing.Content)
-			log.Printf("Loaded existing record: %s (%s) = %s", record.Name, recordType, existing.Content)
+			u.state.Set(zoneID, record.Name, recordType, rec)
+			log.Printf("Loaded existing record: %s (%s) = %s", cloudflare.DisplayName(record.Name), recordType, rec.Content)
 		}
 	}
 
-	log.Println("State initialization complete")
 	return nil
 }
+
+// reconcileRecord replaces the cached remote record with the one just listed,
+// logging changes made outside the daemon
+func (u *Updater) reconcileRecord(record config.DNSRecord, recordType string, rec *cloudflare.DNSRecordInfo) {
+	name := cloudflare.DisplayName(record.Name)
+	cached := u.state.Get(record.ZoneID, record.Name, recordType)
+	switch {
+	case rec == nil:
+		if cached != nil {
+			log.Printf("Drift on %s (%s): record was deleted outside cf-ddns", name, recordType)
+		}
+		u.state.Delete(record.ZoneID, record.Name, recordType)
+		return
+	case cached == nil:
+		// Not known before, e.g. because its zone couldn't be listed
+	case remoteContent(cached) != remoteContent(rec):
+		log.Printf("Drift on %s (%s): changed outside cf-ddns from %s to %s", name, recordType, cached.Content, rec.Content)
+	case cached.TTL != rec.TTL || cached.Proxied != rec.Proxied || cached.Comment != rec.Comment:
+		log.Printf("Drift on %s (%s): TTL, proxy setting or comment changed outside cf-ddns (ttl %d, proxied %t, comment %q)", name, recordType, rec.TTL, rec.Proxied, rec.Comment)
+	}
+	u.state.Set(record.ZoneID, record.Name, recordType, rec)
+}
@@ -0,0 +1,91 @@
+package updater_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"testing"
+
+	"github.com/MrLonely14/cf-ddns/config"
+	"github.com/MrLonely14/cf-ddns/fake"
+	"github.com/MrLonely14/cf-ddns/ipdetect"
+	"github.com/MrLonely14/cf-ddns/updater"
+)
+
+// benchRecords is the number of records each benchmark cycle updates
+const benchRecords = 100
+
+// fixedSource is a stub detection source that always reports the same addresses
+type fixedSource struct{}
+
+func (fixedSource) Name() string { return "fixed" }
+
+func (fixedSource) GetIP(ctx context.Context, isIPv6 bool) (string, error) {
+	if isIPv6 {
+		return "2606:4700::1", nil
+	}
+	return "198.18.0.1", nil
+}
+
+// benchConfig returns a configuration with n dual-stack records in one zone
+func benchConfig(n int) *config.Config {
+	cfg := &config.Config{CheckInterval: "5m"}
+	cfg.Cloudflare.APIToken = "bench"
+	for i := range n {
+		cfg.Records = append(cfg.Records, config.DNSRecord{
+			ZoneID: "zone",
+			Name:   fmt.Sprintf("host%d.example.com", i),
+			Types:  []string{"A", "AAAA"},
+			TTL:    300,
+		})
+	}
+	return cfg
+}
+
+// quietLog discards the per-record log lines for the rest of the benchmark
+func quietLog(b *testing.B) {
+	out := log.Writer()
+	log.SetOutput(io.Discard)
+	b.Cleanup(func() { log.SetOutput(out) })
+}
+
+// BenchmarkUpdateAll measures a cycle in which the address is unchanged and
+// every record already matches, the common case of a running daemon
+func BenchmarkUpdateAll(b *testing.B) {
+	quietLog(b)
+
+	upd := updater.NewUpdater(benchConfig(benchRecords), fake.NewProvider(), ipdetect.NewDetectorFromSource(fixedSource{}))
+	ctx := context.Background()
+	if err := upd.UpdateAll(ctx); err != nil {
+		b.Fatalf("initial update failed: %v", err)
+	}
+
+	b.ReportAllocs()
+	for b.Loop() {
+		if err := upd.UpdateAll(ctx); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkUpdateAllChanged measures a cycle in which the address changed
+// and every record is written
+func BenchmarkUpdateAllChanged(b *testing.B) {
+	quietLog(b)
+
+	source := fake.NewChurnSource()
+	upd := updater.NewUpdater(benchConfig(benchRecords), fake.NewProvider(), ipdetect.NewDetectorFromSource(source))
+	ctx := context.Background()
+	if err := upd.UpdateAll(ctx); err != nil {
+		b.Fatalf("initial update failed: %v", err)
+	}
+
+	b.ReportAllocs()
+	for b.Loop() {
+		source.Change()
+		if err := upd.UpdateAll(ctx); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/MrLonely14/cf-ddns/config"
+	"github.com/MrLonely14/cf-ddns/i18n"
+	"github.com/MrLonely14/cf-ddns/logging"
+	"github.com/MrLonely14/cf-ddns/provider"
+	"github.com/MrLonely14/cf-ddns/sysproxy"
+	"github.com/MrLonely14/cf-ddns/term"
+	"github.com/MrLonely14/cf-ddns/zones"
+)
+
+// onlineCheckTimeout bounds the API requests made by validate -online
+const onlineCheckTimeout = 30 * time.Second
+
+// Severities of validation problems
+const (
+	severityError   = "error"
+	severityWarning = "warning"
+)
+
+// problem is one finding of the validate command
+type problem struct {
+	Severity string `json:"severity"`
+	Check    string `json:"check"` // config, lint or online
+	Message  string `json:"message"`
+}
+
+// validationReport is the result of the validate command
+type validationReport struct {
+	Config   string    `json:"config"`
+	Online   bool      `json:"online"`
+	Valid    bool      `json:"valid"`
+	Problems []problem `json:"problems"`
+}
+
+func validateCommand(args []string) {
+	validateCmd := flag.NewFlagSet("validate", flag.ExitOnError)
+	configPath := validateCmd.String("config", "config.yaml", "Path to configuration file")
+	online := validateCmd.Bool("online", false, "Also verify the API token and zone IDs against the provider")
+	output := validateCmd.String("output", "text", "Report format: text or json")
+	strict := validateCmd.Bool("strict", false, "Treat warnings as errors")
+	validateCmd.Parse(args)
+
+	if *output != "text" && *output != "json" {
+		log.Fatalf("Invalid -output %s (must be text or json)", *output)
+	}
+
+	report := validateConfig(*configPath, *online)
+	for _, p := range report.Problems {
+		if p.Severity == severityError || *strict {
+			report.Valid = false
+		}
+	}
+
+	if *output == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.SetEscapeHTML(false)
+		if err := enc.Encode(report); err != nil {
+			log.Fatalf("Failed to write report: %v", err)
+		}
+	} else {
+		printValidationReport(report)
+	}
+
+	if !report.Valid {
+		os.Exit(1)
+	}
+}
+
+// validateConfig loads and checks a configuration file, and with online the
+// token and zones it refers to, collecting every problem found
+func validateConfig(configPath string, online bool) validationReport {
+	report := validationReport{Config: configPath, Online: online, Valid: true, Problems: []problem{}}
+	add := func(severity, check, message string) {
+		report.Problems = append(report.Problems, problem{Severity: severity, Check: check, Message: logging.Redact(message)})
+	}
+
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		add(severityError, "config", err.Error())
+		return report
+	}
+	logging.SetSecrets(cfg.Secrets())
+	i18n.SetLanguage(cfg.Language)
+	if err := sysproxy.Enable(cfg.SystemProxy); err != nil {
+		add(severityWarning, "config", err.Error())
+	}
+
+	for _, warning := range cfg.Lint(configPath) {
+		add(severityWarning, "lint", warning)
+	}
+	if !online {
+		return report
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), onlineCheckTimeout)
+	defer cancel()
+
+	dnsProvider, err := provider.New(cfg)
+	if err != nil {
+		add(severityError, "online", fmt.Sprintf("failed to create DNS provider: %v", err))
+		return report
+	}
+	if err := resolveZoneNames(ctx, cfg, dnsProvider); err != nil {
+		add(severityError, "online", fmt.Sprintf("failed to resolve zones: %v", err))
+		return report
+	}
+
+	if checker, ok := dnsProvider.(provider.AccessChecker); ok {
+		var zoneIDs []string
+		seen := make(map[string]bool)
+		for _, record := range cfg.Records {
+			if !seen[record.ZoneID] {
+				seen[record.ZoneID] = true
+				zoneIDs = append(zoneIDs, record.ZoneID)
+			}
+		}
+		// CheckAccess joins its findings, which are reported one by one
+		err := checker.CheckAccess(ctx, zoneIDs, !cfg.Observing() && !cfg.DryRun)
+		if joined, ok := err.(interface{ Unwrap() []error }); ok {
+			for _, e := range joined.Unwrap() {
+				add(severityError, "online", e.Error())
+			}
+		} else if err != nil {
+			add(severityError, "online", err.Error())
+		}
+	}
+
+	if inspector, ok := dnsProvider.(provider.ZoneInspector); ok {
+		zoneCache := zones.NewCache(inspector, nil, zones.DefaultTTL)
+		if err := checkRecordZones(ctx, cfg, zoneCache); err != nil {
+			add(severityError, "online", err.Error())
+		}
+	}
+	return report
+}
+
+// printValidationReport prints the problems found, errors first
+func printValidationReport(report validationReport) {
+	fmt.Println(term.Bold(i18n.T("Validating %s", report.Config)))
+
+	errorCount, warningCount := 0, 0
+	for _, severity := range []string{severityError, severityWarning} {
+		for _, p := range report.Problems {
+			if p.Severity != severity {
+				continue
+			}
+			if severity == severityError {
+				errorCount++
+				fmt.Println(term.Fail(fmt.Sprintf("[%s] %s", p.Check, p.Message)))
+			} else {
+				warningCount++
+				fmt.Println(term.Warn(fmt.Sprintf("[%s] %s", p.Check, p.Message)))
+			}
+		}
+	}
+
+	summary := i18n.T("%d error(s), %d warning(s)", errorCount, warningCount)
+	if !report.Online {
+		summary += "; " + i18n.T("token and zones not checked (use -online)")
+	}
+	if report.Valid {
+		fmt.Println(term.OK(i18n.T("Configuration is valid: %s", summary)))
+	} else {
+		fmt.Println(term.Fail(i18n.T("Configuration is invalid: %s", summary)))
+	}
+}
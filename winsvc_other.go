@@ -0,0 +1,10 @@
+//go:build !windows
+
+package main
+
+import "log"
+
+// runWindowsService refuses to run; there is no Windows service manager here
+func runWindowsService(configPath string, opts runOptions) {
+	log.Fatalf("-service is only supported on Windows")
+}
@@ -0,0 +1,62 @@
+//go:build windows
+
+package main
+
+import (
+	"log"
+	"sync"
+
+	"golang.org/x/sys/windows/svc"
+)
+
+// stopWaitHint is how long the service manager is told stopping may take,
+// which covers the final update cycle
+const stopWaitHint = 30000 // milliseconds
+
+// serviceStop is closed when the service manager asks the daemon to stop.
+// It is nil unless the daemon runs as a service.
+var serviceStop <-chan struct{}
+
+// runWindowsService runs the daemon under the Windows service manager, which
+// starts it with the -service flag set by install
+func runWindowsService(configPath string, opts runOptions) {
+	// The name is ignored for a service running in its own process
+	if err := svc.Run("", &windowsService{configPath: configPath, opts: opts}); err != nil {
+		log.Fatalf("Failed to run as a service: %v", err)
+	}
+}
+
+// windowsService runs the daemon and relays stop requests to it
+type windowsService struct {
+	configPath string
+	opts       runOptions
+}
+
+// Execute runs the daemon until it returns after a stop request
+func (s *windowsService) Execute(args []string, requests <-chan svc.ChangeRequest, status chan<- svc.Status) (bool, uint32) {
+	stop := make(chan struct{})
+	var stopOnce sync.Once
+	serviceStop = stop
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		runDaemon(s.configPath, s.opts)
+	}()
+
+	status <- svc.Status{State: svc.Running, Accepts: svc.AcceptStop | svc.AcceptShutdown}
+	for {
+		select {
+		case <-done:
+			return false, 0
+		case req := <-requests:
+			switch req.Cmd {
+			case svc.Interrogate:
+				status <- req.CurrentStatus
+			case svc.Stop, svc.Shutdown:
+				status <- svc.Status{State: svc.StopPending, WaitHint: stopWaitHint}
+				stopOnce.Do(func() { close(stop) })
+			}
+		}
+	}
+}
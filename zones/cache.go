@@ -0,0 +1,96 @@
+package zones
+
+import (
+	"context"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/MrLonely14/cf-ddns/cloudflare"
+	"github.com/MrLonely14/cf-ddns/provider"
+	"github.com/MrLonely14/cf-ddns/store"
+)
+
+// DefaultTTL is how long cached zone metadata is trusted before it is refetched
+const DefaultTTL = 24 * time.Hour
+
+// Cache resolves zone metadata, keeping results in the state file so
+// frequently restarted daemons don't refetch them on every boot
+type Cache struct {
+	client provider.ZoneInspector
+	store  *store.Store // nil keeps the cache in memory only
+	ttl    time.Duration
+	mu     sync.Mutex
+	zones  map[string]store.Zone
+}
+
+// NewCache creates a zone cache backed by the state file, if one is given
+func NewCache(client provider.ZoneInspector, st *store.Store, ttl time.Duration) *Cache {
+	c := &Cache{
+		client: client,
+		store:  st,
+		ttl:    ttl,
+		zones:  make(map[string]store.Zone),
+	}
+	if st != nil {
+		for id, zone := range st.View().Zones {
+			c.zones[id] = zone
+		}
+	}
+	return c
+}
+
+// Zone returns the metadata for a zone ID, from the cache if it is fresh
+func (c *Cache) Zone(ctx context.Context, zoneID string) (store.Zone, error) {
+	c.mu.Lock()
+	zone, ok := c.zones[zoneID]
+	c.mu.Unlock()
+	if ok && time.Since(zone.FetchedAt) < c.ttl {
+		return zone, nil
+	}
+
+	info, err := c.client.GetZone(ctx, zoneID)
+	if err != nil {
+		return store.Zone{}, err
+	}
+
+	zone = store.Zone{
+		ID:        info.ID,
+		Name:      info.Name,
+		Plan:      info.Plan,
+		Status:    info.Status,
+		FetchedAt: time.Now(),
+	}
+	c.put(zone)
+
+	return zone, nil
+}
+
+// put stores a zone in memory and in the state file
+func (c *Cache) put(zone store.Zone) {
+	c.mu.Lock()
+	c.zones[zone.ID] = zone
+	c.mu.Unlock()
+
+	if c.store == nil {
+		return
+	}
+	err := c.store.Update(func(d *store.Data) {
+		if d.Zones == nil {
+			d.Zones = make(map[string]store.Zone)
+		}
+		d.Zones[zone.ID] = zone
+	})
+	if err != nil {
+		log.Printf("Warning: failed to cache zone %s: %v", zone.ID, err)
+	}
+}
+
+// Contains reports whether a record name lies within a zone, i.e. is the zone
+// apex or a name below it
+func Contains(zoneName, recordName string) bool {
+	zone := cloudflare.NormalizeName(zoneName)
+	name := cloudflare.NormalizeName(recordName)
+	return name == zone || strings.HasSuffix(name, "."+zone)
+}